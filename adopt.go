@@ -0,0 +1,81 @@
+package lockfile
+
+import (
+	"bytes"
+	"errors"
+	"os"
+)
+
+// Adopt acquires the lock file at path in place, for migrating a service
+// from another locking scheme to this package without downtime.
+//
+// If the file exists, is not locked, and is empty or holds metadata in
+// this package's format or one of the foreign formats recognized by
+// [Inspect], it is locked without being recreated. Its contents are
+// replaced with metadata in this package's format, encoded as configured
+// by [WithMetadata], and from then on it is managed like any other lock
+// file: it is deleted when it is released. If the file does not exist, it
+// is created as it would be by [Create].
+//
+// A foreign lock file that records the process ID of a live process on
+// this host is treated as held, because many tools signal a held lock by
+// the existence of the file alone. In that case, or if the file is locked,
+// it returns an [*os.PathError] that wraps [os.ErrExist]. If the file has
+// any other contents, it returns an error that wraps [ErrNotEmpty], and
+// the file is left alone.
+//
+// The lock file is adopted at path itself, so options that relocate lock
+// files, such as [WithFallbackDir], are not applied.
+func Adopt(path string, opts ...Option) (*File, error) {
+	c := newConfig(opts)
+	if c.err != nil {
+		return nil, c.err
+	}
+	if path == "" {
+		return nil, ErrEmptyPath
+	}
+
+	file, err := c.adoptLock(path, checkAdoptable)
+	if errors.Is(err, os.ErrNotExist) {
+		return c.create(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c.fencing {
+		if err := c.fence(file); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	// Replace the previous holder's description with ours.
+	file.h.writeMetadata()
+
+	return file, nil
+}
+
+// checkAdoptable returns an error if data, the contents of the lock file
+// at path, prevents the lock file from being adopted.
+func checkAdoptable(path string, data []byte) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if _, err := DecodeMetadata(data, IgnoreUnknownFields); err == nil {
+		return nil
+	}
+
+	_, md, ok := parseForeign(data)
+	if !ok {
+		return &os.PathError{Op: "adopt", Path: path, Err: ErrNotEmpty}
+	}
+
+	hostname, _ := os.Hostname()
+	pid := md.Holder.PID
+	if (md.Holder.Hostname == "" || md.Holder.Hostname == hostname) && pid != os.Getpid() && processAlive(pid) {
+		return &os.PathError{Op: "adopt", Path: path, Err: os.ErrExist}
+	}
+
+	return nil
+}
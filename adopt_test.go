@@ -0,0 +1,79 @@
+package lockfile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestAdopt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.lock")
+
+	// A legacy PID file whose process has exited.
+	if err := os.WriteFile(path, []byte("1073741822\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := lockfile.Adopt(path)
+	if err != nil {
+		t.Fatalf("Adopt failed: %v", err)
+	}
+
+	if _, err := lockfile.Create(path); !lockfile.IsTemporary(err) {
+		t.Fatalf("Create returned %v for an adopted lock file", err)
+	}
+
+	// Held lock files cannot be read on Windows.
+	if runtime.GOOS != "windows" {
+		info, err := lockfile.Inspect(path)
+		if err != nil {
+			t.Fatalf("Inspect failed: %v", err)
+		}
+		if info.Format != "json" || info.Metadata.Holder.PID != os.Getpid() {
+			t.Fatalf("the metadata was not converted: %+v", info)
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("the adopted lock file was not deleted: %v", err)
+	}
+
+	// Without a lock file, Adopt creates one.
+	file, err = lockfile.Adopt(path)
+	if err != nil {
+		t.Fatalf("Adopt failed without a lock file: %v", err)
+	}
+	file.Close()
+}
+
+func TestAdoptRefused(t *testing.T) {
+	dir := t.TempDir()
+
+	// The parent of the test process is known to be alive.
+	live := filepath.Join(dir, "live.lock")
+	if err := os.WriteFile(live, []byte(strconv.Itoa(os.Getppid())), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockfile.Adopt(live); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("Adopt returned %v for a live holder", err)
+	}
+
+	data := filepath.Join(dir, "data.lock")
+	if err := os.WriteFile(data, []byte("important data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockfile.Adopt(data); !errors.Is(err, lockfile.ErrNotEmpty) {
+		t.Fatalf("Adopt returned %v for a file that is not a lock file", err)
+	}
+	if contents, err := os.ReadFile(data); err != nil || string(contents) != "important data" {
+		t.Fatalf("the file was disturbed: %q, %v", contents, err)
+	}
+}
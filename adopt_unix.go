@@ -0,0 +1,63 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// adoptLock locks the existing lock file at path in place, if check
+// accepts its contents. It returns an error that wraps [os.ErrNotExist]
+// if there is no lock file to adopt.
+func (c *config) adoptLock(path string, check func(path string, data []byte) error) (*File, error) {
+	sys := c.system()
+
+	// The contents are replaced once the lock file is adopted, which needs
+	// write access. A lock file that we cannot write to is adopted anyway.
+	fd, err := sys.open(path, syscall.O_RDWR|int(c.openFlags), 0)
+	if errors.Is(err, syscall.EACCES) {
+		fd, err = sys.open(path, syscall.O_RDONLY|int(c.openFlags), 0)
+	}
+	if err != nil {
+		return nil, pathError("open", path, err)
+	}
+
+	if err := sys.flock(path, fd, syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		sys.closeFd(path, fd)
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, &os.PathError{Op: "flock", Path: path, Err: os.ErrExist}
+		}
+		return nil, pathError("flock", path, err)
+	}
+
+	// The lock file may have been deleted by its holder before we locked
+	// it, in which case it is created as usual.
+	stat, err := sys.fstat(path, fd)
+	if err != nil {
+		sys.closeFd(path, fd)
+		return nil, pathError("stat", path, err)
+	}
+	if stat.Nlink == 0 {
+		sys.closeFd(path, fd)
+		return nil, &os.PathError{Op: "adopt", Path: path, Err: os.ErrNotExist}
+	}
+	if stat.Size > maxMetadataSize {
+		sys.closeFd(path, fd)
+		return nil, &os.PathError{Op: "adopt", Path: path, Err: ErrNotEmpty}
+	}
+
+	data := make([]byte, stat.Size)
+	n, err := syscall.Pread(fd, data, 0)
+	if err != nil {
+		sys.closeFd(path, fd)
+		return nil, pathError("read", path, err)
+	}
+	if err := check(path, data[:n]); err != nil {
+		sys.closeFd(path, fd)
+		return nil, err
+	}
+
+	return newFile(path, c, os.NewFile(uintptr(fd), path), false), nil
+}
@@ -0,0 +1,65 @@
+//go:build windows
+
+package lockfile
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// adoptLock opens the existing lock file at path exclusively, if check
+// accepts its contents. It returns an error that wraps [os.ErrNotExist]
+// if there is no lock file to adopt.
+//
+// The lock file is opened without delete-on-close, so that a file that
+// turns out not to be a lock file is left alone. Once its contents have
+// been checked, it is marked for deletion, which takes effect when it is
+// closed, just like a lock file created by [Create].
+func (c *config) adoptLock(path string, check func(path string, data []byte) error) (*File, error) {
+	const (
+		DELETE                  = 0x00010000
+		ERROR_SHARING_VIOLATION = syscall.Errno(32)
+	)
+
+	sys := c.system()
+	handle, err := sys.open(path, syscall.GENERIC_READ|syscall.GENERIC_WRITE|DELETE, 0, syscall.OPEN_EXISTING, c.openFlags)
+	if err != nil {
+		if err == ERROR_SHARING_VIOLATION {
+			return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrExist}
+		}
+		return nil, pathError("open", path, err)
+	}
+	file := os.NewFile(uintptr(handle), path)
+
+	data, err := io.ReadAll(io.LimitReader(file, maxMetadataSize+1))
+	if err == nil && len(data) > maxMetadataSize {
+		err = &os.PathError{Op: "adopt", Path: path, Err: ErrNotEmpty}
+	}
+	if err == nil {
+		err = check(path, data)
+	}
+	if err == nil {
+		err = pathError("adopt", path, setDeleteDisposition(handle))
+	}
+	if err != nil {
+		sys.closeFile(path, file)
+		return nil, err
+	}
+
+	return newFile(path, c, file, false), nil
+}
+
+// setDeleteDisposition marks the file with the given handle for deletion
+// when it is closed.
+func setDeleteDisposition(handle syscall.Handle) error {
+	const FileDispositionInfo = 4
+
+	info := struct{ DeleteFile bool }{DeleteFile: true}
+	r1, _, e1 := procSetFileInformationByHandle.Call(uintptr(handle), FileDispositionInfo, uintptr(unsafe.Pointer(&info)), unsafe.Sizeof(info))
+	if r1 == 0 {
+		return e1
+	}
+	return nil
+}
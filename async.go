@@ -0,0 +1,116 @@
+package lockfile
+
+import (
+	"context"
+	"sync"
+)
+
+// Pending is a lock file acquisition that is in progress. It is returned
+// by [AcquireAsync].
+type Pending struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+
+	mutex     sync.Mutex
+	cancelled bool
+	collected bool
+	file      *File
+	err       error
+}
+
+// AcquireAsync starts waiting for a lock file at the given path in the
+// background and returns immediately. The returned [Pending] can be used
+// to collect the lock file when it is needed.
+//
+// This allows applications to start waiting on a lock while doing
+// unrelated preparation work, and only block at the point they actually
+// need the lock.
+//
+// The wait ends when the lock file is acquired, a non-temporary error is
+// encountered, the provided context is cancelled or [Pending.Cancel] is
+// called.
+//
+// Options may be provided to customize its behavior. They are passed to
+// [WaitCtx].
+func AcquireAsync(ctx context.Context, path string, opts ...Option) (*Pending, error) {
+	if path == "" {
+		return nil, ErrEmptyPath
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	p := &Pending{
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+
+	go func() {
+		defer close(p.done)
+		defer cancel()
+
+		file, err := WaitCtx(ctx, path, opts...)
+
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+
+		// If Cancel won the race with a successful acquisition, hand the
+		// lock back immediately.
+		if file != nil && p.cancelled {
+			file.Close()
+			file, err = nil, context.Canceled
+		}
+
+		p.file, p.err = file, err
+	}()
+
+	return p, nil
+}
+
+// Done returns a channel that is closed when the acquisition has finished,
+// either successfully or with an error.
+func (p *Pending) Done() <-chan struct{} {
+	return p.done
+}
+
+// Result blocks until the acquisition has finished and returns its result.
+//
+// If successful, the caller is responsible for closing the returned
+// [File]. Multiple calls to Result return the same values.
+//
+// If [Pending.Cancel] was called before the first call to Result, Result
+// never returns a lock file.
+func (p *Pending) Result() (*File, error) {
+	<-p.done
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.collected = true
+	return p.file, p.err
+}
+
+// Cancel stops waiting for the lock file. It does not wait for the
+// acquisition to finish.
+//
+// If the lock file has already been acquired but has not yet been
+// collected by a call to [Pending.Result], it is released. This
+// guarantees that a cancelled acquisition never leaves behind an orphaned
+// lock, regardless of how the acquisition and the cancellation race.
+//
+// Cancel has no effect on a lock file that has already been returned by
+// [Pending.Result].
+func (p *Pending) Cancel() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.cancel()
+
+	if p.cancelled || p.collected {
+		return
+	}
+	p.cancelled = true
+
+	if p.file != nil {
+		p.file.Close()
+		p.file, p.err = nil, context.Canceled
+	}
+}
@@ -0,0 +1,105 @@
+package lockfile_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestAcquireAsync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "async.lock")
+
+	held, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create lock file: %v", err)
+	}
+
+	pending, err := lockfile.AcquireAsync(context.Background(), path)
+	if err != nil {
+		t.Fatalf("AcquireAsync failed: %v", err)
+	}
+
+	select {
+	case <-pending.Done():
+		t.Fatalf("acquisition finished while the lock was held")
+	default:
+	}
+
+	if err := held.Close(); err != nil {
+		t.Fatalf("failed to close lock file: %v", err)
+	}
+
+	lock, err := pending.Result()
+	if err != nil {
+		t.Fatalf("pending acquisition failed: %v", err)
+	}
+	if err := lock.Close(); err != nil {
+		t.Fatalf("failed to close acquired lock file: %v", err)
+	}
+}
+
+func TestAcquireAsyncCancel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "async.lock")
+
+	held, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create lock file: %v", err)
+	}
+	defer held.Close()
+
+	pending, err := lockfile.AcquireAsync(context.Background(), path)
+	if err != nil {
+		t.Fatalf("AcquireAsync failed: %v", err)
+	}
+	pending.Cancel()
+
+	if lock, err := pending.Result(); err == nil {
+		lock.Close()
+		t.Fatalf("cancelled acquisition succeeded while the lock was held")
+	}
+}
+
+func TestAcquireAsyncCancelAfterAcquisition(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "async.lock")
+
+	// Cancel repeatedly at varying points in the acquisition, and make sure
+	// that a cancelled acquisition never leaves the lock held.
+	for range 100 {
+		pending, err := lockfile.AcquireAsync(context.Background(), path)
+		if err != nil {
+			t.Fatalf("AcquireAsync failed: %v", err)
+		}
+		pending.Cancel()
+
+		if lock, err := pending.Result(); err == nil {
+			lock.Close()
+			t.Fatalf("cancelled acquisition returned a lock file")
+		}
+
+		lock, err := lockfile.Create(path)
+		if err != nil {
+			t.Fatalf("cancelled acquisition left the lock held: %v", err)
+		}
+		lock.Close()
+	}
+}
+
+func TestWaitCtxCancelled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wait.lock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if lock, err := lockfile.WaitCtx(ctx, path); err == nil {
+		lock.Close()
+		t.Fatalf("WaitCtx returned a lock file for a cancelled context")
+	}
+
+	lock, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("cancelled wait left the lock held: %v", err)
+	}
+	lock.Close()
+}
@@ -0,0 +1,346 @@
+package lockfile
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Backend implements the locks that lock files are acquired with, in place
+// of the operating system locks that are used by default. It allows other
+// mechanisms, such as directories, named mutexes or locks that only exist
+// in memory, to be selected with [WithBackend] or supplied by users.
+//
+// A Backend must be safe for concurrent use.
+type Backend interface {
+	// TryAcquire makes a single attempt to acquire the lock with the given
+	// path, without waiting. The lock is shared with other shared holders
+	// if shared is true, and exclusive otherwise.
+	//
+	// If the lock is held by someone else, it returns an error that wraps
+	// [os.ErrExist], which is treated as contention.
+	TryAcquire(path string, shared bool) (BackendLock, error)
+
+	// Release releases a lock that was acquired by TryAcquire with the
+	// given path.
+	Release(path string, lock BackendLock) error
+
+	// Probe reports whether the lock with the given path exists, and
+	// whether it is held, without acquiring it.
+	Probe(path string) (exists, held bool, err error)
+}
+
+// BackendReader is implemented by a [Backend] that records the holder of a
+// lock somewhere other than in a lock file at its path, such as in a file
+// within a lock directory. [Inspect] and [StaleCheck] read the holder with
+// ReadLock instead of reading the lock file.
+type BackendReader interface {
+	// ReadLock returns the contents that record the holder of the lock
+	// with the given path, as metadata or in one of the foreign formats
+	// recognized by [Inspect]. It returns an error that wraps
+	// [os.ErrNotExist] if the lock does not exist.
+	ReadLock(path string) ([]byte, error)
+}
+
+// BackendLock is a lock that is held through a [Backend].
+type BackendLock struct {
+	// File is the open lock file, if the backend has one. Features that
+	// read or write the lock file, such as [WithMetadata],
+	// [WithLinkMonitor] and [File.Share], are only available when it is
+	// provided. The backend is responsible for closing it on release.
+	File *os.File
+
+	// Value holds any state that the backend needs to release the lock.
+	Value any
+}
+
+// WithBackend returns an option that acquires lock files through the given
+// backend, instead of with the operating system locks that are used by
+// default. Everything else, such as waiting, hooks around the acquisition
+// and the [File] that represents the lock, works as usual.
+//
+// Lock file creation fails with an error that wraps [ErrInvalidOption] if
+// a backend is combined with [WithSoftLock], [WithPOSIXLocks] or
+// [WithOFDLocks], which select the locks of the default backend.
+func WithBackend(backend Backend) Option {
+	return func(c *config) {
+		c.backend = backend
+	}
+}
+
+// validateBackend returns an error if the backend of the configuration is
+// combined with options that select a different one.
+func (c *config) validateBackend() error {
+	if c.backend != nil && (c.soft || c.fcntlLocks()) {
+		return fmt.Errorf("%w: a backend cannot be combined with soft lock files or fcntl locks", ErrInvalidOption)
+	}
+	return nil
+}
+
+// lockBackend attempts to acquire a lock with the given path through the
+// backend of the configuration.
+func (c *config) lockBackend(path string) (*File, error) {
+	lock, err := c.backend.TryAcquire(path, c.shared)
+	if err != nil {
+		c.warnMoved(path, err)
+		return nil, err
+	}
+
+	file := newFile(path, c, lock.File, false)
+	file.h.shared = c.shared
+	file.h.backend = &lock
+	if lock.File != nil && c.writesMetadata() {
+		file.h.writeMetadata()
+	}
+	return file, nil
+}
+
+// readLock reads the contents of the lock at path, through the backend of
+// the configuration if it implements [BackendReader], or from the lock
+// file otherwise.
+func (c *config) readLock(path string) (data []byte, ok bool, err error) {
+	if r, isReader := c.backend.(BackendReader); isReader {
+		data, err = r.ReadLock(path)
+		return data, err == nil, err
+	}
+	return c.readLockFile(path)
+}
+
+// releaseBackend releases a lock that was acquired through a backend.
+//
+// The caller must hold h.mutex.
+func (h *lockHandle) releaseBackend() error {
+	err := h.cfg.backend.Release(h.path, *h.backend)
+	h.file = nil
+	return err
+}
+
+// MemoryBackend is a [Backend] whose locks only exist in the memory of the
+// current process. It excludes goroutines within the process from each
+// other without touching the filesystem, which makes it suitable for tests
+// and for processes that only need to coordinate with themselves.
+//
+// Paths are cleaned before they are compared, but are otherwise taken
+// literally.
+type MemoryBackend struct {
+	mutex sync.Mutex
+	locks map[string]int // The number of shared holders, or -1 if exclusive
+}
+
+// NewMemoryBackend returns a [MemoryBackend] in which no locks are held.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{locks: make(map[string]int)}
+}
+
+// TryAcquire makes a single attempt to acquire the lock with the given
+// path. It returns an [*os.PathError] that wraps [os.ErrExist] if the lock
+// is held by someone else.
+func (b *MemoryBackend) TryAcquire(path string, shared bool) (BackendLock, error) {
+	key := filepath.Clean(path)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	holders := b.locks[key]
+	switch {
+	case holders < 0, holders > 0 && !shared:
+		return BackendLock{}, &os.PathError{Op: "acquire", Path: path, Err: os.ErrExist}
+	case shared:
+		b.locks[key] = holders + 1
+	default:
+		b.locks[key] = -1
+	}
+	return BackendLock{}, nil
+}
+
+// Release releases the lock with the given path. It returns an
+// [*os.PathError] that wraps [ErrNotHeld] if the lock is not held.
+func (b *MemoryBackend) Release(path string, lock BackendLock) error {
+	key := filepath.Clean(path)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch holders := b.locks[key]; holders {
+	case 0:
+		return &os.PathError{Op: "release", Path: path, Err: ErrNotHeld}
+	case -1, 1:
+		delete(b.locks, key)
+	default:
+		b.locks[key] = holders - 1
+	}
+	return nil
+}
+
+// Probe reports whether the lock with the given path is held. A lock only
+// exists while it is held.
+func (b *MemoryBackend) Probe(path string) (exists, held bool, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	held = b.locks[filepath.Clean(path)] != 0
+	return held, held, nil
+}
+
+// tryAcquireOrBreak makes a single attempt to acquire the lock at path for
+// a backend whose locks are not released by the operating system when
+// their holder crashes, and does the work of their TryAcquire methods.
+//
+// The lock is created by create, which returns an error that wraps
+// [os.ErrExist] if a lock already exists at path. An existing lock is
+// broken if stale reports that it was abandoned, along with the contents
+// that record its holder, which are compared with the contents read by
+// read once the lock has been moved out of the way by breakLock. A stale
+// lock is broken at most once, so that a lock that is broken and
+// immediately acquired by someone else is contended.
+//
+// It returns an [*os.PathError] that wraps [os.ErrExist] if the lock is
+// held by someone else.
+func tryAcquireOrBreak(path string, create func() (BackendLock, error), stale func(path string) ([]byte, bool), read func(path string) ([]byte, error)) (BackendLock, error) {
+	for range 2 {
+		lock, err := create()
+		if err == nil {
+			return lock, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return BackendLock{}, classifyError(err)
+		}
+
+		data, abandoned := stale(path)
+		if !abandoned {
+			break
+		}
+		broken, err := breakLock(path, data, read)
+		if err != nil {
+			return BackendLock{}, err
+		}
+		if !broken {
+			break
+		}
+	}
+
+	return BackendLock{}, &os.PathError{Op: "acquire", Path: path, Err: os.ErrExist}
+}
+
+// staleHolder returns true if data, which records the holder of a lock
+// that was last written at modTime, describes a holder that is known to be
+// no longer running. A lock whose holder cannot be checked, such as one on
+// another host or one that does not record its holder, is stale once it
+// is older than staleAfter, if staleAfter is positive.
+func staleHolder(data []byte, modTime time.Time, staleAfter time.Duration) bool {
+	if md, ok := holderMetadata(data); ok && md.Holder.PID != 0 {
+		switch liveness, _ := checkMetadata(md); liveness {
+		case LivenessDead, LivenessReused, LivenessRebooted, LivenessExpired:
+			return true
+		case LivenessAlive:
+			return false
+		}
+	}
+	return staleAfter > 0 && time.Since(modTime) > staleAfter
+}
+
+// holderMetadata parses data that records the holder of a lock, in one of
+// the foreign formats or as metadata.
+func holderMetadata(data []byte) (Metadata, bool) {
+	if _, md, ok := parseForeign(data); ok {
+		return md, true
+	}
+	md, err := DecodeMetadata(data, IgnoreUnknownFields)
+	return md, err == nil
+}
+
+// breakLock removes the lock at path, which was found to be stale while it
+// recorded the holder in data. It returns true if the lock was removed, by
+// this call or by someone else.
+//
+// The holder is read with read after the lock has been moved out of the
+// way by breakTomb, in case the lock was broken and acquired again by
+// someone else in the meantime. Errors are reported as they are by
+// breakTomb.
+func breakLock(path string, data []byte, read func(path string) ([]byte, error)) (bool, error) {
+	return breakTomb(path, func(tomb string) bool {
+		after, _ := read(tomb)
+		return bytes.Equal(data, after)
+	})
+}
+
+// warnMoved reports err through the Warning hook if it wraps [ErrMoved],
+// which is how breakTomb reports a lock that it could not put back.
+func (c *config) warnMoved(path string, err error) {
+	if errors.Is(err, ErrMoved) {
+		c.warn(path, err)
+	}
+}
+
+// breakTomb removes the lock at path, which was found to be stale. It
+// returns true if the lock was removed, by this call or by someone else.
+//
+// The lock is renamed to a unique temporary name before it is removed.
+// Renaming is atomic, so only one of the processes that found it stale can
+// break it. The lock is only removed if unchanged reports that it is still
+// the lock that was found to be stale under its temporary name, and it is
+// put back otherwise.
+//
+// It returns an error if the lock could not be moved out of the way for a
+// reason other than it having been removed already. A lock that cannot be
+// put back, such as when yet another lock has been created at path, is
+// left under its temporary name, where its holder no longer excludes
+// anyone. It returns an [*os.PathError] that wraps [ErrMoved] and names
+// the temporary name in that case.
+func breakTomb(path string, unchanged func(tomb string) bool) (bool, error) {
+	var suffix [8]byte
+	rand.Read(suffix[:])
+	tomb := path + ".stale-" + hex.EncodeToString(suffix[:])
+	if err := os.Rename(path, tomb); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	if !unchanged(tomb) {
+		if err := restoreLock(tomb, path); err != nil {
+			return false, &os.PathError{Op: "restore", Path: path, Err: fmt.Errorf("%w: the lock was left at %s: %w", ErrMoved, tomb, err)}
+		}
+		return false, nil
+	}
+
+	return true, os.RemoveAll(tomb)
+}
+
+// restoreLock puts the lock that was renamed to tomb back at path, without
+// replacing anything that has been created at path since. It returns an
+// error that wraps [os.ErrExist] if path is occupied.
+//
+// A directory cannot be moved without replacing an empty directory at
+// path, so a new one is created exclusively at path and the contents of
+// the tomb are moved into it.
+func restoreLock(tomb, path string) error {
+	info, err := os.Lstat(tomb)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return renameNoReplace(tomb, path)
+	}
+
+	if err := os.Mkdir(path, info.Mode().Perm()); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(tomb)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.Rename(filepath.Join(tomb, entry.Name()), filepath.Join(path, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return os.Remove(tomb)
+}
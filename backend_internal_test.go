@@ -0,0 +1,96 @@
+package lockfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRestoreLock(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		create func(path string, data []byte) error
+		read   func(path string) ([]byte, error)
+	}{
+		{"file",
+			func(path string, data []byte) error { return os.WriteFile(path, data, 0644) },
+			os.ReadFile},
+		{"directory",
+			func(path string, data []byte) error {
+				if err := os.Mkdir(path, 0755); err != nil {
+					return err
+				}
+				return os.WriteFile(filepath.Join(path, mkdirHolderName), data, 0644)
+			},
+			(&MkdirBackend{}).ReadLock},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "restored.lock")
+			tomb := path + ".stale-tomb"
+
+			if err := tc.create(tomb, []byte("old")); err != nil {
+				t.Fatal(err)
+			}
+			if err := restoreLock(tomb, path); err != nil {
+				t.Fatalf("restoreLock failed: %v", err)
+			}
+			if data, err := tc.read(path); err != nil || string(data) != "old" {
+				t.Fatalf("the lock was not restored: %q, %v", data, err)
+			}
+			if _, err := os.Lstat(tomb); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("the tomb was left behind: %v", err)
+			}
+
+			// A lock that was created at the path in the meantime is never
+			// replaced, and the tomb is left for cleanup.
+			if err := os.Rename(path, tomb); err != nil {
+				t.Fatal(err)
+			}
+			if err := tc.create(path, []byte("new")); err != nil {
+				t.Fatal(err)
+			}
+			if err := restoreLock(tomb, path); !errors.Is(err, os.ErrExist) {
+				t.Fatalf("expected ErrExist for an occupied path, got: %v", err)
+			}
+			if data, err := tc.read(path); err != nil || string(data) != "new" {
+				t.Fatalf("the new lock was replaced: %q, %v", data, err)
+			}
+			if data, err := tc.read(tomb); err != nil || string(data) != "old" {
+				t.Fatalf("the tomb was not left alone: %q, %v", data, err)
+			}
+		})
+	}
+}
+
+func TestBreakTombRestoreFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "restore.lock")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another lock is created at the path while the old one is checked, so
+	// the old one cannot be put back.
+	var tomb string
+	broken, err := breakTomb(path, func(name string) bool {
+		tomb = name
+		if err := os.WriteFile(path, []byte("new"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return false
+	})
+	if broken {
+		t.Fatal("breakTomb reported a lock that was not removed as broken")
+	}
+	if !errors.Is(err, ErrMoved) || !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected ErrMoved for a lock that was not put back, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), tomb) {
+		t.Fatalf("the error does not name the tomb %s: %v", tomb, err)
+	}
+	if data, err := os.ReadFile(tomb); err != nil || string(data) != "old" {
+		t.Fatalf("the tomb was not left alone: %q, %v", data, err)
+	}
+}
@@ -0,0 +1,79 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestMemoryBackend(t *testing.T) {
+	backend := lockfile.NewMemoryBackend()
+	path := filepath.Join(t.TempDir(), "memory.lock")
+
+	file, err := lockfile.Create(path, lockfile.WithBackend(backend))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the memory backend touched the filesystem: %v", err)
+	}
+	if _, err := lockfile.Create(path, lockfile.WithBackend(backend)); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected ErrExist while the lock is held, got: %v", err)
+	}
+	if _, err := lockfile.CreateShared(path, lockfile.WithBackend(backend)); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected ErrExist for a reader while the lock is held, got: %v", err)
+	}
+	if info, err := lockfile.Inspect(path, lockfile.WithBackend(backend)); err != nil || !info.Held {
+		t.Fatalf("expected Inspect to report the lock as held, got %+v, %v", info, err)
+	}
+
+	// Another backend has its own locks.
+	other, err := lockfile.Create(path, lockfile.WithBackend(lockfile.NewMemoryBackend()))
+	if err != nil {
+		t.Fatalf("Create with another backend failed: %v", err)
+	}
+	other.Close()
+
+	// Waiting works as usual.
+	time.AfterFunc(20*time.Millisecond, func() { file.Close() })
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	file, err = lockfile.WaitCtx(ctx, path, lockfile.WithBackend(backend))
+	if err != nil {
+		t.Fatalf("WaitCtx failed: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Readers share the lock.
+	a, err := lockfile.CreateShared(path, lockfile.WithBackend(backend))
+	if err != nil {
+		t.Fatalf("CreateShared failed: %v", err)
+	}
+	b, err := lockfile.CreateShared(path, lockfile.WithBackend(backend))
+	if err != nil {
+		t.Fatalf("CreateShared failed: %v", err)
+	}
+	a.Close()
+	if _, err := lockfile.Create(path, lockfile.WithBackend(backend)); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected ErrExist while a reader remains, got: %v", err)
+	}
+	b.Close()
+	if info, err := lockfile.Inspect(path, lockfile.WithBackend(backend)); err != nil || info.Held {
+		t.Fatalf("expected Inspect to report the lock as free, got %+v, %v", info, err)
+	}
+}
+
+func TestBackendInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.lock")
+	backend := lockfile.WithBackend(lockfile.NewMemoryBackend())
+	if _, err := lockfile.Create(path, backend, lockfile.WithSoftLock(0)); !errors.Is(err, lockfile.ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption, got: %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile
+
+import "os"
+
+// renameNoReplace renames the file at oldpath to newpath, unless newpath
+// exists. A rename would replace newpath, so the file is linked to newpath
+// and then removed from oldpath.
+func renameNoReplace(oldpath, newpath string) error {
+	if err := os.Link(oldpath, newpath); err != nil {
+		return err
+	}
+	return os.Remove(oldpath)
+}
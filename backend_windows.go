@@ -0,0 +1,25 @@
+//go:build windows
+
+package lockfile
+
+import (
+	"os"
+	"syscall"
+)
+
+// renameNoReplace renames the file at oldpath to newpath, unless newpath
+// exists. Unlike [os.Rename], MoveFile never replaces newpath.
+func renameNoReplace(oldpath, newpath string) error {
+	from, err := syscall.UTF16PtrFromString(oldpath)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	to, err := syscall.UTF16PtrFromString(newpath)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	if err := syscall.MoveFile(from, to); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	return nil
+}
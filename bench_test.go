@@ -0,0 +1,89 @@
+package lockfile_test
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+// maxCreateCloseAllocs is the maximum number of allocations that an
+// uncontended call to Create followed by Close is expected to make on each
+// platform.
+var maxCreateCloseAllocs = map[string]float64{
+	"linux": 6,
+}
+
+func TestCreateCloseAllocs(t *testing.T) {
+	limit, ok := maxCreateCloseAllocs[runtime.GOOS]
+	if !ok {
+		t.Skipf("no allocation limit has been established for %s", runtime.GOOS)
+	}
+
+	path := filepath.Join(t.TempDir(), "allocs.lock")
+	allocs := testing.AllocsPerRun(100, func() {
+		lock, err := lockfile.Create(path)
+		if err != nil {
+			t.Fatalf("failed to create lock file: %v", err)
+		}
+		if err := lock.Close(); err != nil {
+			t.Fatalf("failed to close lock file: %v", err)
+		}
+	})
+
+	if allocs > limit {
+		t.Fatalf("Create and Close made %v allocations, expected no more than %v", allocs, limit)
+	}
+}
+
+func BenchmarkCreateClose(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "bench.lock")
+	b.ReportAllocs()
+	for b.Loop() {
+		lock, err := lockfile.Create(path)
+		if err != nil {
+			b.Fatalf("failed to create lock file: %v", err)
+		}
+		if err := lock.Close(); err != nil {
+			b.Fatalf("failed to close lock file: %v", err)
+		}
+	}
+}
+
+func BenchmarkCreateContended(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "bench.lock")
+
+	held, err := lockfile.Create(path)
+	if err != nil {
+		b.Fatalf("failed to create lock file: %v", err)
+	}
+	defer held.Close()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := lockfile.Create(path); !lockfile.IsTemporary(err) {
+			b.Fatalf("expected contention, got: %v", err)
+		}
+	}
+}
+
+func BenchmarkLockAcquire(b *testing.B) {
+	lock, err := lockfile.New(filepath.Join(b.TempDir(), "bench.lock"))
+	if err != nil {
+		b.Fatalf("failed to prepare lock: %v", err)
+	}
+
+	ctx := context.Background()
+	b.ReportAllocs()
+	for b.Loop() {
+		file, err := lock.Acquire(ctx)
+		if err != nil {
+			b.Fatalf("failed to acquire lock: %v", err)
+		}
+		if err := file.Close(); err != nil {
+			b.Fatalf("failed to release lock: %v", err)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package lockfile
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+)
+
+// WithForce returns an option that lets [Break] and [StealCtx] remove or
+// take over a lock file that could not be verified to be stale. It is
+// intended for operators who know that the holder has gone away, such as
+// when it ran on another host that has since been decommissioned.
+//
+// A lock file that is held cannot be taken over, so it is removed from its
+// path instead, and a new one is created in its place by [StealCtx]. Its
+// previous holder keeps a lock on the removed file, and its release
+// reports [ErrMoved]. On Windows, a lock file that is held cannot be
+// removed at all.
+func WithForce() Option {
+	return func(c *config) {
+		c.force = true
+	}
+}
+
+// Break removes the lock file at path if it is stale, so that it can be
+// acquired again. It returns the verdict of [StaleCheck] that it acted on.
+//
+// A lock file is stale if it is not held and its holder is known to be no
+// longer running, or if it is not held and does not record its holder. A
+// soft lock file is also stale if it has outlived the stale timeout
+// configured by [WithSoftLock], as its holder's lease on it has expired.
+// Otherwise it returns an [*os.PathError] that wraps [ErrNotStale], unless
+// the operator overrides the check with [WithForce].
+//
+// The lock file is locked before it is removed, and its contents are
+// checked again while it is locked, so that a lock file that is acquired
+// by someone else in the meantime is never removed. This provides the same
+// guarantees as [Create]. Soft lock files and the locks of a [Backend]
+// cannot be locked, so they are renamed out of the way and checked again
+// under their new name instead. They provide weaker guarantees, as
+// described by [Guarantees].
+//
+// A missing lock file is not an error. If [WithDryRun] is provided, the
+// lock file is not removed, and the removal is recorded in the plan.
+func Break(path string, opts ...Option) (StaleVerdict, error) {
+	c := newConfig(opts)
+	if path == "" {
+		return StaleVerdict{}, ErrEmptyPath
+	}
+
+	verdict, info, err := c.staleCheck(path)
+	if err != nil || !info.Exists {
+		return verdict, err
+	}
+	path = info.Path
+
+	if !c.breakable(info, verdict) {
+		return verdict, &os.PathError{Op: "break", Path: path, Err: ErrNotStale}
+	}
+
+	if c.dryRun != nil {
+		c.dryRun.record(Action{Op: "remove", Path: path, Reason: verdict.Reason})
+		return verdict, nil
+	}
+
+	if c.backend != nil || c.useSoftLock(path) {
+		return verdict, c.breakAside(path)
+	}
+
+	file, err := c.adoptLock(path, c.checkBreakable)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return verdict, nil
+	case c.force && errors.Is(err, os.ErrExist):
+		return verdict, c.breakAside(path)
+	case err != nil:
+		return verdict, err
+	}
+
+	// Releasing the lock file removes it.
+	return verdict, file.Close()
+}
+
+// StealCtx takes over the lock file at path if it is stale, as determined
+// by [Break], and returns it. If the lock file does not exist, it is
+// created as it would be by [Create].
+//
+// The lock file is taken over in place if it can be, in which case its
+// contents are replaced with the metadata configured by [WithMetadata], as
+// they are by [Adopt]. Otherwise it is removed and created again.
+//
+// If [WithDryRun] is provided, the lock file is neither removed nor
+// acquired. The take over is recorded in the plan, and it returns an error
+// that wraps [ErrDryRun].
+func StealCtx(ctx context.Context, path string, opts ...Option) (*File, error) {
+	c := newConfig(opts)
+	if path == "" {
+		return nil, ErrEmptyPath
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, withReason(err)
+	}
+
+	verdict, info, err := c.staleCheck(path)
+	if err != nil {
+		return nil, err
+	}
+	mapped := info.Path
+
+	if info.Exists && !c.breakable(info, verdict) {
+		return nil, &os.PathError{Op: "steal", Path: mapped, Err: ErrNotStale}
+	}
+
+	if c.dryRun != nil {
+		if info.Exists {
+			c.dryRun.record(Action{Op: "steal", Path: mapped, Reason: verdict.Reason})
+		}
+		return nil, &os.PathError{Op: "steal", Path: mapped, Err: ErrDryRun}
+	}
+
+	if !info.Exists {
+		return c.createCtx(ctx, path)
+	}
+
+	if c.backend != nil || c.useSoftLock(mapped) {
+		if err := c.breakAside(mapped); err != nil {
+			return nil, err
+		}
+		return c.createCtx(ctx, path)
+	}
+
+	file, err := c.adoptLock(mapped, c.checkBreakable)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return c.createCtx(ctx, path)
+	case c.force && errors.Is(err, os.ErrExist):
+		if err := c.breakAside(mapped); err != nil {
+			return nil, err
+		}
+		return c.createCtx(ctx, path)
+	case err != nil:
+		return nil, err
+	}
+
+	if c.fencing {
+		if err := c.fence(file); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	// Replace the previous holder's description with ours.
+	file.h.writeMetadata()
+
+	return file, nil
+}
+
+// breakable returns true if the lock file described by info and verdict
+// may be broken.
+func (c *config) breakable(info Inspection, verdict StaleVerdict) bool {
+	switch {
+	case c.force, verdict.Stale():
+		return true
+	case c.useSoftLock(info.Path):
+		// A soft lock file is not held once its stale timeout has passed.
+		return !info.Held
+	}
+	return !info.Held && (verdict.Holder == nil || verdict.Holder.PID == 0)
+}
+
+// checkBreakable returns an error if data, the contents of the lock file
+// at path, prevents it from being broken. It is called while the lock file
+// is locked, so that the decision is based on contents that cannot change.
+func (c *config) checkBreakable(path string, data []byte) error {
+	if c.force || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	md, err := DecodeMetadata(data, IgnoreUnknownFields)
+	if err != nil {
+		var ok bool
+		if _, md, ok = parseForeign(data); !ok {
+			return &os.PathError{Op: "break", Path: path, Err: ErrNotEmpty}
+		}
+	}
+	if md.Holder.PID == 0 {
+		return nil
+	}
+
+	if liveness, _ := checkMetadata(md); (StaleVerdict{Liveness: liveness}).Stale() {
+		return nil
+	}
+	return &os.PathError{Op: "break", Path: path, Err: ErrNotStale}
+}
+
+// breakAside removes the lock file at path without locking it, as is
+// necessary for soft lock files, backends and lock files that are held.
+//
+// The lock file is moved out of the way as described by breakTomb, and only
+// removed if it is still breakable under its temporary name, so that a lock
+// file that was broken and acquired again by someone else in the meantime
+// is never removed. It returns an [*os.PathError] that wraps [ErrNotStale]
+// if the lock file was not removed for that reason. A lock file that has
+// already been removed is not an error. A lock file that could not be put
+// back is reported as it is by breakTomb, and through the Warning hook.
+func (c *config) breakAside(path string) error {
+	broken, err := breakTomb(path, func(tomb string) bool {
+		info, err := c.inspectAt(tomb)
+		return err == nil && info.Exists && c.breakable(info, c.verdict(info))
+	})
+	switch {
+	case err != nil:
+		c.warnMoved(path, err)
+		return err
+	case !broken:
+		return &os.PathError{Op: "break", Path: path, Err: ErrNotStale}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+// exitedPID starts a process and waits for it to exit, and returns its ID,
+// which is most likely unused.
+func exitedPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+// writePIDFile writes a foreign lock file that records pid at path.
+func writePIDFile(t *testing.T, path string, pid int) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(strconv.Itoa(pid)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBreak(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "break.lock")
+
+	if _, err := lockfile.Break(path); err != nil {
+		t.Fatalf("Break failed for a missing lock file: %v", err)
+	}
+
+	writePIDFile(t, path, exitedPID(t))
+	verdict, err := lockfile.Break(path)
+	if err != nil {
+		t.Fatalf("Break failed for a stale lock file: %v", err)
+	}
+	if verdict.Liveness != lockfile.LivenessDead {
+		t.Errorf("unexpected verdict: %+v", verdict)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the stale lock file was not removed: %v", err)
+	}
+
+	// A lock file that records a live holder is not stale, unless the
+	// operator says otherwise.
+	writePIDFile(t, path, os.Getpid())
+	if _, err := lockfile.Break(path); !errors.Is(err, lockfile.ErrNotStale) {
+		t.Fatalf("expected ErrNotStale for a live holder, got: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("the lock file of a live holder was disturbed: %v", err)
+	}
+	if _, err := lockfile.Break(path, lockfile.WithForce()); err != nil {
+		t.Fatalf("Break failed with WithForce: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the lock file was not removed with WithForce: %v", err)
+	}
+}
+
+func TestBreakHeld(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "held.lock")
+
+	file, err := lockfile.Create(path, lockfile.WithMetadata(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if _, err := lockfile.Break(path); !errors.Is(err, lockfile.ErrNotStale) {
+		t.Fatalf("expected ErrNotStale for a held lock file, got: %v", err)
+	}
+	if _, err := lockfile.Create(path); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected the lock file to still be held, got: %v", err)
+	}
+}
+
+func TestBreakDryRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dryrun.lock")
+	writePIDFile(t, path, exitedPID(t))
+
+	var plan lockfile.Plan
+	if _, err := lockfile.Break(path, lockfile.WithDryRun(&plan)); err != nil {
+		t.Fatalf("Break failed in dry-run mode: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("the lock file was removed in dry-run mode: %v", err)
+	}
+	if actions := plan.Actions(); len(actions) != 1 || actions[0].Op != "remove" || actions[0].Path != path {
+		t.Fatalf("unexpected plan: %+v", actions)
+	}
+
+	if _, err := lockfile.StealCtx(context.Background(), path, lockfile.WithDryRun(&plan)); !errors.Is(err, lockfile.ErrDryRun) {
+		t.Fatalf("expected ErrDryRun from StealCtx, got: %v", err)
+	}
+	if actions := plan.Actions(); len(actions) != 2 || actions[1].Op != "steal" {
+		t.Fatalf("unexpected plan: %+v", actions)
+	}
+}
+
+func TestBreakSoftExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "soft.lock")
+	opts := []lockfile.Option{lockfile.WithSoftLock(time.Minute), lockfile.WithMetadata(nil)}
+
+	file, err := lockfile.Create(path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if _, err := lockfile.Break(path, opts...); !errors.Is(err, lockfile.ErrNotStale) {
+		t.Fatalf("expected ErrNotStale for a soft lock file within its lease, got: %v", err)
+	}
+
+	expired := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, expired, expired); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockfile.Break(path, opts...); err != nil {
+		t.Fatalf("Break failed for an expired soft lock file: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the expired soft lock file was not removed: %v", err)
+	}
+}
+
+func TestBreakBackend(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "backend.lock")
+	backend := lockfile.WithBackend(lockfile.NewMkdirBackend(0))
+	hostname, _ := os.Hostname()
+
+	md := lockfile.NewMetadata(0)
+	writeMkdirLock(t, path, md)
+	if _, err := lockfile.Break(path, backend); !errors.Is(err, lockfile.ErrNotStale) {
+		t.Fatalf("expected ErrNotStale for a live holder, got: %v", err)
+	}
+
+	md.Holder = lockfile.Holder{PID: exitedPID(t), Hostname: hostname}
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatal(err)
+	}
+	writeMkdirLock(t, path, md)
+	if _, err := lockfile.Break(path, backend); err != nil {
+		t.Fatalf("Break failed for a stale lock directory: %v", err)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Fatalf("the stale lock directory was not removed: %v, %v", entries, err)
+	}
+}
+
+func TestStealCtx(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "steal.lock")
+	writePIDFile(t, path, exitedPID(t))
+
+	file, err := lockfile.StealCtx(context.Background(), path, lockfile.WithMetadata(nil))
+	if err != nil {
+		t.Fatalf("StealCtx failed for a stale lock file: %v", err)
+	}
+
+	info, err := lockfile.Inspect(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Held || info.Metadata == nil || info.Metadata.Holder.PID != os.Getpid() {
+		t.Fatalf("the lock file was not taken over: %+v", info)
+	}
+
+	if _, err := lockfile.StealCtx(context.Background(), path); !errors.Is(err, lockfile.ErrNotStale) {
+		t.Fatalf("expected ErrNotStale for a held lock file, got: %v", err)
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the stolen lock file was not removed when it was released: %v", err)
+	}
+}
+
+func TestStealCtxForceHeld(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("lock files that are held cannot be removed on Windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "force.lock")
+	previous, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := lockfile.StealCtx(context.Background(), path, lockfile.WithForce())
+	if err != nil {
+		t.Fatalf("StealCtx failed with WithForce: %v", err)
+	}
+	defer file.Close()
+
+	if err := previous.Close(); !errors.Is(err, lockfile.ErrMoved) {
+		t.Fatalf("expected the previous holder to report ErrMoved, got: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("the lock file of the new holder was removed: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package lockfile
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// Classifier decides which errors returned while creating a lock file are
+// temporary, and how many of them may be retried in a row.
+//
+// By default, only contention and the errors recognized by [IsTemporary]
+// are temporary, and they are retried indefinitely. A filesystem that is
+// permanently broken in a way that looks temporary, such as one that
+// always denies access on Windows, then causes [WaitCtx] to retry forever.
+// A Classifier with a budget stops waiting once that many consecutive
+// temporary errors other than contention have been encountered.
+//
+// A Classifier is safe for concurrent use, and can be shared by many
+// waiters. Each waiter counts its own errors.
+type Classifier struct {
+	budget int
+
+	mutex     sync.RWMutex
+	temporary []error
+}
+
+// NewClassifier returns a [Classifier] that treats the given errors as
+// temporary, in addition to those recognized by [IsTemporary].
+//
+// If budget is positive, a waiter gives up after budget consecutive
+// temporary errors, not counting contention. If budget is zero, temporary
+// errors are retried indefinitely.
+func NewClassifier(budget int, temporary ...error) *Classifier {
+	return &Classifier{
+		budget:    max(budget, 0),
+		temporary: temporary,
+	}
+}
+
+// WithClassifier returns an option that classifies errors with c.
+func WithClassifier(c *Classifier) Option {
+	return func(cfg *config) {
+		cfg.classifier = c
+	}
+}
+
+// Budget returns the number of consecutive temporary errors that a waiter
+// may encounter before it gives up, or zero if there is no limit.
+func (c *Classifier) Budget() int {
+	return c.budget
+}
+
+// AddTemporary registers additional errors that are treated as temporary.
+// Errors are matched with [errors.Is].
+func (c *Classifier) AddTemporary(errs ...error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.temporary = append(c.temporary, errs...)
+}
+
+// IsTemporary returns true if err is temporary according to c.
+func (c *Classifier) IsTemporary(err error) bool {
+	if IsTemporary(err) {
+		return true
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	for _, target := range c.temporary {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTemporary returns true if err is temporary according to the
+// configuration.
+func (c *config) isTemporary(err error) bool {
+	if c.classifier != nil {
+		return c.classifier.IsTemporary(err)
+	}
+	return IsTemporary(err)
+}
+
+// exhausted records a temporary error in streak, which counts consecutive
+// temporary errors other than contention, and returns true if the budget
+// of the configuration has been exhausted.
+func (c *config) exhausted(err error, streak *int) bool {
+	if errors.Is(err, os.ErrExist) {
+		*streak = 0
+		return false
+	}
+
+	*streak++
+	return c.classifier != nil && c.classifier.budget > 0 && *streak >= c.classifier.budget
+}
@@ -0,0 +1,55 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestClassifierBudget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.lock")
+	errFlaky := errors.New("flaky")
+
+	classifier := lockfile.NewClassifier(3, errFlaky)
+	if !classifier.IsTemporary(errFlaky) {
+		t.Fatalf("registered error is not temporary")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	_, err := lockfile.WaitCtx(ctx, path, lockfile.WithClassifier(classifier), failOpen(errFlaky))
+	if !errors.Is(err, lockfile.ErrRetryBudgetExhausted) {
+		t.Fatalf("expected ErrRetryBudgetExhausted, got: %v", err)
+	}
+	if !errors.Is(err, errFlaky) {
+		t.Fatalf("the exhausted error does not wrap the last error: %v", err)
+	}
+}
+
+func TestClassifierContention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contention.lock")
+
+	held, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	go func() {
+		time.Sleep(time.Millisecond * 200)
+		held.Close()
+	}()
+
+	// Contention must not exhaust the budget.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	file, err := lockfile.WaitCtx(ctx, path, lockfile.WithClassifier(lockfile.NewClassifier(1)))
+	if err != nil {
+		t.Fatalf("WaitCtx failed: %v", err)
+	}
+	file.Close()
+}
@@ -0,0 +1,46 @@
+package lockfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// classifyError adds a sentinel error from this package to err if it
+// represents a recognized filesystem condition, such as a read-only or
+// full filesystem. Otherwise it returns err unchanged.
+//
+// The returned error continues to match the original system error.
+func classifyError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case isReadOnly(err):
+		return withSentinel(err, ErrReadOnlyFilesystem)
+	case isNoSpace(err):
+		return withSentinel(err, ErrNoSpace)
+	case isQuotaExceeded(err):
+		return withSentinel(err, ErrQuotaExceeded)
+	}
+	return err
+}
+
+// withSentinel returns err with sentinel added to it. If err is an
+// [*os.PathError], the sentinel is added to the error it wraps, so that the
+// operation and path are preserved.
+func withSentinel(err, sentinel error) error {
+	if errors.Is(err, sentinel) {
+		return err
+	}
+
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return &os.PathError{
+			Op:   pathErr.Op,
+			Path: pathErr.Path,
+			Err:  fmt.Errorf("%w: %w", sentinel, pathErr.Err),
+		}
+	}
+
+	return fmt.Errorf("%w: %w", sentinel, err)
+}
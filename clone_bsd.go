@@ -0,0 +1,14 @@
+//go:build darwin || dragonfly || freebsd || openbsd
+
+package lockfile
+
+import (
+	"errors"
+	"os"
+)
+
+// cloneFile returns an error, because cloning an open file is not
+// supported on this platform, and dst is unchanged.
+func cloneFile(dst, src *os.File) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,23 @@
+//go:build linux
+
+package lockfile
+
+import (
+	"os"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl request, which makes one file share the
+// blocks of another on file systems that support reflinks, such as Btrfs
+// and XFS. See the ioctl_ficlone(2) man page for details.
+const ficlone = 0x40049409
+
+// cloneFile makes dst a copy-on-write clone of src. It returns an error if
+// the file system does not support cloning, or if the files are on
+// different file systems, in which case dst is unchanged.
+func cloneFile(dst, src *os.File) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), ficlone, src.Fd()); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+//go:build windows
+
+package lockfile
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// fsctlDuplicateExtentsToFile asks the file system to make a range of one
+// file share the clusters of a range of another. It is supported by ReFS,
+// including Dev Drives, and by NTFS volumes with block cloning.
+const fsctlDuplicateExtentsToFile = 0x00098344
+
+// cloneChunk is the largest range that is cloned by a single request. Each
+// request must cover less than 4 GiB.
+const cloneChunk = 1 << 30
+
+// duplicateExtentsData is the DUPLICATE_EXTENTS_DATA structure.
+type duplicateExtentsData struct {
+	FileHandle       syscall.Handle
+	SourceFileOffset int64
+	TargetFileOffset int64
+	ByteCount        int64
+}
+
+// cloneFile makes dst a copy-on-write clone of src. It returns an error if
+// the file system does not support cloning, or if the files are on
+// different volumes. dst may have been extended when an error is returned.
+func cloneFile(dst, src *os.File) error {
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	size := fi.Size()
+	if size == 0 {
+		return nil
+	}
+
+	cluster, err := getClusterSize(dst.Name())
+	if err != nil {
+		return err
+	}
+
+	// The target range must already exist, and every range but the last
+	// must be aligned to the cluster size. The last may extend to the end
+	// of the cluster that contains the end of the file.
+	if err := dst.Truncate(size); err != nil {
+		return err
+	}
+
+	for offset := int64(0); offset < size; offset += cloneChunk {
+		count := min(cloneChunk, (size-offset+cluster-1)/cluster*cluster)
+		data := duplicateExtentsData{
+			FileHandle:       syscall.Handle(src.Fd()),
+			SourceFileOffset: offset,
+			TargetFileOffset: offset,
+			ByteCount:        count,
+		}
+		var returned uint32
+		if err := syscall.DeviceIoControl(syscall.Handle(dst.Fd()), fsctlDuplicateExtentsToFile, (*byte)(unsafe.Pointer(&data)), uint32(unsafe.Sizeof(data)), nil, 0, &returned, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
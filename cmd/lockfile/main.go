@@ -0,0 +1,52 @@
+// Command lockfile provides diagnostics for lock files.
+//
+// Usage:
+//
+//	lockfile stress --dir DIR [--procs N] [--duration D] [--hold H]
+//
+// The stress command spawns several processes that contend for a lock file
+// in DIR, and reports whether the lock file excluded them from each other,
+// along with the latency of acquiring it. It is intended to be run on the
+// filesystem in question, such as a network share, when lock files appear
+// not to work on it.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run runs the command with the given arguments, and returns its exit
+// code.
+func run(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+
+	switch args[0] {
+	case "stress":
+		return stress(args[1:])
+	case stressWorkerCommand:
+		return stressWorker(args[1:])
+	case "help", "-h", "-help", "--help":
+		usage()
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "lockfile: unknown command %q\n", args[0])
+	usage()
+	return 2
+}
+
+// usage prints a summary of the available commands.
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: lockfile <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  stress  contend for a lock file from several processes and report the results")
+}
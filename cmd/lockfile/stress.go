@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+// stressWorkerCommand is the hidden command that runs a single contending
+// process of the stress command.
+const stressWorkerCommand = "stress-worker"
+
+// Names of the files used by the stress command within its directory.
+const (
+	stressLockName    = "stress.lock"
+	stressOwnerName   = "stress.owner"
+	stressCounterName = "stress.counter"
+)
+
+// stressResult is reported by each worker process to the stress command.
+type stressResult struct {
+	PID          int     `json:"pid"`
+	Acquisitions int     `json:"acquisitions"`
+	Violations   int     `json:"violations"`
+	Errors       int     `json:"errors"`
+	FirstError   string  `json:"error,omitempty"`
+	Waits        []int64 `json:"waits"` // Nanoseconds spent waiting for each acquisition
+}
+
+// stress runs the stress command.
+func stress(args []string) int {
+	flags := flag.NewFlagSet("stress", flag.ContinueOnError)
+	dir := flags.String("dir", "", "directory in which to contend for a lock file (required)")
+	procs := flags.Int("procs", 4, "number of contending processes")
+	duration := flags.Duration("duration", 10*time.Second, "how long to contend for the lock file")
+	hold := flags.Duration("hold", time.Millisecond, "how long each process holds the lock file")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *dir == "" || *procs < 1 || *duration <= 0 || *hold < 0 {
+		flags.Usage()
+		return 2
+	}
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "lockfile: %v\n", err)
+		return 1
+	}
+	counter := filepath.Join(*dir, stressCounterName)
+	if err := os.WriteFile(counter, []byte("0"), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "lockfile: %v\n", err)
+		return 1
+	}
+	defer os.Remove(counter)
+	defer os.Remove(filepath.Join(*dir, stressOwnerName))
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lockfile: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Contending for %s with %d processes for %v...\n", filepath.Join(*dir, stressLockName), *procs, *duration)
+
+	results := make([]stressResult, *procs)
+	failures := make([]error, *procs)
+	var wg sync.WaitGroup
+	for i := range *procs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], failures[i] = runStressWorker(exe, *dir, *duration, *hold)
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(failures...); err != nil {
+		fmt.Fprintf(os.Stderr, "lockfile: %v\n", err)
+		return 1
+	}
+
+	count, err := readCounter(counter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lockfile: %v\n", err)
+		return 1
+	}
+
+	report := summarize(results, count)
+	report.print(os.Stdout)
+	if !report.excluded() {
+		return 1
+	}
+	return 0
+}
+
+// runStressWorker runs a worker process and returns its result.
+func runStressWorker(exe, dir string, duration, hold time.Duration) (stressResult, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command(exe, stressWorkerCommand,
+		"-dir", dir,
+		"-duration", duration.String(),
+		"-hold", hold.String())
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return stressResult{}, fmt.Errorf("worker process failed: %w", err)
+	}
+
+	var result stressResult
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		return stressResult{}, fmt.Errorf("worker process returned an invalid result: %w", err)
+	}
+	return result, nil
+}
+
+// stressWorker runs a single contending process. It repeatedly acquires
+// the lock file until its duration has passed, and writes its result to
+// standard output.
+func stressWorker(args []string) int {
+	flags := flag.NewFlagSet(stressWorkerCommand, flag.ContinueOnError)
+	dir := flags.String("dir", "", "")
+	duration := flags.Duration("duration", 0, "")
+	hold := flags.Duration("hold", 0, "")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var (
+		path    = filepath.Join(*dir, stressLockName)
+		owner   = filepath.Join(*dir, stressOwnerName)
+		counter = filepath.Join(*dir, stressCounterName)
+		self    = []byte(strconv.Itoa(os.Getpid()))
+		result  = stressResult{PID: os.Getpid()}
+	)
+
+	fail := func(err error) {
+		result.Errors++
+		if result.FirstError == "" {
+			result.FirstError = err.Error()
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+
+	for ctx.Err() == nil {
+		start := time.Now()
+		file, err := lockfile.WaitCtx(ctx, path)
+		if err != nil {
+			if ctx.Err() == nil {
+				fail(err)
+				time.Sleep(10 * time.Millisecond)
+			}
+			continue
+		}
+		result.Waits = append(result.Waits, int64(time.Since(start)))
+		result.Acquisitions++
+
+		// Claim ownership of the critical section, hold it, and check
+		// that nobody else claimed it in the meantime.
+		if err := os.WriteFile(owner, self, 0644); err != nil {
+			fail(err)
+		}
+		if err := incrementCounter(counter); err != nil {
+			fail(err)
+		}
+		time.Sleep(*hold)
+		if data, err := os.ReadFile(owner); err != nil {
+			fail(err)
+		} else if !bytes.Equal(data, self) {
+			result.Violations++
+		}
+
+		if err := file.Close(); err != nil {
+			fail(err)
+		}
+
+		// Give other processes a chance to acquire the lock file.
+		time.Sleep(time.Duration(rand.IntN(1000)) * time.Microsecond)
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// incrementCounter increments the number stored in the file at path. The
+// read and write are not atomic, so increments are lost if two processes
+// are in the critical section at once.
+func incrementCounter(path string) error {
+	count, err := readCounter(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strconv.Itoa(count+1)), 0644)
+}
+
+// readCounter reads the number stored in the file at path.
+func readCounter(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(bytes.TrimSpace(data)))
+}
+
+// stressReport summarizes the results of the stress command.
+type stressReport struct {
+	Procs        int
+	Acquisitions int
+	Counted      int // The value of the shared counter
+	Violations   int
+	Errors       int
+	FirstError   string
+	WaitP50      time.Duration
+	WaitP99      time.Duration
+	WaitMax      time.Duration
+}
+
+// summarize combines the results of the worker processes.
+func summarize(results []stressResult, counted int) stressReport {
+	report := stressReport{Procs: len(results), Counted: counted}
+
+	var waits []time.Duration
+	for _, result := range results {
+		report.Acquisitions += result.Acquisitions
+		report.Violations += result.Violations
+		report.Errors += result.Errors
+		if report.FirstError == "" {
+			report.FirstError = result.FirstError
+		}
+		for _, wait := range result.Waits {
+			waits = append(waits, time.Duration(wait))
+		}
+	}
+
+	if len(waits) > 0 {
+		slices.Sort(waits)
+		rank := func(p int) time.Duration {
+			return waits[max((p*len(waits)+99)/100-1, 0)]
+		}
+		report.WaitP50, report.WaitP99, report.WaitMax = rank(50), rank(99), waits[len(waits)-1]
+	}
+
+	return report
+}
+
+// excluded returns true if the lock file excluded the worker processes
+// from each other throughout the test.
+func (r stressReport) excluded() bool {
+	return r.Violations == 0 && r.Counted == r.Acquisitions
+}
+
+// print writes the report to w.
+func (r stressReport) print(w io.Writer) {
+	fmt.Fprintf(w, "Processes:    %d\n", r.Procs)
+	fmt.Fprintf(w, "Acquisitions: %d\n", r.Acquisitions)
+	fmt.Fprintf(w, "Wait:         p50 %v, p99 %v, max %v\n", r.WaitP50, r.WaitP99, r.WaitMax)
+	if r.Errors > 0 {
+		fmt.Fprintf(w, "Errors:       %d (first: %s)\n", r.Errors, r.FirstError)
+	}
+
+	if r.excluded() {
+		fmt.Fprintln(w, "Exclusion:    OK")
+		return
+	}
+	fmt.Fprintln(w, "Exclusion:    FAILED")
+	fmt.Fprintf(w, "  %d overlapping critical sections were observed\n", r.Violations)
+	fmt.Fprintf(w, "  %d of %d increments of a shared counter were lost\n", r.Acquisitions-r.Counted, r.Acquisitions)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// The stress command runs its workers by executing itself, which is
+	// the test binary when testing.
+	if len(os.Args) > 1 && os.Args[1] == stressWorkerCommand {
+		os.Exit(run(os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+func TestStress(t *testing.T) {
+	code := run([]string{"stress", "-dir", t.TempDir(), "-procs", "3", "-duration", "500ms"})
+	if code != 0 {
+		t.Fatalf("stress exited with code %d", code)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	results := []stressResult{
+		{Acquisitions: 2, Waits: []int64{1, 3}},
+		{Acquisitions: 2, Violations: 1, Waits: []int64{2, 4}},
+	}
+
+	report := summarize(results, 3)
+	if report.Acquisitions != 4 || report.Violations != 1 || report.excluded() {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.WaitP50 != 2 || report.WaitMax != 4 {
+		t.Fatalf("unexpected percentiles: %+v", report)
+	}
+}
@@ -0,0 +1,139 @@
+package lockfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Codec encodes and decodes lock file metadata.
+//
+// The package provides [JSONCodec], which is the default. Organizations
+// with existing schemas can provide their own, such as one based on
+// protocol buffers or CBOR, and register it with [RegisterCodec] so that
+// metadata encoded with it can be decoded by [DecodeMetadata].
+type Codec interface {
+	// Name returns the name of the codec, which identifies it in the
+	// header of encoded metadata. It must not be empty, and must not
+	// contain whitespace.
+	Name() string
+
+	// Marshal encodes the metadata.
+	Marshal(md Metadata) ([]byte, error)
+
+	// Unmarshal decodes metadata that was encoded by Marshal, handling
+	// unrecognized fields and versions according to policy.
+	Unmarshal(data []byte, policy UnknownFieldPolicy) (Metadata, error)
+}
+
+// JSONCodec encodes metadata as JSON. It is registered by default.
+var JSONCodec Codec = jsonCodec{}
+
+// jsonCodec implements [JSONCodec].
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Marshal(md Metadata) ([]byte, error) {
+	return json.Marshal(md)
+}
+
+func (jsonCodec) Unmarshal(data []byte, policy UnknownFieldPolicy) (Metadata, error) {
+	return ParseMetadata(data, policy)
+}
+
+// codecHeader is the prefix of the header line that identifies the codec
+// used to encode metadata. The name of the codec follows it, and the line
+// ends with a newline.
+const codecHeader = "#lockfile:"
+
+var (
+	codecMutex sync.RWMutex
+	codecs     = map[string]Codec{"json": JSONCodec}
+)
+
+// RegisterCodec makes a codec available to [DecodeMetadata]. It is
+// typically called from an init function.
+//
+// It panics if the name of the codec is invalid, or if a codec with the
+// same name has already been registered.
+func RegisterCodec(codec Codec) {
+	name := codec.Name()
+	if name == "" || strings.ContainsAny(name, " \t\r\n") {
+		panic(fmt.Sprintf("lockfile: invalid codec name %q", name))
+	}
+
+	codecMutex.Lock()
+	defer codecMutex.Unlock()
+
+	if _, exists := codecs[name]; exists {
+		panic(fmt.Sprintf("lockfile: codec %q is already registered", name))
+	}
+	codecs[name] = codec
+}
+
+// LookupCodec returns the registered codec with the given name.
+func LookupCodec(name string) (Codec, bool) {
+	codecMutex.RLock()
+	defer codecMutex.RUnlock()
+
+	codec, ok := codecs[name]
+	return codec, ok
+}
+
+// EncodeMetadata encodes the metadata with the given codec, preceded by a
+// short header that identifies the codec. If codec is nil, [JSONCodec] is
+// used.
+func EncodeMetadata(md Metadata, codec Codec) ([]byte, error) {
+	if codec == nil {
+		codec = JSONCodec
+	}
+
+	payload, err := codec.Marshal(md)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, 0, len(codecHeader)+len(codec.Name())+1+len(payload))
+	data = append(data, codecHeader...)
+	data = append(data, codec.Name()...)
+	data = append(data, '\n')
+	return append(data, payload...), nil
+}
+
+// DecodeMetadata decodes metadata that was encoded by [EncodeMetadata],
+// detecting the codec from its header. Metadata without a header is
+// decoded as JSON, as produced by earlier versions of this package.
+//
+// It returns an error that wraps [ErrInvalidMetadata] if the codec is not
+// registered, or if the codec cannot decode the metadata.
+func DecodeMetadata(data []byte, policy UnknownFieldPolicy) (Metadata, error) {
+	codec, payload, err := detectCodec(data)
+	if err != nil {
+		return Metadata{}, err
+	}
+	return codec.Unmarshal(payload, policy)
+}
+
+// detectCodec returns the codec identified by the header of data, and the
+// payload that follows the header.
+func detectCodec(data []byte) (Codec, []byte, error) {
+	if !bytes.HasPrefix(data, []byte(codecHeader)) {
+		return JSONCodec, data, nil
+	}
+
+	line, payload, ok := bytes.Cut(data[len(codecHeader):], []byte{'\n'})
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: the header is incomplete", ErrInvalidMetadata)
+	}
+
+	name := string(bytes.TrimSuffix(line, []byte{'\r'}))
+	codec, ok := LookupCodec(name)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: unknown codec %q", ErrInvalidMetadata, name)
+	}
+
+	return codec, payload, nil
+}
@@ -0,0 +1,117 @@
+package lockfile_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+// pidCodec is a minimal custom codec that only records the holder's PID.
+type pidCodec struct{}
+
+func (pidCodec) Name() string { return "test-pid" }
+
+func (pidCodec) Marshal(md lockfile.Metadata) ([]byte, error) {
+	return fmt.Appendf(nil, "%d %d", md.Version, md.Holder.PID), nil
+}
+
+func (pidCodec) Unmarshal(data []byte, policy lockfile.UnknownFieldPolicy) (lockfile.Metadata, error) {
+	var md lockfile.Metadata
+	if _, err := fmt.Sscanf(string(data), "%d %d", &md.Version, &md.Holder.PID); err != nil {
+		return lockfile.Metadata{}, fmt.Errorf("%w: %w", lockfile.ErrInvalidMetadata, err)
+	}
+	return md, nil
+}
+
+func init() {
+	lockfile.RegisterCodec(pidCodec{})
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, codec := range []lockfile.Codec{lockfile.JSONCodec, pidCodec{}} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			data, err := lockfile.EncodeMetadata(lockfile.NewMetadata(3), codec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			md, err := lockfile.DecodeMetadata(data, lockfile.RejectUnknownFields)
+			if err != nil {
+				t.Fatalf("DecodeMetadata failed: %v", err)
+			}
+			if md.Holder.PID != os.Getpid() {
+				t.Fatalf("unexpected metadata: %+v", md)
+			}
+		})
+	}
+}
+
+func TestDecodeMetadataUnknownCodec(t *testing.T) {
+	_, err := lockfile.DecodeMetadata([]byte("#lockfile:missing\n{}"), lockfile.IgnoreUnknownFields)
+	if !errors.Is(err, lockfile.ErrInvalidMetadata) {
+		t.Fatalf("DecodeMetadata returned %v for an unknown codec", err)
+	}
+}
+
+func TestRegisterCodecDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("registering a duplicate codec did not panic")
+		}
+	}()
+	lockfile.RegisterCodec(pidCodec{})
+}
+
+func TestInspect(t *testing.T) {
+	dir := t.TempDir()
+
+	data, err := lockfile.EncodeMetadata(lockfile.NewMetadata(5), pidCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom := filepath.Join(dir, "custom.lock")
+	if err := os.WriteFile(custom, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Metadata without a header is assumed to be JSON.
+	plain := filepath.Join(dir, "plain.lock")
+	if err := os.WriteFile(plain, []byte(`{"version": 1, "holder": {"pid": 42}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	empty := filepath.Join(dir, "empty.lock")
+	if err := os.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path   string
+		format string
+		pid    int
+	}{
+		{custom, "test-pid", os.Getpid()},
+		{plain, "json", 42},
+		{empty, "", 0},
+	}
+	for _, test := range tests {
+		info, err := lockfile.Inspect(test.path)
+		if err != nil {
+			t.Fatalf("Inspect(%s) failed: %v", filepath.Base(test.path), err)
+		}
+		if info.Format != test.format {
+			t.Errorf("Inspect(%s): format %q, want %q", filepath.Base(test.path), info.Format, test.format)
+		}
+		pid := 0
+		if info.Metadata != nil {
+			pid = info.Metadata.Holder.PID
+		}
+		if pid != test.pid {
+			t.Errorf("Inspect(%s): pid %d, want %d", filepath.Base(test.path), pid, test.pid)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package lockfile
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ContentionError is returned when a lock file could not be acquired
+// because it is held by someone else. It wraps the underlying error, which
+// is usually an [*os.PathError] that wraps [os.ErrExist], so it is still
+// recognized by [IsTemporary].
+type ContentionError struct {
+	Path string
+
+	// Attempt is the number of the attempt to acquire the lock file that
+	// found it held, starting from 1.
+	Attempt int
+
+	// Holder is the holder recorded in the metadata of the lock file. It is
+	// only read when metadata is enabled with [WithMetadata], and is nil if
+	// it could not be read.
+	Holder *Holder
+
+	Err error
+}
+
+// Error returns a description of the contention, which identifies the
+// holder if it is known.
+func (e *ContentionError) Error() string {
+	if e.Holder == nil {
+		return fmt.Sprintf("lockfile: \"%s\" is held by someone else (attempt %d): %v", e.Path, e.Attempt, e.Err)
+	}
+	h := e.Holder
+	return fmt.Sprintf("lockfile: \"%s\" is held by PID %d on %s (attempt %d): %v", e.Path, h.PID, h.Hostname, e.Attempt, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ContentionError) Unwrap() error {
+	return e.Err
+}
+
+// As sets target to [ReasonContended] if it is a [*ReasonCode].
+func (e *ContentionError) As(target any) bool {
+	return setReason(target, ReasonContended)
+}
+
+// contention wraps err, which reports that the lock file at path is held
+// by someone else, in a [*ContentionError]. The holder is read from the
+// lock file if metadata is enabled.
+func (c *config) contention(path string, err error) error {
+	var ce *ContentionError
+	if errors.As(err, &ce) {
+		return err
+	}
+
+	ce = &ContentionError{Path: path, Attempt: 1, Err: err}
+	if c.metadata {
+		if data, ok, err := c.readLockFile(path); err == nil && ok {
+			if md, err := DecodeMetadata(data, IgnoreUnknownFields); err == nil && md.Holder.PID != 0 {
+				ce.Holder = &md.Holder
+			}
+		}
+	}
+	return ce
+}
@@ -0,0 +1,65 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestContentionError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contended.lock")
+	metadata := lockfile.WithMetadata(nil)
+
+	held, err := lockfile.Create(path, metadata)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer held.Close()
+
+	_, err = lockfile.Create(path, metadata)
+	var ce *lockfile.ContentionError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected a ContentionError, got: %v", err)
+	}
+	if ce.Path != path || ce.Attempt != 1 {
+		t.Errorf("unexpected contention: %+v", ce)
+	}
+	if ce.Holder == nil || ce.Holder.PID != os.Getpid() {
+		t.Errorf("the holder was not identified: %+v", ce.Holder)
+	}
+	if !lockfile.IsTemporary(err) {
+		t.Errorf("the contention is not temporary: %v", err)
+	}
+	var pathErr *os.PathError
+	if !errors.As(err, &pathErr) {
+		t.Errorf("the contention does not wrap an *os.PathError: %v", err)
+	}
+
+	// The holder is only read when metadata is enabled.
+	_, err = lockfile.Create(path)
+	if !errors.As(err, &ce) || ce.Holder != nil {
+		t.Errorf("unexpected contention without metadata: %v", err)
+	}
+}
+
+func TestContentionErrorAttempt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contended.lock")
+	held, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer held.Close()
+
+	_, err = lockfile.WaitCtx(context.Background(), path, lockfile.WithMaxAttempts(3))
+	var ce *lockfile.ContentionError
+	if !errors.Is(err, lockfile.ErrNeverFree) || !errors.As(err, &ce) {
+		t.Fatalf("expected ErrNeverFree with the last contention, got: %v", err)
+	}
+	if ce.Attempt != 3 {
+		t.Fatalf("expected the contention of attempt 3, got attempt %d", ce.Attempt)
+	}
+}
@@ -0,0 +1,118 @@
+// Package core provides the minimal core of the lockfile package: creating
+// lock files, waiting for them and releasing them.
+//
+// It is intended for constrained environments, such as agents embedded in
+// small devices or programs built with TinyGo. It only depends on a few
+// standard library packages, and does not use reflection, so it does not
+// carry the metadata, metrics, watchers and other subsystems of the
+// lockfile package.
+//
+// Lock files created by this package are compatible with those created by
+// the lockfile package with its default options, so the two may be used
+// to contend for the same lock files. On Unix systems, the lockfile
+// package creates and releases its lock files with [Lock] and [Unlink], so
+// the two share the same algorithm.
+package core
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ErrEmptyPath is returned when an empty lock file path is provided.
+var ErrEmptyPath = errors.New("lockfile: an empty path was provided")
+
+// ErrNotEmpty is returned when an existing lock file unexpectedly contains
+// data.
+var ErrNotEmpty = errors.New("lockfile: the lock file is not empty")
+
+// ErrMoved is returned by [File.Close] when the lock file was moved or
+// deleted while it was held.
+var ErrMoved = errors.New("lockfile: the lock file was moved or deleted")
+
+// File is a lock file that has been created and locked. It is released by
+// calling [File.Close].
+type File struct {
+	path  string
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// Path returns the path of the lock file.
+func (f *File) Path() string {
+	return f.path
+}
+
+// Close releases the lock file and deletes it. It returns an error that
+// wraps [os.ErrClosed] if the lock file has already been closed.
+func (f *File) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return &os.PathError{Op: "close", Path: f.path, Err: os.ErrClosed}
+	}
+	return f.release()
+}
+
+// WaitCtx repeatedly calls [Create] with the given path until a lock file is
+// successfully created, a non-temporary error is encountered or the provided
+// context is cancelled.
+func WaitCtx(ctx context.Context, path string) (*File, error) {
+	var timer *time.Timer
+	for attempt := 0; ; attempt++ {
+		file, err := Create(path)
+		if err == nil {
+			if err := ctx.Err(); err != nil {
+				file.Close()
+				return nil, err
+			}
+			return file, nil
+		}
+		if !IsTemporary(err) {
+			return nil, err
+		}
+
+		delay := randomBackoff(attempt)
+		if timer == nil {
+			timer = time.NewTimer(delay)
+			defer timer.Stop()
+		} else {
+			timer.Reset(delay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// IsTemporary returns true if the given error returned by [Create] indicates
+// temporary contention of the lock file.
+func IsTemporary(err error) bool {
+	switch {
+	case errors.Is(err, os.ErrExist):
+		return true
+	case errors.Is(err, os.ErrPermission):
+		// On Windows, os.ErrPermission can be returned by Create if a
+		// previous lock file is in the process of being deleted.
+		return runtime.GOOS == "windows"
+	}
+	return false
+}
+
+// randomBackoff returns a random backoff time betwen 0 and 1 second.
+func randomBackoff(attempt int) time.Duration {
+	if attempt > 99 {
+		attempt = 99
+	}
+	milliseconds := rand.IntN((1 + attempt) * 10)
+	return time.Millisecond * time.Duration(milliseconds)
+}
@@ -0,0 +1,83 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+	"github.com/gentlemanautomaton/lockfile/core"
+)
+
+func TestCreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "core.lock")
+
+	file, err := core.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := core.Create(path); !core.IsTemporary(err) {
+		t.Fatalf("expected contention, got: %v", err)
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := file.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("expected os.ErrClosed from a second Close, got: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the lock file was not removed: %v", err)
+	}
+}
+
+func TestWaitCtx(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "core.lock")
+
+	file, err := core.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(50*time.Millisecond, func() { file.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	waiter, err := core.WaitCtx(ctx, path)
+	if err != nil {
+		t.Fatalf("WaitCtx failed: %v", err)
+	}
+	defer waiter.Close()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := core.WaitCtx(ctx, path); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected WaitCtx to time out, got: %v", err)
+	}
+}
+
+func TestCompatible(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "core.lock")
+
+	file, err := core.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockfile.Create(path); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected the lockfile package to see contention, got: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if _, err := core.Create(path); !core.IsTemporary(err) {
+		t.Fatalf("expected the core package to see contention, got: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+//go:build unix && !aix && !solaris
+
+package core
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Syscalls is the set of system calls through which lock files are created
+// and released by [Lock] and [Unlink].
+//
+// The lockfile package provides its own implementation, so that it can
+// route these calls through its hooks and timeouts while sharing the same
+// algorithm.
+type Syscalls interface {
+	Open(path string, flag int, perm uint32) (fd int, err error)
+	Flock(path string, fd int, how int) error
+	Fstat(path string, fd int) (syscall.Stat_t, error)
+	Stat(path string) (syscall.Stat_t, error)
+	Unlink(path string) error
+	Close(path string, fd int) error
+}
+
+// direct makes system calls directly.
+type direct struct{}
+
+func (direct) Open(path string, flag int, perm uint32) (int, error) {
+	return syscall.Open(path, flag|syscall.O_CLOEXEC, perm)
+}
+
+func (direct) Flock(path string, fd int, how int) error {
+	return syscall.Flock(fd, how)
+}
+
+func (direct) Fstat(path string, fd int) (stat syscall.Stat_t, err error) {
+	err = syscall.Fstat(fd, &stat)
+	return stat, err
+}
+
+func (direct) Stat(path string) (stat syscall.Stat_t, err error) {
+	err = syscall.Stat(path, &stat)
+	return stat, err
+}
+
+func (direct) Unlink(path string) error {
+	return syscall.Unlink(path)
+}
+
+func (direct) Close(path string, fd int) error {
+	return syscall.Close(fd)
+}
+
+// Create attempts to create a lock file with the given path, and locks it
+// with the flock system call.
+//
+// If the lock file already exists and is locked, it returns an
+// [*os.PathError] that wraps [os.ErrExist].
+func Create(path string) (*File, error) {
+	if path == "" {
+		return nil, ErrEmptyPath
+	}
+
+	sys := direct{}
+	fd, stat, err := Lock(sys, path, syscall.O_RDONLY|syscall.O_CREAT, 0400, syscall.LOCK_EX)
+	if err != nil {
+		return nil, err
+	}
+	if stat.Size != 0 {
+		sys.Close(path, fd)
+		return nil, &os.PathError{Op: "open", Path: path, Err: ErrNotEmpty}
+	}
+
+	return &File{path: path, file: os.NewFile(uintptr(fd), path)}, nil
+}
+
+// Lock opens the lock file with the given path, creating it if necessary,
+// and locks it with the given flock operation, which must not block. It
+// returns the descriptor of the lock file and its status once it has been
+// locked. The caller is responsible for checking that the lock file is
+// empty, and for closing the descriptor.
+//
+// If the lock file is already locked, it returns an [*os.PathError] that
+// wraps [os.ErrExist]. Errors returned by sys are wrapped in an
+// [*os.PathError] if they are [syscall.Errno] values, and returned as-is
+// otherwise.
+func Lock(sys Syscalls, path string, flag int, perm uint32, how int) (fd int, stat syscall.Stat_t, err error) {
+	for {
+		// Create the lock file if it doesn't exist.
+		//
+		// Note that we could race with another process here, so this might
+		// open a lock file that was created by another process.
+		fd, err := sys.Open(path, flag, perm)
+		if err != nil {
+			return -1, stat, pathError("open", path, err)
+		}
+
+		// If we get a [syscall.EWOULDBLOCK] error, it means that someone
+		// else got the lock before we did. In that case, they will be
+		// responsible for deleting the file when they are done with it.
+		if err := sys.Flock(path, fd, how|syscall.LOCK_NB); err != nil {
+			sys.Close(path, fd)
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				return -1, stat, &os.PathError{Op: "flock", Path: path, Err: os.ErrExist}
+			}
+			return -1, stat, pathError("flock", path, err)
+		}
+
+		stat, err = sys.Fstat(path, fd)
+		if err != nil {
+			sys.Close(path, fd)
+			return -1, stat, pathError("stat", path, err)
+		}
+
+		// The lock file may have been deleted by its previous holder
+		// between our open and flock calls, in which case we start over.
+		if stat.Nlink == 0 {
+			sys.Close(path, fd)
+			continue
+		}
+
+		return fd, stat, nil
+	}
+}
+
+// Unlink deletes the lock file with the given path, which is open and
+// locked as fd, if it is still at its path. It does not close fd, which
+// must remain open until the lock file has been deleted.
+//
+// If some other file is at the path, it returns an [*os.PathError] that
+// wraps [ErrMoved]. Errors returned by sys are wrapped in the same way as
+// they are by [Lock].
+func Unlink(sys Syscalls, path string, fd int) error {
+	stat1, err := sys.Fstat(path, fd)
+	if err != nil {
+		return pathError("stat", path, err)
+	}
+	stat2, err := sys.Stat(path)
+	if err != nil {
+		return pathError("stat", path, err)
+	}
+	if stat1.Dev != stat2.Dev || stat1.Ino != stat2.Ino {
+		return &os.PathError{Op: "unlink", Path: path, Err: ErrMoved}
+	}
+
+	if err := sys.Unlink(path); err != nil {
+		return pathError("unlink", path, err)
+	}
+	return nil
+}
+
+// release deletes the lock file if it is still at its path, and closes it,
+// which releases the lock. The caller must hold f.mutex.
+func (f *File) release() (err error) {
+	defer func() {
+		closeErr := f.file.Close()
+		f.file = nil
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	return Unlink(direct{}, f.path, int(f.file.Fd()))
+}
+
+// pathError wraps err in an [*os.PathError] if it is a [syscall.Errno].
+// Other errors already describe the operation that failed.
+func pathError(op, path string, err error) error {
+	if errno, ok := err.(syscall.Errno); ok {
+		return &os.PathError{Op: op, Path: path, Err: errno}
+	}
+	return err
+}
@@ -0,0 +1,45 @@
+//go:build windows
+
+package core
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	fileAttributeTemporary = 0x00000100
+	fileFlagDeleteOnClose  = 0x04000000
+)
+
+// Create attempts to create a lock file with the given path. The lock file
+// is opened without sharing, and is deleted when it is closed.
+//
+// If the lock file already exists, it returns an [*os.PathError] that wraps
+// an error satisfying [os.ErrExist]. If it exists but is marked for
+// deletion, the error satisfies [os.ErrPermission] instead.
+func Create(path string) (*File, error) {
+	if path == "" {
+		return nil, ErrEmptyPath
+	}
+
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	handle, err := syscall.CreateFile(name, syscall.GENERIC_READ, 0, nil, syscall.CREATE_NEW, fileAttributeTemporary|fileFlagDeleteOnClose, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	return &File{path: path, file: os.NewFile(uintptr(handle), path)}, nil
+}
+
+// release closes the lock file, which causes it to be deleted. The caller
+// must hold f.mutex.
+func (f *File) release() error {
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
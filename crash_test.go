@@ -0,0 +1,69 @@
+package lockfile_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+	"github.com/gentlemanautomaton/lockfile/lockfiletest"
+)
+
+func TestCrashRelease(t *testing.T) {
+	lockfiletest.HoldInChild(t, func(path string) error {
+		_, err := lockfile.Create(path)
+		return err
+	})
+
+	path := filepath.Join(t.TempDir(), "crash.lock")
+	holder := lockfiletest.StartHolder(t, path)
+	if _, err := lockfile.Create(path); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected contention while the holder is running, got: %v", err)
+	}
+	if err := holder.Kill(); err != nil {
+		t.Fatalf("failed to kill the holder: %v", err)
+	}
+
+	// The file is deleted on close on Windows, while on Linux it is left
+	// behind but no longer locked.
+	if runtime.GOOS == "windows" {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("the lock file of the crashed holder was not deleted: %v", err)
+		}
+	}
+
+	file, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("the lock of the crashed holder was not released: %v", err)
+	}
+	file.Close()
+}
+
+func TestCrashSoftLock(t *testing.T) {
+	soft := lockfile.WithSoftLock(500 * time.Millisecond)
+	lockfiletest.HoldInChild(t, func(path string) error {
+		_, err := lockfile.Create(path, soft)
+		return err
+	})
+
+	path := filepath.Join(t.TempDir(), "crash.lock")
+	if err := lockfiletest.StartHolder(t, path).Kill(); err != nil {
+		t.Fatalf("failed to kill the holder: %v", err)
+	}
+
+	// A soft lock file outlives its holder until it becomes stale.
+	if _, err := lockfile.Create(path, soft); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected contention before the lock file became stale, got: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	file, err := lockfile.WaitCtx(ctx, path, soft)
+	if err != nil {
+		t.Fatalf("the stale lock file of the crashed holder was not broken: %v", err)
+	}
+	file.Close()
+}
@@ -0,0 +1,188 @@
+package lockfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// dotlockStaleAge is the age after which a dotlock is stale, regardless of
+// its holder. It matches liblockfile.
+const dotlockStaleAge = 5 * time.Minute
+
+// dotlockTouchInterval is the interval at which a held dotlock is touched,
+// so that it does not become stale. It matches the recommendation of
+// liblockfile.
+const dotlockTouchInterval = time.Minute
+
+// DotlockBackend is a [Backend] that follows the dotlock convention of
+// liblockfile, dotlockfile and the mail spools that use them. It allows Go
+// programs to share lock files with existing shell scripts and C programs.
+//
+// A lock is held while a lock file exists at its path. The lock file is
+// created exclusively, with O_CREAT|O_EXCL, and contains the process ID of
+// its holder in decimal, followed by a newline.
+//
+// The operating system does not release the lock if its holder crashes, so
+// the staleness rules of liblockfile apply. A lock file is stale if the
+// process whose ID it contains no longer exists, or if it has not been
+// modified for five minutes. The modification time of a held lock file is
+// updated every minute, as liblockfile recommends, so that it never becomes
+// stale while its holder is running. A stale lock file is removed by the
+// next process that attempts to acquire it. A holder whose lock file was
+// removed receives an error that wraps [ErrMoved] when it releases the
+// lock.
+//
+// As in liblockfile, the process ID is checked on the current host, even if
+// the lock file was created by a process on another host that shares the
+// filesystem. Such a lock file is only protected by being touched.
+//
+// liblockfile has no notion of shared locks, so neither does the backend.
+// The lock file is closed as soon as the process ID has been written, so
+// features that read or write the lock file are not available.
+type DotlockBackend struct{}
+
+// NewDotlockBackend returns a [DotlockBackend].
+func NewDotlockBackend() *DotlockBackend {
+	return &DotlockBackend{}
+}
+
+// dotlock is the state of a dotlock that is held.
+type dotlock struct {
+	info os.FileInfo   // The lock file that was created
+	stop chan struct{} // Closed to stop touching the lock file
+	done sync.WaitGroup
+}
+
+// TryAcquire makes a single attempt to create the lock file with the given
+// path, removing it first if it is stale. It returns an [*os.PathError]
+// that wraps [os.ErrExist] if the lock is held by someone else.
+func (b *DotlockBackend) TryAcquire(path string, shared bool) (BackendLock, error) {
+	if shared {
+		return BackendLock{}, &os.PathError{Op: "acquire", Path: path, Err: fmt.Errorf("%w: the dotlock backend does not support shared locks", ErrInvalidOption)}
+	}
+
+	return tryAcquireOrBreak(path, func() (BackendLock, error) {
+		lock, err := createDotlock(path)
+		if err != nil {
+			return BackendLock{}, err
+		}
+		lock.done.Add(1)
+		go lock.touch(path)
+		return BackendLock{Value: lock}, nil
+	}, b.stale, b.ReadLock)
+}
+
+// Release stops touching the lock file with the given path and removes it.
+// It returns an [*os.PathError] that wraps [ErrMoved] if the lock file was
+// removed or replaced by someone else while it was held, in which case it
+// is left alone.
+func (b *DotlockBackend) Release(path string, lock BackendLock) error {
+	held, ok := lock.Value.(*dotlock)
+	if !ok {
+		return &os.PathError{Op: "release", Path: path, Err: ErrNotHeld}
+	}
+	close(held.stop)
+	held.done.Wait()
+
+	info, err := os.Stat(path)
+	if err != nil || !os.SameFile(info, held.info) {
+		return &os.PathError{Op: "release", Path: path, Err: ErrMoved}
+	}
+	return os.Remove(path)
+}
+
+// Guarantees returns the guarantees provided by the locks of the backend.
+// The operating system does not release them, and they are broken once
+// they have not been touched for five minutes.
+func (b *DotlockBackend) Guarantees(shared bool) Guarantees {
+	return Guarantees{
+		Exclusive:  !shared,
+		StaleAfter: dotlockStaleAge,
+	}
+}
+
+// Probe reports whether the lock file with the given path exists, and
+// whether it is held. A stale lock file exists but is not held.
+func (b *DotlockBackend) Probe(path string) (exists, held bool, err error) {
+	_, err = os.Stat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return false, false, nil
+	case err != nil:
+		return false, false, err
+	}
+	_, stale := b.stale(path)
+	return true, !stale, nil
+}
+
+// ReadLock returns the contents of the lock file with the given path.
+func (b *DotlockBackend) ReadLock(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+// stale returns true if the lock file at path is stale according to the
+// rules of liblockfile. It also returns the contents of the lock file that
+// the decision was based on.
+func (b *DotlockBackend) stale(path string) ([]byte, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	if pid, err := strconv.Atoi(string(bytes.TrimSpace(data))); err == nil && pid > 0 && !processAlive(pid) {
+		return data, true
+	}
+
+	info, err := os.Stat(path)
+	return data, err == nil && time.Since(info.ModTime()) > dotlockStaleAge
+}
+
+// createDotlock exclusively creates a lock file at path that contains the
+// process ID of the current process.
+func createDotlock(path string) (*dotlock, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = fmt.Fprintf(file, "%d\n", os.Getpid())
+	var info os.FileInfo
+	if err == nil {
+		info, err = file.Stat()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	return &dotlock{info: info, stop: make(chan struct{})}, nil
+}
+
+// touch updates the modification time of the lock file at path every
+// dotlockTouchInterval, until the lock is released.
+func (l *dotlock) touch(path string) {
+	defer l.done.Done()
+
+	ticker := time.NewTicker(dotlockTouchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			// A lock file that was replaced belongs to someone else.
+			if info, err := os.Stat(path); err == nil && os.SameFile(info, l.info) {
+				now := time.Now()
+				os.Chtimes(path, now, now)
+			}
+		}
+	}
+}
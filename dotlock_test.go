@@ -0,0 +1,84 @@
+package lockfile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestDotlockBackend(t *testing.T) {
+	backend := lockfile.WithBackend(lockfile.NewDotlockBackend())
+	path := filepath.Join(t.TempDir(), "mbox.lock")
+
+	file, err := lockfile.Create(path, backend)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if g := file.Guarantees(); !g.Exclusive || g.KernelLock || g.StaleAfter != 5*time.Minute {
+		t.Fatalf("unexpected guarantees: %+v", g)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != strconv.Itoa(os.Getpid())+"\n" {
+		t.Fatalf("the lock file does not contain the process ID: %q, %v", data, err)
+	}
+	if _, err := lockfile.Create(path, backend); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected ErrExist while the lock is held, got: %v", err)
+	}
+
+	info, err := lockfile.Inspect(path, backend)
+	if err != nil || !info.Held || info.Format != lockfile.FormatPID {
+		t.Fatalf("expected Inspect to report a held dotlock, got %+v, %v", info, err)
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the lock file was not removed: %v", err)
+	}
+}
+
+func TestDotlockBackendStale(t *testing.T) {
+	backend := lockfile.WithBackend(lockfile.NewDotlockBackend())
+	dir := t.TempDir()
+	old := time.Now().Add(-10 * time.Minute)
+
+	tests := []struct {
+		name     string
+		contents string
+		modified time.Time
+		stale    bool
+	}{
+		{"dead", "4194303\n", time.Now(), true},
+		{"alive", strconv.Itoa(os.Getpid()) + "\n", time.Now(), false},
+		{"recent", "0\n", time.Now(), false},
+		{"old", "0\n", old, true},
+		{"old-alive", strconv.Itoa(os.Getpid()) + "\n", old, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, test.name+".lock")
+			if err := os.WriteFile(path, []byte(test.contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(path, test.modified, test.modified); err != nil {
+				t.Fatal(err)
+			}
+
+			file, err := lockfile.Create(path, backend)
+			switch {
+			case test.stale && err != nil:
+				t.Fatalf("Create did not remove the stale lock file: %v", err)
+			case !test.stale && !errors.Is(err, os.ErrExist):
+				t.Fatalf("expected ErrExist for a lock file that is not stale, got: %v", err)
+			case err == nil:
+				file.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package lockfile
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxStackDepth is the largest number of frames recorded in the call stack
+// that acquired a lock file.
+const maxStackDepth = 32
+
+// Straggler describes a lock file that was still held by a [Manager] when
+// [Manager.Drain] gave up waiting for it to be released.
+type Straggler struct {
+	Path     string
+	Acquired time.Time
+
+	// Stack is the call stack that acquired the lock file, formatted with
+	// one function per line followed by its file and line number, like a
+	// goroutine in a panic.
+	Stack string
+}
+
+// DrainError is returned by [Manager.Drain] when its context is done before
+// every lock file held by the manager has been released. It wraps the error
+// of the context.
+type DrainError struct {
+	Stragglers []Straggler
+	Err        error
+}
+
+// Error returns a description of the lock files that are still held.
+func (e *DrainError) Error() string {
+	paths := make([]string, len(e.Stragglers))
+	for i, s := range e.Stragglers {
+		paths[i] = s.Path
+	}
+	return fmt.Sprintf("lockfile: %d lock files are still held: %s: %v", len(paths), strings.Join(paths, ", "), e.Err)
+}
+
+// Unwrap returns the error of the context.
+func (e *DrainError) Unwrap() error {
+	return e.Err
+}
+
+// As sets target to the reason code of the error of the context if it is a
+// [*ReasonCode].
+func (e *DrainError) As(target any) bool {
+	return setReason(target, Reason(e.Err))
+}
+
+// Drain waits until every lock file held by the manager has been released,
+// or ctx is done. It allows a service that is shutting down to let the work
+// that holds locks finish before it exits. Drain does not stop new lock
+// files from being acquired, so it is usually preceded by [Manager.Freeze].
+//
+// If ctx is done first, it returns a [*DrainError] that lists the lock
+// files that are still held, in the order they were acquired, along with
+// the call stacks that acquired them. They remain held, and may be released
+// with [Manager.CloseAll].
+func (m *Manager) Drain(ctx context.Context) error {
+	m.mutex.Lock()
+	if len(m.handles) == 0 {
+		m.mutex.Unlock()
+		return nil
+	}
+	if m.drained == nil {
+		m.drained = make(chan struct{})
+	}
+	drained := m.drained
+	m.mutex.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if len(m.handles) == 0 {
+		return nil
+	}
+
+	stragglers := make([]Straggler, 0, len(m.handles))
+	for h := range m.handles {
+		stragglers = append(stragglers, Straggler{
+			Path:     h.path,
+			Acquired: h.stats.Acquired,
+			Stack:    formatStack(h.stack),
+		})
+	}
+	slices.SortFunc(stragglers, func(a, b Straggler) int {
+		return a.Acquired.Compare(b.Acquired)
+	})
+
+	return &DrainError{Stragglers: stragglers, Err: ctx.Err()}
+}
+
+// callers returns the call stack of the caller of the function that acquired
+// a lock file on behalf of a manager, skipping the frames of the manager.
+func callers() []uintptr {
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(4, pcs[:])
+	return slices.Clone(pcs[:n])
+}
+
+// formatStack formats a call stack recorded by callers.
+func formatStack(pcs []uintptr) string {
+	if len(pcs) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
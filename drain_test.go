@@ -0,0 +1,59 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestManagerDrain(t *testing.T) {
+	m := lockfile.NewManager()
+	dir := t.TempDir()
+
+	if err := m.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain failed without any lock files: %v", err)
+	}
+
+	released, err := m.Create(filepath.Join(dir, "released.lock"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	straggler, err := m.Create(filepath.Join(dir, "straggler.lock"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	time.AfterFunc(time.Millisecond*20, func() { released.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+
+	err = m.Drain(ctx)
+	var drainErr *lockfile.DrainError
+	if !errors.As(err, &drainErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Drain returned %v, expected a DrainError", err)
+	}
+	if lockfile.Reason(err) != lockfile.ReasonTimeout {
+		t.Errorf("Drain returned an error with reason %s", lockfile.Reason(err))
+	}
+	if len(drainErr.Stragglers) != 1 {
+		t.Fatalf("Drain reported %d stragglers, expected 1", len(drainErr.Stragglers))
+	}
+	s := drainErr.Stragglers[0]
+	if s.Path != straggler.Path() {
+		t.Errorf("Drain reported %s as a straggler, expected %s", s.Path, straggler.Path())
+	}
+	if !strings.Contains(s.Stack, "TestManagerDrain") {
+		t.Errorf("the stack of the straggler does not include the test:\n%s", s.Stack)
+	}
+
+	time.AfterFunc(time.Millisecond*20, func() { straggler.Close() })
+	if err := m.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package lockfile
+
+import "sync"
+
+// Action describes a destructive maintenance action on a lock file, such as
+// removing a lock file that was left behind.
+type Action struct {
+	Op     string // The action, such as "remove"
+	Path   string // The path of the lock file
+	Reason string // Why the action is needed
+}
+
+// Plan collects the actions that maintenance operations would have taken,
+// when they are run in dry-run mode with [WithDryRun].
+//
+// A Plan is safe for concurrent use.
+type Plan struct {
+	mutex   sync.Mutex
+	actions []Action
+}
+
+// WithDryRun returns an option that puts destructive maintenance
+// operations, such as [Manager.Reconcile] with [ReconcileBreak], in dry-run
+// mode. Instead of removing or breaking lock files, they record the actions
+// they would have taken in plan, so that operators can preview them.
+//
+// Lock files are still inspected in dry-run mode, but they are never
+// created, acquired or removed. It does not affect the acquisition of lock
+// files by [Create] and related functions.
+func WithDryRun(plan *Plan) Option {
+	return func(c *config) {
+		c.dryRun = plan
+	}
+}
+
+// Actions returns the actions that have been recorded in the plan, in the
+// order they were recorded.
+func (p *Plan) Actions() []Action {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	actions := make([]Action, len(p.actions))
+	copy(actions, p.actions)
+	return actions
+}
+
+// record adds an action to the plan.
+func (p *Plan) record(action Action) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.actions = append(p.actions, action)
+}
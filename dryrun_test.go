@@ -0,0 +1,57 @@
+package lockfile_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestReconcileDryRun(t *testing.T) {
+	dir := t.TempDir()
+	snapshot := filepath.Join(dir, "locks.json")
+	abandoned, held := filepath.Join(dir, "abandoned.lock"), filepath.Join(dir, "held.lock")
+
+	before := lockfile.NewManager()
+	for _, path := range []string{abandoned, held} {
+		if _, err := before.Create(path); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+	if err := before.Snapshot(snapshot); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	before.CloseAll()
+
+	// Leave an abandoned lock file behind, and hold the other one.
+	if err := os.WriteFile(abandoned, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	other, err := lockfile.Create(held)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer other.Close()
+
+	var plan lockfile.Plan
+	after := lockfile.NewManager(lockfile.WithDryRun(&plan))
+	results, err := after.Reconcile(context.Background(), snapshot, lockfile.ReconcileBreak)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	actions := plan.Actions()
+	if len(actions) != 1 || actions[0].Op != "remove" || actions[0].Path != abandoned {
+		t.Fatalf("unexpected plan: %+v", actions)
+	}
+
+	// Nothing may have been removed.
+	if _, err := os.Stat(abandoned); err != nil {
+		t.Fatalf("the abandoned lock file was removed during a dry run: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package lockfile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestDup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dup.lock")
+
+	first, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create lock file: %v", err)
+	}
+
+	second, err := first.Dup()
+	if err != nil {
+		t.Fatalf("failed to duplicate lock file: %v", err)
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatalf("failed to close first reference: %v", err)
+	}
+	if err := first.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("closing the first reference twice returned: %v", err)
+	}
+	if _, err := first.Dup(); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("duplicating a closed reference returned: %v", err)
+	}
+
+	// The lock should still be held by the second reference.
+	if _, err := lockfile.Create(path); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected contention while a reference remains, got: %v", err)
+	}
+
+	if err := second.Close(); err != nil {
+		t.Fatalf("failed to close second reference: %v", err)
+	}
+
+	lock, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("lock was not released after all references were closed: %v", err)
+	}
+	lock.Close()
+}
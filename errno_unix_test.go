@@ -0,0 +1,11 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile_test
+
+import "syscall"
+
+// System errors used to simulate filesystem conditions.
+var (
+	readOnlyErr error = syscall.EROFS
+	noSpaceErr  error = syscall.ENOSPC
+)
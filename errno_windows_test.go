@@ -0,0 +1,11 @@
+//go:build windows
+
+package lockfile_test
+
+import "syscall"
+
+// System errors used to simulate filesystem conditions.
+var (
+	readOnlyErr error = syscall.Errno(19)  // ERROR_WRITE_PROTECT
+	noSpaceErr  error = syscall.Errno(112) // ERROR_DISK_FULL
+)
@@ -1,17 +1,197 @@
 package lockfile
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"runtime"
+	"syscall"
+	"time"
+)
+
+// Errors that are reported by this package. They are usually wrapped in an
+// [*os.PathError] that records the path of the lock file, so that they can
+// be matched with [errors.Is] and the path can be retrieved with
+// [errors.As] instead of being parsed from the error text.
+//
+// The text of these errors is stable and may be used as a key when
+// translating them. Each of them also carries a [ReasonCode].
+var (
+	// ErrEmptyPath is returned when an empty lock file path is provided.
+	ErrEmptyPath = newError(ReasonInvalid, "lockfile: an empty path was provided")
+
+	// ErrDirectoryPath is returned when a lock file path refers to a
+	// directory.
+	ErrDirectoryPath = newError(ReasonInvalid, "lockfile: the path refers to a directory")
+
+	// ErrNotEmpty is returned when an existing lock file unexpectedly
+	// contains data.
+	ErrNotEmpty = newError(ReasonTampered, "lockfile: the lock file is not empty")
+
+	// ErrMoved is returned when a lock file could not be deleted because it
+	// was moved or deleted by someone else while it was held.
+	ErrMoved = newError(ReasonStale, "lockfile: the lock file was moved or deleted")
+
+	// ErrUnreliableFilesystem is reported when a lock file is located on a
+	// filesystem that does not reliably support file locking.
+	ErrUnreliableFilesystem = newError(ReasonUnsafeFilesystem, "lockfile: the filesystem does not reliably support file locking")
+
+	// ErrReadOnlyFilesystem is returned when a lock file cannot be created
+	// because its filesystem is read-only.
+	ErrReadOnlyFilesystem = newError(ReasonPermissionDenied, "lockfile: the filesystem is read-only")
+
+	// ErrInsufficientSpace is returned when the filesystem containing a
+	// lock file does not have the free space or inodes required by
+	// [WithMinFree].
+	ErrInsufficientSpace = newError(ReasonExhausted, "lockfile: insufficient free space")
+
+	// ErrNoSpace is returned when a lock file cannot be created because its
+	// filesystem is full.
+	ErrNoSpace = newError(ReasonExhausted, "lockfile: no space left on the filesystem")
+
+	// ErrQuotaExceeded is returned when a lock file cannot be created
+	// because the disk quota of the user has been exhausted.
+	ErrQuotaExceeded = newError(ReasonExhausted, "lockfile: disk quota exceeded")
+
+	// ErrInvalidOption is returned when an option has an invalid value.
+	ErrInvalidOption = newError(ReasonInvalid, "lockfile: invalid option")
+
+	// ErrFilesystemHang is reported when an operating system operation on a
+	// lock file does not complete within the timeout configured by
+	// [WithOpTimeout].
+	ErrFilesystemHang = newError(ReasonTimeout, "lockfile: filesystem operation did not complete in time")
+
+	// ErrNotHeld is returned when a lock is released by a caller that does
+	// not hold it.
+	ErrNotHeld = newError(ReasonNotHeld, "lockfile: the lock is not held")
+
+	// ErrAlreadyHeld is returned when a lock is acquired by a caller that
+	// already holds it.
+	ErrAlreadyHeld = newError(ReasonInvalid, "lockfile: the lock is already held")
+
+	// ErrNotShared is returned by [Inherited] when a lock file was not
+	// shared with the current process by its parent.
+	ErrNotShared = newError(ReasonNotHeld, "lockfile: the lock was not shared with this process")
+
+	// ErrFDStoreUnavailable is returned when lock files cannot be stored
+	// because the process was not started by systemd with a notification
+	// socket.
+	ErrFDStoreUnavailable = newError(ReasonUnsupported, "lockfile: the systemd file descriptor store is not available")
+
+	// ErrRetryBudgetExhausted is returned when waiting for a lock file
+	// stops because the budget of consecutive temporary errors configured
+	// by [NewClassifier] has been exhausted.
+	ErrRetryBudgetExhausted = newError(ReasonExhausted, "lockfile: too many consecutive temporary errors")
+
+	// ErrInvalidMetadata is returned when the metadata of a lock file
+	// cannot be parsed.
+	ErrInvalidMetadata = newError(ReasonTampered, "lockfile: invalid lock file metadata")
+
+	// ErrMetadataUnwritable is reported to the Warning hook when
+	// [WithMetadata] is configured, but metadata cannot be written to the
+	// lock file.
+	ErrMetadataUnwritable = newError(ReasonPermissionDenied, "lockfile: unable to write metadata to the lock file")
+
+	// ErrMetadataVersion is returned when the metadata of a lock file has a
+	// version that is not supported.
+	ErrMetadataVersion = newError(ReasonUnsupported, "lockfile: unsupported lock file metadata version")
+
+	// ErrExclusionFailed is returned by [VerifyExclusionWithChild] when lock
+	// files do not provide mutual exclusion between processes.
+	ErrExclusionFailed = newError(ReasonUnsafeFilesystem, "lockfile: lock files do not provide mutual exclusion")
+
+	// ErrNoLongerNeeded is returned when a lock file was acquired, but the
+	// check provided by [WithStillNeeded] reported that the work it guards
+	// is no longer needed.
+	ErrNoLongerNeeded = newError(ReasonRefused, "lockfile: the lock is no longer needed")
+
+	// ErrSiblingLock is reported by [WithSiblingCheck] when a lock file
+	// that follows a different naming convention for the same resource is
+	// in use.
+	ErrSiblingLock = newError(ReasonContended, "lockfile: a sibling lock file for the same resource is in use")
+
+	// ErrSharedUnsupported is returned by [CreateShared] when a shared lock
+	// is requested for a soft lock file.
+	ErrSharedUnsupported = newError(ReasonUnsupported, "lockfile: shared locks are not supported for soft lock files")
+
+	// ErrRangeLocksUnsupported is returned by [OpenRWLock] on platforms
+	// without byte-range locks that belong to the open file.
+	ErrRangeLocksUnsupported = newError(ReasonUnsupported, "lockfile: byte-range locks are not supported on this platform")
+
+	// ErrTooManyLocks is reported by a [*QuotaError] when a [Manager] holds
+	// as many lock files in a directory as [WithDirQuota] allows.
+	ErrTooManyLocks = newError(ReasonExhausted, "lockfile: too many lock files in the directory")
+
+	// ErrOutsideWindow is returned by [Create] when the schedule configured
+	// by [WithSchedule] does not allow the lock file to be acquired at the
+	// current time.
+	ErrOutsideWindow = newError(ReasonRefused, "lockfile: the lock file may not be acquired outside of its schedule")
+
+	// ErrPreconditionFailed is returned when the check configured by
+	// [WithPrecondition] fails.
+	ErrPreconditionFailed = newError(ReasonRefused, "lockfile: the precondition for acquiring the lock file failed")
+
+	// ErrNotStale is returned by [Break] and [StealCtx] when a lock file
+	// could not be verified to be stale.
+	ErrNotStale = newError(ReasonContended, "lockfile: the lock file is not stale")
+
+	// ErrDryRun is returned by operations that would have acquired a lock
+	// file, when they are run in dry-run mode with [WithDryRun].
+	ErrDryRun = newError(ReasonRefused, "lockfile: the operation was recorded in a dry-run plan")
+
+	// ErrEvictedFromQueue is returned by a waiter whose ticket was removed
+	// from the queue for a lock file by [CancelWaiter].
+	ErrEvictedFromQueue = newError(ReasonCancelled, "lockfile: the waiter was evicted from the queue")
+
+	// ErrRateLimited is returned by Create when the [RateLimit] middleware
+	// does not allow a lock file to be acquired at the current time.
+	ErrRateLimited = newError(ReasonRefused, "lockfile: the acquisition was rate limited")
+
+	// ErrCompromised is reported by [WithLinkMonitor] when a held lock
+	// file was removed, linked under another name or replaced.
+	ErrCompromised = newError(ReasonTampered, "lockfile: the lock file was compromised while it was held")
+
+	// ErrIdle is reported by [WithIdleDetection] when a lock file has been
+	// held for a long time without any activity.
+	ErrIdle = newError(ReasonStale, "lockfile: the lock file is held without any activity")
+
+	// ErrFrozen is returned by a [Manager] that does not acquire lock
+	// files because it has been frozen by [Manager.Freeze].
+	ErrFrozen = newError(ReasonRefused, "lockfile: the manager is frozen")
+
+	// ErrThunderingHerd is reported by [WithHerdControl] when many
+	// goroutines within the process are waiting for the same lock file.
+	ErrThunderingHerd = newError(ReasonContended, "lockfile: too many waiters in the process for the same lock file")
+
+	// ErrNeverFree is returned when waiting for a lock file stops because
+	// of [WithMaxAttempts] or [WithWaitBudget], and the lock file was held
+	// by someone else on every attempt to acquire it.
+	ErrNeverFree = newError(ReasonContended, "lockfile: the lock file was never free")
+
+	// ErrNameTooLong is returned by a [Manager] with a root directory when
+	// the escaped name of a lock file is too long to be used as a file name.
+	ErrNameTooLong = newError(ReasonInvalid, "lockfile: the name of the lock file is too long")
+
+	// ErrPathEscape is returned by a [Mapper] when a lock name would be
+	// mapped to a path outside of the directory it is placed in.
+	ErrPathEscape = newError(ReasonInvalid, "lockfile: the name escapes the directory it is mapped to")
+
+	// ErrGroupLimit is returned by [AcquireGroup] when its group declines
+	// to run a waiter for every lock file, because it limits the number of
+	// goroutines it runs.
+	ErrGroupLimit = newError(ReasonInvalid, "lockfile: the group cannot run a waiter for every lock file")
 )
 
 // IsTemporary returns true if the given error returned by [Create] indicates
 // temporary contention of the lock file.
+//
+// Errors are matched with [errors.Is], so errors that wrap [os.ErrExist],
+// such as an [*os.PathError], are recognized.
 func IsTemporary(err error) bool {
-	switch err {
-	case os.ErrExist:
+	switch {
+	case errors.Is(err, os.ErrExist):
 		return true
-	case os.ErrPermission:
+	case errors.Is(err, os.ErrPermission):
 		if runtime.GOOS == "windows" {
 			// On Windows, os.ErrPermission can be returned by Create if a
 			// previous lock file is in the process of being deleted.
@@ -21,3 +201,39 @@ func IsTemporary(err error) bool {
 	}
 	return false
 }
+
+// HangError records an operating system operation that did not complete
+// within the configured timeout.
+//
+// The operation is abandoned, but the goroutine performing it may remain
+// blocked in the kernel until the filesystem recovers.
+type HangError struct {
+	Op      Op
+	Path    string
+	Timeout time.Duration
+}
+
+// Error returns a description of the hung operation.
+func (e *HangError) Error() string {
+	return fmt.Sprintf("lockfile: %s \"%s\" did not complete within %s", e.Op, e.Path, e.Timeout)
+}
+
+// Is returns true if target is [ErrFilesystemHang].
+func (e *HangError) Is(target error) bool {
+	return target == ErrFilesystemHang
+}
+
+// As sets target to [ReasonTimeout] if it is a [*ReasonCode].
+func (e *HangError) As(target any) bool {
+	return setReason(target, ReasonTimeout)
+}
+
+// pathError wraps err in an [*os.PathError] if it is a raw system error
+// code, so that it records the operation and path that failed. Other
+// errors, which already describe the failure, are returned unchanged.
+func pathError(op, path string, err error) error {
+	if errno, ok := err.(syscall.Errno); ok {
+		return &os.PathError{Op: op, Path: path, Err: errno}
+	}
+	return err
+}
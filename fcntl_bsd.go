@@ -0,0 +1,15 @@
+//go:build darwin || dragonfly || freebsd || openbsd
+
+package lockfile
+
+import "syscall"
+
+// fcntl performs a byte-range lock command on the file that is open as fd.
+func fcntl(fd int, cmd int, lk *syscall.Flock_t) error {
+	for {
+		err := syscall.FcntlFlock(uintptr(fd), cmd, lk)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
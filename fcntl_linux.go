@@ -0,0 +1,29 @@
+//go:build linux
+
+package lockfile
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// Commands for open file description locks, which are missing from the
+// syscall package. See the fcntl(2) man page.
+const (
+	fOFDGetLk = 36
+	fOFDSetLk = 37
+)
+
+// fcntl performs a byte-range lock command on the file that is open as fd.
+func fcntl(fd int, cmd int, lk *syscall.Flock_t) error {
+	for {
+		_, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), uintptr(cmd), uintptr(unsafe.Pointer(lk)))
+		switch errno {
+		case 0:
+			return nil
+		case syscall.EINTR:
+		default:
+			return errno
+		}
+	}
+}
@@ -0,0 +1,191 @@
+//go:build linux
+
+package lockfile
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// A systemd service can deposit file descriptors in a store that systemd
+// keeps while the service restarts, and passes back to the new instance
+// of the service in the same way as socket activation. Lock files that are
+// stored this way are never released across a graceful restart, because
+// systemd holds a descriptor for the same open file description, and
+// therefore the same flock, in the meantime.
+//
+// The service must be configured with FileDescriptorStoreMax. See the
+// sd_notify(3) and sd_listen_fds(3) man pages for details.
+
+const (
+	envNotifySocket = "NOTIFY_SOCKET"
+	envListenPID    = "LISTEN_PID"
+	envListenFDs    = "LISTEN_FDS"
+	envListenNames  = "LISTEN_FDNAMES"
+
+	listenFDsStart = 3
+)
+
+// StoreLocks deposits the lock files held by the manager in the file
+// descriptor store of the systemd service that runs the current process,
+// so that they can be adopted with [Manager.AdoptLocks] after the service
+// restarts.
+//
+// Once a lock file has been stored, the manager hands it over to systemd:
+// the lock file is not deleted, and references to it are closed. It is
+// intended to be called just before the process exits for a graceful
+// restart. Soft lock files cannot be stored, and remain held by the
+// manager.
+//
+// It returns [ErrFDStoreUnavailable] if the process was not started by
+// systemd with a notification socket.
+func (m *Manager) StoreLocks() error {
+	socket := os.Getenv(envNotifySocket)
+	if socket == "" {
+		return ErrFDStoreUnavailable
+	}
+
+	m.mutex.Lock()
+	handles := make([]*lockHandle, 0, len(m.handles))
+	for h := range m.handles {
+		if !h.soft && !h.inherited {
+			handles = append(handles, h)
+		}
+	}
+	m.mutex.Unlock()
+
+	for _, h := range handles {
+		if err := h.store(socket); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AdoptLocks adopts the lock files with the given paths that were stored
+// by [Manager.StoreLocks] before the service restarted. The adopted lock
+// files are held by the manager, and are removed from the file descriptor
+// store.
+//
+// Each adopted descriptor is verified to refer to the lock file at its
+// path, and to hold its lock. Paths that were not stored are skipped, so
+// the returned slice may be shorter than paths. Callers can acquire those
+// lock files as usual.
+func (m *Manager) AdoptLocks(paths ...string) ([]*File, error) {
+	stored := storedFiles()
+	socket := os.Getenv(envNotifySocket)
+
+	var files []*File
+	for _, path := range paths {
+		name := fdStoreName(path)
+		fd, ok := stored[name]
+		if !ok {
+			continue
+		}
+
+		file, err := inheritFile(path, fd)
+		if err != nil {
+			return files, err
+		}
+
+		if socket != "" {
+			if err := notify(socket, "FDSTOREREMOVE=1\nFDNAME="+name, -1); err != nil {
+				file.Close()
+				return files, pathError("notify", path, err)
+			}
+		}
+
+		adopted, err := m.track(newFile(path, m.cfg, file, false), nil)
+		if err != nil {
+			return files, err
+		}
+		files = append(files, adopted)
+	}
+
+	return files, nil
+}
+
+// store deposits the lock file in the file descriptor store and closes
+// every reference to it without deleting it.
+func (h *lockHandle) store(socket string) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.refs == 0 {
+		return nil
+	}
+	if h.file == nil {
+		return &os.PathError{Op: "store", Path: h.path, Err: ErrInvalidOption}
+	}
+
+	state := "FDSTORE=1\nFDNAME=" + fdStoreName(h.path)
+	if err := notify(socket, state, int(h.file.Fd())); err != nil {
+		return pathError("notify", h.path, err)
+	}
+
+	// Systemd now shares the lock, so it is released like a lock that was
+	// inherited: closing the file neither releases nor deletes it.
+	h.refs = 0
+	h.inherited = true
+	return h.releaseLocked()
+}
+
+// fdStoreName returns the name that the lock file at path is stored under.
+//
+// Names are limited in length and must not contain colons, so they are
+// derived from a hash of the absolute path.
+func fdStoreName(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(path))
+
+	return fmt.Sprintf("lockfile-%016x", h.Sum64())
+}
+
+// storedFiles returns the descriptors that were passed to the current
+// process by systemd, indexed by name.
+func storedFiles() map[string]uintptr {
+	if pid, err := strconv.Atoi(os.Getenv(envListenPID)); err != nil || pid != os.Getpid() {
+		return nil
+	}
+
+	n, err := strconv.Atoi(os.Getenv(envListenFDs))
+	if err != nil {
+		return nil
+	}
+
+	names := strings.Split(os.Getenv(envListenNames), ":")
+	files := make(map[string]uintptr, n)
+	for i := range min(n, len(names)) {
+		files[names[i]] = uintptr(listenFDsStart + i)
+	}
+	return files
+}
+
+// notify sends a notification message to systemd, along with fd if it is
+// not negative.
+func notify(socket, state string, fd int) error {
+	conn, err := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(conn)
+
+	var oob []byte
+	if fd >= 0 {
+		oob = syscall.UnixRights(fd)
+	}
+
+	// A leading @ denotes a socket in the abstract namespace, which is
+	// understood by SockaddrUnix.
+	return syscall.Sendmsg(conn, []byte(state), oob, &syscall.SockaddrUnix{Name: socket}, 0)
+}
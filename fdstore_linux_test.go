@@ -0,0 +1,120 @@
+//go:build linux
+
+package lockfile_test
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+// envAdoptChild is set when the test binary is run as a child process by
+// TestStoreLocks.
+const envAdoptChild = "LOCKFILE_TEST_ADOPT_CHILD"
+
+func TestStoreLocks(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "stored.lock")
+
+	// Play the part of systemd.
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("failed to listen for notifications: %v", err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", socket)
+
+	manager := lockfile.NewManager()
+	held, err := manager.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	var stages []lockfile.Stage
+	held.OnRelease(func(stage lockfile.Stage) { stages = append(stages, stage) })
+	if err := manager.StoreLocks(); err != nil {
+		t.Fatalf("StoreLocks failed: %v", err)
+	}
+	if len(stages) != 2 || stages[0] != lockfile.StageBeforeRelease || stages[1] != lockfile.StageReleased {
+		t.Fatalf("storing the lock ran release stages %v", stages)
+	}
+	if n := manager.Len(); n != 0 {
+		t.Fatalf("manager holds %d locks after storing them, expected 0", n)
+	}
+
+	buf, oob := make([]byte, 512), make([]byte, 512)
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatalf("failed to read notification: %v", err)
+	}
+	name, ok := strings.CutPrefix(string(buf[:n]), "FDSTORE=1\nFDNAME=")
+	if !ok {
+		t.Fatalf("unexpected notification: %q", buf[:n])
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("failed to parse control message: %v", err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("failed to parse stored descriptor: %v", err)
+	}
+	stored := os.NewFile(uintptr(fds[0]), path)
+
+	// The lock must still be held while it is stored.
+	if _, err := lockfile.Create(path); !lockfile.IsTemporary(err) {
+		t.Fatalf("the lock was released when it was stored: %v", err)
+	}
+
+	// Pass the stored descriptor to a new process, which adopts it.
+	cmd := exec.Command(os.Args[0], "-test.run=^TestStoreLocksChild$", "-test.v")
+	cmd.ExtraFiles = []*os.File{stored}
+	cmd.Env = append(os.Environ(), envAdoptChild+"="+path, "LISTEN_FDS=1", "LISTEN_FDNAMES="+name)
+	out, err := cmd.CombinedOutput()
+	if err != nil || !bytes.Contains(out, []byte("--- PASS: TestStoreLocksChild")) {
+		t.Fatalf("child failed to adopt the lock: %v\n%s", err, out)
+	}
+	stored.Close()
+
+	file, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("the lock was not released by the child: %v", err)
+	}
+	file.Close()
+}
+
+func TestStoreLocksChild(t *testing.T) {
+	path := os.Getenv(envAdoptChild)
+	if path == "" {
+		t.Skip("only run as a child process by TestStoreLocks")
+	}
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+
+	manager := lockfile.NewManager()
+	files, err := manager.AdoptLocks(path)
+	if err != nil {
+		t.Fatalf("AdoptLocks failed: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("adopted %d locks, expected 1", len(files))
+	}
+	if err := files[0].Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func TestStoreLocksUnavailable(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := lockfile.NewManager().StoreLocks(); !errors.Is(err, lockfile.ErrFDStoreUnavailable) {
+		t.Fatalf("expected ErrFDStoreUnavailable, got: %v", err)
+	}
+}
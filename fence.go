@@ -0,0 +1,102 @@
+package lockfile
+
+import "strconv"
+
+// WithFencing returns an option that assigns a fencing token to each
+// acquisition of a lock file, which is returned by [File.Token].
+//
+// Tokens increase monotonically with each acquisition of the lock file,
+// by any process. A holder passes its token along with each write to a
+// downstream resource, such as a database or storage service, which
+// rejects writes that carry a lower token than one it has already seen.
+// This protects the resource from a holder that lost the lock without
+// noticing, such as one that was paused for longer than its [Lease].
+//
+// The most recent token is stored in a companion file, named after the
+// lock file with a ".fence" suffix, which persists after the lock is
+// released. It is incremented atomically while the lock is held. If
+// metadata is written to the lock file, the token is recorded in it. The
+// generation numbers of acquisitions through a [Lock] are drawn from the
+// same companion file.
+//
+// Shared locks are not assigned tokens, because they have several holders.
+func WithFencing() Option {
+	return func(c *config) {
+		c.fencing = true
+	}
+}
+
+// Token returns the fencing token that was assigned to this acquisition of
+// the lock file by [WithFencing], or 0 if it was not assigned one.
+func (f *File) Token() uint64 {
+	return f.h.token
+}
+
+// fencePath returns the path of the file that holds the most recent
+// fencing token of the lock file at path.
+func fencePath(path string) string {
+	return path + ".fence"
+}
+
+// fence assigns the next fencing token to a newly acquired file, if it is
+// configured with [WithFencing], and the same number as its generation, if
+// it was acquired through a [Lock]. Both are recorded in the metadata of
+// the lock file, if it has any.
+func (c *config) fence(file *File) error {
+	if file.h.shared {
+		return nil
+	}
+
+	token, err := nextSequence(fencePath(file.h.path))
+	if err != nil {
+		return err
+	}
+
+	if c.fencing {
+		file.h.token = token
+	}
+	if c.generations != nil {
+		file.h.generation = token
+		c.generations.Store(token)
+	}
+	if file.h.metadata {
+		file.h.writeMetadata()
+	}
+	return nil
+}
+
+// restoreFence ensures that the fencing token of file, which was acquired
+// again after a crash, is greater than token, the last one that is known
+// to have been assigned to it. The companion file may have been lost or
+// restored from an older backup in the meantime.
+func (c *config) restoreFence(file *File, token uint64) error {
+	if !c.fencing || file.h.shared {
+		return nil
+	}
+
+	file.h.mutex.Lock()
+	defer file.h.mutex.Unlock()
+
+	if file.h.token > token {
+		return nil
+	}
+
+	path := fencePath(file.h.path)
+	if err := writeFileAtomic(path, []byte(strconv.FormatUint(token, 10)+"\n")); err != nil {
+		return err
+	}
+	next, err := nextSequence(path)
+	if err != nil {
+		return err
+	}
+
+	file.h.token = next
+	if c.generations != nil {
+		file.h.generation = next
+		c.generations.Store(next)
+	}
+	if file.h.metadata {
+		file.h.writeMetadata()
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package lockfile_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestWithFencing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fence.lock")
+
+	for want := uint64(1); want <= 3; want++ {
+		file, err := lockfile.Create(path, lockfile.WithFencing(), lockfile.WithMetadata(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token := file.Token(); token != want {
+			t.Errorf("token %d, want %d", token, want)
+		}
+
+		info, err := lockfile.Inspect(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Metadata == nil || info.Metadata.Token != want {
+			t.Errorf("the token was not recorded in the metadata: %+v", info.Metadata)
+		}
+
+		if err := file.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Acquisitions without fencing are not assigned tokens, and do not
+	// consume them.
+	file, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token := file.Token(); token != 0 {
+		t.Errorf("unexpected token without fencing: %d", token)
+	}
+	file.Close()
+
+	file, err = lockfile.Create(path, lockfile.WithFencing())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if token := file.Token(); token != 4 {
+		t.Errorf("token %d, want 4", token)
+	}
+}
+
+func TestWithFencingShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fence.lock")
+
+	file, err := lockfile.CreateShared(path, lockfile.WithFencing())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if token := file.Token(); token != 0 {
+		t.Errorf("unexpected token for a shared lock: %d", token)
+	}
+}
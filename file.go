@@ -0,0 +1,399 @@
+package lockfile
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// File is an open lock file.
+//
+// Each File is an independent reference to a held lock. Additional
+// references can be created with [File.Dup]. The lock is released when
+// every reference has been closed.
+type File struct {
+	h      *lockHandle
+	mutex  sync.Mutex
+	closed bool
+	done   chan struct{}  // Created on demand, closed by Close
+	ops    sync.WaitGroup // Operations in flight, see begin
+}
+
+// lockHandle is the state of a held lock that is shared by all of the
+// [File] references to it.
+type lockHandle struct {
+	path       string
+	cfg        *config
+	generation uint64
+	soft       bool
+	shared     bool         // Holds a shared lock rather than an exclusive one
+	metadata   bool         // Metadata has been written to the lock file
+	expires    time.Time    // Expiration of the lease recorded in the metadata
+	token      uint64       // Fencing token, see WithFencing
+	inherited  bool         // Shared with this process by its parent
+	backend    *BackendLock // Held through the backend, see WithBackend
+	manager    *Manager     // Tracks the lock, if it was acquired by one
+	stack      []uintptr    // Call stack that acquired the lock, see Manager.Drain
+	quotaDir   string       // Directory whose quota the lock counts against
+	stats      Stats
+	lifecycle  lifecycle
+
+	mutex           sync.Mutex
+	refs            int
+	file            *os.File
+	released        chan struct{} // Created on demand, closed when refs reaches 0
+	sharedWithChild bool          // Shared with a child process by File.Share
+
+	requested    chan struct{} // Created on demand, closed to request release
+	requestTimer *time.Timer   // Requests release when the window closes
+
+	onRelease []func(Stage) // Callbacks registered with OnRelease
+
+	monitors    []chan error  // Channels returned by Monitor
+	monitorStop chan struct{} // Closed to stop the link monitor
+	compromise  error         // The problem found by the link monitor
+
+	activity atomic.Int64  // Time of the last Touch in Unix nanoseconds
+	idleStop chan struct{} // Closed to stop the idle detector
+
+	refreshStop chan struct{} // Closed to stop refreshing a soft lock file
+}
+
+// newFile returns a [File] that holds the lock for the given open file.
+//
+// The File and its handle are allocated together, so that the common case
+// of a lock with a single reference requires one allocation.
+func newFile(path string, cfg *config, file *os.File, soft bool) *File {
+	pair := &struct {
+		f File
+		h lockHandle
+	}{
+		h: lockHandle{
+			path: path,
+			cfg:  cfg,
+			soft: soft,
+			refs: 1,
+			file: file,
+			stats: Stats{
+				Acquired: time.Now(),
+				Attempts: 1,
+			},
+		},
+	}
+	pair.f.h = &pair.h
+	return &pair.f
+}
+
+// Path returns the path of the lock file.
+func (f *File) Path() string {
+	return f.h.path
+}
+
+// Generation returns the generation number of this acquisition.
+//
+// Each time a [Lock] is acquired, the resulting [File] is assigned the next
+// generation number of the lock file, starting at 1. Consumers can compare
+// generation numbers to detect that the lock they observe now is a
+// different acquisition than one they sampled earlier, even if the lock
+// was released and reacquired in the meantime, by this process or another.
+//
+// Generation numbers are drawn from the same companion file as the tokens
+// of [WithFencing], which persists after the lock is released, so they
+// increase across processes and restarts. A Lock that is also configured
+// with [WithFencing] is assigned a token equal to its generation number.
+//
+// Lock files that were not acquired through a [Lock], and shared lock
+// files, have a generation number of 0.
+func (f *File) Generation() uint64 {
+	return f.h.generation
+}
+
+// Dup returns a new reference to the lock held by f.
+//
+// The new reference has its own independent [File.Close] method. This
+// allows two subsystems within a process to each hold the lock, and to
+// each release it when they are done. The lock file is only deleted when
+// every reference to it has been closed.
+//
+// It returns an [*os.PathError] that wraps [os.ErrClosed] if f has already
+// been closed.
+func (f *File) Dup() (*File, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return nil, &os.PathError{Op: "dup", Path: f.h.path, Err: os.ErrClosed}
+	}
+
+	f.h.mutex.Lock()
+	defer f.h.mutex.Unlock()
+
+	// The lock may already have been released by its manager.
+	if f.h.refs == 0 {
+		return nil, &os.PathError{Op: "dup", Path: f.h.path, Err: os.ErrClosed}
+	}
+
+	f.h.refs++
+	return &File{h: f.h}, nil
+}
+
+// Close closes this reference to the lock. When the last reference is
+// closed, the lock file is deleted and the lock is released. It returns an
+// error if the lock file could not be deleted, or if the underlying file
+// handle could not be closed.
+//
+// Close first closes the channel returned by [File.Closed], and then waits
+// for any operations on f that are in flight, such as those run by helpers
+// that watch the lock, to finish. It must not be called from within such
+// an operation.
+//
+// It returns an [*os.PathError] that wraps [os.ErrClosed] if the function
+// has already been called.
+func (f *File) Close() error {
+	// Hold a lock so that this call is threadsafe.
+	f.mutex.Lock()
+	if f.closed {
+		f.mutex.Unlock()
+		return &os.PathError{Op: "close", Path: f.h.path, Err: os.ErrClosed}
+	}
+	f.closed = true
+
+	if f.done == nil {
+		f.done = closedChan
+	} else {
+		close(f.done)
+	}
+	f.mutex.Unlock()
+
+	// No operation can begin once f is marked closed, so it is safe to wait.
+	// The lock is not held while waiting, so that operations in flight can
+	// still call methods on f.
+	f.ops.Wait()
+
+	return f.h.unref()
+}
+
+// unref removes a reference to the handle, and releases the lock if no
+// references remain.
+func (h *lockHandle) unref() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	// The lock may already have been released by its manager.
+	if h.refs == 0 {
+		return &os.PathError{Op: "close", Path: h.path, Err: os.ErrClosed}
+	}
+
+	h.refs--
+	if h.refs > 0 {
+		return nil
+	}
+
+	return h.releaseLocked()
+}
+
+// releaseAll releases the lock, regardless of how many references to it
+// remain. It does nothing if the lock has already been released.
+func (h *lockHandle) releaseAll() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.refs == 0 {
+		return nil
+	}
+	h.refs = 0
+
+	return h.releaseLocked()
+}
+
+// releaseLocked releases the lock after its last reference has been
+// removed.
+//
+// The caller must hold h.mutex.
+func (h *lockHandle) releaseLocked() (err error) {
+	if h.released != nil {
+		defer close(h.released)
+	}
+
+	h.lifecycle.transition(StateReleasing)
+	defer h.notify(StageReleased)
+	defer func() { h.lifecycle.finish(err) }()
+	h.notify(StageBeforeRelease)
+	h.stopMonitor()
+	h.stopIdleDetector()
+	h.stopRefresher()
+
+	if h.requestTimer != nil {
+		h.requestTimer.Stop()
+	}
+
+	if h.manager != nil {
+		h.manager.forget(h)
+	}
+	if h.cfg.negativeCache != nil {
+		defer h.cfg.negativeCache.Invalidate(h.path)
+	}
+
+	// Inherited lock files are deleted by the process that created them.
+	if h.inherited {
+		return h.releaseInherited()
+	}
+
+	// Scrub the metadata before the lock file is deleted. Shared lock files
+	// have no metadata.
+	if h.cfg.scrub && h.metadata && !h.shared {
+		h.scrubMetadata()
+	}
+
+	// Soft lock files are managed without an operating system lock.
+	if h.soft {
+		return h.releaseSoft()
+	}
+
+	if h.backend != nil {
+		return h.releaseBackend()
+	}
+
+	return h.release()
+}
+
+// create attempts to create a lock file with the given path, applying
+// the behaviors described by the configuration.
+func (c *config) create(path string) (*File, error) {
+	return c.createCtx(context.Background(), path)
+}
+
+// createCtx attempts to create a lock file like create. The context is
+// passed to the precondition configured by [WithPrecondition], if any.
+//
+// Errors that do not already carry a [ReasonCode] are given one.
+func (c *config) createCtx(ctx context.Context, path string) (*File, error) {
+	file, err := c.tryCreate(ctx, path)
+	return file, withReason(err)
+}
+
+// tryCreate attempts to create a lock file on behalf of createCtx.
+func (c *config) tryCreate(ctx context.Context, path string) (*File, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	if c.precondition != nil {
+		if err := c.checkPrecondition(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	path, err := c.mapPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var windowEnd time.Time
+	if c.schedule != nil {
+		end, err := c.checkSchedule(path)
+		if err != nil {
+			return nil, err
+		}
+		windowEnd = end
+	}
+
+	if c.negativeCache != nil && c.negativeCache.contended(path) {
+		return nil, c.contention(path, &os.PathError{Op: "open", Path: path, Err: os.ErrExist})
+	}
+
+	file, err := c.createAt(path)
+	if err != nil {
+		if c.negativeCache != nil && c.isTemporary(err) {
+			c.negativeCache.record(path)
+		}
+		if c.isTemporary(err) {
+			err = c.contention(path, err)
+		}
+		return nil, err
+	}
+
+	if c.siblingPolicy != SiblingIgnore {
+		if err := c.checkSiblings(file.h.path, file); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.fencing || c.generations != nil {
+		if err := c.fence(file); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	if c.precondition != nil {
+		if err := c.checkPrecondition(ctx); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	if !windowEnd.IsZero() {
+		file.h.requestReleaseAt(windowEnd)
+	}
+
+	if c.linkMonitor > 0 {
+		file.h.startMonitor(c.linkMonitor)
+	}
+
+	if c.idleThreshold > 0 {
+		file.h.startIdleDetector(c.idleThreshold)
+	}
+
+	return file, nil
+}
+
+// createAt attempts to create a lock file with the given path in the
+// appropriate mode, relocating it to the fallback directory if the
+// filesystem is read-only.
+func (c *config) createAt(path string) (*File, error) {
+	file, err := c.lockAt(path)
+	if err == nil {
+		return file, nil
+	}
+
+	err = classifyError(err)
+	if c.fallbackDir == "" || !errors.Is(err, ErrReadOnlyFilesystem) {
+		return nil, err
+	}
+
+	c.warn(path, err)
+	return c.lockAt(fallbackPath(c.fallbackDir, path))
+}
+
+// lockAt attempts to create a lock file with the given path in the
+// appropriate mode.
+func (c *config) lockAt(path string) (*File, error) {
+	if err := c.checkSpace(path); err != nil {
+		return nil, err
+	}
+	if c.backend != nil {
+		return c.lockBackend(path)
+	}
+	soft, err := c.softLockMode(path)
+	if err != nil {
+		if !soft {
+			return nil, err
+		}
+		c.warn(path, err)
+	}
+	if soft {
+		if c.shared {
+			return nil, &os.PathError{Op: "open", Path: path, Err: ErrSharedUnsupported}
+		}
+		file, err := c.lockSoft(path)
+		if err == nil && !c.soft {
+			file.h.startRefresher(c.softStaleTimeout())
+		}
+		return file, err
+	}
+	return c.lock(path)
+}
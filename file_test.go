@@ -1,7 +1,11 @@
 package lockfile_test
 
 import (
+	"context"
+	"errors"
 	"math/rand/v2"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -71,3 +75,111 @@ func TestCreateParallel(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestCreatePathError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "patherror.lock")
+
+	lock, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create lock file: %v", err)
+	}
+
+	_, err = lockfile.Create(path)
+	var pathErr *os.PathError
+	if !errors.As(err, &pathErr) {
+		t.Fatalf("contention was not reported as an *os.PathError: %v", err)
+	}
+	if pathErr.Path != path {
+		t.Fatalf("contention error has path %s, expected %s", pathErr.Path, path)
+	}
+	if !errors.Is(err, os.ErrExist) {
+		t.Fatalf("contention error does not match os.ErrExist: %v", err)
+	}
+
+	if err := lock.Close(); err != nil {
+		t.Fatalf("failed to close lock file: %v", err)
+	}
+	if err := lock.Close(); !errors.Is(err, os.ErrClosed) || !errors.As(err, &pathErr) {
+		t.Fatalf("closing twice did not return an *os.PathError wrapping os.ErrClosed: %v", err)
+	}
+}
+
+func TestFileClosed(t *testing.T) {
+	file, err := lockfile.Create(filepath.Join(t.TempDir(), "closed.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closed := file.Closed()
+	select {
+	case <-closed:
+		t.Fatalf("the channel was closed while the file was open")
+	default:
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatalf("the channel was not closed by Close")
+	}
+
+	select {
+	case <-file.Closed():
+	default:
+		t.Fatalf("Closed returned an open channel after Close")
+	}
+}
+
+func TestContext(t *testing.T) {
+	file, err := lockfile.Create(filepath.Join(t.TempDir(), "context.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent, cancelParent := context.WithCancel(context.Background())
+	defer cancelParent()
+	ctx := file.Context(parent)
+	other := file.Context(context.Background())
+
+	// Cancelling the parent only cancels its own context.
+	cancelParent()
+	<-ctx.Done()
+	if cause := context.Cause(ctx); !errors.Is(cause, context.Canceled) {
+		t.Fatalf("unexpected cause after the parent was cancelled: %v", cause)
+	}
+	if other.Err() != nil {
+		t.Fatalf("the context was cancelled while the file was open: %v", other.Err())
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-other.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("the context was not cancelled by Close")
+	}
+	if cause := context.Cause(other); !errors.Is(cause, os.ErrClosed) {
+		t.Fatalf("expected a cause that wraps os.ErrClosed, got: %v", cause)
+	}
+	if cause := context.Cause(waitDone(t, file.Context(context.Background()))); !errors.Is(cause, os.ErrClosed) {
+		t.Fatalf("unexpected cause for a context derived after Close: %v", cause)
+	}
+}
+
+// waitDone waits for ctx to be cancelled and returns it.
+func waitDone(t *testing.T, ctx context.Context) context.Context {
+	t.Helper()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("the context was not cancelled")
+	}
+	return ctx
+}
@@ -0,0 +1,191 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"github.com/gentlemanautomaton/lockfile/core"
+)
+
+// Lennart Poettering provides a helpful overview of the hazards of file
+// locking in Linux here:
+// https://0pointer.net/blog/projects/locking.html
+//
+// We follow an algorithm similar to the one described by Guido U. Draheim
+// in his answer to the Stack Overflow question "flock(): removing locked
+// file without race condition?", which can be found here:
+// https://stackoverflow.com/questions/17708885/flock-removing-locked-file-without-race-condition/51070775#51070775
+
+// Create attempts to create a lock file with the given path.
+//
+// It uses the flock system call to lock the file, which acquires an advisory
+// lock. This means that the lock is only effective if all processes competing
+// for the lock file also acquire the same kind of file lock.
+//
+// If successful, it returns a [File] that wraps the underlying [os.File].
+//
+// The [File] will stay open and locked until [File.Close] is called, which
+// will delete the lock file and release system resources that are associated
+// with it.
+//
+// If the lock file already exists, it returns a [*ContentionError] that
+// wraps an [*os.PathError] that wraps [os.ErrExist].
+//
+// Failures of the underlying system calls are reported as [*os.PathError]
+// values that identify the operation and path that failed.
+//
+// Options may be provided to customize its behavior.
+func Create(path string, opts ...Option) (*File, error) {
+	return newConfig(opts).create(path)
+}
+
+// lock attempts to create and lock a lock file with the given path, using
+// the flock system call. The lock is shared if the configuration calls for
+// a shared lock, and exclusive otherwise.
+func (c *config) lock(path string) (*File, error) {
+	how := syscall.LOCK_EX
+	if c.shared {
+		how = syscall.LOCK_SH
+	}
+
+	sys := c.system()
+
+	// Create the lock file if it doesn't exist, and lock it with the flock
+	// system call, or with an fcntl lock if [WithPOSIXLocks] or
+	// [WithOFDLocks] is configured. The algorithm is shared with the core
+	// package, so that the two always agree on how lock files are handled.
+	//
+	// Note that we don't make the lock file world readable. This prevents
+	// unprivileged processes from taking a lock on this file, which could
+	// result in a denial-of-service attack if they never release it.
+	//
+	// When metadata is written, the lock file must be writable by its
+	// owner. A lock file left behind by a holder that did not write
+	// metadata is read-only, in which case the lock is acquired without
+	// writing any.
+	//
+	// Write locks acquired with fcntl can only be acquired through a
+	// descriptor that is open for writing, so the lock file must always be
+	// writable when they are used.
+	//
+	// https://man7.org/linux/man-pages/man2/flock.2.html
+	writable := c.writesMetadata()
+	flag, perm := syscall.O_RDONLY, uint32(0400)
+	if writable || c.fcntlLocks() {
+		flag, perm = syscall.O_RDWR, 0600
+	}
+	fd, stat, err := core.Lock(coreSyscalls{c}, path, flag|syscall.O_CREAT|int(c.openFlags), perm, how)
+	if writable && !c.fcntlLocks() && errors.Is(err, syscall.EACCES) {
+		writable = false
+		fd, stat, err = core.Lock(coreSyscalls{c}, path, syscall.O_RDONLY|syscall.O_CREAT|int(c.openFlags), 0400, how)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A lock file that was left behind by a holder that crashed may
+	// contain the metadata it wrote. Anything else means that the file is
+	// not a lock file.
+	//
+	// The flock may not be visible to other hosts on some network
+	// filesystems, so a lease held by a holder on another host is honored
+	// until it expires.
+	if stat.Size != 0 {
+		md, ok := readMetadata(fd, stat.Size)
+		if !ok {
+			sys.closeFd(path, fd)
+			return nil, &os.PathError{Op: "open", Path: path, Err: ErrNotEmpty}
+		}
+		if leaseHeld(md) {
+			sys.closeFd(path, fd)
+			return nil, &os.PathError{Op: "lease", Path: path, Err: os.ErrExist}
+		}
+	}
+
+	file := newFile(path, c, os.NewFile(uintptr(fd), path), false)
+	file.h.shared = c.shared
+	if writable {
+		file.h.writeMetadata()
+	} else if c.writesMetadata() {
+		c.warn(path, &os.PathError{Op: "open", Path: path, Err: ErrMetadataUnwritable})
+	}
+	return file, nil
+}
+
+// release deletes the lock file and closes it. It returns an error if it
+// is unable to do so, or if the underlying file handle could not be closed.
+//
+// The caller must hold h.mutex.
+func (h *lockHandle) release() (err error) {
+	// A lock file that was shared with a child process may still be held
+	// by the child.
+	if h.sharedWithChild {
+		return h.releaseInherited()
+	}
+
+	sys := h.cfg.system()
+
+	// Always close the file handle when we're done. This will automatically
+	// release the file lock at the same time.
+	//
+	// It's very important that this happens after the file is unlinked. To
+	// do otherwise can lead to race conditions.
+	defer func() {
+		closeErr := pathError("close", h.path, sys.closeFile(h.path, h.file))
+		h.file = nil
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	// A shared lock file is only deleted by its last holder. Converting the
+	// lock to an exclusive one tells us whether any other holders remain.
+	// If they do, they are responsible for deleting the lock file.
+	//
+	// The conversion is not atomic, so a writer may acquire the lock in the
+	// meantime, in which case it becomes responsible instead. A new holder
+	// that opens the lock file before we unlink it notices that it has no
+	// links once it has been locked, and starts over.
+	if h.shared {
+		if err := sys.flock(h.path, int(h.file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				return nil
+			}
+			return pathError("flock", h.path, err)
+		}
+	}
+
+	// If the file is still at the expected file path, unlink it.
+	if err := core.Unlink(coreSyscalls{h.cfg}, h.path, int(h.file.Fd())); err != nil {
+		if errors.Is(err, core.ErrMoved) {
+			// The lock file was probably renamed. That's not good, but
+			// there's not much we can do about it.
+			return &os.PathError{Op: "unlink", Path: h.path, Err: ErrMoved}
+		}
+		return err
+	}
+	h.notify(StageUnlinked)
+
+	return nil
+}
+
+// readMetadata reads the lock file metadata held by the file that is open
+// as fd, which has the given size. It returns false if the file does not
+// hold valid metadata.
+func readMetadata(fd int, size int64) (Metadata, bool) {
+	if size > maxMetadataSize {
+		return Metadata{}, false
+	}
+
+	data := make([]byte, size)
+	n, err := syscall.Pread(fd, data, 0)
+	if err != nil {
+		return Metadata{}, false
+	}
+
+	md, err := DecodeMetadata(data[:n], IgnoreUnknownFields)
+	return md, err == nil
+}
@@ -3,16 +3,15 @@
 package lockfile
 
 import (
+	"errors"
 	"os"
-	"sync"
 	"syscall"
 )
 
-// File is an open lock file.
-type File struct {
-	mutex sync.Mutex
-	file  *os.File
-}
+const (
+	fileAttributeTemporary = 0x00000100
+	fileFlagDeleteOnClose  = 0x04000000
+)
 
 // Create attempts to create a lock file with the given path.
 //
@@ -25,57 +24,131 @@ type File struct {
 // will delete the lock file and release system resources that are associated
 // with it.
 //
-// If the file already exists, it returns [os.ErrExists].
+// If the file already exists, it returns a [*ContentionError] that wraps an
+// [*os.PathError] that wraps an error satisfying [os.ErrExist].
 //
-// If the file already exists but is marked for deletion, it returns
-// [os.ErrPermission]. Unfortunately, this case is indistinguishable from
-// regular access denied errors, due to the design of the underlying API
-// calls.
-func Create(path string) (*File, error) {
-	const (
-		FILE_ATTRIBUTE_TEMPORARY  = 0x00000100
-		FILE_FLAG_DELETE_ON_CLOSE = 0x04000000
-	)
+// If the file already exists but is marked for deletion, it returns a
+// [*ContentionError] that wraps an [*os.PathError] that wraps an error
+// satisfying [os.ErrPermission].
+// Unfortunately, this case is indistinguishable from regular access denied
+// errors, due to the design of the underlying API calls.
+//
+// Options may be provided to customize its behavior.
+func Create(path string, opts ...Option) (*File, error) {
+	return newConfig(opts).create(path)
+}
+
+// lock attempts to create a lock file with the given path, which is
+// exclusively locked and deleted when closed.
+func (c *config) lock(path string) (*File, error) {
+	if c.shared {
+		return c.lockShared(path)
+	}
 
 	// FIXME: Handle long file paths by prefixing them with the extended path
 	// prefix (\\?\). The standard library does this with [os.fixLongPath],
 	// which sadly is not exposed.
 
-	handle, err := createFile(path, syscall.GENERIC_READ, 0, syscall.CREATE_NEW, FILE_ATTRIBUTE_TEMPORARY|FILE_FLAG_DELETE_ON_CLOSE)
+	// When metadata is written, the lock file is opened for writing, and
+	// others are allowed to read it. They are still unable to open it for
+	// writing or deletion, or to create it, so the lock remains exclusive.
+	access, share := uint32(syscall.GENERIC_READ), uint32(0)
+	if c.writesMetadata() {
+		access |= syscall.GENERIC_WRITE
+		share = syscall.FILE_SHARE_READ
+	}
+
+	handle, err := c.system().open(path, access, share, syscall.CREATE_NEW, fileAttributeTemporary|fileFlagDeleteOnClose|c.openFlags)
 	if err != nil {
-		if errno, ok := err.(syscall.Errno); ok {
-			switch errno {
-			case syscall.ERROR_FILE_EXISTS:
-				return nil, os.ErrExist
-			case syscall.ERROR_ACCESS_DENIED:
-				// This can happen if the file is pending deletion, but
-				// it can also happen if we don't have the necessary
-				// privileges to create the file.
-				return nil, os.ErrPermission
-			}
-		}
-		return nil, err
+		// The system error codes are preserved, because they already
+		// match the appropriate sentinel errors:
+		//
+		// ERROR_FILE_EXISTS matches os.ErrExist.
+		//
+		// ERROR_ACCESS_DENIED matches os.ErrPermission. This can happen if
+		// the file is pending deletion, but it can also happen if we don't
+		// have the necessary privileges to create the file.
+		return nil, pathError("open", path, err)
 	}
 
-	return &File{
-		file: os.NewFile(uintptr(handle), path),
-	}, nil
+	file := newFile(path, c, os.NewFile(uintptr(handle), path), false)
+	if c.writesMetadata() {
+		file.h.writeMetadata()
+	}
+	return file, nil
 }
 
-// Close deletes the lock file.
-func (f *File) Close() error {
-	// Hold a lock so that this call is threadsafe.
-	f.mutex.Lock()
-	defer f.mutex.Unlock()
+// lockShared attempts to open or create a lock file with the given path,
+// which is shared with other readers and deleted when the last of them
+// closes it.
+//
+// Readers share read and delete access with each other, but not write
+// access, which an exclusive holder never shares. An exclusive holder
+// therefore excludes readers, and readers exclude an exclusive holder
+// because its file already exists.
+//
+// The reader that creates the lock file opens it with delete-on-close.
+// The others open the existing file without it, so that a file that is
+// not a lock file is never deleted, and they refuse a file that is not
+// empty. The lock file is still deleted once every reader has closed it.
+func (c *config) lockShared(path string) (*File, error) {
+	const (
+		ERROR_FILE_NOT_FOUND    = syscall.Errno(2)
+		ERROR_SHARING_VIOLATION = syscall.Errno(32)
+	)
+
+	sys := c.system()
+	share := uint32(syscall.FILE_SHARE_READ | syscall.FILE_SHARE_DELETE)
+	for {
+		handle, err := sys.open(path, syscall.GENERIC_READ, share, syscall.CREATE_NEW, fileAttributeTemporary|fileFlagDeleteOnClose|c.openFlags)
+		if err == nil {
+			file := newFile(path, c, os.NewFile(uintptr(handle), path), false)
+			file.h.shared = true
+			return file, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, pathError("open", path, err)
+		}
+
+		handle, err = sys.open(path, syscall.GENERIC_READ, share, syscall.OPEN_EXISTING, c.openFlags)
+		switch {
+		case err == ERROR_FILE_NOT_FOUND:
+			continue // The last reader closed it in the meantime.
+		case err == ERROR_SHARING_VIOLATION:
+			// The lock file is held exclusively.
+			return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrExist}
+		case err != nil:
+			return nil, pathError("open", path, err)
+		}
 
-	// If the file has already been closed, we're done.
-	if f.file == nil {
-		return os.ErrClosed
+		f := os.NewFile(uintptr(handle), path)
+		if fi, err := f.Stat(); err != nil || fi.Size() != 0 {
+			sys.closeFile(path, f)
+			if err != nil {
+				return nil, pathError("stat", path, err)
+			}
+			return nil, &os.PathError{Op: "open", Path: path, Err: ErrNotEmpty}
+		}
+
+		file := newFile(path, c, f, false)
+		file.h.shared = true
+		return file, nil
 	}
+}
 
+// release closes the lock file, which causes it to be deleted.
+//
+// The caller must hold h.mutex.
+func (h *lockHandle) release() error {
 	// Close the file.
-	err := f.file.Close()
-	f.file = nil
+	err := pathError("close", h.path, h.cfg.system().closeFile(h.path, h.file))
+	h.file = nil
+
+	// The last holder of a shared lock file deletes it, but we can't tell
+	// whether that was us.
+	if err == nil && !h.shared {
+		h.notify(StageUnlinked)
+	}
 
 	return err
 }
@@ -0,0 +1,116 @@
+package lockfile
+
+import (
+	"fmt"
+	"time"
+)
+
+// FilesystemError reports a problem with the filesystem that contains a
+// lock file.
+type FilesystemError struct {
+	Path       string // The path of the lock file
+	Filesystem string // The name of the filesystem, if known
+	Err        error
+}
+
+// Error returns a description of the problem.
+func (e *FilesystemError) Error() string {
+	if e.Filesystem == "" {
+		return fmt.Sprintf("%s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s: %v (%s)", e.Path, e.Err, e.Filesystem)
+}
+
+// Unwrap returns the underlying error.
+func (e *FilesystemError) Unwrap() error {
+	return e.Err
+}
+
+// DefaultSoftStaleAfter is the stale timeout of the soft lock files that
+// are created when filesystem detection falls back to soft-lock mode,
+// unless another is configured by [WithSoftLockFallback].
+const DefaultSoftStaleAfter = 10 * time.Minute
+
+// WithFilesystemDetection returns an option that controls whether the
+// filesystem containing a lock file is inspected before the lock file is
+// created. Detection is enabled by default.
+//
+// On Windows, FAT, FAT32 and exFAT volumes lack the features that regular
+// lock files rely on. Lock files on them are created in soft-lock mode, as
+// described by [WithSoftLock], and a [*FilesystemError] that wraps
+// [ErrUnreliableFilesystem] is reported to the Warning hook. The stale
+// timeout of such lock files is [DefaultSoftStaleAfter], unless another is
+// configured by [WithSoftLockFallback], and they are refreshed in the
+// background while they are held, since their holders may not know to call
+// [File.Refresh]. [WithStrictFilesystem] refuses these filesystems
+// instead.
+func WithFilesystemDetection(enabled bool) Option {
+	return func(c *config) {
+		c.noFilesystemDetection = !enabled
+	}
+}
+
+// WithSoftLockFallback returns an option that sets the stale timeout of the
+// soft lock files that are created when filesystem detection finds that
+// their filesystem does not reliably support regular lock files, as
+// described by [WithFilesystemDetection].
+//
+// The operating system does not release a soft lock when its holder
+// crashes, so staleAfter must be greater than zero. A marker file that has
+// not been modified for longer than staleAfter is considered abandoned.
+func WithSoftLockFallback(staleAfter time.Duration) Option {
+	return func(c *config) {
+		c.softFallback = true
+		c.softStaleAfter = staleAfter
+	}
+}
+
+// WithStrictFilesystem returns an option that refuses to create lock files
+// on filesystems that do not reliably support regular lock files, rather
+// than falling back to soft-lock mode as described by
+// [WithFilesystemDetection]. Creating a lock file on one of them fails
+// with a [*FilesystemError] that wraps [ErrUnreliableFilesystem].
+//
+// It cannot be combined with [WithSoftLockFallback].
+func WithStrictFilesystem() Option {
+	return func(c *config) {
+		c.strictFilesystem = true
+	}
+}
+
+// useSoftLock returns true if the lock file at path should be created in
+// soft-lock mode.
+func (c *config) useSoftLock(path string) bool {
+	soft, _ := c.softLockMode(path)
+	return soft
+}
+
+// softLockMode returns true if the lock file at path should be created in
+// soft-lock mode. It also returns a [*FilesystemError] if the filesystem
+// does not reliably support regular lock files, which is a warning if soft
+// is true and prevents the lock file from being created otherwise.
+func (c *config) softLockMode(path string) (soft bool, err error) {
+	if c.soft {
+		return true, nil
+	}
+	if c.noFilesystemDetection || c.fcntlLocks() {
+		return false, nil
+	}
+
+	name, unreliable := unreliableFilesystem(path)
+	if !unreliable {
+		return false, nil
+	}
+	return !c.strictFilesystem, &FilesystemError{Path: path, Filesystem: name, Err: ErrUnreliableFilesystem}
+}
+
+// softStaleTimeout returns the stale timeout of the soft lock files that
+// are created with the configuration. Lock files that were created by the
+// fallback described by [WithFilesystemDetection] use
+// [DefaultSoftStaleAfter] unless another timeout was configured.
+func (c *config) softStaleTimeout() time.Duration {
+	if c.soft || c.softFallback {
+		return c.softStaleAfter
+	}
+	return DefaultSoftStaleAfter
+}
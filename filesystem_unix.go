@@ -0,0 +1,13 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile
+
+// unreliableFilesystem returns true if the filesystem containing path
+// cannot be trusted to support regular lock files, along with the name of
+// the filesystem.
+//
+// The flock system call is supported by all local Unix filesystems, so
+// this always returns false.
+func unreliableFilesystem(path string) (name string, unreliable bool) {
+	return "", false
+}
@@ -0,0 +1,73 @@
+//go:build windows
+
+package lockfile
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// filesystemNames caches the filesystem names of the directories that
+// lock files have been created in. It is emptied when it reaches
+// filesystemNamesSize entries, so that a process that creates lock files
+// in many directories does not accumulate them.
+var filesystemNames struct {
+	mutex sync.Mutex
+	names map[string]string
+}
+
+// filesystemNamesSize is the maximum number of entries in filesystemNames.
+const filesystemNamesSize = 256
+
+// unreliableFilesystem returns true if the filesystem containing path
+// cannot be trusted to support regular lock files, along with the name of
+// the filesystem.
+//
+// FAT, FAT32 and exFAT volumes lack access control lists, and their
+// handling of locks and pending deletions varies between drivers and
+// removable media.
+//
+// If the filesystem cannot be determined, it returns false.
+func unreliableFilesystem(path string) (name string, unreliable bool) {
+	dir := filepath.Dir(path)
+
+	name, ok := cachedFilesystemName(dir)
+	if !ok {
+		volume, err := getVolumePathName(path)
+		if err != nil {
+			return "", false
+		}
+		name, err = getFileSystemName(volume)
+		if err != nil {
+			return "", false
+		}
+		cacheFilesystemName(dir, name)
+	}
+
+	switch strings.ToUpper(name) {
+	case "FAT", "FAT32", "EXFAT":
+		return name, true
+	}
+	return name, false
+}
+
+// cachedFilesystemName returns the cached filesystem name of dir.
+func cachedFilesystemName(dir string) (string, bool) {
+	filesystemNames.mutex.Lock()
+	defer filesystemNames.mutex.Unlock()
+
+	name, ok := filesystemNames.names[dir]
+	return name, ok
+}
+
+// cacheFilesystemName records the filesystem name of dir.
+func cacheFilesystemName(dir, name string) {
+	filesystemNames.mutex.Lock()
+	defer filesystemNames.mutex.Unlock()
+
+	if filesystemNames.names == nil || len(filesystemNames.names) >= filesystemNamesSize {
+		filesystemNames.names = make(map[string]string)
+	}
+	filesystemNames.names[dir] = name
+}
@@ -0,0 +1,63 @@
+//go:build windows
+
+package lockfile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+// TestFATVolume verifies that lock files on a FAT volume fall back to
+// soft-lock mode, or are refused in strict mode. The test harness must
+// mount a FAT-formatted volume, such as a VHD, and provide a directory on
+// it in the LOCKFILE_TEST_FAT_DIR environment variable.
+func TestFATVolume(t *testing.T) {
+	dir := os.Getenv("LOCKFILE_TEST_FAT_DIR")
+	if dir == "" {
+		t.Skip("LOCKFILE_TEST_FAT_DIR is not set")
+	}
+
+	path := filepath.Join(dir, "fat.lock")
+
+	// In strict mode, lock files cannot be created on the volume.
+	if _, err := lockfile.Create(path, lockfile.WithStrictFilesystem()); !errors.Is(err, lockfile.ErrUnreliableFilesystem) {
+		t.Fatalf("expected ErrUnreliableFilesystem in strict mode, got: %v", err)
+	}
+
+	var warned error
+	hooks := lockfile.WithHooks(lockfile.Hooks{
+		Warning: func(path string, err error) {
+			warned = err
+		},
+	})
+
+	lock, err := lockfile.Create(path, hooks)
+	if err != nil {
+		t.Fatalf("failed to create lock file on FAT volume: %v", err)
+	}
+	defer lock.Close()
+
+	if !errors.Is(warned, lockfile.ErrUnreliableFilesystem) {
+		t.Fatalf("expected an unreliable filesystem warning, got: %v", warned)
+	}
+	g := lock.Guarantees()
+	if g.KernelLock {
+		t.Fatalf("lock file on FAT volume claims a kernel lock")
+	}
+	if g.StaleAfter != lockfile.DefaultSoftStaleAfter {
+		t.Fatalf("lock file on FAT volume is stale after %s, expected %s", g.StaleAfter, lockfile.DefaultSoftStaleAfter)
+	}
+	if _, err := lockfile.Create(path); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected contention on FAT volume, got: %v", err)
+	}
+
+	// The stale timeout of the fallback can be configured.
+	if _, err := lockfile.Create(path, lockfile.WithSoftLockFallback(time.Hour)); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected contention on FAT volume with a configured fallback, got: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package lockfile
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// Lock files created by other software often record their holder in a
+// simple text format. Inspect recognizes the common ones, so that a
+// mixed-language system can at least report who holds a foreign lock
+// file. Metadata parsed from a foreign format has a version of 0.
+const (
+	// FormatPID is a lock file that holds the process ID of its holder,
+	// as written by most PID file implementations.
+	FormatPID = "pid"
+
+	// FormatPIDHost is a lock file that holds the process ID of its holder
+	// and its hostname, on separate lines.
+	FormatPIDHost = "pid-host"
+
+	// FormatJava is a lock file that holds the process ID and hostname of
+	// its holder in the form "pid@hostname". This is the name of the Java
+	// runtime reported by RuntimeMXBean, which Java programs commonly
+	// write into the files they lock with FileChannel.lock.
+	FormatJava = "java"
+
+	// FormatSymlink is the target of a lock held through a
+	// [SymlinkBackend], in the form "pid@hostname:started:nonce". Started
+	// is the start time of the holder in nanoseconds since the Unix epoch,
+	// or 0 if it is unknown, and the nonce tells acquisitions apart.
+	FormatSymlink = "symlink"
+
+	// FormatHostPID is a lock file that holds the hostname and process ID
+	// of its holder in the form "hostname:pid", as written by the hard link
+	// technique for lock files on NFS and by [LinkBackend].
+	FormatHostPID = "host-pid"
+)
+
+// parseForeign attempts to parse data in one of the foreign formats. It
+// returns false if the format is not recognized.
+func parseForeign(data []byte) (format string, md Metadata, ok bool) {
+	lines := strings.Fields(string(bytes.TrimSpace(data)))
+
+	switch len(lines) {
+	case 1:
+		if pid, ok := parsePID(lines[0]); ok {
+			return FormatPID, Metadata{Holder: Holder{PID: pid}}, true
+		}
+		if holder, ok := parseSymlinkTarget(lines[0]); ok {
+			return FormatSymlink, Metadata{Holder: holder}, true
+		}
+		if id, host, found := strings.Cut(lines[0], "@"); found && host != "" {
+			if pid, ok := parsePID(id); ok {
+				return FormatJava, Metadata{Holder: Holder{PID: pid, Hostname: host}}, true
+			}
+		}
+		if host, id, found := strings.Cut(lines[0], ":"); found && host != "" {
+			if pid, ok := parsePID(id); ok {
+				return FormatHostPID, Metadata{Holder: Holder{PID: pid, Hostname: host}}, true
+			}
+		}
+	case 2:
+		if pid, ok := parsePID(lines[0]); ok {
+			return FormatPIDHost, Metadata{Holder: Holder{PID: pid, Hostname: lines[1]}}, true
+		}
+	}
+
+	return "", Metadata{}, false
+}
+
+// parsePID parses a process ID.
+func parsePID(s string) (int, bool) {
+	pid, err := strconv.Atoi(s)
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, true
+}
@@ -0,0 +1,57 @@
+package lockfile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestInspectForeign(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		format   string
+		pid      int
+		hostname string
+	}{
+		{"pid", "1234\n", lockfile.FormatPID, 1234, ""},
+		{"pid-host", "1234\nbuild-01\n", lockfile.FormatPIDHost, 1234, "build-01"},
+		{"java", "5678@worker.example.com", lockfile.FormatJava, 5678, "worker.example.com"},
+		{"host-pid", "build-01:4321\n", lockfile.FormatHostPID, 4321, "build-01"},
+	}
+
+	dir := t.TempDir()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, test.name+".lock")
+			if err := os.WriteFile(path, []byte(test.contents), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := lockfile.Inspect(path)
+			if err != nil {
+				t.Fatalf("Inspect failed: %v", err)
+			}
+			if info.Format != test.format {
+				t.Fatalf("unexpected format: %q", info.Format)
+			}
+			if info.Metadata.Version != 0 || info.Metadata.Holder.PID != test.pid || info.Metadata.Holder.Hostname != test.hostname {
+				t.Fatalf("unexpected metadata: %+v", *info.Metadata)
+			}
+		})
+	}
+}
+
+func TestInspectUnrecognized(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "garbage.lock")
+	if err := os.WriteFile(path, []byte("not a lock file\nat all\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := lockfile.Inspect(path); !errors.Is(err, lockfile.ErrInvalidMetadata) {
+		t.Fatalf("Inspect returned %v for unrecognized contents", err)
+	}
+}
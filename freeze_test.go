@@ -0,0 +1,88 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestManagerFreeze(t *testing.T) {
+	m := lockfile.NewManager()
+	dir := t.TempDir()
+
+	held, err := m.Create(filepath.Join(dir, "held.lock"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer held.Close()
+
+	m.Freeze()
+	if !m.Frozen() {
+		t.Fatal("the manager is not frozen")
+	}
+
+	path := filepath.Join(dir, "frozen.lock")
+	if _, err := m.Create(path); !errors.Is(err, lockfile.ErrFrozen) {
+		t.Fatalf("Create returned %v, expected an error that wraps %v", err, lockfile.ErrFrozen)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	acquired := make(chan error, 1)
+	go func() {
+		file, err := m.Wait(ctx, path)
+		if err == nil {
+			err = file.Close()
+		}
+		acquired <- err
+	}()
+
+	select {
+	case err := <-acquired:
+		t.Fatalf("Wait returned while the manager was frozen: %v", err)
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	m.Thaw()
+	if err := <-acquired; err != nil {
+		t.Fatalf("Wait failed after the manager was thawed: %v", err)
+	}
+	if m.Frozen() {
+		t.Fatal("the manager is still frozen")
+	}
+}
+
+func TestManagerFreezeCancel(t *testing.T) {
+	m := lockfile.NewManager()
+	m.Freeze()
+	defer m.Thaw()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	if _, err := m.Wait(ctx, filepath.Join(t.TempDir(), "frozen.lock")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait returned %v, expected %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestManagerFreezeFail(t *testing.T) {
+	m := lockfile.NewManager(lockfile.WithFreezePolicy(lockfile.FreezeFail))
+	m.Freeze()
+
+	path := filepath.Join(t.TempDir(), "frozen.lock")
+	if _, err := m.Wait(context.Background(), path); !errors.Is(err, lockfile.ErrFrozen) {
+		t.Fatalf("Wait returned %v, expected an error that wraps %v", err, lockfile.ErrFrozen)
+	}
+
+	m.Thaw()
+	file, err := m.Wait(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Wait failed after the manager was thawed: %v", err)
+	}
+	file.Close()
+}
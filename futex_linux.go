@@ -0,0 +1,327 @@
+//go:build linux
+
+package lockfile
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// The shared mutex is a word in a small memory-mapped file. The word holds
+// the ID of the holder, and a bit that records whether any waiters are
+// sleeping on it. It is acquired with an atomic compare-and-swap, so that
+// uncontended acquisition does not require a system call.
+//
+// Every SharedMutex that opens the file is given an ID of its own from a
+// counter that follows the word, and holds a lock file that is named after
+// the ID for as long as it is open. The lock file is the crash-recovery
+// backstop: it is created like any other lock file, so the kernel releases
+// its lock when the process exits, even if it crashes. A waiter can tell
+// whether the holder recorded in the word is still alive by attempting to
+// acquire the holder's lock file. IDs are not reused, so a holder that
+// exited is never mistaken for another process that happens to have the
+// same process ID.
+//
+// See the futex(2) man page, and "Futexes Are Tricky" by Ulrich Drepper,
+// for details.
+
+const (
+	sharedMutexSize = 4096
+
+	sharedMutexWaiters = 1 << 31 // Set when waiters may be sleeping
+	sharedMutexOwner   = sharedMutexWaiters - 1
+
+	// sharedMutexCounter is the offset of the counter that IDs are taken
+	// from.
+	sharedMutexCounter = 4
+
+	// sharedMutexPoll is the longest time a waiter sleeps before it checks
+	// whether the holder has died or its context has been cancelled.
+	sharedMutexPoll = time.Millisecond * 50
+
+	futexWait = 0
+	futexWake = 1
+)
+
+// SharedMutex is a mutual exclusion lock that is shared by cooperating
+// processes on the same host. It is only available on Linux.
+//
+// A SharedMutex is a hybrid of a futex and lock files. Acquiring an
+// uncontended SharedMutex takes a single atomic operation on shared memory,
+// which is much faster than creating a lock file. Waiters sleep on a futex
+// and are woken when the mutex is released. Lock files are only used to
+// recover from crashes: each SharedMutex holds one, created with [Create],
+// for as long as it is open. If a holder exits without releasing the
+// mutex, the operating system releases its lock file, and the next caller
+// that finds the lock file free recovers the mutex.
+//
+// A SharedMutex is identified by the path of its backing file, which is
+// not deleted when the mutex is closed. The lock files are created next to
+// it. The mutex is not reentrant: a goroutine that attempts to acquire a
+// mutex that it already holds through the same SharedMutex waits for it
+// to be released.
+//
+// A SharedMutex is safe for concurrent use by multiple goroutines, except
+// that [SharedMutex.Close] must not be called while other methods are in
+// progress.
+type SharedMutex struct {
+	path      string
+	cfg       *config
+	fd        int
+	mem       []byte
+	state     *atomic.Uint32
+	id        uint32
+	backstop  *File
+	recovered atomic.Bool
+	closed    atomic.Bool
+}
+
+// OpenSharedMutex opens the shared mutex backed by the file at the given
+// path, creating the file if necessary.
+//
+// Options configure the lock files that detect crashed holders, which are
+// created as they would be by [Create]. Crashes are only detected if the
+// operating system releases these lock files when their holder exits, as
+// it does for the default locks, so options such as [WithSoftLock] should
+// not be used.
+func OpenSharedMutex(path string, opts ...Option) (*SharedMutex, error) {
+	if path == "" {
+		return nil, ErrEmptyPath
+	}
+
+	fd, err := syscall.Open(path, syscall.O_RDWR|syscall.O_CREAT|syscall.O_CLOEXEC, 0600)
+	if err != nil {
+		return nil, pathError("open", path, err)
+	}
+
+	m, err := newSharedMutex(path, fd, newConfig(opts))
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// newSharedMutex maps the backing file that is open as fd, and holds a lock
+// file under a new ID for the mutex.
+func newSharedMutex(path string, fd int, cfg *config) (*SharedMutex, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Fstat(fd, &stat); err != nil {
+		return nil, pathError("fstat", path, err)
+	}
+
+	// Extend a new file to its full size. Files are never shrunk, so it is
+	// safe for several processes to do this at the same time.
+	if stat.Size < sharedMutexSize {
+		if err := syscall.Ftruncate(fd, sharedMutexSize); err != nil {
+			return nil, pathError("truncate", path, err)
+		}
+	}
+
+	mem, err := syscall.Mmap(fd, 0, sharedMutexSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, pathError("mmap", path, err)
+	}
+
+	m := &SharedMutex{
+		path:  path,
+		cfg:   cfg,
+		fd:    fd,
+		mem:   mem,
+		state: (*atomic.Uint32)(unsafe.Pointer(&mem[0])),
+	}
+
+	// The lock file must be held before the ID can be recorded as the
+	// holder, so that the holder is never mistaken for one that exited.
+	counter := (*atomic.Uint32)(unsafe.Pointer(&mem[sharedMutexCounter]))
+	for m.id == 0 {
+		m.id = counter.Add(1) & sharedMutexOwner
+	}
+	m.backstop, err = cfg.create(m.backstopPath(m.id))
+	if err != nil {
+		syscall.Munmap(mem)
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Path returns the path of the file that backs the mutex.
+func (m *SharedMutex) Path() string {
+	return m.path
+}
+
+// TryLock makes a single attempt to acquire the mutex. It returns true if
+// the mutex was acquired.
+func (m *SharedMutex) TryLock() (bool, error) {
+	if m.closed.Load() {
+		return false, &os.PathError{Op: "lock", Path: m.path, Err: os.ErrClosed}
+	}
+
+	if m.state.CompareAndSwap(0, m.id) {
+		m.recovered.Store(false)
+		return true, nil
+	}
+
+	if v := m.state.Load(); v != 0 {
+		return m.recover(v)
+	}
+
+	return false, nil
+}
+
+// Lock acquires the mutex, waiting until it is released by its holder or
+// ctx is cancelled.
+func (m *SharedMutex) Lock(ctx context.Context) error {
+	if ok, err := m.TryLock(); ok || err != nil {
+		return err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return withReason(err)
+		}
+
+		v := m.state.Load()
+
+		// Once we have slept, there may be other sleepers that we cannot
+		// see, so the mutex is acquired with the waiters bit set. This
+		// ensures that they are woken when it is released.
+		if v == 0 {
+			if m.state.CompareAndSwap(0, m.id|sharedMutexWaiters) {
+				m.recovered.Store(false)
+				return nil
+			}
+			continue
+		}
+
+		if ok, err := m.recover(v); ok || err != nil {
+			return err
+		}
+
+		if v&sharedMutexWaiters == 0 {
+			if !m.state.CompareAndSwap(v, v|sharedMutexWaiters) {
+				continue
+			}
+			v |= sharedMutexWaiters
+		}
+
+		// Sleep until the mutex is released. The sleep is bounded so that
+		// the death of the holder and the cancellation of ctx are noticed.
+		futex(m.state, futexWait, v, sharedMutexPoll)
+	}
+}
+
+// Unlock releases the mutex.
+//
+// It returns an [*os.PathError] that wraps [ErrNotHeld] if the mutex is
+// not held through m.
+func (m *SharedMutex) Unlock() error {
+	if m.closed.Load() {
+		return &os.PathError{Op: "unlock", Path: m.path, Err: os.ErrClosed}
+	}
+
+	for {
+		v := m.state.Load()
+		if v&sharedMutexOwner != m.id {
+			return &os.PathError{Op: "unlock", Path: m.path, Err: ErrNotHeld}
+		}
+		if m.state.CompareAndSwap(v, 0) {
+			if v&sharedMutexWaiters != 0 {
+				futex(m.state, futexWake, 1, 0)
+			}
+			return nil
+		}
+	}
+}
+
+// Recovered returns true if the most recent acquisition of the mutex by
+// this process took it over from a holder that exited without releasing
+// it. The data protected by the mutex may have been left in an
+// inconsistent state by the previous holder.
+func (m *SharedMutex) Recovered() bool {
+	return m.recovered.Load()
+}
+
+// Close unmaps the mutex, closes its backing file and releases its lock
+// file. It does not release the mutex if it is held through m, but other
+// processes recover it once its lock file has been released.
+//
+// It returns an [*os.PathError] that wraps [os.ErrClosed] if the function
+// has already been called.
+func (m *SharedMutex) Close() error {
+	if m.closed.Swap(true) {
+		return &os.PathError{Op: "close", Path: m.path, Err: os.ErrClosed}
+	}
+
+	err := syscall.Munmap(m.mem)
+	if closeErr := syscall.Close(m.fd); err == nil {
+		err = closeErr
+	}
+	err = pathError("close", m.path, err)
+	if closeErr := m.backstop.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// recover takes over the mutex if the state v records a holder that has
+// exited. It returns true if the mutex was acquired.
+func (m *SharedMutex) recover(v uint32) (bool, error) {
+	owner := v & sharedMutexOwner
+	if owner == m.id {
+		return false, nil
+	}
+
+	alive, err := m.alive(owner)
+	if err != nil || alive {
+		return false, err
+	}
+
+	// Preserve the waiters bit, so that any sleepers are woken when the
+	// mutex is released.
+	if !m.state.CompareAndSwap(v, m.id|v&sharedMutexWaiters) {
+		return false, nil
+	}
+
+	m.recovered.Store(true)
+	return true, nil
+}
+
+// alive returns true if the lock file of the holder with the given ID is
+// held. A lock file that is free is removed on the way, as it would be by
+// its holder.
+func (m *SharedMutex) alive(id uint32) (bool, error) {
+	file, err := m.cfg.create(m.backstopPath(id))
+	switch {
+	case err == nil:
+		return false, file.Close()
+	case m.cfg.isTemporary(err):
+		return true, nil
+	}
+	return false, err
+}
+
+// backstopPath returns the path of the lock file that is held by the
+// SharedMutex with the given ID.
+func (m *SharedMutex) backstopPath(id uint32) string {
+	return fmt.Sprintf("%s.%d.lock", m.path, id)
+}
+
+// futex performs a futex operation on the shared word. A timeout of zero
+// means no timeout. Errors are ignored, because callers always re-examine
+// the word afterward.
+func futex(word *atomic.Uint32, op int, val uint32, timeout time.Duration) {
+	var ts *syscall.Timespec
+	if timeout > 0 {
+		t := syscall.NsecToTimespec(int64(timeout))
+		ts = &t
+	}
+	syscall.Syscall6(syscall.SYS_FUTEX, uintptr(unsafe.Pointer(word)), uintptr(op), uintptr(val), uintptr(unsafe.Pointer(ts)), 0, 0)
+}
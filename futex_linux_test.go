@@ -0,0 +1,187 @@
+//go:build linux
+
+package lockfile_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func openSharedMutex(t testing.TB, path string) *lockfile.SharedMutex {
+	t.Helper()
+	m, err := lockfile.OpenSharedMutex(path)
+	if err != nil {
+		t.Fatalf("OpenSharedMutex failed: %v", err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func TestSharedMutex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.mutex")
+	a := openSharedMutex(t, path)
+	b := openSharedMutex(t, path)
+
+	if ok, err := a.TryLock(); !ok || err != nil {
+		t.Fatalf("first TryLock failed: %v, %v", ok, err)
+	}
+	if ok, err := b.TryLock(); ok || err != nil {
+		t.Fatalf("second TryLock succeeded while the mutex was held: %v, %v", ok, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	if err := b.Lock(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Lock returned %v while the mutex was held", err)
+	}
+
+	if err := a.Unlock(); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if err := a.Unlock(); !errors.Is(err, lockfile.ErrNotHeld) {
+		t.Fatalf("second Unlock returned %v", err)
+	}
+
+	if ok, err := b.TryLock(); !ok || err != nil {
+		t.Fatalf("TryLock failed after the mutex was released: %v, %v", ok, err)
+	}
+	if err := b.Unlock(); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+}
+
+func TestSharedMutexContention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.mutex")
+
+	const workers = 8
+	const iterations = 200
+
+	// The race detector cannot see synchronization through shared memory
+	// that is mapped at different addresses, so overlapping holders are
+	// detected with an atomic flag instead of a plain counter.
+	var (
+		wg     sync.WaitGroup
+		inside atomic.Bool
+	)
+	for range workers {
+		m := openSharedMutex(t, path)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range iterations {
+				if err := m.Lock(context.Background()); err != nil {
+					t.Errorf("Lock failed: %v", err)
+					return
+				}
+				if inside.Swap(true) {
+					t.Errorf("the mutex was held by two holders at once")
+				}
+				inside.Store(false)
+				if err := m.Unlock(); err != nil {
+					t.Errorf("Unlock failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSharedMutexRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.mutex")
+	m := openSharedMutex(t, path)
+
+	// Record a holder that holds no lock file, as though it had exited
+	// while holding the mutex.
+	var word [4]byte
+	binary.NativeEndian.PutUint32(word[:], 0x3fffffff)
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(word[:], 0); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if err := m.Lock(ctx); err != nil {
+		t.Fatalf("Lock failed to recover the mutex: %v", err)
+	}
+	if !m.Recovered() {
+		t.Fatalf("Recovered returned false after recovering the mutex")
+	}
+	if err := m.Unlock(); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+}
+
+func TestSharedMutexBackstop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.mutex")
+	m := openSharedMutex(t, path)
+
+	// Record a holder whose lock file, which is named after its ID, is
+	// still held. It is alive, so the mutex must not be recovered.
+	const id = 0x3ffffffe
+	backstop, err := lockfile.Create(fmt.Sprintf("%s.%d.lock", path, id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backstop.Close()
+
+	var word [4]byte
+	binary.NativeEndian.PutUint32(word[:], id)
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(word[:], 0); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if ok, err := m.TryLock(); ok || err != nil {
+		t.Fatalf("TryLock took the mutex from a live holder: %v, %v", ok, err)
+	}
+
+	// Once the lock file is released, as it would be by the operating
+	// system if the holder crashed, the mutex is recovered.
+	if err := backstop.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := m.TryLock(); !ok || err != nil {
+		t.Fatalf("TryLock failed to recover the mutex: %v, %v", ok, err)
+	}
+	if !m.Recovered() {
+		t.Fatalf("Recovered returned false after recovering the mutex")
+	}
+	if err := m.Unlock(); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+}
+
+func BenchmarkSharedMutex(b *testing.B) {
+	m := openSharedMutex(b, filepath.Join(b.TempDir(), "shared.mutex"))
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := m.Lock(ctx); err != nil {
+			b.Fatal(err)
+		}
+		if err := m.Unlock(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
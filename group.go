@@ -0,0 +1,121 @@
+package lockfile
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Group is a collection of goroutines working on subtasks of a common task.
+//
+// It is satisfied by [golang.org/x/sync/errgroup.Group], which allows
+// [AcquireGroup] to participate in errgroup-style code without this package
+// depending on it.
+type Group interface {
+	Go(f func() error)
+}
+
+// tryGroup is implemented by groups that decline to start a goroutine when
+// they are running as many as they allow, such as an errgroup.Group with a
+// limit.
+type tryGroup interface {
+	TryGo(f func() error) bool
+}
+
+// AcquireGroup waits for a lock file at each of the given paths. Each
+// waiter is launched as a goroutine within g, so that lock acquisition
+// takes part in the group's error handling.
+//
+// If any waiter fails, the remaining waiters are cancelled, any locks that
+// were already acquired are released, and the first error is returned. The
+// same error is returned by the failed goroutine within g.
+//
+// When all of the locks have been acquired, it returns a [LockSet] holding
+// them in the order they were actually acquired, which may differ from the
+// order of paths. Duplicate paths are only acquired once.
+//
+// AcquireGroup does not return until every waiter has finished, so g must
+// be able to run a waiter for each path at the same time, in addition to
+// the caller if it runs within g. If g limits the number of goroutines it
+// runs and implements TryGo, as an errgroup.Group does, the waiters are
+// launched with TryGo, and it returns an error that wraps [ErrGroupLimit]
+// if g has no room for one of them, rather than waiting for room that
+// would never be made. Other groups must start every goroutine that is
+// passed to Go without waiting for another to finish.
+//
+// Because the waiters run concurrently, two processes that call
+// AcquireGroup with overlapping paths can each end up holding a lock the
+// other is waiting on. Callers should supply a context with a deadline, or
+// acquire their locks in a consistent order with [AcquireAll].
+func AcquireGroup(ctx context.Context, g Group, paths ...string) (*LockSet, error) {
+	paths = uniquePaths(paths)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		firstErr error
+		files    = make([]*File, 0, len(paths)) // In acquisition order
+	)
+
+	fail := func(err error) {
+		mutex.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		mutex.Unlock()
+		cancel()
+	}
+
+	tg, canTry := g.(tryGroup)
+	for i, path := range paths {
+		wait := func() error {
+			defer wg.Done()
+
+			file, err := WaitCtx(ctx, path)
+			if err != nil {
+				fail(err)
+				return err
+			}
+
+			mutex.Lock()
+			files = append(files, file)
+			mutex.Unlock()
+			return nil
+		}
+
+		wg.Add(1)
+		if !canTry {
+			g.Go(wait)
+		} else if !tg.TryGo(wait) {
+			wg.Done()
+			fail(fmt.Errorf("%w: %d of %d waiters were started", ErrGroupLimit, i, len(paths)))
+			break
+		}
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		closeReverse(files)
+		return nil, firstErr
+	}
+
+	return newLockSet(files), nil
+}
+
+// uniquePaths returns paths with duplicate entries removed. The order of
+// the remaining entries is preserved.
+func uniquePaths(paths []string) []string {
+	seen := make(map[string]struct{}, len(paths))
+	unique := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if _, exists := seen[path]; exists {
+			continue
+		}
+		seen[path] = struct{}{}
+		unique = append(unique, path)
+	}
+	return unique
+}
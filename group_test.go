@@ -0,0 +1,178 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+// testGroup is a minimal errgroup-style group.
+type testGroup struct {
+	wg    sync.WaitGroup
+	mutex sync.Mutex
+	err   error
+}
+
+func (g *testGroup) Go(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := f(); err != nil {
+			g.mutex.Lock()
+			if g.err == nil {
+				g.err = err
+			}
+			g.mutex.Unlock()
+		}
+	}()
+}
+
+func (g *testGroup) Wait() error {
+	g.wg.Wait()
+	return g.err
+}
+
+// limitedGroup is an errgroup-style group that runs at most limit
+// goroutines at a time, and declines to start more with TryGo.
+type limitedGroup struct {
+	testGroup
+	slots chan struct{}
+}
+
+func newLimitedGroup(limit int) *limitedGroup {
+	return &limitedGroup{slots: make(chan struct{}, limit)}
+}
+
+func (g *limitedGroup) Go(f func() error) {
+	g.slots <- struct{}{}
+	g.testGroup.Go(func() error {
+		defer func() { <-g.slots }()
+		return f()
+	})
+}
+
+func (g *limitedGroup) TryGo(f func() error) bool {
+	select {
+	case g.slots <- struct{}{}:
+	default:
+		return false
+	}
+	g.testGroup.Go(func() error {
+		defer func() { <-g.slots }()
+		return f()
+	})
+	return true
+}
+
+func TestAcquireGroup(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{
+		filepath.Join(dir, "a.lock"),
+		filepath.Join(dir, "b.lock"),
+		filepath.Join(dir, "c.lock"),
+	}
+
+	var g testGroup
+	set, err := lockfile.AcquireGroup(context.Background(), &g, paths...)
+	if err != nil {
+		t.Fatalf("AcquireGroup failed: %v", err)
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatalf("group returned an error: %v", err)
+	}
+	if set.Len() != len(paths) {
+		t.Fatalf("lock set holds %d locks, expected %d", set.Len(), len(paths))
+	}
+	if err := set.Close(); err != nil {
+		t.Fatalf("closing the lock set failed: %v", err)
+	}
+}
+
+func TestAcquireGroupFailure(t *testing.T) {
+	dir := t.TempDir()
+	free := filepath.Join(dir, "free.lock")
+	held := filepath.Join(dir, "held.lock")
+
+	lock, err := lockfile.Create(held)
+	if err != nil {
+		t.Fatalf("failed to create lock file: %v", err)
+	}
+	defer lock.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	var g testGroup
+	if _, err := lockfile.AcquireGroup(ctx, &g, free, held); err == nil {
+		t.Fatalf("AcquireGroup succeeded while a lock was held")
+	}
+	if err := g.Wait(); err == nil {
+		t.Fatalf("group did not record the acquisition failure")
+	}
+
+	// The lock that was acquired should have been released.
+	other, err := lockfile.Create(free)
+	if err != nil {
+		t.Fatalf("lock was not released after group failure: %v", err)
+	}
+	other.Close()
+}
+
+func TestAcquireGroupLimit(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{
+		filepath.Join(dir, "a.lock"),
+		filepath.Join(dir, "b.lock"),
+		filepath.Join(dir, "c.lock"),
+	}
+
+	// The caller runs within the group, so a limit of three leaves room
+	// for only two of the waiters.
+	g := newLimitedGroup(3)
+	result := make(chan error, 1)
+	g.Go(func() error {
+		set, err := lockfile.AcquireGroup(context.Background(), g, paths...)
+		if err == nil {
+			set.Close()
+		}
+		result <- err
+		return nil
+	})
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, lockfile.ErrGroupLimit) {
+			t.Fatalf("expected ErrGroupLimit, got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("AcquireGroup did not return when the group had no room")
+	}
+	g.Wait()
+
+	// The locks that were acquired should have been released.
+	for _, path := range paths {
+		file, err := lockfile.Create(path)
+		if err != nil {
+			t.Fatalf("lock was not released after the group ran out of room: %v", err)
+		}
+		file.Close()
+	}
+
+	// With room for every waiter, it succeeds from within the group.
+	g = newLimitedGroup(len(paths) + 1)
+	g.Go(func() error {
+		set, err := lockfile.AcquireGroup(context.Background(), g, paths...)
+		if err != nil {
+			return err
+		}
+		return set.Close()
+	})
+	if err := g.Wait(); err != nil {
+		t.Fatalf("AcquireGroup failed within a group with enough room: %v", err)
+	}
+}
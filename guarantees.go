@@ -0,0 +1,69 @@
+package lockfile
+
+import "time"
+
+// Guarantees describes the properties of a lock file, which depend on the
+// mode it was created with and the platform it was created on.
+type Guarantees struct {
+	// Exclusive is true if at most one holder can acquire the lock file
+	// at a time, provided that all competitors use the same mode.
+	Exclusive bool
+
+	// KernelLock is true if the operating system tracks the lock and
+	// releases it automatically when the holder exits, even if the holder
+	// crashes.
+	KernelLock bool
+
+	// StaleAfter is the age after which an abandoned lock file is
+	// considered stale and may be broken by another caller. It is zero if
+	// lock files are never broken based on their age.
+	StaleAfter time.Duration
+}
+
+// BackendGuarantor is implemented by a [Backend] that describes the
+// guarantees provided by its locks. The locks of a backend that does not
+// implement it are only described as exclusive, unless they are shared.
+type BackendGuarantor interface {
+	// Guarantees returns the guarantees provided by the locks of the
+	// backend that are acquired as shared locks if shared is true, and as
+	// exclusive locks otherwise.
+	Guarantees(shared bool) Guarantees
+}
+
+// GuaranteesOf returns the guarantees provided by lock files that are
+// created with the given options.
+func GuaranteesOf(opts ...Option) Guarantees {
+	cfg := newConfig(opts)
+	return cfg.guarantees(cfg.soft, cfg.shared)
+}
+
+// Guarantees returns the guarantees provided by the lock file.
+//
+// This can differ from the guarantees described by [GuaranteesOf] for the
+// same options, if the lock file was created in soft-lock mode because its
+// filesystem does not reliably support file locking.
+func (f *File) Guarantees() Guarantees {
+	return f.h.cfg.guarantees(f.h.soft, f.h.shared)
+}
+
+// guarantees returns the guarantees provided by lock files that are
+// created with the configuration, in soft-lock mode or otherwise, and as
+// shared or exclusive locks.
+func (c *config) guarantees(soft, shared bool) Guarantees {
+	switch {
+	case c.backend != nil:
+		if g, ok := c.backend.(BackendGuarantor); ok {
+			return g.Guarantees(shared)
+		}
+		return Guarantees{Exclusive: !shared}
+	case soft:
+		return Guarantees{
+			Exclusive:  true,
+			StaleAfter: max(c.softStaleTimeout(), 0),
+		}
+	}
+	return Guarantees{
+		Exclusive:  !shared,
+		KernelLock: true,
+	}
+}
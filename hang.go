@@ -0,0 +1,98 @@
+package lockfile
+
+import (
+	"sync"
+	"time"
+)
+
+// WithOpTimeout returns an option that limits the amount of time each
+// operating system operation on a lock file is allowed to take.
+//
+// On hard-mounted network filesystems, operations such as open, stat and
+// unlink can hang indefinitely and ignore context cancellation. When a
+// timeout is configured, each operation runs on its own goroutine. If the
+// operation does not complete in time, it is abandoned and a [*HangError]
+// is returned, so that the caller can degrade gracefully instead of
+// freezing.
+//
+// An abandoned goroutine remains blocked until the operation completes.
+// If an abandoned open eventually succeeds, the file it opened is closed.
+//
+// A timeout of zero or less disables the limit, which is the default.
+func WithOpTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.opTimeout = timeout
+	}
+}
+
+// perform calls fn, abandoning it if it does not complete within the
+// configured operation timeout. If a worker pool has been configured, fn
+// runs within it.
+//
+// If fn is abandoned and later succeeds, undo is called to release any
+// resources that it acquired. undo may be nil.
+func (c *config) perform(op Op, path string, fn func() error, undo func()) error {
+	if c.opTimeout <= 0 && c.pool == nil {
+		return fn()
+	}
+
+	var timeout <-chan time.Time
+	if c.opTimeout > 0 {
+		timer := time.NewTimer(c.opTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	// Wait for a free slot in the worker pool.
+	if c.pool != nil {
+		select {
+		case c.pool.slots <- struct{}{}:
+		case <-timeout:
+			return &HangError{Op: op, Path: path, Timeout: c.opTimeout}
+		}
+	}
+
+	var (
+		mutex     sync.Mutex
+		abandoned bool
+		done      = make(chan error, 1)
+	)
+
+	go func() {
+		if c.pool != nil {
+			defer c.pool.release()
+		}
+
+		err := fn()
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if abandoned {
+			if err == nil && undo != nil {
+				undo()
+			}
+			return
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timeout:
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// The operation may have completed while we were acquiring the mutex.
+	select {
+	case err := <-done:
+		return err
+	default:
+	}
+
+	abandoned = true
+	return &HangError{Op: op, Path: path, Timeout: c.opTimeout}
+}
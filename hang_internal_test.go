@@ -0,0 +1,69 @@
+package lockfile
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPerformAbandonsHungOperation(t *testing.T) {
+	cfg := newConfig([]Option{WithOpTimeout(time.Millisecond * 10)})
+
+	release := make(chan struct{})
+	undone := make(chan struct{})
+
+	err := cfg.perform(OpOpen, "hung.lock", func() error {
+		<-release
+		return nil
+	}, func() {
+		close(undone)
+	})
+	if !errors.Is(err, ErrFilesystemHang) {
+		t.Fatalf("expected ErrFilesystemHang, got: %v", err)
+	}
+
+	// Let the abandoned operation complete, and make sure that its result
+	// is undone.
+	close(release)
+	select {
+	case <-undone:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("the abandoned operation was not undone")
+	}
+}
+
+func TestPerformWorkerPool(t *testing.T) {
+	pool, err := NewWorkerPool(1)
+	if err != nil {
+		t.Fatalf("failed to create worker pool: %v", err)
+	}
+	cfg := newConfig([]Option{WithWorkerPool(pool), WithOpTimeout(time.Millisecond * 10)})
+
+	// Occupy the only slot in the pool with a hung operation.
+	release := make(chan struct{})
+	defer close(release)
+
+	err = cfg.perform(OpOpen, "hung.lock", func() error {
+		<-release
+		return nil
+	}, nil)
+	if !errors.Is(err, ErrFilesystemHang) {
+		t.Fatalf("expected ErrFilesystemHang, got: %v", err)
+	}
+	if busy := pool.Busy(); busy != 1 {
+		t.Fatalf("expected 1 busy worker, got %d", busy)
+	}
+
+	// A second operation should time out while waiting for a slot.
+	ran := false
+	err = cfg.perform(OpStat, "hung.lock", func() error {
+		ran = true
+		return nil
+	}, nil)
+	if !errors.Is(err, ErrFilesystemHang) {
+		t.Fatalf("expected ErrFilesystemHang, got: %v", err)
+	}
+	if ran {
+		t.Fatalf("an operation ran without a free slot in the worker pool")
+	}
+}
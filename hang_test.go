@@ -0,0 +1,32 @@
+package lockfile_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestOpTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hang.lock")
+
+	lock, err := lockfile.Create(path, lockfile.WithOpTimeout(time.Second*10))
+	if err != nil {
+		t.Fatalf("failed to create lock file with an operation timeout: %v", err)
+	}
+	if err := lock.Close(); err != nil {
+		t.Fatalf("failed to close lock file with an operation timeout: %v", err)
+	}
+}
+
+func TestHangError(t *testing.T) {
+	var err error = &lockfile.HangError{Op: lockfile.OpOpen, Path: "test.lock", Timeout: time.Second}
+	if !errors.Is(err, lockfile.ErrFilesystemHang) {
+		t.Fatalf("HangError does not match ErrFilesystemHang")
+	}
+	if lockfile.IsTemporary(err) {
+		t.Fatalf("HangError should not be considered temporary")
+	}
+}
@@ -0,0 +1,261 @@
+package lockfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Heatmap aggregates rolling statistics about contention for each lock file
+// path, so that the lock files that are bottlenecks can be found.
+//
+// A Heatmap is fed by each [Manager] that it is installed in by
+// [WithHeatmap]. It only considers events within its window, so that its
+// reports reflect recent behavior. It is safe for concurrent use and may
+// be shared by many managers.
+type Heatmap struct {
+	window time.Duration
+	mutex  sync.Mutex
+	paths  map[string]*heat
+}
+
+// heat holds the recent events recorded for one lock file path.
+type heat struct {
+	acquired []heatSample // Wait durations of acquisitions
+	released []heatSample // Hold durations of releases
+	failed   []time.Time  // Attempts that failed because of contention
+}
+
+// heatSample is a duration recorded at a point in time.
+type heatSample struct {
+	at       time.Time
+	duration time.Duration
+}
+
+// maxHeatSamples is the maximum number of samples of each kind that are
+// kept for a path. The oldest samples are discarded first.
+const maxHeatSamples = 1024
+
+// HeatStats describes the recent contention for a lock file path, as
+// reported by [Heatmap.Report].
+type HeatStats struct {
+	Path string
+
+	// Acquisitions is the number of times that the lock file was acquired.
+	Acquisitions int
+
+	// Contended is the number of attempts to acquire the lock file that
+	// had to wait for it, or that failed because it was held.
+	Contended int
+
+	// ContentionRate is the fraction of attempts to acquire the lock file
+	// that were contended, between 0 and 1.
+	ContentionRate float64
+
+	// WaitP50 and WaitP99 are percentiles of the time spent waiting for
+	// the lock file by acquisitions.
+	WaitP50 time.Duration
+	WaitP99 time.Duration
+
+	// TotalWait is the total time spent waiting for the lock file by
+	// acquisitions.
+	TotalWait time.Duration
+
+	// HoldP50 and HoldP99 are percentiles of the time that the lock file
+	// was held for, by holders that have released it.
+	HoldP50 time.Duration
+	HoldP99 time.Duration
+}
+
+// NewHeatmap returns a [Heatmap] that considers events within the given
+// window.
+func NewHeatmap(window time.Duration) *Heatmap {
+	return &Heatmap{
+		window: window,
+		paths:  make(map[string]*heat),
+	}
+}
+
+// WithHeatmap returns an option that records each attempt to acquire a lock
+// file by a [Manager], and each release of one, in the given heatmap.
+func WithHeatmap(heatmap *Heatmap) Option {
+	return func(c *config) {
+		c.heatmap = heatmap
+	}
+}
+
+// Report returns the statistics of the n hottest lock file paths, hottest
+// first. If n is zero or less, every path is reported.
+//
+// Paths are ranked by the number of contended attempts to acquire them,
+// and then by the total time spent waiting for them.
+func (h *Heatmap) Report(n int) []HeatStats {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	cutoff := time.Now().Add(-h.window)
+	report := make([]HeatStats, 0, len(h.paths))
+	for path, heat := range h.paths {
+		heat.prune(cutoff)
+		if heat.empty() {
+			delete(h.paths, path)
+			continue
+		}
+		report = append(report, heat.stats(path))
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		switch {
+		case a.Contended != b.Contended:
+			return a.Contended > b.Contended
+		case a.TotalWait != b.TotalWait:
+			return a.TotalWait > b.TotalWait
+		}
+		return a.Path < b.Path
+	})
+
+	if n > 0 && len(report) > n {
+		report = report[:n]
+	}
+	return report
+}
+
+// WriteReport writes a table of the n hottest lock file paths to w, as
+// reported by [Heatmap.Report].
+func (h *Heatmap) WriteReport(w io.Writer, n int) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tACQUIRED\tCONTENDED\tRATE\tWAIT P50\tWAIT P99\tHOLD P50\tHOLD P99")
+	for _, s := range h.Report(n) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f%%\t%v\t%v\t%v\t%v\n",
+			s.Path, s.Acquisitions, s.Contended, s.ContentionRate*100,
+			s.WaitP50, s.WaitP99, s.HoldP50, s.HoldP99)
+	}
+	return tw.Flush()
+}
+
+// Reset discards all recorded events.
+func (h *Heatmap) Reset() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	clear(h.paths)
+}
+
+// acquired records the acquisition of the lock file at path.
+func (h *Heatmap) acquired(path string, stats Stats) {
+	h.record(path, func(heat *heat, now time.Time) {
+		heat.acquired = appendSample(heat.acquired, heatSample{at: now, duration: stats.Wait})
+	})
+}
+
+// released records the release of the lock file at path, which was held
+// for the given duration.
+func (h *Heatmap) released(path string, held time.Duration) {
+	h.record(path, func(heat *heat, now time.Time) {
+		heat.released = appendSample(heat.released, heatSample{at: now, duration: held})
+	})
+}
+
+// failed records an attempt to acquire the lock file at path that failed
+// with err, if it failed because of contention.
+func (h *Heatmap) failed(path string, err error) {
+	if !IsTemporary(err) && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+		return
+	}
+	h.record(path, func(heat *heat, now time.Time) {
+		heat.failed = appendSample(heat.failed, now)
+	})
+}
+
+// record calls fn with the events recorded for path, after pruning the
+// ones that have fallen out of the window.
+func (h *Heatmap) record(path string, fn func(heat *heat, now time.Time)) {
+	if h == nil {
+		return
+	}
+
+	now := time.Now()
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entry, found := h.paths[path]
+	if !found {
+		entry = &heat{}
+		h.paths[path] = entry
+	}
+	entry.prune(now.Add(-h.window))
+	fn(entry, now)
+}
+
+// prune discards the events that happened before cutoff.
+func (h *heat) prune(cutoff time.Time) {
+	before := func(s heatSample) bool { return s.at.Before(cutoff) }
+	h.acquired = slices.DeleteFunc(h.acquired, before)
+	h.released = slices.DeleteFunc(h.released, before)
+	h.failed = slices.DeleteFunc(h.failed, func(t time.Time) bool { return t.Before(cutoff) })
+}
+
+// empty returns true if no events are recorded.
+func (h *heat) empty() bool {
+	return len(h.acquired) == 0 && len(h.released) == 0 && len(h.failed) == 0
+}
+
+// stats summarizes the events recorded for path.
+func (h *heat) stats(path string) HeatStats {
+	s := HeatStats{
+		Path:         path,
+		Acquisitions: len(h.acquired),
+		Contended:    len(h.failed),
+	}
+
+	waits := make([]time.Duration, len(h.acquired))
+	for i, sample := range h.acquired {
+		waits[i] = sample.duration
+		s.TotalWait += sample.duration
+		if sample.duration > 0 {
+			s.Contended++
+		}
+	}
+	if attempts := len(h.acquired) + len(h.failed); attempts > 0 {
+		s.ContentionRate = float64(s.Contended) / float64(attempts)
+	}
+	s.WaitP50, s.WaitP99 = percentiles(waits)
+
+	holds := make([]time.Duration, len(h.released))
+	for i, sample := range h.released {
+		holds[i] = sample.duration
+	}
+	s.HoldP50, s.HoldP99 = percentiles(holds)
+
+	return s
+}
+
+// appendSample appends sample to samples, discarding the oldest sample if
+// there are too many.
+func appendSample[T any](samples []T, sample T) []T {
+	if len(samples) >= maxHeatSamples {
+		samples = slices.Delete(samples, 0, len(samples)-maxHeatSamples+1)
+	}
+	return append(samples, sample)
+}
+
+// percentiles returns the 50th and 99th percentiles of durations, using
+// the nearest-rank method. The durations are sorted in place.
+func percentiles(durations []time.Duration) (p50, p99 time.Duration) {
+	if len(durations) == 0 {
+		return 0, 0
+	}
+	slices.Sort(durations)
+	rank := func(p int) time.Duration {
+		i := (p*len(durations)+99)/100 - 1
+		return durations[max(i, 0)]
+	}
+	return rank(50), rank(99)
+}
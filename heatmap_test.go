@@ -0,0 +1,86 @@
+package lockfile_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestHeatmap(t *testing.T) {
+	dir := t.TempDir()
+	hot := filepath.Join(dir, "hot.lock")
+	cold := filepath.Join(dir, "cold.lock")
+
+	heatmap := lockfile.NewHeatmap(time.Minute)
+	manager := lockfile.NewManager(lockfile.WithHeatmap(heatmap))
+
+	file, err := manager.Create(cold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	holder, err := manager.Create(hot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.Create(hot); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected contention, got: %v", err)
+	}
+
+	time.AfterFunc(50*time.Millisecond, func() { holder.Close() })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	waiter, err := manager.Wait(ctx, hot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := waiter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	report := heatmap.Report(0)
+	if len(report) != 2 {
+		t.Fatalf("expected two paths in the report, got: %+v", report)
+	}
+	if report[0].Path != hot || report[1].Path != cold {
+		t.Fatalf("unexpected ranking: %+v", report)
+	}
+
+	stats := report[0]
+	if stats.Acquisitions != 2 || stats.Contended != 2 {
+		t.Errorf("unexpected counts for the hot lock: %+v", stats)
+	}
+	if stats.ContentionRate <= 0.5 || stats.ContentionRate > 1 {
+		t.Errorf("unexpected contention rate for the hot lock: %v", stats.ContentionRate)
+	}
+	if stats.WaitP99 <= 0 || stats.HoldP99 < 50*time.Millisecond {
+		t.Errorf("unexpected percentiles for the hot lock: %+v", stats)
+	}
+	if report[1].Contended != 0 || report[1].ContentionRate != 0 {
+		t.Errorf("unexpected contention for the cold lock: %+v", report[1])
+	}
+
+	if top := heatmap.Report(1); len(top) != 1 || top[0].Path != hot {
+		t.Errorf("unexpected top report: %+v", top)
+	}
+
+	var b strings.Builder
+	if err := heatmap.WriteReport(&b, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), hot) || strings.Contains(b.String(), cold) {
+		t.Errorf("unexpected written report:\n%s", b.String())
+	}
+
+	heatmap.Reset()
+	if report := heatmap.Report(0); len(report) != 0 {
+		t.Errorf("expected an empty report after a reset, got: %+v", report)
+	}
+}
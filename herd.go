@@ -0,0 +1,228 @@
+package lockfile
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithHerdControl returns an option that protects a lock file from a
+// thundering herd of waiters within the current process.
+//
+// When many goroutines wait for the same lock file, each of them probes
+// the filesystem on every retry, and every release wakes all of them at
+// once. Once threshold or more goroutines in the process are waiting for
+// the same lock file through [WaitCtx] or [WaitUntil], only one of them
+// probes the filesystem on behalf of the others, which are parked in an
+// in-process queue. When the probing waiter acquires the lock file or
+// stops waiting, the waiter that has been parked the longest takes its
+// place.
+//
+// The first time that a herd reaches a threshold above 1, an
+// [*os.PathError] that wraps [ErrThunderingHerd] is reported to the
+// Warning hook. The herds that are currently waiting are reported by
+// [Herds], whether or not herd control is enabled.
+//
+// A threshold of 1 always serializes waiters. A threshold of zero or less
+// disables herd control, which is the default.
+func WithHerdControl(threshold int) Option {
+	return func(c *config) {
+		c.herdThreshold = threshold
+	}
+}
+
+// HerdStats describes the goroutines within the current process that are
+// waiting for a lock file, as reported by [Herds].
+type HerdStats struct {
+	Path string // The absolute path of the lock file
+
+	// Waiters is the number of goroutines that are waiting for the lock
+	// file, and Peak is the largest number that have waited at once since
+	// the herd formed.
+	Waiters int
+	Peak    int
+
+	// Parked is the number of waiters that are parked by [WithHerdControl]
+	// while another waiter probes the filesystem on their behalf.
+	Parked int
+
+	// Attempts is the number of attempts to acquire the lock file that the
+	// herd has made since it formed. An attempt count that grows much
+	// faster than the number of waiters indicates a retry storm.
+	Attempts int
+
+	// Since is the time at which the first of the current waiters started
+	// waiting.
+	Since time.Time
+}
+
+// Herds returns statistics about each lock file that goroutines within the
+// current process are waiting for, in order of their paths. A lock file is
+// reported from the time that its first waiter starts waiting until its
+// last waiter stops.
+func Herds() []HerdStats {
+	herds.mutex.Lock()
+	defer herds.mutex.Unlock()
+
+	stats := make([]HerdStats, 0, len(herds.paths))
+	for _, h := range herds.paths {
+		stats = append(stats, HerdStats{
+			Path:     h.path,
+			Waiters:  h.waiters,
+			Peak:     h.peak,
+			Parked:   h.parked,
+			Attempts: h.attempts,
+			Since:    h.since,
+		})
+	}
+	slices.SortFunc(stats, func(a, b HerdStats) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return stats
+}
+
+// herd tracks the goroutines within the process that are waiting for one
+// lock file.
+//
+// Its fields are protected by herds.mutex.
+type herd struct {
+	path  string
+	since time.Time
+	turn  chan struct{} // Holds a token while no waiter probes for the herd
+
+	waiters  int
+	peak     int
+	parked   int
+	attempts int
+}
+
+// herds is the registry of lock files that the process is waiting for.
+var herds struct {
+	mutex sync.Mutex
+	paths map[string]*herd
+}
+
+// herdMember is a waiter that belongs to a herd.
+//
+// A nil herdMember is valid, and never parks.
+type herdMember struct {
+	herd      *herd
+	threshold int  // The size at which the herd is serialized, or zero
+	prober    bool // Whether the member holds the turn of the herd
+}
+
+// joinHerd adds a waiter for the lock file with the given path to its
+// herd, which is created if it does not exist. The waiter must call leave
+// once it stops waiting.
+func (c *config) joinHerd(path string) *herdMember {
+	if mapped, err := c.mapPath(path); err == nil {
+		path = mapped
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	herds.mutex.Lock()
+	h := herds.paths[path]
+	if h == nil {
+		if herds.paths == nil {
+			herds.paths = make(map[string]*herd)
+		}
+		h = &herd{path: path, since: time.Now(), turn: make(chan struct{}, 1)}
+		h.turn <- struct{}{}
+		herds.paths[path] = h
+	}
+	h.waiters++
+	h.peak = max(h.peak, h.waiters)
+	formed := c.herdThreshold > 0 && h.waiters == c.herdThreshold && h.peak == h.waiters
+	herds.mutex.Unlock()
+
+	if formed && c.herdThreshold > 1 {
+		c.warn(path, &os.PathError{Op: "wait", Path: path, Err: ErrThunderingHerd})
+	}
+
+	return &herdMember{herd: h, threshold: max(c.herdThreshold, 0)}
+}
+
+// park returns a channel that receives the turn of the herd if the member
+// must wait for it before making its next attempt. It returns nil if the
+// member may make its attempt immediately.
+//
+// A member that receives the turn must call promote.
+func (m *herdMember) park() <-chan struct{} {
+	if m == nil || m.threshold == 0 || m.prober {
+		return nil
+	}
+
+	herds.mutex.Lock()
+	defer herds.mutex.Unlock()
+
+	h := m.herd
+	if h.waiters < m.threshold {
+		return nil
+	}
+
+	select {
+	case <-h.turn:
+		m.prober = true
+		return nil
+	default:
+	}
+	h.parked++
+	return h.turn
+}
+
+// promote records that the member has received the turn of the herd from
+// the channel returned by park.
+func (m *herdMember) promote() {
+	herds.mutex.Lock()
+	defer herds.mutex.Unlock()
+
+	m.herd.parked--
+	m.prober = true
+}
+
+// unpark records that the member has stopped waiting for the turn of the
+// herd without receiving it.
+func (m *herdMember) unpark() {
+	herds.mutex.Lock()
+	defer herds.mutex.Unlock()
+
+	m.herd.parked--
+}
+
+// attempted records an attempt to acquire the lock file.
+func (m *herdMember) attempted() {
+	if m == nil {
+		return
+	}
+
+	herds.mutex.Lock()
+	defer herds.mutex.Unlock()
+
+	m.herd.attempts++
+}
+
+// leave removes the member from its herd. If it holds the turn of the
+// herd, the turn passes to the member that has been parked the longest.
+func (m *herdMember) leave() {
+	if m == nil {
+		return
+	}
+
+	herds.mutex.Lock()
+	defer herds.mutex.Unlock()
+
+	h := m.herd
+	if m.prober {
+		h.turn <- struct{}{}
+		m.prober = false
+	}
+	h.waiters--
+	if h.waiters == 0 {
+		delete(herds.paths, h.path)
+	}
+}
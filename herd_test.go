@@ -0,0 +1,120 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestHerdControl(t *testing.T) {
+	const waiters = 5
+	path := filepath.Join(t.TempDir(), "herd.lock")
+
+	held, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	var (
+		mutex  sync.Mutex
+		warned []error
+	)
+	hooks := lockfile.Hooks{
+		Warning: func(path string, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			warned = append(warned, err)
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	acquired := make(chan error, waiters)
+	for range waiters {
+		go func() {
+			file, err := lockfile.WaitCtx(ctx, path, lockfile.WithHerdControl(2), lockfile.WithHooks(hooks))
+			if err == nil {
+				err = file.Close()
+			}
+			acquired <- err
+		}()
+	}
+
+	// Wait for every waiter but one to be parked.
+	var herd lockfile.HerdStats
+	for herd.Parked != waiters-1 {
+		if ctx.Err() != nil {
+			t.Fatalf("the waiters were not parked: %+v", herd)
+		}
+		time.Sleep(time.Millisecond * 10)
+		herd, _ = findHerd(path)
+	}
+	if herd.Waiters != waiters || herd.Peak != waiters {
+		t.Errorf("the herd has %d waiters and a peak of %d, expected %d", herd.Waiters, herd.Peak, waiters)
+	}
+
+	if err := held.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	for range waiters {
+		if err := <-acquired; err != nil {
+			t.Fatalf("WaitCtx failed: %v", err)
+		}
+	}
+
+	if herd, ok := findHerd(path); ok {
+		t.Errorf("Herds reported %+v after every waiter finished", herd)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(warned) != 1 || !errors.Is(warned[0], lockfile.ErrThunderingHerd) {
+		t.Errorf("the warnings were %v, expected one that wraps %v", warned, lockfile.ErrThunderingHerd)
+	}
+}
+
+func TestHerdControlCancel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "herd.lock")
+
+	held, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer held.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := lockfile.WaitCtx(ctx, path, lockfile.WithHerdControl(1)); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("WaitCtx returned %v, expected %v", err, context.DeadlineExceeded)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if herd, ok := findHerd(path); ok {
+		t.Errorf("Herds reported %+v after every waiter gave up", herd)
+	}
+}
+
+// findHerd returns the herd reported by [lockfile.Herds] for the lock file
+// at path, if there is one.
+func findHerd(path string) (lockfile.HerdStats, bool) {
+	for _, herd := range lockfile.Herds() {
+		if herd.Path == path {
+			return herd, true
+		}
+	}
+	return lockfile.HerdStats{}, false
+}
@@ -0,0 +1,41 @@
+package lockfile
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Holder identifies the process that holds a lock file.
+//
+// Started and Boot distinguish the process from a later one that is given
+// the same process ID, after it exits or after the host reboots. They are
+// empty if they could not be determined.
+type Holder struct {
+	PID        int               `json:"pid"`
+	Started    time.Time         `json:"started,omitzero"`
+	Boot       string            `json:"boot,omitempty"`
+	Hostname   string            `json:"hostname,omitempty"`
+	Kubernetes *KubernetesHolder `json:"kubernetes,omitempty"`
+}
+
+// CurrentHolder returns a [Holder] that identifies the current process.
+//
+// When the process is running in Kubernetes, the holder is enriched with
+// the pod, namespace and node of the process, as described by
+// [DetectKubernetes].
+func CurrentHolder() Holder {
+	hostname, _ := os.Hostname()
+	return Holder{
+		PID:        os.Getpid(),
+		Started:    currentStarted(),
+		Boot:       bootID(),
+		Hostname:   hostname,
+		Kubernetes: DetectKubernetes(),
+	}
+}
+
+// currentStarted returns the time at which the current process started.
+var currentStarted = sync.OnceValue(func() time.Time {
+	return processStarted(os.Getpid())
+})
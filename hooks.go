@@ -0,0 +1,89 @@
+package lockfile
+
+import "time"
+
+// Op identifies an operating system operation that is performed on a lock
+// file.
+type Op string
+
+// Operations that are reported to [Hooks].
+const (
+	OpOpen    Op = "open"
+	OpFlock   Op = "flock"
+	OpStat    Op = "stat"
+	OpUnlink  Op = "unlink"
+	OpClose   Op = "close"
+	OpChtimes Op = "chtimes"
+)
+
+// OpAttempt identifies a whole attempt to acquire a lock file, which is
+// made up of several operations, in a [*HangError] caused by
+// [WithAttemptTimeout]. It is not reported to [Hooks].
+const OpAttempt Op = "attempt"
+
+// Hooks are optional callbacks that are invoked around each operating
+// system operation performed on a lock file.
+//
+// Hooks can be used to enforce policies, such as forbidding lock files
+// outside of a particular directory, or to collect fine-grained timing
+// information. Hooks may be called concurrently from multiple goroutines.
+type Hooks struct {
+	// BeforeOp is called before each operation is performed. If it returns
+	// an error, the operation is not performed and the error is returned
+	// to the caller.
+	BeforeOp func(op Op, path string) error
+
+	// AfterOp is called after each operation has been performed, with the
+	// result of the operation and the time it took.
+	AfterOp func(op Op, path string, err error, elapsed time.Duration)
+
+	// Warning is called when a problem is detected that does not prevent
+	// the operation from succeeding, but that weakens the guarantees that
+	// the lock file provides.
+	Warning func(path string, err error)
+}
+
+// WithHooks returns an option that installs the given hooks.
+func WithHooks(hooks Hooks) Option {
+	return func(c *config) {
+		c.hooks = hooks
+	}
+}
+
+// do performs an operation on the lock file at path by calling fn,
+// invoking any hooks that have been configured.
+func (c *config) do(op Op, path string, fn func() error) error {
+	return c.doUndo(op, path, fn, nil)
+}
+
+// doUndo performs an operation like do. If the operation is abandoned
+// because it timed out and later succeeds, undo is called to release any
+// resources that it acquired. undo may be nil.
+func (c *config) doUndo(op Op, path string, fn func() error, undo func()) error {
+	if c.hooks.BeforeOp == nil && c.hooks.AfterOp == nil {
+		return c.perform(op, path, fn, undo)
+	}
+
+	if c.hooks.BeforeOp != nil {
+		if err := c.hooks.BeforeOp(op, path); err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	err := c.perform(op, path, fn, undo)
+
+	if c.hooks.AfterOp != nil {
+		c.hooks.AfterOp(op, path, err, time.Since(start))
+	}
+
+	return err
+}
+
+// warn reports a warning for the lock file at path to the Warning hook, if
+// one has been configured.
+func (c *config) warn(path string, err error) {
+	if c.hooks.Warning != nil {
+		c.hooks.Warning(path, err)
+	}
+}
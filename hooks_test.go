@@ -0,0 +1,82 @@
+package lockfile_test
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestHooks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hooks.lock")
+
+	var (
+		mutex  sync.Mutex
+		before []lockfile.Op
+		after  []lockfile.Op
+	)
+
+	hooks := lockfile.WithHooks(lockfile.Hooks{
+		BeforeOp: func(op lockfile.Op, p string) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if p != path {
+				t.Errorf("BeforeOp received an unexpected path: %s", p)
+			}
+			before = append(before, op)
+			return nil
+		},
+		AfterOp: func(op lockfile.Op, p string, err error, elapsed time.Duration) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			after = append(after, op)
+		},
+	})
+
+	lock, err := lockfile.Create(path, hooks)
+	if err != nil {
+		t.Fatalf("failed to create lock file: %v", err)
+	}
+	if err := lock.Close(); err != nil {
+		t.Fatalf("failed to close lock file: %v", err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(before) == 0 {
+		t.Fatalf("no operations were reported")
+	}
+	if len(before) != len(after) {
+		t.Fatalf("BeforeOp was called %d times but AfterOp was called %d times", len(before), len(after))
+	}
+	if before[0] != lockfile.OpOpen {
+		t.Errorf("the first operation was %s, expected %s", before[0], lockfile.OpOpen)
+	}
+	if last := before[len(before)-1]; last != lockfile.OpClose {
+		t.Errorf("the last operation was %s, expected %s", last, lockfile.OpClose)
+	}
+}
+
+func TestHooksPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forbidden.lock")
+	errForbidden := errors.New("forbidden")
+
+	hooks := lockfile.WithHooks(lockfile.Hooks{
+		BeforeOp: func(op lockfile.Op, path string) error {
+			return errForbidden
+		},
+	})
+
+	lock, err := lockfile.Create(path, hooks)
+	if err == nil {
+		lock.Close()
+		t.Fatalf("lock file was created despite a forbidding policy")
+	}
+	if !errors.Is(err, errForbidden) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
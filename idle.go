@@ -0,0 +1,120 @@
+package lockfile
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithIdleDetection returns an option that watches a held lock file for
+// activity, and reports it when it has been held for longer than threshold
+// without any. Activity is recorded by calling [File.Touch] from the
+// critical section that the lock protects.
+//
+// It catches the classic bug of a code path that returns early without
+// closing the lock file, leaving the lock held long after the goroutine
+// that acquired it has moved on. An idle lock file is reported to the
+// Warning hook as an [*IdleError] that wraps [ErrIdle]. It is reported
+// once per idle period: it is reported again only if it is touched and
+// then becomes idle again. The lock remains held either way.
+//
+// A threshold of zero or less disables detection, which is the default.
+func WithIdleDetection(threshold time.Duration) Option {
+	return func(c *config) {
+		c.idleThreshold = threshold
+	}
+}
+
+// IdleError records a lock file that has been held without any activity
+// for longer than the threshold configured by [WithIdleDetection].
+type IdleError struct {
+	Path string
+	Idle time.Duration // Time since the lock file was acquired or touched
+}
+
+// Error returns a description of the idle lock file.
+func (e *IdleError) Error() string {
+	return fmt.Sprintf("lockfile: \"%s\" has been held without any activity for %s", e.Path, e.Idle)
+}
+
+// Is returns true if target is [ErrIdle].
+func (e *IdleError) Is(target error) bool {
+	return target == ErrIdle
+}
+
+// As sets target to [ReasonStale] if it is a [*ReasonCode].
+func (e *IdleError) As(target any) bool {
+	return setReason(target, ReasonStale)
+}
+
+// Touch records activity on the lock file, to show that the work it
+// protects is still in progress. It should be called periodically from the
+// critical section when [WithIdleDetection] is used, and costs little
+// enough to be called often. Touch has no effect on the lock file itself.
+func (f *File) Touch() {
+	f.h.activity.Store(time.Now().UnixNano())
+}
+
+// LastActivity returns the time at which the lock file was last touched by
+// [File.Touch], or the time at which it was acquired if it has never been
+// touched.
+func (f *File) LastActivity() time.Time {
+	return f.h.lastActivity()
+}
+
+// lastActivity returns the time of the last activity on the lock file.
+func (h *lockHandle) lastActivity() time.Time {
+	if touched := h.activity.Load(); touched != 0 {
+		return time.Unix(0, touched)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.stats.Acquired
+}
+
+// startIdleDetector starts watching the lock file for activity, until it
+// is released.
+func (h *lockHandle) startIdleDetector(threshold time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.idleStop = make(chan struct{})
+	go h.idleLoop(threshold, h.idleStop)
+}
+
+// idleLoop reports the lock file to the Warning hook each time it has been
+// idle for longer than threshold, until stop is closed.
+func (h *lockHandle) idleLoop(threshold time.Duration, stop <-chan struct{}) {
+	// The lock file is checked several times per threshold, so that it is
+	// reported soon after it becomes idle.
+	ticker := time.NewTicker(max(threshold/4, time.Millisecond))
+	defer ticker.Stop()
+
+	var reported time.Time // The last activity before the current report
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		last := h.lastActivity()
+		idle := time.Since(last)
+		if idle < threshold || last.Equal(reported) {
+			continue
+		}
+		reported = last
+		h.cfg.warn(h.path, &IdleError{Path: h.path, Idle: idle.Round(time.Millisecond)})
+	}
+}
+
+// stopIdleDetector stops watching the lock file for activity.
+//
+// The caller must hold h.mutex.
+func (h *lockHandle) stopIdleDetector() {
+	if h.idleStop != nil {
+		close(h.idleStop)
+		h.idleStop = nil
+	}
+}
@@ -0,0 +1,95 @@
+package lockfile_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestIdleDetection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idle.lock")
+
+	warnings := make(chan error, 4)
+	file, err := lockfile.Create(path,
+		lockfile.WithIdleDetection(50*time.Millisecond),
+		lockfile.WithHooks(lockfile.Hooks{
+			Warning: func(path string, err error) {
+				select {
+				case warnings <- err:
+				default:
+				}
+			},
+		}))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer file.Close()
+
+	select {
+	case err := <-warnings:
+		var idleErr *lockfile.IdleError
+		if !errors.As(err, &idleErr) || !errors.Is(err, lockfile.ErrIdle) || idleErr.Path != path {
+			t.Fatalf("unexpected warning: %v", err)
+		}
+		if lockfile.Reason(err) != lockfile.ReasonStale {
+			t.Fatalf("unexpected reason for an idle lock file: %v", lockfile.Reason(err))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the idle lock file was not reported")
+	}
+
+	// An idle lock file is only reported once per idle period.
+	select {
+	case err := <-warnings:
+		t.Fatalf("the idle lock file was reported again without activity: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Touching it starts a new period.
+	before := time.Now()
+	file.Touch()
+	if file.LastActivity().Before(before) {
+		t.Fatalf("LastActivity did not record the touch: %v", file.LastActivity())
+	}
+	select {
+	case <-warnings:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lock file was not reported after becoming idle again")
+	}
+}
+
+func TestIdleDetectionTouched(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "busy.lock")
+
+	warnings := make(chan error, 1)
+	file, err := lockfile.Create(path,
+		lockfile.WithIdleDetection(100*time.Millisecond),
+		lockfile.WithHooks(lockfile.Hooks{
+			Warning: func(path string, err error) {
+				select {
+				case warnings <- err:
+				default:
+				}
+			},
+		}))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	for deadline := time.Now().Add(300 * time.Millisecond); time.Now().Before(deadline); {
+		file.Touch()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	select {
+	case err := <-warnings:
+		t.Fatalf("a lock file that was touched was reported as idle: %v", err)
+	default:
+	}
+}
@@ -0,0 +1,111 @@
+package lockfile
+
+import (
+	"bytes"
+	"errors"
+	"os"
+)
+
+// Inspection describes the contents of a lock file, as reported by
+// [Inspect].
+type Inspection struct {
+	Path string
+
+	// Exists is true if the lock file exists.
+	Exists bool
+
+	// Held is true if the lock file is held. A soft lock file is held if
+	// it has not gone stale.
+	Held bool
+
+	// Format is the name of the codec that encoded the metadata, or one of
+	// the foreign formats, such as [FormatPID]. It is empty if the lock
+	// file has no contents, or if they could not be read.
+	Format string
+
+	// Metadata describes the holder of the lock file, if it was recorded.
+	Metadata *Metadata
+}
+
+// Inspect reports whether the lock file at path is held, along with the
+// holder metadata recorded in it, without acquiring it. The codec is
+// detected from the header written by [EncodeMetadata].
+//
+// The lock file is opened read-only, and is neither created nor removed,
+// so that inspecting it does not disturb its holder or anyone waiting for
+// it. This makes it suitable for monitoring tools that report on
+// contention.
+//
+// Lock files written by other software are parsed on a best-effort basis.
+// If their contents are in one of the foreign formats, such as
+// [FormatPID], the holder they record is reported.
+//
+// A missing lock file is not an error, and results in an inspection in
+// which Exists is false. Nor is a lock file without contents, or one whose
+// contents cannot be read, such as a lock file held on Windows by a holder
+// that does not write metadata. Either results in an inspection without
+// metadata. It returns an error that wraps [ErrInvalidMetadata] if the
+// contents cannot be decoded.
+//
+// Options may be provided to customize its behavior, such as
+// [WithSoftLock], which determines whether the lock file is held.
+func Inspect(path string, opts ...Option) (Inspection, error) {
+	return newConfig(opts).inspect(path)
+}
+
+// inspect reads the lock file at path, as described by [Inspect].
+func (c *config) inspect(path string) (Inspection, error) {
+	if c.err != nil {
+		return Inspection{}, c.err
+	}
+	mapped, err := c.mapPath(path)
+	if err != nil {
+		return Inspection{Path: path}, err
+	}
+	return c.inspectAt(mapped)
+}
+
+// inspectAt reads the lock file at path, to which the mapper has already
+// been applied.
+func (c *config) inspectAt(path string) (Inspection, error) {
+	result := Inspection{Path: path}
+
+	exists, held, err := c.probe(path)
+	if err != nil || !exists {
+		return result, err
+	}
+	result.Exists, result.Held = true, held
+
+	data, ok, err := c.readLock(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// A backend may hold locks without lock files.
+		if c.backend != nil {
+			return result, nil
+		}
+		// The lock file was removed since it was probed.
+		return Inspection{Path: path}, nil
+	}
+	if err != nil || !ok || len(bytes.TrimSpace(data)) == 0 {
+		return result, err
+	}
+
+	if format, md, ok := parseForeign(data); ok {
+		result.Format = format
+		result.Metadata = &md
+		return result, nil
+	}
+
+	codec, payload, err := detectCodec(data)
+	if err != nil {
+		return result, &os.PathError{Op: "inspect", Path: path, Err: err}
+	}
+
+	md, err := codec.Unmarshal(payload, IgnoreUnknownFields)
+	if err != nil {
+		return result, &os.PathError{Op: "inspect", Path: path, Err: err}
+	}
+
+	result.Format = codec.Name()
+	result.Metadata = &md
+	return result, nil
+}
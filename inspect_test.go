@@ -0,0 +1,77 @@
+package lockfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestInspectHeld(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "held.lock")
+
+	info, err := lockfile.Inspect(path)
+	if err != nil {
+		t.Fatalf("Inspect failed for a missing lock file: %v", err)
+	}
+	if info.Exists || info.Held || info.Metadata != nil {
+		t.Fatalf("unexpected inspection of a missing lock file: %+v", info)
+	}
+
+	file, err := lockfile.Create(path, lockfile.WithMetadata(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	info, err = lockfile.Inspect(path)
+	if err != nil {
+		t.Fatalf("Inspect failed for a held lock file: %v", err)
+	}
+	if !info.Exists || !info.Held {
+		t.Fatalf("expected the lock file to exist and be held: %+v", info)
+	}
+	if info.Metadata == nil || info.Metadata.Holder.PID != os.Getpid() {
+		t.Fatalf("unexpected metadata: %+v", info.Metadata)
+	}
+
+	// Inspecting the lock file must not disturb its holder.
+	if _, err := lockfile.Create(path); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected the lock file to still be held, got: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("failed to close the lock file after inspecting it: %v", err)
+	}
+
+	info, err = lockfile.Inspect(path)
+	if err != nil {
+		t.Fatalf("Inspect failed for a released lock file: %v", err)
+	}
+	if info.Exists || info.Held {
+		t.Fatalf("expected the released lock file to be gone: %+v", info)
+	}
+}
+
+func TestInspectLeftBehind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stale.lock")
+	if err := os.WriteFile(path, []byte("4242\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := lockfile.Inspect(path)
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if !info.Exists || info.Held {
+		t.Fatalf("expected the lock file to exist without being held: %+v", info)
+	}
+	if info.Format != lockfile.FormatPID || info.Metadata == nil || info.Metadata.Holder.PID != 4242 {
+		t.Fatalf("unexpected inspection: %+v", info)
+	}
+
+	// The lock file must be left in place.
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("the lock file was disturbed by Inspect: %v", err)
+	}
+}
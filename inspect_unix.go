@@ -0,0 +1,34 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+// readLockFile reads the contents of the existing lock file at path,
+// without creating, locking or removing it. It returns false if the lock
+// file exists but cannot be read, such as when it belongs to another user.
+func (c *config) readLockFile(path string) (data []byte, ok bool, err error) {
+	sys := c.system()
+
+	fd, err := sys.open(path, syscall.O_RDONLY, 0)
+	if errors.Is(err, syscall.EACCES) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, pathError("open", path, err)
+	}
+
+	file := os.NewFile(uintptr(fd), path)
+	defer sys.closeFile(path, file)
+
+	data, err = io.ReadAll(io.LimitReader(file, maxMetadataSize))
+	if err != nil {
+		return nil, false, pathError("read", path, err)
+	}
+	return data, true, nil
+}
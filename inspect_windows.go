@@ -0,0 +1,45 @@
+//go:build windows
+
+package lockfile
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// readLockFile reads the contents of the existing lock file at path,
+// without creating, locking or removing it. It returns false if the lock
+// file exists but cannot be read.
+//
+// Holders that write metadata share read access to their lock files, so
+// that their contents can be read while they are held. Holders that don't
+// share nothing, and their lock files cannot be read until they are
+// released, which is not an error.
+func (c *config) readLockFile(path string) (data []byte, ok bool, err error) {
+	const (
+		ERROR_ACCESS_DENIED     = syscall.Errno(5)
+		ERROR_SHARING_VIOLATION = syscall.Errno(32)
+	)
+
+	sys := c.system()
+
+	share := uint32(syscall.FILE_SHARE_READ | syscall.FILE_SHARE_WRITE | syscall.FILE_SHARE_DELETE)
+	handle, err := sys.open(path, syscall.GENERIC_READ, share, syscall.OPEN_EXISTING, 0)
+	switch err {
+	case nil:
+	case ERROR_SHARING_VIOLATION, ERROR_ACCESS_DENIED:
+		return nil, false, nil
+	default:
+		return nil, false, pathError("open", path, err)
+	}
+
+	file := os.NewFile(uintptr(handle), path)
+	defer sys.closeFile(path, file)
+
+	data, err = io.ReadAll(io.LimitReader(file, maxMetadataSize))
+	if err != nil {
+		return nil, false, pathError("read", path, err)
+	}
+	return data, true, nil
+}
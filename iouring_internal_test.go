@@ -0,0 +1,52 @@
+//go:build linux && lockfile_iouring
+
+package lockfile
+
+import (
+	"errors"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestUringSystem(t *testing.T) {
+	if uring() == nil {
+		t.Skip("io_uring is not available")
+	}
+
+	path := filepath.Join(t.TempDir(), "uring.lock")
+	sys := uringSystem{}
+
+	fd, err := sys.open(path, syscall.O_RDONLY|syscall.O_CREAT, 0400)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+
+	st1, err := sys.fstat(path, fd)
+	if err != nil {
+		t.Fatalf("fstat failed: %v", err)
+	}
+	st2, err := sys.stat(path)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	want, err := directSystem{}.stat(path)
+	if err != nil {
+		t.Fatalf("direct stat failed: %v", err)
+	}
+	for _, st := range []syscall.Stat_t{st1, st2} {
+		if st.Dev != want.Dev || st.Ino != want.Ino || st.Nlink != want.Nlink || st.Mode != want.Mode {
+			t.Fatalf("stat mismatch: got %+v, want %+v", st, want)
+		}
+	}
+
+	if err := sys.unlink(path); err != nil {
+		t.Fatalf("unlink failed: %v", err)
+	}
+	if _, err := sys.stat(path); !errors.Is(err, syscall.ENOENT) {
+		t.Fatalf("stat after unlink returned %v", err)
+	}
+	if err := sys.closeFd(path, fd); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package lockfile
+
+import (
+	"os"
+	"strings"
+)
+
+// KubernetesHolder identifies the Kubernetes pod that holds a lock file.
+type KubernetesHolder struct {
+	Pod       string `json:"pod,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Node      string `json:"node,omitempty"`
+}
+
+// kubernetesNamespaceFile holds the namespace of the pod's service account,
+// when one is mounted.
+const kubernetesNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// Environment variables that are conventionally populated from the
+// Kubernetes downward API. The first variable that is set takes precedence.
+var (
+	kubernetesPodEnv       = []string{"POD_NAME", "MY_POD_NAME", "K8S_POD_NAME"}
+	kubernetesNamespaceEnv = []string{"POD_NAMESPACE", "MY_POD_NAMESPACE", "K8S_POD_NAMESPACE"}
+	kubernetesNodeEnv      = []string{"NODE_NAME", "MY_NODE_NAME", "K8S_NODE_NAME"}
+)
+
+// DetectKubernetes returns the identity of the Kubernetes pod that the
+// current process is running in, or nil if it is not running in
+// Kubernetes.
+//
+// The process is considered to be running in Kubernetes if the
+// KUBERNETES_SERVICE_HOST environment variable is set. The pod name,
+// namespace and node are read from environment variables populated by the
+// downward API, such as POD_NAME, POD_NAMESPACE and NODE_NAME. If they are
+// not present, the pod name falls back to the hostname and the namespace
+// falls back to the namespace of the mounted service account.
+func DetectKubernetes() *KubernetesHolder {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return nil
+	}
+
+	k := &KubernetesHolder{
+		Pod:       firstEnv(kubernetesPodEnv),
+		Namespace: firstEnv(kubernetesNamespaceEnv),
+		Node:      firstEnv(kubernetesNodeEnv),
+	}
+
+	if k.Pod == "" {
+		k.Pod, _ = os.Hostname()
+	}
+	if k.Namespace == "" {
+		if data, err := os.ReadFile(kubernetesNamespaceFile); err == nil {
+			k.Namespace = strings.TrimSpace(string(data))
+		}
+	}
+
+	return k
+}
+
+// firstEnv returns the value of the first environment variable in names
+// that is set to a non-empty value.
+func firstEnv(names []string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
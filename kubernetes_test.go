@@ -0,0 +1,30 @@
+package lockfile_test
+
+import (
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestDetectKubernetes(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	t.Setenv("POD_NAME", "worker-0")
+	t.Setenv("POD_NAMESPACE", "batch")
+	t.Setenv("NODE_NAME", "node-a")
+
+	holder := lockfile.CurrentHolder()
+	if holder.Kubernetes == nil {
+		t.Fatalf("Kubernetes was not detected")
+	}
+	if got := *holder.Kubernetes; got != (lockfile.KubernetesHolder{Pod: "worker-0", Namespace: "batch", Node: "node-a"}) {
+		t.Fatalf("unexpected Kubernetes identity: %+v", got)
+	}
+}
+
+func TestDetectKubernetesAbsent(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+
+	if k := lockfile.DetectKubernetes(); k != nil {
+		t.Fatalf("Kubernetes was detected outside of Kubernetes: %+v", k)
+	}
+}
@@ -0,0 +1,208 @@
+package lockfile
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Lease is a lock file whose holder records in it the time at which its
+// hold expires, and renews it periodically in the background until it is
+// closed.
+//
+// Some network filesystems do not make the locks of one host visible to
+// others, so a holder that crashes may leave behind a lock file that
+// appears to be free on one host and held on another. A lease bounds how
+// long such a lock file is honored: processes on other hosts treat the
+// lock file as held until the lease expires, and as acquirable once it
+// has. Soft lock files held as leases are likewise considered stale once
+// their lease expires, regardless of their stale timeout.
+//
+// The lease is renewed at a third of its time-to-live, so that it survives
+// a missed renewal or two. If renewal fails, the error is reported by
+// [Lease.Err] and the Warning hook, and renewal is tried again on the next
+// interval. If the lease expires before it is renewed, other hosts may
+// take the lock file, so the lease moves to [StateLost] and an error that
+// wraps [ErrCompromised] is sent to the channels returned by
+// [File.Monitor], which cancels the contexts returned by [File.Context].
+// The lease is no longer renewed, but must still be closed.
+//
+// A Lease is released by calling its Close method, which stops renewal.
+type Lease struct {
+	*File
+
+	ttl time.Duration
+
+	mutex sync.Mutex
+	err   error // The result of the most recent renewal
+}
+
+// AcquireLease waits for a lock file with the given path like [WaitCtx],
+// and holds it as a [Lease] with the given time-to-live.
+//
+// Metadata is always written to the lock file, so that the expiration of
+// the lease can be recorded. It is encoded as configured by [WithMetadata],
+// if provided. If the metadata cannot be written, the lock file is
+// released and it returns an error that wraps [ErrMetadataUnwritable].
+//
+// It returns an error that wraps [ErrInvalidOption] if ttl is not
+// positive.
+func AcquireLease(ctx context.Context, path string, ttl time.Duration, opts ...Option) (*Lease, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("%w: the time-to-live of a lease must be positive", ErrInvalidOption)
+	}
+
+	c := newConfig(withLease(opts, ttl))
+	file, err := c.wait(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.h.expires.IsZero() {
+		file.Close()
+		return nil, &os.PathError{Op: "lease", Path: path, Err: ErrMetadataUnwritable}
+	}
+
+	lease := &Lease{File: file, ttl: ttl}
+	go lease.renewLoop()
+	return lease, nil
+}
+
+// withLease returns opts with an additional option that holds lock files
+// as leases with the given time-to-live. It does not modify opts.
+func withLease(opts []Option, ttl time.Duration) []Option {
+	return append(opts[:len(opts):len(opts)], func(c *config) {
+		c.metadata = true
+		c.leaseTTL = ttl
+	})
+}
+
+// TTL returns the time-to-live of the lease.
+func (l *Lease) TTL() time.Duration {
+	return l.ttl
+}
+
+// Expires returns the time at which the lease expires, unless it is
+// renewed.
+func (l *Lease) Expires() time.Time {
+	l.h.mutex.Lock()
+	defer l.h.mutex.Unlock()
+
+	return l.h.expires
+}
+
+// Err returns the error encountered by the most recent renewal of the
+// lease, or nil if it succeeded.
+func (l *Lease) Err() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.err
+}
+
+// Renew renews the lease immediately, extending its expiration by its
+// time-to-live. It returns an [*os.PathError] that wraps [os.ErrClosed] if
+// the lease has been closed.
+func (l *Lease) Renew() error {
+	err := l.renew()
+
+	l.mutex.Lock()
+	l.err = err
+	l.mutex.Unlock()
+
+	return err
+}
+
+// renew rewrites the metadata of the lock file with a new expiration.
+func (l *Lease) renew() error {
+	if err := l.begin("renew"); err != nil {
+		return err
+	}
+	defer l.end()
+
+	l.h.mutex.Lock()
+	defer l.h.mutex.Unlock()
+
+	if l.h.file == nil {
+		return &os.PathError{Op: "renew", Path: l.h.path, Err: os.ErrClosed}
+	}
+	return l.h.writeMetadata()
+}
+
+// renewLoop renews the lease periodically until it is closed.
+func (l *Lease) renewLoop() {
+	ticker := time.NewTicker(max(l.ttl/3, time.Millisecond))
+	defer ticker.Stop()
+
+	closed := l.Closed()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+		}
+
+		// A lease that expired may already have been taken by another host,
+		// so renewing it would not restore the protection of the lock.
+		if expires := l.Expires(); !time.Now().Before(expires) {
+			err := fmt.Errorf("%w: the lease expired at %s", ErrCompromised, expires.Format(time.RFC3339Nano))
+			if renewErr := l.Err(); renewErr != nil {
+				err = fmt.Errorf("%w: %w", err, renewErr)
+			}
+			l.expire(&os.PathError{Op: "lease", Path: l.h.path, Err: err})
+			return
+		}
+		l.Renew()
+	}
+}
+
+// expire moves the lease to [StateLost] because it expired, unless it has
+// already been released.
+func (l *Lease) expire(err error) {
+	l.h.mutex.Lock()
+	if l.h.refs == 0 || l.h.compromise != nil {
+		l.h.mutex.Unlock()
+		return
+	}
+	l.h.compromised(err)
+	l.h.mutex.Unlock()
+
+	l.h.cfg.warn(l.h.path, err)
+}
+
+// leaseHeld returns true if md records a lease that is still held by a
+// holder on another host. The locks of other hosts may not be visible to
+// this one, so their leases are honored until they expire. Holders on this
+// host are excluded by the lock itself.
+func leaseHeld(md Metadata) bool {
+	return !md.Expires.IsZero() && time.Now().Before(md.Expires) && remoteHolder(md.Holder)
+}
+
+// leaseExpired reports whether the lock file at path records a lease that
+// has expired. It returns false for ok if the lock file does not record a
+// lease.
+func (c *config) leaseExpired(path string) (expired, ok bool) {
+	var data []byte
+	err := c.do(OpOpen, path, func() error {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		data, err = io.ReadAll(io.LimitReader(file, maxMetadataSize))
+		return err
+	})
+	if err != nil || len(bytes.TrimSpace(data)) == 0 {
+		return false, false
+	}
+
+	md, err := DecodeMetadata(data, IgnoreUnknownFields)
+	if err != nil || md.Expires.IsZero() {
+		return false, false
+	}
+	return time.Now().After(md.Expires), true
+}
@@ -0,0 +1,174 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestLease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.lock")
+	ttl := 150 * time.Millisecond
+
+	lease, err := lockfile.AcquireLease(context.Background(), path, ttl)
+	if err != nil {
+		t.Fatalf("AcquireLease failed: %v", err)
+	}
+
+	first := lease.Expires()
+	info, err := lockfile.Inspect(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Metadata == nil || !info.Metadata.Expires.Equal(first) {
+		t.Fatalf("the expiration of the lease was not recorded: %+v", info.Metadata)
+	}
+
+	// The lease is renewed in the background.
+	time.Sleep(2 * ttl)
+	if err := lease.Err(); err != nil {
+		t.Fatalf("renewal failed: %v", err)
+	}
+	if renewed := lease.Expires(); !renewed.After(first) || time.Until(renewed) <= 0 {
+		t.Fatalf("the lease was not renewed: first %v, now %v", first, renewed)
+	}
+
+	if err := lease.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := lease.Renew(); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("expected os.ErrClosed from Renew after Close, got: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the lease was not removed: %v", err)
+	}
+}
+
+func TestLeaseInvalidTTL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.lock")
+	if _, err := lockfile.AcquireLease(context.Background(), path, 0); !errors.Is(err, lockfile.ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption, got: %v", err)
+	}
+}
+
+// writeRemoteLease writes a lock file at path that records a lease held by
+// a holder on another host, which expires at the given time.
+func writeRemoteLease(t *testing.T, path string, expires time.Time) {
+	t.Helper()
+	md := lockfile.NewMetadata(0)
+	md.Holder.Hostname = "another-host.invalid"
+	md.Expires = expires
+	data, err := lockfile.EncodeMetadata(md, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLeaseRemote(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("lock files left behind are never acquired on Windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "remote.lock")
+
+	writeRemoteLease(t, path, time.Now().Add(time.Hour))
+	if _, err := lockfile.Create(path); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected an unexpired remote lease to be honored, got: %v", err)
+	}
+	verdict, err := lockfile.StaleCheck(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verdict.Liveness != lockfile.LivenessAlive {
+		t.Fatalf("unexpected verdict for an unexpired lease: %+v", verdict)
+	}
+
+	writeRemoteLease(t, path, time.Now().Add(-time.Minute))
+	verdict, err = lockfile.StaleCheck(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verdict.Liveness != lockfile.LivenessExpired || !verdict.Stale() {
+		t.Fatalf("unexpected verdict for an expired lease: %+v", verdict)
+	}
+
+	file, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("expected an expired remote lease to be acquirable, got: %v", err)
+	}
+	file.Close()
+}
+
+func TestLeaseSoft(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "soft.lock")
+	soft := lockfile.WithSoftLock(time.Hour)
+
+	writeRemoteLease(t, path, time.Now().Add(time.Hour))
+	if _, err := lockfile.Create(path, soft); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected an unexpired lease to be honored, got: %v", err)
+	}
+
+	writeRemoteLease(t, path, time.Now().Add(-time.Minute))
+	file, err := lockfile.Create(path, soft)
+	if err != nil {
+		t.Fatalf("expected an expired lease to be acquirable, got: %v", err)
+	}
+	file.Close()
+}
+
+// failingCodec encodes metadata with JSONCodec until it is broken.
+type failingCodec struct {
+	broken *atomic.Bool
+}
+
+func (c failingCodec) Name() string { return lockfile.JSONCodec.Name() }
+
+func (c failingCodec) Marshal(md lockfile.Metadata) ([]byte, error) {
+	if c.broken.Load() {
+		return nil, errors.New("the codec is broken")
+	}
+	return lockfile.JSONCodec.Marshal(md)
+}
+
+func (c failingCodec) Unmarshal(data []byte, policy lockfile.UnknownFieldPolicy) (lockfile.Metadata, error) {
+	return lockfile.JSONCodec.Unmarshal(data, policy)
+}
+
+func TestLeaseExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.lock")
+	codec := failingCodec{broken: new(atomic.Bool)}
+
+	lease, err := lockfile.AcquireLease(context.Background(), path, 150*time.Millisecond, lockfile.WithMetadata(codec))
+	if err != nil {
+		t.Fatalf("AcquireLease failed: %v", err)
+	}
+	defer lease.Close()
+
+	// Once renewal fails, the lease expires and its protection ends.
+	ctx := lease.Context(context.Background())
+	codec.broken.Store(true)
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the context was not cancelled when the lease expired")
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, lockfile.ErrCompromised) || !errors.Is(cause, lockfile.ErrMetadataUnwritable) {
+		t.Fatalf("unexpected cause for an expired lease: %v", cause)
+	}
+	if state := lease.State(); state != lockfile.StateLost {
+		t.Fatalf("the expired lease is %s, expected lost", state)
+	}
+	if err, ok := <-lease.Monitor(); !ok || !errors.Is(err, lockfile.ErrCompromised) {
+		t.Fatalf("Monitor did not report the expired lease: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package lockfile
+
+import (
+	"context"
+	"os"
+)
+
+// closedChan is a closed channel, which is shared by every [File] that is
+// closed before its Closed method is called.
+var closedChan = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+// Closed returns a channel that is closed when [File.Close] is called on
+// f. Helpers that run alongside a held lock, such as those that monitor or
+// refresh it, select on the channel so that they stop when the lock is no
+// longer wanted.
+//
+// The channel is closed before Close waits for in-flight operations, so a
+// helper that sees it closed should return promptly.
+func (f *File) Closed() <-chan struct{} {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.done == nil {
+		if f.closed {
+			f.done = closedChan
+		} else {
+			f.done = make(chan struct{})
+		}
+	}
+	return f.done
+}
+
+// Context returns a context derived from parent that is cancelled when the
+// protection of the lock ends: when f is closed, when the lock is released
+// through another reference or by its manager, when it is found to be
+// compromised as described by [WithLinkMonitor], or when it is a [Lease]
+// that expired before it could be renewed. Work in the critical
+// section can respect the context to stop as soon as it is no longer
+// protected.
+//
+// The cause of the cancellation, as returned by [context.Cause], is the
+// error that wraps [ErrCompromised] if the lock was compromised, or an
+// [*os.PathError] that wraps [os.ErrClosed] if it was closed or released.
+// The context is also cancelled when parent is.
+func (f *File) Context(parent context.Context) context.Context {
+	ctx, cancel := context.WithCancelCause(parent)
+
+	lost := f.Monitor()
+	closed := f.Closed()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case err, ok := <-lost:
+			if !ok {
+				err = &os.PathError{Op: "context", Path: f.h.path, Err: os.ErrClosed}
+			}
+			cancel(err)
+		case <-closed:
+			cancel(&os.PathError{Op: "context", Path: f.h.path, Err: os.ErrClosed})
+		}
+	}()
+
+	return ctx
+}
+
+// begin records the start of an operation on f that may run concurrently
+// with a call to [File.Close]. Close waits for the operation to end before
+// it releases the reference. Every successful call must be paired with a
+// call to end.
+//
+// It returns an [*os.PathError] that wraps [os.ErrClosed] if f has been
+// closed, or if the lock has been released by its manager.
+func (f *File) begin(op string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return &os.PathError{Op: op, Path: f.h.path, Err: os.ErrClosed}
+	}
+
+	f.h.mutex.Lock()
+	released := f.h.refs == 0
+	f.h.mutex.Unlock()
+	if released {
+		return &os.PathError{Op: op, Path: f.h.path, Err: os.ErrClosed}
+	}
+
+	f.ops.Add(1)
+	return nil
+}
+
+// end records the end of an operation that was started by begin.
+func (f *File) end() {
+	f.ops.Done()
+}
@@ -0,0 +1,83 @@
+package lockfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCloseWaitsForOperations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lifecycle.lock")
+	file, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := file.begin("test"); err != nil {
+		t.Fatalf("begin failed: %v", err)
+	}
+
+	// Simulate a helper that notices the lock is being closed, and takes a
+	// moment to finish what it was doing.
+	finished := make(chan struct{})
+	go func() {
+		<-file.Closed()
+		time.Sleep(time.Millisecond * 50)
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("the lock file was released while an operation was in flight: %v", err)
+		}
+		close(finished)
+		file.end()
+	}()
+
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	select {
+	case <-finished:
+	default:
+		t.Fatalf("Close returned before the operation in flight had finished")
+	}
+
+	if err := file.begin("test"); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("begin returned %v after Close", err)
+	}
+}
+
+func TestConcurrentClose(t *testing.T) {
+	file, err := Create(filepath.Join(t.TempDir(), "lifecycle.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const closers = 8
+	var (
+		wg     sync.WaitGroup
+		mutex  sync.Mutex
+		closed int
+	)
+	for range closers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := file.Close()
+			switch {
+			case err == nil:
+				mutex.Lock()
+				closed++
+				mutex.Unlock()
+			case !errors.Is(err, os.ErrClosed):
+				t.Errorf("Close returned %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if closed != 1 {
+		t.Fatalf("Close succeeded %d times", closed)
+	}
+}
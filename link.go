@@ -0,0 +1,205 @@
+package lockfile
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// LinkBackend is a [Backend] that uses the creation of a hard link as the
+// lock, following the technique that has traditionally been used for lock
+// files on NFSv2 and NFSv3, where O_EXCL and flock cannot be trusted.
+//
+// To acquire a lock, a temporary file with a unique name is created next to
+// the lock file and linked to its path. The link count of the temporary
+// file is then checked instead of the result of the link call, because a
+// retransmitted request can make a link that succeeded on the server look
+// like it failed to the client, and vice versa. If the count is two, the
+// lock is held and the temporary file is removed. A lock is held while a
+// lock file exists at its path.
+//
+// The lock file records the holder in the [FormatHostPID] format, which
+// is what other implementations of the technique write. Since the kernel
+// keeps no lock, a lock file outlives a holder that crashes. If that
+// holder ran on this host and has exited, as determined by [StaleCheck],
+// the lock file is removed by the next caller of TryAcquire. If it ran on
+// another host, the lock file is removed once it is older than the
+// staleness threshold of the backend. Release reports an error that wraps
+// [ErrMoved] to a holder whose lock file was removed.
+//
+// Exclusive locks are the only kind supported, and the lock is released
+// by removing the lock file rather than by closing it, so no open file is
+// kept and features that read or write the lock file are not available.
+// The filesystem must support hard links.
+type LinkBackend struct {
+	staleAfter time.Duration
+}
+
+// NewLinkBackend returns a [LinkBackend]. A lock file whose holder is on
+// another host is treated as abandoned once it has existed for longer than
+// staleAfter, or never if staleAfter is zero or less.
+//
+// No lock may be held for longer than staleAfter, allowing for the clocks
+// of the hosts that share the locks to disagree.
+func NewLinkBackend(staleAfter time.Duration) *LinkBackend {
+	return &LinkBackend{staleAfter: staleAfter}
+}
+
+// TryAcquire makes a single attempt to link a new lock file to the given
+// path, breaking the existing one first if it is stale. It returns an
+// [*os.PathError] that wraps [os.ErrExist] if the lock is held by someone
+// else.
+func (b *LinkBackend) TryAcquire(path string, shared bool) (BackendLock, error) {
+	if shared {
+		return BackendLock{}, &os.PathError{Op: "acquire", Path: path, Err: fmt.Errorf("%w: the link backend does not support shared locks", ErrInvalidOption)}
+	}
+
+	holder := CurrentHolder()
+	data := fmt.Appendf(nil, "%s:%d\n", holder.Hostname, holder.PID)
+
+	return tryAcquireOrBreak(path, func() (BackendLock, error) {
+		info, err := createLink(path, data)
+		if err != nil {
+			return BackendLock{}, err
+		}
+		return BackendLock{Value: info}, nil
+	}, b.stale, b.ReadLock)
+}
+
+// Release removes the lock file with the given path. It returns an
+// [*os.PathError] that wraps [ErrMoved] if the lock was broken by someone
+// else while it was held, in which case the lock file is left alone.
+func (b *LinkBackend) Release(path string, lock BackendLock) error {
+	held, ok := lock.Value.(os.FileInfo)
+	if !ok {
+		return &os.PathError{Op: "release", Path: path, Err: ErrNotHeld}
+	}
+
+	// A lock file that replaced ours may have been given the same inode
+	// number, but not the same modification time.
+	info, err := os.Stat(path)
+	if err != nil || !os.SameFile(info, held) || !info.ModTime().Equal(held.ModTime()) {
+		return &os.PathError{Op: "release", Path: path, Err: ErrMoved}
+	}
+	return os.Remove(path)
+}
+
+// Guarantees returns the guarantees provided by the lock files of the
+// backend. Nothing releases them when their holder crashes, so a holder on
+// another host relies on the staleness threshold of the backend.
+func (b *LinkBackend) Guarantees(shared bool) Guarantees {
+	return Guarantees{
+		Exclusive:  !shared,
+		StaleAfter: max(b.staleAfter, 0),
+	}
+}
+
+// Probe reports whether the lock file with the given path exists, and
+// whether it is held. A stale lock file exists but is not held.
+func (b *LinkBackend) Probe(path string) (exists, held bool, err error) {
+	_, err = os.Stat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return false, false, nil
+	case err != nil:
+		return false, false, err
+	}
+	_, stale := b.stale(path)
+	return true, !stale, nil
+}
+
+// ReadLock returns the contents of the lock file with the given path.
+func (b *LinkBackend) ReadLock(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+// stale returns true if the lock file at path was left behind by a holder
+// that is no longer running. It also returns the contents of the lock file
+// that the decision was based on.
+func (b *LinkBackend) stale(path string) ([]byte, bool) {
+	data, err := b.ReadLock(path)
+	if err != nil {
+		return nil, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return data, false
+	}
+	return data, staleHolder(data, info.ModTime(), b.staleAfter)
+}
+
+// createLink writes data to a temporary file with a unique name next to
+// path and links it to path. It returns the lock file if the link count of
+// the temporary file shows that the link was created, or an error that
+// wraps [os.ErrExist] if path already exists.
+func createLink(path string, data []byte) (os.FileInfo, error) {
+	var nonce [8]byte
+	rand.Read(nonce[:])
+	temp := path + ".link-" + hex.EncodeToString(nonce[:])
+
+	if err := writeExclusive(temp, data); err != nil {
+		return nil, err
+	}
+	defer os.Remove(temp)
+
+	// The result of the link call cannot be trusted over NFS, so it is only
+	// used to explain a failure.
+	linkErr := os.Link(temp, path)
+
+	links, err := linkCount(temp)
+	if err != nil {
+		return nil, err
+	}
+	owned := sameFile(temp, path)
+
+	switch {
+	case links == 2 && owned != nil:
+		return owned, nil
+	case links == 2 || linkErr == nil:
+		// The link count and the lock file disagree, so neither can be
+		// trusted. A lock file that may be ours is not left behind.
+		if owned != nil {
+			os.Remove(path)
+		}
+		return nil, &os.PathError{Op: "link", Path: path, Err: ErrUnreliableFilesystem}
+	case errors.Is(linkErr, os.ErrExist):
+		return nil, &os.PathError{Op: "acquire", Path: path, Err: os.ErrExist}
+	default:
+		return nil, linkErr
+	}
+}
+
+// sameFile returns the file at path if it is the same file as the one at
+// temp, or nil if it is not.
+func sameFile(temp, path string) os.FileInfo {
+	tempInfo, err := os.Stat(temp)
+	if err != nil {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil || !os.SameFile(tempInfo, info) {
+		return nil
+	}
+	return info
+}
+
+// writeExclusive creates a file at path that contains data. It fails if the
+// file already exists.
+func writeExclusive(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
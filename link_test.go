@@ -0,0 +1,120 @@
+package lockfile_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestLinkBackend(t *testing.T) {
+	backend := lockfile.WithBackend(lockfile.NewLinkBackend(0))
+	dir := t.TempDir()
+	path := filepath.Join(dir, "link.lock")
+
+	file, err := lockfile.Create(path, backend)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if g := file.Guarantees(); !g.Exclusive || g.KernelLock || g.StaleAfter != 0 {
+		t.Fatalf("unexpected guarantees: %+v", g)
+	}
+	hostname, _ := os.Hostname()
+	if data, err := os.ReadFile(path); err != nil || string(data) != fmt.Sprintf("%s:%d\n", hostname, os.Getpid()) {
+		t.Fatalf("unexpected lock file contents: %q, %v", data, err)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Fatalf("the temporary file was not removed: %v, %v", entries, err)
+	}
+	if _, err := lockfile.Create(path, backend); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected ErrExist while the lock is held, got: %v", err)
+	}
+	if _, err := lockfile.CreateShared(path, backend); !errors.Is(err, lockfile.ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption for a shared lock, got: %v", err)
+	}
+
+	info, err := lockfile.Inspect(path, backend)
+	if err != nil || !info.Held {
+		t.Fatalf("expected Inspect to report the lock as held, got %+v, %v", info, err)
+	}
+	if info.Format != lockfile.FormatHostPID || info.Metadata == nil || info.Metadata.Holder.PID != os.Getpid() {
+		t.Fatalf("Inspect did not report the holder: %q, %+v", info.Format, info.Metadata)
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Fatalf("the lock file was not removed: %v, %v", entries, err)
+	}
+}
+
+func TestLinkBackendStale(t *testing.T) {
+	dir := t.TempDir()
+	hostname, _ := os.Hostname()
+
+	// A holder on this host that is no longer running is broken.
+	dead := filepath.Join(dir, "dead.lock")
+	if err := os.WriteFile(dead, fmt.Appendf(nil, "%s:%d\n", hostname, 1<<22-1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := lockfile.Create(dead, lockfile.WithBackend(lockfile.NewLinkBackend(0)))
+	if err != nil {
+		t.Fatalf("Create did not break the lock of a dead holder: %v", err)
+	}
+	file.Close()
+
+	// A holder on another host is only broken once it is older than the
+	// threshold.
+	remote := filepath.Join(dir, "remote.lock")
+	if err := os.WriteFile(remote, []byte(hostname+".elsewhere:1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockfile.Create(remote, lockfile.WithBackend(lockfile.NewLinkBackend(time.Hour))); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected ErrExist for a recent remote holder, got: %v", err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(remote, old, old); err != nil {
+		t.Fatal(err)
+	}
+	file, err = lockfile.Create(remote, lockfile.WithBackend(lockfile.NewLinkBackend(time.Hour)))
+	if err != nil {
+		t.Fatalf("Create did not break the lock of an old remote holder: %v", err)
+	}
+	file.Close()
+
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Fatalf("the directory was not left empty: %v, %v", entries, err)
+	}
+}
+
+func TestLinkBackendBroken(t *testing.T) {
+	backend := lockfile.WithBackend(lockfile.NewLinkBackend(0))
+	path := filepath.Join(t.TempDir(), "link.lock")
+
+	file, err := lockfile.Create(path, backend)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// Someone else breaks the lock and acquires it.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	other, err := lockfile.Create(path, backend)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer other.Close()
+
+	if err := file.Close(); !errors.Is(err, lockfile.ErrMoved) {
+		t.Fatalf("expected ErrMoved from the broken lock, got: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("releasing the broken lock removed the new holder's lock file: %v", err)
+	}
+}
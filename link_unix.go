@@ -0,0 +1,21 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile
+
+import (
+	"os"
+	"syscall"
+)
+
+// linkCount returns the number of hard links to the file at path.
+func linkCount(path string) (uint64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, &os.PathError{Op: "stat", Path: path, Err: ErrUnreliableFilesystem}
+	}
+	return uint64(stat.Nlink), nil
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package lockfile
+
+import (
+	"os"
+	"syscall"
+)
+
+// linkCount returns the number of hard links to the file at path.
+func linkCount(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var info syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(syscall.Handle(file.Fd()), &info); err != nil {
+		return 0, &os.PathError{Op: "stat", Path: path, Err: err}
+	}
+	return uint64(info.NumberOfLinks), nil
+}
@@ -0,0 +1,99 @@
+package lockfile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// Lock is a lock file path that has been configured once so that it can be
+// acquired many times.
+//
+// Applications that acquire and release the same lock file frequently can
+// use a Lock to avoid re-processing options on every cycle. A Lock is safe
+// for concurrent use, but each successful acquisition returns a distinct
+// [File] that must be closed by its caller.
+type Lock struct {
+	path       string
+	cfg        *config
+	generation atomic.Uint64
+}
+
+// New returns a [Lock] for the given path, configured with the given
+// options.
+//
+// The path is mapped by the [Mapper] installed by [WithMapper], if any,
+// validated and converted to a clean absolute path once, so that the Lock
+// continues to refer to the same file even if the working directory of
+// the process changes.
+//
+// It returns an error if the path is empty, refers to a directory, or
+// cannot be mapped or made absolute, or if the options are invalid.
+func New(path string, opts ...Option) (*Lock, error) {
+	// Each acquisition is assigned a generation number once it has been
+	// created. The option is appended rather than set afterwards, because
+	// the configuration for no options is shared.
+	l := &Lock{}
+	l.cfg = newConfig(append(opts[:len(opts):len(opts)], func(c *config) {
+		c.generations = &l.generation
+	}))
+	if l.cfg.err != nil {
+		return nil, l.cfg.err
+	}
+
+	// A relative name is mapped before it is made absolute, so that it is
+	// placed in the default directory of the mapper rather than the working
+	// directory. It is not mapped again when the lock is acquired.
+	path, err := l.cfg.mapPath(path)
+	if err != nil {
+		return nil, err
+	}
+	l.cfg.mapper = nil
+	if l.path, err = canonicalPath(path); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Path returns the canonical path of the lock file.
+func (l *Lock) Path() string {
+	return l.path
+}
+
+// TryAcquire makes a single attempt to create the lock file. It behaves
+// like [Create].
+func (l *Lock) TryAcquire() (*File, error) {
+	return l.cfg.create(l.path)
+}
+
+// Acquire waits for the lock file to be created until it succeeds, a
+// non-temporary error is encountered or the provided context is cancelled.
+// It behaves like [WaitCtx].
+func (l *Lock) Acquire(ctx context.Context) (*File, error) {
+	return l.cfg.wait(ctx, l.path)
+}
+
+// Generation returns the generation number of the most recent exclusive
+// acquisition of the lock by this Lock, or 0 if it has never been
+// acquired. It is described by [File.Generation].
+func (l *Lock) Generation() uint64 {
+	return l.generation.Load()
+}
+
+// canonicalPath validates path and returns it as a clean absolute path.
+func canonicalPath(path string) (string, error) {
+	if path == "" {
+		return "", ErrEmptyPath
+	}
+	if os.IsPathSeparator(path[len(path)-1]) {
+		return "", &os.PathError{Op: "open", Path: path, Err: ErrDirectoryPath}
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", &os.PathError{Op: "abs", Path: path, Err: err}
+	}
+
+	return abs, nil
+}
@@ -0,0 +1,184 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestLockAcquire(t *testing.T) {
+	lock, err := lockfile.New(filepath.Join(t.TempDir(), "reuse.lock"))
+	if err != nil {
+		t.Fatalf("failed to prepare lock: %v", err)
+	}
+
+	for i := range 10 {
+		file, err := lock.Acquire(context.Background())
+		if err != nil {
+			t.Fatalf("acquisition %d failed: %v", i, err)
+		}
+		if err := file.Close(); err != nil {
+			t.Fatalf("release %d failed: %v", i, err)
+		}
+	}
+}
+
+func TestNewEmptyPath(t *testing.T) {
+	if _, err := lockfile.New(""); !errors.Is(err, lockfile.ErrEmptyPath) {
+		t.Fatalf("expected ErrEmptyPath, got: %v", err)
+	}
+}
+
+func TestLockTryAcquire(t *testing.T) {
+	lock, err := lockfile.New(filepath.Join(t.TempDir(), "try.lock"))
+	if err != nil {
+		t.Fatalf("failed to prepare lock: %v", err)
+	}
+	if !filepath.IsAbs(lock.Path()) {
+		t.Fatalf("lock path is not absolute: %s", lock.Path())
+	}
+
+	file, err := lock.TryAcquire()
+	if err != nil {
+		t.Fatalf("first attempt failed: %v", err)
+	}
+	defer file.Close()
+
+	if second, err := lock.TryAcquire(); !lockfile.IsTemporary(err) {
+		if err == nil {
+			second.Close()
+		}
+		t.Fatalf("expected contention on second attempt, got: %v", err)
+	}
+}
+
+func TestNewDirectoryPath(t *testing.T) {
+	if _, err := lockfile.New(t.TempDir() + string(filepath.Separator)); !errors.Is(err, lockfile.ErrDirectoryPath) {
+		t.Fatalf("expected ErrDirectoryPath, got: %v", err)
+	}
+}
+
+func TestLockGeneration(t *testing.T) {
+	lock, err := lockfile.New(filepath.Join(t.TempDir(), "generation.lock"))
+	if err != nil {
+		t.Fatalf("failed to prepare lock: %v", err)
+	}
+
+	var last uint64
+	for i := range 3 {
+		file, err := lock.TryAcquire()
+		if err != nil {
+			t.Fatalf("acquisition %d failed: %v", i, err)
+		}
+		if gen := file.Generation(); gen <= last {
+			t.Fatalf("acquisition %d has generation %d, expected more than %d", i, gen, last)
+		} else {
+			last = gen
+		}
+		file.Close()
+	}
+
+	if gen := lock.Generation(); gen != last {
+		t.Fatalf("lock reports generation %d, expected %d", gen, last)
+	}
+}
+
+func TestLockGenerationPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "generation.lock")
+
+	// Each Lock stands in for a separate process, which would start
+	// counting afresh if generations were only kept in memory.
+	var last uint64
+	for i := range 3 {
+		lock, err := lockfile.New(path)
+		if err != nil {
+			t.Fatalf("failed to prepare lock: %v", err)
+		}
+		file, err := lock.TryAcquire()
+		if err != nil {
+			t.Fatalf("acquisition %d failed: %v", i, err)
+		}
+		if gen := file.Generation(); gen <= last {
+			t.Fatalf("acquisition %d has generation %d, expected more than %d", i, gen, last)
+		} else {
+			last = gen
+		}
+		file.Close()
+	}
+}
+
+func TestLockGenerationMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "generation.lock")
+	lock, err := lockfile.New(path, lockfile.WithMetadata(nil), lockfile.WithLinkMonitor(time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to prepare lock: %v", err)
+	}
+
+	for i := range 3 {
+		file, err := lock.TryAcquire()
+		if err != nil {
+			t.Fatalf("acquisition %d failed: %v", i, err)
+		}
+		info, err := lockfile.Inspect(path)
+		if err != nil || info.Metadata == nil || info.Metadata.Generation != file.Generation() {
+			t.Fatalf("the metadata does not record generation %d: %+v, %v", file.Generation(), info.Metadata, err)
+		}
+		file.Close()
+	}
+}
+
+func TestLocker(t *testing.T) {
+	locker, err := lockfile.NewLocker(filepath.Join(t.TempDir(), "locker.lock"))
+	if err != nil {
+		t.Fatalf("failed to prepare locker: %v", err)
+	}
+
+	var (
+		_       sync.Locker = locker
+		wg      sync.WaitGroup
+		counter int
+	)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				locker.Lock()
+				counter++
+				locker.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if counter != 40 {
+		t.Fatalf("expected a count of 40, got %d", counter)
+	}
+}
+
+func TestLockerTryLock(t *testing.T) {
+	locker, err := lockfile.NewLocker(filepath.Join(t.TempDir(), "locker.lock"))
+	if err != nil {
+		t.Fatalf("failed to prepare locker: %v", err)
+	}
+
+	if !locker.TryLock() {
+		t.Fatal("TryLock failed for a lock file that is not held")
+	}
+	if locker.TryLock() {
+		t.Fatal("TryLock succeeded for a lock file that is held")
+	}
+	locker.Unlock()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Unlock did not panic for a Locker that is not locked")
+		}
+	}()
+	locker.Unlock()
+}
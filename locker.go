@@ -0,0 +1,90 @@
+package lockfile
+
+import (
+	"context"
+	"sync"
+)
+
+// Locker adapts a [Lock] to the [sync.Locker] interface, so that a lock file
+// can be used by code that is written against it.
+//
+// Like a [sync.Mutex], a Locker is held by at most one caller at a time,
+// and it may be unlocked by a different goroutine than the one that locked
+// it. Unlike a [sync.Mutex], it also excludes other processes.
+//
+// The methods of [sync.Locker] cannot return errors. Lock panics if the lock
+// file cannot be acquired for a reason other than contention, and Unlock
+// reports a failure to release it to the Warning hook.
+type Locker struct {
+	lock  *Lock
+	mutex sync.Mutex
+	file  *File // The lock file while it is held
+}
+
+// Locker returns a [Locker] that acquires the lock file of l.
+func (l *Lock) Locker() *Locker {
+	return &Locker{lock: l}
+}
+
+// NewLocker returns a [Locker] for the given path, configured with the given
+// options. It returns an error if the path or options are invalid, as for
+// [New].
+func NewLocker(path string, opts ...Option) (*Locker, error) {
+	lock, err := New(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return lock.Locker(), nil
+}
+
+// Lock waits until the lock file is acquired. It panics if the lock file
+// cannot be acquired for a reason other than contention.
+func (l *Locker) Lock() {
+	file, err := l.lock.Acquire(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	l.hold(file)
+}
+
+// TryLock makes a single attempt to acquire the lock file, and reports
+// whether it succeeded.
+func (l *Locker) TryLock() bool {
+	file, err := l.lock.TryAcquire()
+	if err != nil {
+		return false
+	}
+	l.hold(file)
+	return true
+}
+
+// Unlock releases the lock file. It panics if the lock file is not held
+// by the Locker.
+func (l *Locker) Unlock() {
+	l.mutex.Lock()
+	file := l.file
+	l.file = nil
+	l.mutex.Unlock()
+
+	if file == nil {
+		panic("lockfile: unlock of unlocked Locker")
+	}
+	if err := file.Close(); err != nil {
+		l.lock.cfg.warn(l.lock.path, err)
+	}
+}
+
+// hold records file as the lock file held by the Locker.
+func (l *Locker) hold(file *File) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.file != nil {
+		// Another process should never be able to acquire a lock file
+		// that we hold, so this indicates that the lock file was deleted
+		// or replaced while it was held.
+		file.Close()
+		panic("lockfile: the lock file of a Locker was acquired twice")
+	}
+	l.file = file
+}
@@ -0,0 +1,275 @@
+// Package lockfiletest provides a conformance suite for implementations of
+// [lockfile.Provider].
+//
+// The suite asserts the behavior that callers of the lockfile package rely
+// on, so that alternative implementations can prove that they can be used
+// in its place:
+//
+//	func TestConformance(t *testing.T) {
+//		lockfiletest.RunConformance(t, myProvider())
+//	}
+//
+// It also provides [StartHolder] and [HoldInChild], which simulate a holder
+// that crashes by killing a child process that holds a lock file, so that
+// tests can assert how lock files are cleaned up after a crash:
+//
+//	func TestCrash(t *testing.T) {
+//		lockfiletest.HoldInChild(t, func(path string) error {
+//			_, err := lockfile.Create(path)
+//			return err
+//		})
+//
+//		path := filepath.Join(t.TempDir(), "crash.lock")
+//		lockfiletest.StartHolder(t, path).Kill()
+//		// Assert that the lock file can be acquired.
+//	}
+package lockfiletest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+// RunConformance runs the conformance suite against provider, as a set of
+// subtests of t.
+//
+// The crash scenario uses [StartHolder] to run the current test binary
+// again in a child process that acquires a lock file with provider and is
+// then killed. The test
+// that calls RunConformance must therefore construct provider the same way
+// each time it runs, and must not depend on state established by other
+// tests.
+func RunConformance(t *testing.T, provider lockfile.Provider) {
+	HoldInChild(t, func(path string) error {
+		_, err := provider.Create(path)
+		return err
+	})
+
+	t.Run("contention", func(t *testing.T) { testContention(t, provider) })
+	t.Run("exclusion", func(t *testing.T) { testExclusion(t, provider) })
+	t.Run("wait", func(t *testing.T) { testWait(t, provider) })
+	t.Run("double-close", func(t *testing.T) { testDoubleClose(t, provider) })
+	t.Run("rename-under-lock", func(t *testing.T) { testRenameUnderLock(t, provider) })
+	t.Run("crash", func(t *testing.T) { testCrash(t, provider) })
+}
+
+// lockPath returns the path of a lock file in a temporary directory that
+// is removed when t ends.
+func lockPath(t *testing.T) string {
+	return filepath.Join(t.TempDir(), "conformance.lock")
+}
+
+// testContention asserts that a lock file that is held cannot be acquired
+// again until it is released.
+func testContention(t *testing.T, provider lockfile.Provider) {
+	path := lockPath(t)
+
+	held, err := provider.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if held.Path() != path {
+		t.Errorf("Path returned %q, want %q", held.Path(), path)
+	}
+
+	if other, err := provider.Create(path); err == nil {
+		other.Close()
+		t.Fatal("Create acquired a lock file that is held")
+	} else if !lockfile.IsTemporary(err) {
+		t.Fatalf("Create returned an error that is not temporary for a lock file that is held: %v", err)
+	}
+
+	if err := held.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	again, err := provider.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed after the lock file was released: %v", err)
+	}
+	if err := again.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+// testExclusion asserts that concurrent waiters never hold a lock file at
+// the same time.
+func testExclusion(t *testing.T, provider lockfile.Provider) {
+	const (
+		waiters = 8
+		rounds  = 10
+	)
+
+	path := lockPath(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var (
+		holders atomic.Int32
+		wg      sync.WaitGroup
+	)
+	for range waiters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				held, err := provider.Wait(ctx, path)
+				if err != nil {
+					t.Errorf("Wait failed: %v", err)
+					return
+				}
+				if n := holders.Add(1); n != 1 {
+					t.Errorf("%d holders acquired the lock file at once", n)
+				}
+				time.Sleep(time.Millisecond)
+				holders.Add(-1)
+				if err := held.Close(); err != nil {
+					t.Errorf("Close failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// testWait asserts that a waiter acquires a lock file once it is released,
+// and gives up when its context ends.
+func testWait(t *testing.T, provider lockfile.Provider) {
+	path := lockPath(t)
+
+	held, err := provider.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	short, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if other, err := provider.Wait(short, path); err == nil {
+		other.Close()
+		t.Fatal("Wait acquired a lock file that is held")
+	} else if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait returned an error other than the context's: %v", err)
+	}
+
+	time.AfterFunc(50*time.Millisecond, func() { held.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	waited, err := provider.Wait(ctx, path)
+	if err != nil {
+		t.Fatalf("Wait failed after the lock file was released: %v", err)
+	}
+	if err := waited.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+// testDoubleClose asserts that closing a lock a second time is reported,
+// and does not disturb a new holder.
+func testDoubleClose(t *testing.T, provider lockfile.Provider) {
+	path := lockPath(t)
+
+	held, err := provider.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := held.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	next, err := provider.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed after the lock file was released: %v", err)
+	}
+	defer next.Close()
+
+	if err := held.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("a second Close returned %v, want an error that wraps os.ErrClosed", err)
+	}
+	if other, err := provider.Create(path); err == nil {
+		other.Close()
+		t.Fatal("a second Close released the lock of a new holder")
+	}
+}
+
+// testRenameUnderLock asserts that a lock file that is renamed while it is
+// held does not prevent the path from being acquired again, and that its
+// holder neither reports success nor disturbs the new holder when it
+// releases it. Providers whose lock files cannot be renamed while they are
+// held pass trivially.
+func testRenameUnderLock(t *testing.T, provider lockfile.Provider) {
+	path := lockPath(t)
+
+	held, err := provider.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := os.Rename(path, path+".moved"); err != nil {
+		held.Close()
+		t.Skipf("the lock file cannot be renamed while it is held: %v", err)
+	}
+
+	next, err := provider.Create(path)
+	if err != nil {
+		held.Close()
+		t.Fatalf("Create failed after the lock file was renamed: %v", err)
+	}
+	defer next.Close()
+
+	if err := held.Close(); err == nil {
+		t.Error("Close succeeded for a lock file that was renamed while it was held")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("releasing the renamed lock file disturbed the new holder: %v", err)
+	}
+}
+
+// testCrash asserts that a lock file whose holder is killed is released as
+// described by the guarantees of the provider.
+func testCrash(t *testing.T, provider lockfile.Provider) {
+	guarantees := provider.Guarantees()
+	if !guarantees.KernelLock && guarantees.StaleAfter > 10*time.Second {
+		t.Skipf("lock files only become stale after %v", guarantees.StaleAfter)
+	}
+
+	path := lockPath(t)
+	if err := StartHolder(t, path).Kill(); err != nil {
+		t.Fatalf("failed to kill the holder: %v", err)
+	}
+
+	switch {
+	case guarantees.KernelLock:
+		// The operating system releases the lock when its holder exits.
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		held, err := provider.Wait(ctx, path)
+		if err != nil {
+			t.Fatalf("the lock file of a holder that crashed was not released: %v", err)
+		}
+		held.Close()
+	case guarantees.StaleAfter > 0:
+		// The lock file is broken once it becomes stale.
+		ctx, cancel := context.WithTimeout(context.Background(), guarantees.StaleAfter+10*time.Second)
+		defer cancel()
+		held, err := provider.Wait(ctx, path)
+		if err != nil {
+			t.Fatalf("the lock file of a holder that crashed was not broken once it became stale: %v", err)
+		}
+		held.Close()
+	default:
+		// The lock file is never released automatically.
+		if held, err := provider.Create(path); err == nil {
+			held.Close()
+			t.Fatal("a lock file without a kernel lock or a stale timeout was released when its holder crashed")
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package lockfiletest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+	"github.com/gentlemanautomaton/lockfile/lockfiletest"
+)
+
+func TestConformance(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		lockfiletest.RunConformance(t, lockfile.NewProvider())
+	})
+	t.Run("soft", func(t *testing.T) {
+		lockfiletest.RunConformance(t, lockfile.NewProvider(lockfile.WithSoftLock(time.Second)))
+	})
+	t.Run("soft-without-timeout", func(t *testing.T) {
+		lockfiletest.RunConformance(t, lockfile.NewProvider(lockfile.WithSoftLock(0)))
+	})
+	t.Run("middleware", func(t *testing.T) {
+		lockfiletest.RunConformance(t, lockfile.Chain(lockfile.NewProvider(),
+			lockfile.Metrics(lockfile.Observer{}),
+			lockfile.Retry(1, 0),
+		))
+	})
+}
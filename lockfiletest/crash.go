@@ -0,0 +1,142 @@
+package lockfiletest
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// envHold is the environment variable that tells a child process started
+// by [StartHolder] which lock file to acquire.
+const envHold = "LOCKFILETEST_HOLD"
+
+// heldMessage is written to standard output by a child process once it
+// has acquired its lock file.
+const heldMessage = "lockfiletest: held"
+
+// Holder is a child process that holds a lock file until it is killed,
+// started by [StartHolder]. It simulates a holder that crashes, so that
+// the cleanup performed by the operating system can be observed with its
+// real semantics.
+type Holder struct {
+	path string
+	cmd  *exec.Cmd
+	once sync.Once
+	err  error
+}
+
+// StartHolder runs the current test again in a child process, which
+// acquires the lock file at path and holds it until [Holder.Kill] is
+// called. It returns once the lock file has been acquired, and fails t if
+// it cannot be.
+//
+// The test must call [HoldInChild] before StartHolder, which is how the
+// child acquires the lock file. The holder is killed when the test ends,
+// if it has not been already.
+func StartHolder(t testing.TB, path string) *Holder {
+	t.Helper()
+
+	if os.Getenv(envHold) != "" {
+		t.Fatal("lockfiletest: StartHolder was called in a holder; call HoldInChild first")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run="+runPattern(t.Name()), "-test.count=1")
+	cmd.Env = append(os.Environ(), envHold+"="+path)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("lockfiletest: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("lockfiletest: failed to start the holder: %v", err)
+	}
+
+	h := &Holder{path: path, cmd: cmd}
+	t.Cleanup(func() { h.Kill() })
+
+	held := make(chan bool, 1)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			if scanner.Text() == heldMessage {
+				held <- true
+				return
+			}
+		}
+		held <- false
+	}()
+
+	select {
+	case ok := <-held:
+		if !ok {
+			h.Kill()
+			t.Fatal("lockfiletest: the holder exited without acquiring the lock file")
+		}
+	case <-time.After(30 * time.Second):
+		h.Kill()
+		t.Fatal("lockfiletest: the holder did not acquire the lock file in time")
+	}
+
+	return h
+}
+
+// HoldInChild acquires a lock file with acquire and holds it until the
+// process is killed, if the process was started by [StartHolder]. It
+// returns immediately otherwise.
+//
+// The acquire function is called with the path of the lock file, and
+// should acquire it as the test under scrutiny does. The lock that it
+// acquires is never released.
+func HoldInChild(t testing.TB, acquire func(path string) error) {
+	path := os.Getenv(envHold)
+	if path == "" {
+		return
+	}
+
+	if err := acquire(path); err != nil {
+		t.Fatalf("lockfiletest: the holder failed to acquire the lock file: %v", err)
+	}
+	fmt.Println(heldMessage)
+	for {
+		time.Sleep(time.Hour)
+	}
+}
+
+// Path returns the path of the lock file that is held.
+func (h *Holder) Path() string {
+	return h.path
+}
+
+// PID returns the process ID of the holder.
+func (h *Holder) PID() int {
+	return h.cmd.Process.Pid
+}
+
+// Kill kills the holder abruptly, without giving it a chance to release
+// its lock file, and waits for it to exit. It uses SIGKILL on Unix and
+// TerminateProcess on Windows.
+//
+// It is safe to call more than once.
+func (h *Holder) Kill() error {
+	h.once.Do(func() {
+		h.err = h.cmd.Process.Kill()
+		h.cmd.Wait()
+	})
+	return h.err
+}
+
+// runPattern returns a -test.run pattern that matches only the test with
+// the given name.
+func runPattern(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		parts[i] = "^" + regexp.QuoteMeta(part) + "$"
+	}
+	return strings.Join(parts, "/")
+}
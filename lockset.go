@@ -0,0 +1,221 @@
+package lockfile
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// LockSet is a set of lock files that are held together.
+//
+// A LockSet is returned by functions that acquire more than one lock file
+// at a time. The lock files are released together when [LockSet.Close] is
+// called.
+//
+// The set keeps its lock files in the order they were acquired, which
+// depends on the function that returned it: [AcquireAll] acquires them
+// sorted by absolute path, [CreateAll] in the order of its paths, and
+// [AcquireGroup] in the order in which its concurrent waiters succeeded.
+type LockSet struct {
+	mutex sync.Mutex
+	files []*File
+}
+
+// newLockSet returns a LockSet that holds the given files in acquisition
+// order.
+func newLockSet(files []*File) *LockSet {
+	return &LockSet{files: files}
+}
+
+// Files returns the lock files held by the set, in the order they were
+// acquired, as described by [LockSet].
+//
+// It returns nil if the set has been closed.
+func (s *LockSet) Files() []*File {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.files == nil {
+		return nil
+	}
+
+	files := make([]*File, len(s.files))
+	copy(files, s.files)
+	return files
+}
+
+// Len returns the number of lock files held by the set.
+func (s *LockSet) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.files)
+}
+
+// Close releases all of the lock files in the set in the reverse order of
+// their acquisition. Every lock file is released even if some of them
+// return errors. The returned error joins all of the errors that were
+// encountered.
+//
+// Releasing in reverse order guarantees that a waiter that acquires the
+// same locks in the same order as the set cannot obtain its first lock
+// until every other lock in the set has been released. It therefore never
+// contends with the locks that the set still holds.
+//
+// It returns [os.ErrClosed] if the function has already been called.
+func (s *LockSet) Close() error {
+	return s.CloseInOrder(ReleaseReverse)
+}
+
+// CloseInOrder releases all of the lock files in the set in the order
+// determined by order. It otherwise behaves like [LockSet.Close].
+//
+// With an order other than [ReleaseReverse], releasing one lock file can
+// unblock a waiter that immediately contends with a lock file that the set
+// has yet to release. The set never waits for anything while it releases
+// its lock files, so such a waiter is only delayed until the set has
+// finished, but a caller that attempts to acquire the locks without
+// waiting, such as with [CreateAll], may fail with a temporary error.
+func (s *LockSet) CloseInOrder(order ReleaseOrder) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.files == nil {
+		return os.ErrClosed
+	}
+
+	files := s.files
+	s.files = nil
+
+	return closeAll(order(files))
+}
+
+// ReleaseOrder determines the order in which the lock files of a [LockSet]
+// are released. It receives the lock files in acquisition order, and
+// returns them in the order they should be released. It may reorder the
+// given slice in place.
+type ReleaseOrder func(files []*File) []*File
+
+// ReleaseReverse releases lock files in the reverse order of their
+// acquisition. It is the order used by [LockSet.Close].
+func ReleaseReverse(files []*File) []*File {
+	slices.Reverse(files)
+	return files
+}
+
+// ReleaseForward releases lock files in the order of their acquisition.
+func ReleaseForward(files []*File) []*File {
+	return files
+}
+
+// CreateAll attempts to create a lock file at each of the given paths, in
+// order. If any of them cannot be created, the lock files that were
+// already created are released in reverse order, and the error is
+// returned.
+//
+// Callers that acquire overlapping sets of locks should list the paths in
+// a consistent order, so that they cannot each hold a lock that the other
+// needs, as [AcquireAll] does for them. Duplicate paths are only created
+// once.
+func CreateAll(paths []string, opts ...Option) (*LockSet, error) {
+	cfg := newConfig(opts)
+	paths = uniquePaths(paths)
+
+	files := make([]*File, 0, len(paths))
+	for _, path := range paths {
+		file, err := cfg.create(path)
+		if err != nil {
+			closeReverse(files)
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	return newLockSet(files), nil
+}
+
+// AcquireAll waits for a lock file at each of the given paths, like
+// [WaitCtx], and returns a [LockSet] holding all of them. If any of them
+// cannot be acquired, the lock files that were already acquired are
+// released in reverse order, and the error is returned.
+//
+// The paths are acquired in a canonical order, sorted by absolute path,
+// rather than in the order given. Every caller of AcquireAll therefore
+// acquires overlapping sets of locks in the same order, so that no two
+// callers can each hold a lock that the other is waiting for. Paths that
+// refer to the same lock file after being made absolute are only acquired
+// once.
+//
+// The provided context governs the whole acquisition.
+func AcquireAll(ctx context.Context, paths []string, opts ...Option) (*LockSet, error) {
+	cfg := newConfig(opts)
+
+	paths, err := cfg.canonicalPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.mapper != nil {
+		// The paths have already been mapped. The configuration for no
+		// options is shared, but it has no mapper.
+		cfg.mapper = nil
+	}
+
+	files := make([]*File, 0, len(paths))
+	for _, path := range paths {
+		file, err := cfg.wait(ctx, path)
+		if err != nil {
+			closeReverse(files)
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	return newLockSet(files), nil
+}
+
+// canonicalPaths returns the absolute forms of paths in sorted order, with
+// duplicates removed. Each path is mapped by the mapper of the
+// configuration before it is made absolute.
+func (c *config) canonicalPaths(paths []string) ([]string, error) {
+	canonical := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if path == "" {
+			return nil, ErrEmptyPath
+		}
+		path, err := c.mapPath(path)
+		if err != nil {
+			return nil, err
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, &os.PathError{Op: "abs", Path: path, Err: err}
+		}
+		canonical = append(canonical, abs)
+	}
+
+	slices.Sort(canonical)
+	return slices.Compact(canonical), nil
+}
+
+// closeReverse closes the given files in reverse order and joins any
+// errors that are encountered.
+func closeReverse(files []*File) error {
+	return closeAll(ReleaseReverse(files))
+}
+
+// closeAll closes the given files in order and joins any errors that are
+// encountered.
+func closeAll(files []*File) error {
+	var errs []error
+	for _, file := range files {
+		if file == nil {
+			continue
+		}
+		if err := file.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
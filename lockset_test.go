@@ -0,0 +1,146 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestCreateAll(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{
+		filepath.Join(dir, "a.lock"),
+		filepath.Join(dir, "b.lock"),
+		filepath.Join(dir, "c.lock"),
+	}
+
+	set, err := lockfile.CreateAll(paths)
+	if err != nil {
+		t.Fatalf("CreateAll failed: %v", err)
+	}
+
+	var acquired, released []string
+	err = set.CloseInOrder(func(files []*lockfile.File) []*lockfile.File {
+		for _, file := range files {
+			acquired = append(acquired, file.Path())
+		}
+		files = lockfile.ReleaseReverse(files)
+		for _, file := range files {
+			released = append(released, file.Path())
+		}
+		return files
+	})
+	if err != nil {
+		t.Fatalf("CloseInOrder failed: %v", err)
+	}
+
+	if !slices.Equal(acquired, paths) {
+		t.Fatalf("files were acquired in the order %v, expected %v", acquired, paths)
+	}
+	slices.Reverse(paths)
+	if !slices.Equal(released, paths) {
+		t.Fatalf("files were released in the order %v, expected %v", released, paths)
+	}
+}
+
+func TestCreateAllFailure(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.lock"), filepath.Join(dir, "b.lock")
+
+	held, err := lockfile.Create(b)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer held.Close()
+
+	if _, err := lockfile.CreateAll([]string{a, b}); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected a temporary error, got: %v", err)
+	}
+
+	// The lock that was acquired before the failure must have been
+	// released.
+	file, err := lockfile.Create(a)
+	if err != nil {
+		t.Fatalf("the first lock was not released: %v", err)
+	}
+	file.Close()
+}
+
+func TestAcquireAll(t *testing.T) {
+	dir := t.TempDir()
+	a, b, c := filepath.Join(dir, "a.lock"), filepath.Join(dir, "b.lock"), filepath.Join(dir, "c.lock")
+
+	// Callers that list overlapping paths in opposite orders must not
+	// deadlock.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := range 20 {
+		paths := []string{a, b, c}
+		if i%2 == 1 {
+			slices.Reverse(paths)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			set, err := lockfile.AcquireAll(ctx, paths)
+			if err != nil {
+				errs <- err
+				return
+			}
+			time.Sleep(time.Millisecond)
+			errs <- set.Close()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("AcquireAll failed: %v", err)
+		}
+	}
+
+	set, err := lockfile.AcquireAll(ctx, []string{c, a, filepath.Join(dir, ".", "a.lock")})
+	if err != nil {
+		t.Fatalf("AcquireAll failed: %v", err)
+	}
+	defer set.Close()
+
+	var acquired []string
+	for _, file := range set.Files() {
+		acquired = append(acquired, file.Path())
+	}
+	if !slices.Equal(acquired, []string{a, c}) {
+		t.Fatalf("expected the locks to be acquired in canonical order, got %v", acquired)
+	}
+}
+
+func TestAcquireAllFailure(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.lock"), filepath.Join(dir, "b.lock")
+
+	held, err := lockfile.Create(b)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer held.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := lockfile.AcquireAll(ctx, []string{b, a}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to be exceeded, got: %v", err)
+	}
+
+	file, err := lockfile.Create(a)
+	if err != nil {
+		t.Fatalf("the first lock was not released: %v", err)
+	}
+	file.Close()
+}
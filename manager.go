@@ -0,0 +1,168 @@
+package lockfile
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Manager acquires lock files and keeps track of the ones that are held,
+// so that they can all be released together when the process shuts down.
+//
+// Lock files acquired by a Manager are released as usual when every
+// reference to them has been closed, at which point the Manager stops
+// tracking them. A Manager is safe for concurrent use.
+type Manager struct {
+	cfg  *config
+	root string // The directory of named lock files, see NewDirManager
+
+	mutex   sync.Mutex
+	handles map[*lockHandle]struct{}
+	dirs    map[string]int // Lock files counted against each quota
+	thawed  chan struct{}  // Closed by Thaw while frozen, or nil
+	drained chan struct{}  // Created on demand, closed when handles is empty
+}
+
+// NewManager returns a [Manager] that acquires lock files with the given
+// options.
+func NewManager(opts ...Option) *Manager {
+	return &Manager{
+		cfg:     newConfig(opts),
+		handles: make(map[*lockHandle]struct{}),
+		dirs:    make(map[string]int),
+	}
+}
+
+// Create attempts to create a lock file with the given path. It behaves
+// like [Create]. If the manager was created by [NewDirManager], path is the
+// name of a lock file in its root directory.
+//
+// If the manager was created with [WithDirQuota], it returns a
+// [*QuotaError] if the quota of the directory has been reached. If the
+// manager is frozen, it returns an [*os.PathError] that wraps [ErrFrozen].
+func (m *Manager) Create(path string) (*File, error) {
+	path, err := m.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.admit(path); err != nil {
+		return nil, err
+	}
+	dir, err := m.reserve(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := m.cfg.create(path)
+	m.observe(path, file, err)
+	return m.trackIn(dir, file, err)
+}
+
+// Wait waits for a lock file with the given path to be created. It behaves
+// like [WaitCtx]. If the manager was created by [NewDirManager], path is
+// the name of a lock file in its root directory.
+//
+// If the manager was created with [WithDirQuota], it returns a
+// [*QuotaError] without waiting if the quota of the directory has been
+// reached. While the manager is frozen, it waits for it to be thawed, as
+// described by [Manager.Freeze].
+func (m *Manager) Wait(ctx context.Context, path string) (*File, error) {
+	path, err := m.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := m.reserve(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := m.cfg.waitUntil(ctx, path, m.thawedFor(ctx, path))
+	m.observe(path, file, err)
+	return m.trackIn(dir, file, err)
+}
+
+// Len returns the number of lock files held by the manager.
+func (m *Manager) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return len(m.handles)
+}
+
+// CloseAll releases every lock file held by the manager, including any
+// references to them that have not been closed. Closing those references
+// afterward returns an error that wraps [os.ErrClosed].
+//
+// It returns the first error encountered, but attempts to release every
+// lock file regardless.
+func (m *Manager) CloseAll() error {
+	m.mutex.Lock()
+	handles := make([]*lockHandle, 0, len(m.handles))
+	for h := range m.handles {
+		handles = append(handles, h)
+	}
+	m.mutex.Unlock()
+
+	var first error
+	for _, h := range handles {
+		if err := h.releaseAll(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// track records a newly acquired file.
+func (m *Manager) track(file *File, err error) (*File, error) {
+	return m.trackIn("", file, err)
+}
+
+// trackIn records a newly acquired file, which counts against the quota of
+// dir reserved by reserve. If the file could not be acquired, the
+// reservation is given up.
+func (m *Manager) trackIn(dir string, file *File, err error) (*File, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err != nil {
+		m.unreserveLocked(dir)
+		return nil, err
+	}
+
+	file.h.manager = m
+	file.h.quotaDir = dir
+	file.h.stack = callers()
+	m.handles[file.h] = struct{}{}
+
+	return file, nil
+}
+
+// forget stops tracking a released lock.
+func (m *Manager) forget(h *lockHandle) {
+	m.cfg.heatmap.released(h.path, time.Since(h.stats.Acquired))
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.handles, h)
+	m.unreserveLocked(h.quotaDir)
+
+	if len(m.handles) == 0 && m.drained != nil {
+		close(m.drained)
+		m.drained = nil
+	}
+}
+
+// observe records the outcome of an attempt to acquire the lock file at
+// path in the manager's heatmap, if it has one.
+func (m *Manager) observe(path string, file *File, err error) {
+	if m.cfg.heatmap == nil {
+		return
+	}
+	if err != nil {
+		if mapped, err := m.cfg.mapPath(path); err == nil {
+			path = mapped
+		}
+		m.cfg.heatmap.failed(path, err)
+		return
+	}
+	m.cfg.heatmap.acquired(file.h.path, file.h.stats)
+}
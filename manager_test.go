@@ -0,0 +1,161 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestManagerCloseAll(t *testing.T) {
+	dir := t.TempDir()
+	manager := lockfile.NewManager()
+
+	a, err := manager.Create(filepath.Join(dir, "a.lock"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	b, err := manager.Create(filepath.Join(dir, "b.lock"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := b.Dup(); err != nil {
+		t.Fatalf("Dup failed: %v", err)
+	}
+
+	if n := manager.Len(); n != 2 {
+		t.Fatalf("manager holds %d locks, expected 2", n)
+	}
+
+	// A lock that is closed by its holder is no longer tracked.
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if n := manager.Len(); n != 1 {
+		t.Fatalf("manager holds %d locks after one was closed, expected 1", n)
+	}
+
+	// CloseAll releases the lock even though references remain open.
+	if err := manager.CloseAll(); err != nil {
+		t.Fatalf("CloseAll failed: %v", err)
+	}
+	if n := manager.Len(); n != 0 {
+		t.Fatalf("manager holds %d locks after CloseAll, expected 0", n)
+	}
+	if err := b.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("expected os.ErrClosed when closing a released lock, got: %v", err)
+	}
+
+	file, err := lockfile.Create(b.Path())
+	if err != nil {
+		t.Fatalf("the lock was not released by CloseAll: %v", err)
+	}
+	file.Close()
+}
+
+func TestDirManager(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "locks")
+	manager, err := lockfile.NewDirManager(root)
+	if err != nil {
+		t.Fatalf("NewDirManager failed: %v", err)
+	}
+	defer manager.CloseAll()
+
+	names := []string{"jobs/nightly", ".hidden", "..", "naïve name", "report.v2", "con", "Lpt1.txt", strings.Repeat("x", 250)}
+	for _, name := range names {
+		file, err := manager.Acquire(name)
+		if err != nil {
+			t.Fatalf("Acquire(%q) failed: %v", name, err)
+		}
+		if dir := filepath.Dir(file.Path()); dir != root {
+			t.Errorf("the lock file for %q is in %s, not in the root", name, dir)
+		}
+	}
+
+	// Names of Windows devices are escaped.
+	for _, base := range []string{"%63on.lock", "%4Cpt1.txt.lock"} {
+		if _, err := os.Stat(filepath.Join(root, base)); err != nil {
+			t.Errorf("the lock file %s was not created: %v", base, err)
+		}
+	}
+
+	// Names are limited by the length of their escaped form.
+	if _, err := manager.Acquire(strings.Repeat("/", 84)); !errors.Is(err, lockfile.ErrNameTooLong) {
+		t.Fatalf("expected ErrNameTooLong for a long name, got: %v", err)
+	}
+	if _, err := manager.Acquire("jobs/nightly"); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected contention for a held name, got: %v", err)
+	}
+	if _, err := manager.Acquire(""); !errors.Is(err, lockfile.ErrEmptyPath) {
+		t.Fatalf("expected ErrEmptyPath for an empty name, got: %v", err)
+	}
+
+	listed, err := manager.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	want := slices.Sorted(slices.Values(names))
+	if !slices.Equal(listed, want) {
+		t.Fatalf("List returned %q, expected %q", listed, want)
+	}
+}
+
+func TestDirManagerClean(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("lock files are deleted on close on Windows, so they are never left behind")
+	}
+
+	root := t.TempDir()
+	manager, err := lockfile.NewDirManager(root)
+	if err != nil {
+		t.Fatalf("NewDirManager failed: %v", err)
+	}
+
+	held, err := manager.Wait(context.Background(), "held")
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	defer held.Close()
+
+	// Simulate a lock file that was left behind by a holder that crashed.
+	if err := os.WriteFile(filepath.Join(root, "abandoned.lock"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// In dry-run mode, the lock file is only recorded in the plan.
+	var plan lockfile.Plan
+	preview, err := lockfile.NewDirManager(root, lockfile.WithDryRun(&plan))
+	if err != nil {
+		t.Fatalf("NewDirManager failed: %v", err)
+	}
+	removed, err := preview.Clean()
+	if err != nil {
+		t.Fatalf("Clean failed in dry-run mode: %v", err)
+	}
+	if !slices.Equal(removed, []string{"abandoned"}) {
+		t.Fatalf("Clean would remove %q, expected only the abandoned lock file", removed)
+	}
+	if actions := plan.Actions(); len(actions) != 1 || actions[0].Op != "remove" || actions[0].Path != filepath.Join(root, "abandoned.lock") {
+		t.Fatalf("unexpected plan: %+v", actions)
+	}
+	if listed, _ := manager.List(); !slices.Equal(listed, []string{"abandoned", "held"}) {
+		t.Fatalf("Clean removed lock files in dry-run mode: %q", listed)
+	}
+
+	removed, err = manager.Clean()
+	if err != nil {
+		t.Fatalf("Clean failed: %v", err)
+	}
+	if !slices.Equal(removed, []string{"abandoned"}) {
+		t.Fatalf("Clean removed %q, expected only the abandoned lock file", removed)
+	}
+	if listed, _ := manager.List(); !slices.Equal(listed, []string{"held"}) {
+		t.Fatalf("unexpected lock files after Clean: %q", listed)
+	}
+}
@@ -0,0 +1,159 @@
+package lockfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Environment variables that configure the [Mapper] returned by
+// [MapperFromEnv].
+const (
+	// EnvDir names a directory that relative lock names are placed in.
+	EnvDir = "LOCKFILE_DIR"
+
+	// EnvMap holds a list of prefix=dir rules, separated by the platform's
+	// path list separator, that redirect lock files with a matching path
+	// prefix to another directory.
+	EnvMap = "LOCKFILE_MAP"
+)
+
+// Mapper maps logical lock names to the paths of lock files.
+//
+// A Mapper allows deployments to redirect lock files to a writable
+// location, such as a tmpfs mount inside a hardened container, while the
+// data they protect lives on a read-only layer. The same code can then be
+// used unchanged on bare metal and in containers.
+//
+// A name is mapped according to the rule with the longest matching
+// prefix. If no rule matches, relative names are placed in the default
+// directory, if one has been configured. All other names are used as-is.
+//
+// A Mapper is installed by [WithMapper]. It is safe for concurrent use.
+type Mapper struct {
+	mutex      sync.RWMutex
+	defaultDir string
+	rules      []mapRule // Sorted by descending prefix length
+}
+
+// mapRule redirects names with a particular prefix to a directory.
+type mapRule struct {
+	prefix string
+	dir    string
+}
+
+// NewMapper returns a [Mapper] that places relative lock names in
+// defaultDir. If defaultDir is empty, relative names are used as-is.
+func NewMapper(defaultDir string) *Mapper {
+	return &Mapper{defaultDir: defaultDir}
+}
+
+// MapperFromEnv returns a [Mapper] configured by the [EnvDir] and [EnvMap]
+// environment variables. If neither is set, the returned Mapper leaves all
+// names unchanged.
+//
+// For example, on Linux:
+//
+//	LOCKFILE_DIR=/run/app
+//	LOCKFILE_MAP=/var/lib/app=/run/app/data:/etc/app=/run/app/config
+func MapperFromEnv() (*Mapper, error) {
+	m := NewMapper(os.Getenv(EnvDir))
+	for _, rule := range filepath.SplitList(os.Getenv(EnvMap)) {
+		if rule == "" {
+			continue
+		}
+		prefix, dir, found := strings.Cut(rule, "=")
+		if !found || prefix == "" || dir == "" {
+			return nil, fmt.Errorf("%w: invalid %s rule: %q", ErrInvalidOption, EnvMap, rule)
+		}
+		m.Add(prefix, dir)
+	}
+	return m, nil
+}
+
+// WithMapper returns an option that treats the path of each lock file as a
+// logical name and maps it to a lock file path with m.
+func WithMapper(m *Mapper) Option {
+	return func(c *config) {
+		c.mapper = m
+	}
+}
+
+// Add adds a rule that redirects lock names beginning with prefix to dir.
+// The remainder of the name, after prefix, is preserved beneath dir.
+func (m *Mapper) Add(prefix, dir string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.rules = append(m.rules, mapRule{
+		prefix: filepath.Clean(prefix),
+		dir:    dir,
+	})
+	sort.SliceStable(m.rules, func(i, j int) bool {
+		return len(m.rules[i].prefix) > len(m.rules[j].prefix)
+	})
+}
+
+// Map returns the lock file path for the given lock name.
+//
+// The name is cleaned before it is matched, so that "a/../b" is mapped
+// like "b". It returns an [*os.PathError] that wraps [ErrPathEscape] if the
+// part of the name that is placed beneath a directory, such as "../b",
+// would escape it.
+func (m *Mapper) Map(name string) (string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	clean := filepath.Clean(name)
+	for _, rule := range m.rules {
+		if rest, ok := cutPathPrefix(clean, rule.prefix); ok {
+			return joinLocal(rule.dir, rest, name)
+		}
+	}
+
+	if m.defaultDir != "" && !filepath.IsAbs(clean) {
+		return joinLocal(m.defaultDir, clean, name)
+	}
+
+	return name, nil
+}
+
+// joinLocal joins dir and rest, the part of name that is placed beneath
+// it. It returns an error if rest would escape dir.
+func joinLocal(dir, rest, name string) (string, error) {
+	if rest != "" && !filepath.IsLocal(rest) {
+		return "", &os.PathError{Op: "map", Path: name, Err: ErrPathEscape}
+	}
+	return filepath.Join(dir, rest), nil
+}
+
+// mapPath maps path to the path of a lock file with the mapper of the
+// configuration, if it has one.
+func (c *config) mapPath(path string) (string, error) {
+	if c.mapper == nil {
+		return path, nil
+	}
+	return c.mapper.Map(path)
+}
+
+// cutPathPrefix returns path without prefix if prefix is a leading sequence
+// of whole path elements within path.
+func cutPathPrefix(path, prefix string) (rest string, ok bool) {
+	if path == prefix {
+		return "", true
+	}
+	rest, ok = strings.CutPrefix(path, prefix)
+	if !ok {
+		return "", false
+	}
+	if strings.HasSuffix(prefix, string(filepath.Separator)) {
+		return rest, true
+	}
+	if rest == "" || !os.IsPathSeparator(rest[0]) {
+		return "", false
+	}
+	return rest[1:], true
+}
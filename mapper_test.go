@@ -0,0 +1,97 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestMapper(t *testing.T) {
+	data := filepath.Join(string(filepath.Separator), "var", "lib", "app")
+	runtime := filepath.Join(string(filepath.Separator), "run", "app")
+
+	m := lockfile.NewMapper(runtime)
+	m.Add(data, filepath.Join(runtime, "data"))
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{filepath.Join(data, "db.lock"), filepath.Join(runtime, "data", "db.lock")},
+		{data + "other.lock", data + "other.lock"},
+		{"jobs.lock", filepath.Join(runtime, "jobs.lock")},
+	}
+	for _, test := range tests {
+		if got, err := m.Map(test.name); err != nil || got != test.want {
+			t.Errorf("Map(%q) returned %q, %v, expected %q", test.name, got, err, test.want)
+		}
+	}
+
+	// Names may not escape the directory they are placed in.
+	for _, name := range []string{"../jobs.lock", filepath.Join("a", "..", "..", "jobs.lock")} {
+		if got, err := m.Map(name); !errors.Is(err, lockfile.ErrPathEscape) {
+			t.Errorf("Map(%q) returned %q, %v, expected ErrPathEscape", name, got, err)
+		}
+	}
+}
+
+func TestMapperFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(lockfile.EnvDir, dir)
+	t.Setenv(lockfile.EnvMap, "")
+
+	m, err := lockfile.MapperFromEnv()
+	if err != nil {
+		t.Fatalf("failed to configure mapper from the environment: %v", err)
+	}
+
+	lock, err := lockfile.Create("mapped.lock", lockfile.WithMapper(m))
+	if err != nil {
+		t.Fatalf("failed to create mapped lock file: %v", err)
+	}
+	defer lock.Close()
+
+	if want := filepath.Join(dir, "mapped.lock"); lock.Path() != want {
+		t.Fatalf("lock file was created at %s, expected %s", lock.Path(), want)
+	}
+}
+
+func TestMapperNew(t *testing.T) {
+	dir := t.TempDir()
+	mapper := lockfile.WithMapper(lockfile.NewMapper(dir))
+	want := filepath.Join(dir, "jobs.lock")
+
+	// Relative names are placed in the default directory, not the working
+	// directory.
+	lock, err := lockfile.New("jobs.lock", mapper)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if lock.Path() != want {
+		t.Fatalf("the lock was mapped to %s, expected %s", lock.Path(), want)
+	}
+	file, err := lock.TryAcquire()
+	if err != nil {
+		t.Fatalf("TryAcquire failed: %v", err)
+	}
+	if file.Path() != want {
+		t.Fatalf("the lock file was created at %s, expected %s", file.Path(), want)
+	}
+	file.Close()
+
+	set, err := lockfile.AcquireAll(context.Background(), []string{"jobs.lock"}, mapper)
+	if err != nil {
+		t.Fatalf("AcquireAll failed: %v", err)
+	}
+	if files := set.Files(); len(files) != 1 || files[0].Path() != want {
+		t.Fatalf("AcquireAll did not map the name: %v", files)
+	}
+	set.Close()
+
+	if _, err := lockfile.New("../jobs.lock", mapper); !errors.Is(err, lockfile.ErrPathEscape) {
+		t.Fatalf("expected ErrPathEscape for a name that escapes the directory, got: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package lockfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// MetadataVersion is the version of the metadata format written by this
+// package.
+//
+// The version is only incremented when the meaning of existing fields
+// changes. New fields may be added without changing the version, so
+// readers should usually ignore fields they do not recognize.
+const MetadataVersion = 1
+
+// Metadata describes the holder of a lock file. It is serialized as JSON
+// by default, or with another [Codec].
+type Metadata struct {
+	Version int    `json:"version"`
+	Holder  Holder `json:"holder"`
+
+	// Generation is the generation number of the acquisition, if it was
+	// acquired through a [Lock], as described by [File.Generation].
+	Generation uint64 `json:"generation,omitempty"`
+
+	Acquired time.Time `json:"acquired"`
+
+	// Expires is the time at which the holder's lease on the lock file
+	// expires, if it was acquired as a [Lease].
+	Expires time.Time `json:"expires,omitzero"`
+
+	// Token is the fencing token of the holder, if it was acquired with
+	// [WithFencing].
+	Token uint64 `json:"token,omitempty"`
+
+	// TraceID is the trace or correlation ID of the acquisition, if it
+	// was acquired with [WithTraceID].
+	TraceID string `json:"trace,omitempty"`
+}
+
+// UnknownFieldPolicy determines how metadata written by a newer version of
+// this package is handled.
+type UnknownFieldPolicy int
+
+const (
+	// IgnoreUnknownFields ignores fields that are not recognized, and
+	// accepts metadata with a newer version. This allows a fleet with a
+	// mix of binary versions to share lock directories.
+	IgnoreUnknownFields UnknownFieldPolicy = iota
+
+	// RejectUnknownFields rejects metadata with fields that are not
+	// recognized, or with a newer version.
+	RejectUnknownFields
+)
+
+// NewMetadata returns metadata that describes the current process as the
+// holder of a lock file with the given generation number.
+func NewMetadata(generation uint64) Metadata {
+	return Metadata{
+		Version:    MetadataVersion,
+		Holder:     CurrentHolder(),
+		Generation: generation,
+		Acquired:   time.Now(),
+	}
+}
+
+// ParseMetadata parses metadata that was read from a lock file, handling
+// unrecognized fields and versions according to policy.
+//
+// It returns an error that wraps [ErrInvalidMetadata] if the metadata is
+// malformed or has no version, or [ErrMetadataVersion] if the policy
+// rejects its version.
+func ParseMetadata(data []byte, policy UnknownFieldPolicy) (Metadata, error) {
+	var version struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &version); err != nil {
+		return Metadata{}, fmt.Errorf("%w: %w", ErrInvalidMetadata, err)
+	}
+	switch {
+	case version.Version < 1:
+		return Metadata{}, fmt.Errorf("%w: the version is missing", ErrInvalidMetadata)
+	case version.Version > MetadataVersion && policy == RejectUnknownFields:
+		return Metadata{}, fmt.Errorf("%w: version %d is newer than %d", ErrMetadataVersion, version.Version, MetadataVersion)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if policy == RejectUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+
+	var md Metadata
+	if err := decoder.Decode(&md); err != nil {
+		return Metadata{}, fmt.Errorf("%w: %w", ErrInvalidMetadata, err)
+	}
+
+	return md, nil
+}
+
+// maxMetadataSize is the largest lock file that is considered to hold
+// metadata.
+const maxMetadataSize = 64 * 1024
+
+// WithMetadata returns an option that writes metadata describing the
+// holder into each lock file when it is acquired, so that other tools can
+// identify the holder with [Inspect]. The metadata is encoded with codec,
+// or with [JSONCodec] if codec is nil.
+//
+// Lock files are otherwise empty. A lock file that was left behind by a
+// holder that crashed is still acquired if it contains valid metadata,
+// whether or not this option is configured, and its metadata is replaced.
+// Metadata is not written to shared lock files, which have several
+// holders.
+//
+// Failures to write metadata are reported to the Warning hook as errors
+// that wrap [ErrMetadataUnwritable], because they do not affect the lock.
+func WithMetadata(codec Codec) Option {
+	return func(c *config) {
+		c.metadata = true
+		c.metadataCodec = codec
+	}
+}
+
+// WithScrubOnRelease returns an option that overwrites the metadata of a
+// lock file with zeros and flushes it to storage before the lock file is
+// deleted on release.
+//
+// Some shared volumes cache file contents aggressively, and may replay old
+// data to observers after a file has been deleted or its blocks reused.
+// Scrubbing the metadata first ensures that observers never read the
+// metadata of a holder that has released the lock file. It has no effect
+// on lock files without metadata.
+//
+// Failures to scrub the metadata are reported to the Warning hook, and do
+// not prevent the lock file from being released.
+func WithScrubOnRelease() Option {
+	return func(c *config) {
+		c.scrub = true
+	}
+}
+
+// writesMetadata returns true if metadata should be written to lock files
+// acquired with the configuration.
+func (c *config) writesMetadata() bool {
+	return c.metadata && !c.shared
+}
+
+// writeMetadata replaces the contents of the lock file with metadata that
+// describes the current holder. If the lock file is held as a lease, its
+// expiration is extended.
+//
+// Failures are reported to the Warning hook, and returned for callers that
+// depend on the metadata being written.
+func (h *lockHandle) writeMetadata() error {
+	md := NewMetadata(h.generation)
+	md.Acquired = h.stats.Acquired
+	md.Token = h.token
+	md.TraceID = h.cfg.traceID
+	if h.cfg.leaseTTL > 0 {
+		md.Expires = time.Now().Add(h.cfg.leaseTTL)
+	}
+
+	// The new metadata is written over the old before the file is cut to
+	// length, so that the file is never empty. An empty lock file can be
+	// taken by hosts that rely on the metadata, such as those that share a
+	// lease.
+	data, err := EncodeMetadata(md, h.cfg.metadataCodec)
+	if err == nil {
+		if _, err = h.file.WriteAt(data, 0); err == nil {
+			err = h.file.Truncate(int64(len(data)))
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrMetadataUnwritable, err)
+		h.cfg.warn(h.path, err)
+		return err
+	}
+
+	h.metadata = true
+	h.expires = md.Expires
+	return nil
+}
+
+// scrubMetadata overwrites the metadata of the lock file with zeros, and
+// flushes it to storage. Failures are reported to the Warning hook.
+//
+// The caller must hold h.mutex.
+func (h *lockHandle) scrubMetadata() {
+	err := func() error {
+		fi, err := h.file.Stat()
+		if err != nil {
+			return err
+		}
+		if _, err := h.file.WriteAt(make([]byte, fi.Size()), 0); err != nil {
+			return err
+		}
+		return h.file.Sync()
+	}()
+	if err != nil {
+		h.cfg.warn(h.path, &os.PathError{Op: "scrub", Path: h.path, Err: err})
+	}
+}
@@ -0,0 +1,95 @@
+package lockfile_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestParseMetadata(t *testing.T) {
+	data, err := json.Marshal(lockfile.NewMetadata(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, err := lockfile.ParseMetadata(data, lockfile.RejectUnknownFields)
+	if err != nil {
+		t.Fatalf("ParseMetadata failed: %v", err)
+	}
+	if md.Version != lockfile.MetadataVersion || md.Generation != 7 {
+		t.Fatalf("unexpected metadata: %+v", md)
+	}
+}
+
+func TestParseMetadataFuture(t *testing.T) {
+	future := []byte(`{"version": 99, "holder": {"pid": 42}, "lease": "30s"}`)
+
+	md, err := lockfile.ParseMetadata(future, lockfile.IgnoreUnknownFields)
+	if err != nil {
+		t.Fatalf("ParseMetadata failed to ignore a newer version: %v", err)
+	}
+	if md.Holder.PID != 42 {
+		t.Fatalf("unexpected holder: %+v", md.Holder)
+	}
+
+	if _, err := lockfile.ParseMetadata(future, lockfile.RejectUnknownFields); !errors.Is(err, lockfile.ErrMetadataVersion) {
+		t.Fatalf("expected ErrMetadataVersion, got: %v", err)
+	}
+
+	unknown := []byte(`{"version": 1, "lease": "30s"}`)
+	if _, err := lockfile.ParseMetadata(unknown, lockfile.RejectUnknownFields); !errors.Is(err, lockfile.ErrInvalidMetadata) {
+		t.Fatalf("expected ErrInvalidMetadata for an unknown field, got: %v", err)
+	}
+}
+
+func TestParseMetadataMissingVersion(t *testing.T) {
+	if _, err := lockfile.ParseMetadata([]byte(`{"holder": {"pid": 42}}`), lockfile.IgnoreUnknownFields); !errors.Is(err, lockfile.ErrInvalidMetadata) {
+		t.Fatalf("expected ErrInvalidMetadata, got: %v", err)
+	}
+}
+
+func TestWithMetadata(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "holder.lock")
+
+	file, err := lockfile.Create(path, lockfile.WithMetadata(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	info, err := lockfile.Inspect(path)
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if info.Format != "json" || info.Metadata == nil || info.Metadata.Holder.PID != os.Getpid() {
+		t.Fatalf("unexpected inspection: %+v", info)
+	}
+}
+
+func TestWithMetadataGeneration(t *testing.T) {
+	l, err := lockfile.New(filepath.Join(t.TempDir(), "holder.lock"), lockfile.WithMetadata(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for want := uint64(1); want <= 2; want++ {
+		file, err := l.TryAcquire()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := lockfile.Inspect(l.Path())
+		file.Close()
+		if err != nil {
+			t.Fatalf("Inspect failed: %v", err)
+		}
+		if info.Metadata == nil || info.Metadata.Generation != want {
+			t.Fatalf("unexpected metadata for generation %d: %+v", want, info.Metadata)
+		}
+	}
+}
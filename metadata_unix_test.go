@@ -0,0 +1,96 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestMetadataLeftBehind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "holder.lock")
+
+	// Simulate a holder that crashed after writing its metadata, which
+	// leaves the lock file behind without a lock on it.
+	md := lockfile.NewMetadata(0)
+	md.Holder.PID = 1073741822
+	data, err := lockfile.EncodeMetadata(md, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range [][]lockfile.Option{nil, {lockfile.WithMetadata(nil)}} {
+		file, err := lockfile.Create(path, opts...)
+		if err != nil {
+			t.Fatalf("Create failed with metadata left behind: %v", err)
+		}
+		if len(opts) > 0 {
+			info, err := lockfile.Inspect(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Metadata.Holder.PID != os.Getpid() {
+				t.Fatalf("the metadata left behind was not replaced: %+v", info.Metadata)
+			}
+		}
+		if err := file.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Anything else is not a lock file.
+	if err := os.WriteFile(path, []byte("important data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockfile.Create(path, lockfile.WithMetadata(nil)); !errors.Is(err, lockfile.ErrNotEmpty) {
+		t.Fatalf("Create returned %v for a file that is not a lock file", err)
+	}
+}
+
+func TestScrubOnRelease(t *testing.T) {
+	for _, scrub := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "scrub.lock")
+		opts := []lockfile.Option{lockfile.WithMetadata(nil)}
+		if scrub {
+			opts = append(opts, lockfile.WithScrubOnRelease())
+		}
+
+		file, err := lockfile.Create(path, opts...)
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+
+		// A second link lets us observe the contents after the lock file
+		// has been deleted.
+		link := path + ".link"
+		if err := os.Link(path, link); err != nil {
+			t.Fatal(err)
+		}
+		if err := file.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		data, err := os.ReadFile(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) == 0 {
+			t.Fatal("no metadata was written")
+		}
+		zeroed := len(bytes.Trim(data, "\x00")) == 0
+		if zeroed != scrub {
+			t.Errorf("scrub %t: the metadata was left as %q", scrub, data)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package lockfile
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Middleware wraps a [Provider] to add behavior around the acquisition and
+// release of lock files, in the same way that HTTP middleware wraps an
+// [net/http.Handler].
+//
+// The handles returned by a provider that has been wrapped may not be
+// [*File] values, because middleware that observes releases wraps them.
+type Middleware func(Provider) Provider
+
+// Chain wraps provider with the given middleware. The first middleware is
+// the outermost, so it sees each call first and each result last.
+func Chain(provider Provider, middleware ...Middleware) Provider {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		provider = middleware[i](provider)
+	}
+	return provider
+}
+
+// providerFuncs is a [Provider] that is implemented by functions, which is
+// how the stock middleware is built.
+type providerFuncs struct {
+	next   Provider
+	create func(path string) (Handle, error)
+	wait   func(ctx context.Context, path string) (Handle, error)
+}
+
+func (p providerFuncs) Create(path string) (Handle, error) {
+	return p.create(path)
+}
+
+func (p providerFuncs) Wait(ctx context.Context, path string) (Handle, error) {
+	return p.wait(ctx, path)
+}
+
+func (p providerFuncs) Guarantees() Guarantees {
+	return p.next.Guarantees()
+}
+
+// wrappedHandle is a [Handle] whose Close method is intercepted.
+type wrappedHandle struct {
+	Handle
+	close func(next Handle) error
+}
+
+func (h wrappedHandle) Close() error {
+	return h.close(h.Handle)
+}
+
+// wrapClose wraps the handle that results from an acquisition so that each
+// call to its Close method is made through close.
+func wrapClose(h Handle, err error, close func(next Handle) error) (Handle, error) {
+	if err != nil {
+		return nil, err
+	}
+	return wrappedHandle{Handle: h, close: close}, nil
+}
+
+// observe wraps the handle that results from an acquisition so that closed
+// is called with the result of each call to its Close method.
+func observe(h Handle, err error, closed func(err error)) (Handle, error) {
+	return wrapClose(h, err, func(next Handle) error {
+		err := next.Close()
+		closed(err)
+		return err
+	})
+}
+
+// Logging returns middleware that logs each acquisition and release of a
+// lock file to logger. Successful operations are logged at the debug
+// level, and failures at the warning level. Contention that is reported by
+// Create is logged at the debug level, because it is expected.
+func Logging(logger *slog.Logger) Middleware {
+	return func(next Provider) Provider {
+		log := func(msg, path string, start time.Time, err error) {
+			attrs := []slog.Attr{
+				slog.String("path", path),
+				slog.Duration("elapsed", time.Since(start)),
+			}
+			level := slog.LevelDebug
+			if err != nil {
+				attrs = append(attrs, slog.Any("error", err))
+				if !IsTemporary(err) {
+					level = slog.LevelWarn
+				}
+			}
+			logger.LogAttrs(context.Background(), level, msg, attrs...)
+		}
+		closed := func(path string, acquired time.Time) func(error) {
+			return func(err error) { log("lock file released", path, acquired, err) }
+		}
+
+		return providerFuncs{
+			next: next,
+			create: func(path string) (Handle, error) {
+				start := time.Now()
+				h, err := next.Create(path)
+				log("lock file created", path, start, err)
+				return observe(h, err, closed(path, time.Now()))
+			},
+			wait: func(ctx context.Context, path string) (Handle, error) {
+				start := time.Now()
+				h, err := next.Wait(ctx, path)
+				log("lock file acquired", path, start, err)
+				return observe(h, err, closed(path, time.Now()))
+			},
+		}
+	}
+}
+
+// Observer receives the events reported by the [Metrics] middleware. Any
+// of its functions may be nil. They may be called concurrently from
+// multiple goroutines.
+type Observer struct {
+	// Acquired is called when a lock file is acquired, with the time spent
+	// acquiring it.
+	Acquired func(path string, wait time.Duration)
+
+	// Failed is called when a lock file could not be acquired.
+	Failed func(path string, err error)
+
+	// Released is called when a lock file is released, with the time that
+	// it was held for and the result of the release.
+	Released func(path string, held time.Duration, err error)
+}
+
+// Metrics returns middleware that reports each acquisition and release of
+// a lock file to observer, so that they can be recorded by a metrics
+// system.
+func Metrics(observer Observer) Middleware {
+	return func(next Provider) Provider {
+		record := func(path string, start time.Time, h Handle, err error) (Handle, error) {
+			if err != nil {
+				if observer.Failed != nil {
+					observer.Failed(path, err)
+				}
+				return nil, err
+			}
+			acquired := time.Now()
+			if observer.Acquired != nil {
+				observer.Acquired(path, acquired.Sub(start))
+			}
+			return observe(h, nil, func(err error) {
+				if observer.Released != nil {
+					observer.Released(path, time.Since(acquired), err)
+				}
+			})
+		}
+
+		return providerFuncs{
+			next: next,
+			create: func(path string) (Handle, error) {
+				start := time.Now()
+				h, err := next.Create(path)
+				return record(path, start, h, err)
+			},
+			wait: func(ctx context.Context, path string) (Handle, error) {
+				start := time.Now()
+				h, err := next.Wait(ctx, path)
+				return record(path, start, h, err)
+			},
+		}
+	}
+}
+
+// Tracer starts a span for an operation on the lock file at path, and
+// returns a function that ends it with the result of the operation. The
+// operation is "create", "wait" or "release". For Wait, ctx is the context
+// that was provided by the caller. For Create and release, which do not
+// have one, it is [context.Background].
+//
+// A Tracer adapts the [Tracing] middleware to a tracing system.
+type Tracer func(ctx context.Context, op, path string) (end func(err error))
+
+// Tracing returns middleware that traces each acquisition and release of a
+// lock file with tracer.
+func Tracing(tracer Tracer) Middleware {
+	return func(next Provider) Provider {
+		release := func(next Handle) error {
+			end := tracer(context.Background(), "release", next.Path())
+			err := next.Close()
+			end(err)
+			return err
+		}
+
+		return providerFuncs{
+			next: next,
+			create: func(path string) (Handle, error) {
+				end := tracer(context.Background(), "create", path)
+				h, err := next.Create(path)
+				end(err)
+				return wrapClose(h, err, release)
+			},
+			wait: func(ctx context.Context, path string) (Handle, error) {
+				end := tracer(ctx, "wait", path)
+				h, err := next.Wait(ctx, path)
+				end(err)
+				return wrapClose(h, err, release)
+			},
+		}
+	}
+}
+
+// RateLimit returns middleware that limits the rate at which lock files are
+// acquired to one per interval on average, with bursts of up to burst
+// acquisitions. The limit is shared by all paths.
+//
+// Wait waits until the limit allows it to proceed, or ctx is cancelled.
+// Create does not wait, and returns an [*os.PathError] that wraps
+// [ErrRateLimited] if the limit does not allow it to proceed.
+func RateLimit(interval time.Duration, burst int) Middleware {
+	return func(next Provider) Provider {
+		limiter := &rateLimiter{
+			interval: interval,
+			burst:    float64(max(burst, 1)),
+			tokens:   float64(max(burst, 1)),
+			last:     time.Now(),
+		}
+
+		return providerFuncs{
+			next: next,
+			create: func(path string) (Handle, error) {
+				if limiter.reserve(false) > 0 {
+					return nil, &os.PathError{Op: "create", Path: path, Err: ErrRateLimited}
+				}
+				return next.Create(path)
+			},
+			wait: func(ctx context.Context, path string) (Handle, error) {
+				if delay := limiter.reserve(true); delay > 0 {
+					timer := time.NewTimer(delay)
+					defer timer.Stop()
+					select {
+					case <-ctx.Done():
+						limiter.cancel()
+						return nil, withReason(ctx.Err())
+					case <-timer.C:
+					}
+				}
+				return next.Wait(ctx, path)
+			},
+		}
+	}
+}
+
+// rateLimiter is a token bucket.
+type rateLimiter struct {
+	interval time.Duration
+	burst    float64
+
+	mutex  sync.Mutex
+	tokens float64 // May be negative when tokens have been reserved
+	last   time.Time
+}
+
+// reserve takes a token, and returns how long the caller must wait before
+// it may proceed. If borrow is false and no token is available, no token
+// is taken and a positive delay is returned.
+func (l *rateLimiter) reserve(borrow bool) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	if l.interval > 0 {
+		l.tokens = min(l.burst, l.tokens+float64(now.Sub(l.last))/float64(l.interval))
+	} else {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	delay := time.Duration((1 - l.tokens) * float64(l.interval))
+	if borrow {
+		l.tokens--
+	}
+	return delay
+}
+
+// cancel returns a token that was reserved but not used.
+func (l *rateLimiter) cancel() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.tokens = min(l.burst, l.tokens+1)
+}
+
+// Retry returns middleware that retries acquisitions that fail with an
+// error for which [IsTemporary] returns true, up to the given number of
+// attempts in total, with the given delay between attempts.
+//
+// This gives Create a brief grace period before it reports contention.
+// Wait already retries contention itself, so for Wait it only retries
+// errors such as [ErrRetryBudgetExhausted] that wrap a temporary error,
+// and stops early if ctx is cancelled.
+func Retry(attempts int, delay time.Duration) Middleware {
+	return func(next Provider) Provider {
+		retry := func(ctx context.Context, acquire func() (Handle, error)) (Handle, error) {
+			for attempt := 1; ; attempt++ {
+				h, err := acquire()
+				if err == nil || !IsTemporary(err) || attempt >= attempts {
+					return h, err
+				}
+				timer := time.NewTimer(delay)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return nil, err
+				case <-timer.C:
+				}
+			}
+		}
+
+		return providerFuncs{
+			next: next,
+			create: func(path string) (Handle, error) {
+				return retry(context.Background(), func() (Handle, error) { return next.Create(path) })
+			},
+			wait: func(ctx context.Context, path string) (Handle, error) {
+				return retry(ctx, func() (Handle, error) { return next.Wait(ctx, path) })
+			},
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package lockfile_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestChain(t *testing.T) {
+	var (
+		mutex sync.Mutex
+		ops   []string
+	)
+	trace := func(name string) lockfile.Middleware {
+		return lockfile.Tracing(func(ctx context.Context, op, path string) func(error) {
+			mutex.Lock()
+			ops = append(ops, name+" "+op)
+			mutex.Unlock()
+			return func(error) {}
+		})
+	}
+
+	provider := lockfile.Chain(lockfile.NewProvider(), trace("outer"), trace("inner"))
+	h, err := provider.Create(filepath.Join(t.TempDir(), "chain.lock"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	want := "outer create,inner create,outer release,inner release"
+	if got := strings.Join(ops, ","); got != want {
+		t.Fatalf("unexpected order of operations: %s", got)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	var acquired, failed, released int
+	provider := lockfile.Chain(lockfile.NewProvider(), lockfile.Metrics(lockfile.Observer{
+		Acquired: func(path string, wait time.Duration) { acquired++ },
+		Failed:   func(path string, err error) { failed++ },
+		Released: func(path string, held time.Duration, err error) { released++ },
+	}))
+
+	path := filepath.Join(t.TempDir(), "metrics.lock")
+	h, err := provider.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := provider.Create(path); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected contention, got: %v", err)
+	}
+	h.Close()
+
+	if acquired != 1 || failed != 1 || released != 1 {
+		t.Fatalf("unexpected metrics: acquired %d, failed %d, released %d", acquired, failed, released)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	provider := lockfile.Chain(lockfile.NewProvider(), lockfile.Logging(logger))
+
+	h, err := provider.Wait(context.Background(), filepath.Join(t.TempDir(), "logging.lock"))
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	h.Close()
+
+	for _, msg := range []string{"lock file acquired", "lock file released"} {
+		if !strings.Contains(buf.String(), msg) {
+			t.Errorf("the log does not contain %q:\n%s", msg, buf.String())
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	provider := lockfile.Chain(lockfile.NewProvider(), lockfile.RateLimit(time.Hour, 1))
+	path := filepath.Join(t.TempDir(), "limited.lock")
+
+	h, err := provider.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	h.Close()
+
+	if _, err := provider.Create(path); !errors.Is(err, lockfile.ErrRateLimited) {
+		t.Fatalf("expected the second Create to be rate limited, got: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := provider.Wait(ctx, path); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected Wait to wait for the rate limit, got: %v", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.lock")
+	held, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	time.AfterFunc(50*time.Millisecond, func() { held.Close() })
+
+	provider := lockfile.Chain(lockfile.NewProvider(), lockfile.Retry(100, 10*time.Millisecond))
+	h, err := provider.Create(path)
+	if err != nil {
+		t.Fatalf("Create was not retried until the lock file was released: %v", err)
+	}
+	h.Close()
+}
@@ -0,0 +1,160 @@
+package lockfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// mkdirHolderName is the name of the file within a lock directory that
+// records its holder.
+const mkdirHolderName = "holder"
+
+// MkdirBackend is a [Backend] that uses the creation of a directory as the
+// lock. A lock is held while a directory exists at its path.
+//
+// Creating a directory is atomic on practically every filesystem,
+// including NFS and other network filesystems on which flock and
+// byte-range locks are unreliable or unavailable. The price is that the
+// operating system does not release the lock if its holder crashes, so a
+// holder that exits without releasing the lock leaves a stale directory
+// behind.
+//
+// To recover from this, the holder is recorded as [Metadata] in a file
+// named "holder" within the directory. A lock whose holder is known to no
+// longer be running, as determined by [StaleCheck], is broken by the next
+// process that attempts to acquire it. A holder on another host cannot be
+// checked, so its lock is only broken once the holder file is older than
+// the staleness threshold of the backend, if it has one.
+//
+// Breaking a lock renames the directory out of the way before it is
+// removed, so that only one of several processes that find it stale can
+// break it. A holder whose lock was broken receives an error that wraps
+// [ErrMoved] when it releases the lock.
+//
+// Shared locks are not supported. A lock directory is not a lock file, so
+// features that read or write the lock file, such as [WithMetadata], are
+// not available.
+type MkdirBackend struct {
+	staleAfter time.Duration
+}
+
+// NewMkdirBackend returns a [MkdirBackend]. Locks whose holder cannot be
+// checked are considered stale once they have been held for longer than
+// staleAfter. A staleAfter of zero or less never considers them stale.
+//
+// The threshold must be longer than any lock is held for, and longer than
+// the difference between the clocks of the hosts that share the locks.
+func NewMkdirBackend(staleAfter time.Duration) *MkdirBackend {
+	return &MkdirBackend{staleAfter: staleAfter}
+}
+
+// TryAcquire makes a single attempt to create the lock directory with the
+// given path, breaking it first if it is stale. It returns an
+// [*os.PathError] that wraps [os.ErrExist] if the lock is held by someone
+// else.
+func (b *MkdirBackend) TryAcquire(path string, shared bool) (BackendLock, error) {
+	if shared {
+		return BackendLock{}, &os.PathError{Op: "acquire", Path: path, Err: fmt.Errorf("%w: the mkdir backend does not support shared locks", ErrInvalidOption)}
+	}
+
+	data, err := EncodeMetadata(NewMetadata(0), nil)
+	if err != nil {
+		return BackendLock{}, err
+	}
+
+	return tryAcquireOrBreak(path, func() (BackendLock, error) {
+		if err := os.Mkdir(path, 0755); err != nil {
+			return BackendLock{}, err
+		}
+		if err := writeHolder(path, data); err != nil {
+			os.RemoveAll(path)
+			return BackendLock{}, err
+		}
+		return BackendLock{Value: data}, nil
+	}, b.stale, b.ReadLock)
+}
+
+// Release removes the lock directory with the given path. It returns an
+// [*os.PathError] that wraps [ErrMoved] if the lock was broken by someone
+// else while it was held, in which case the directory is left alone.
+func (b *MkdirBackend) Release(path string, lock BackendLock) error {
+	data, err := os.ReadFile(filepath.Join(path, mkdirHolderName))
+	owned, _ := lock.Value.([]byte)
+	if err != nil || !bytes.Equal(data, owned) {
+		return &os.PathError{Op: "release", Path: path, Err: ErrMoved}
+	}
+
+	if err := os.Remove(filepath.Join(path, mkdirHolderName)); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Guarantees returns the guarantees provided by the locks of the backend.
+// The operating system does not release them, and directories whose holder
+// cannot be checked are broken once they are older than the staleness
+// threshold of the backend.
+func (b *MkdirBackend) Guarantees(shared bool) Guarantees {
+	return Guarantees{
+		Exclusive:  !shared,
+		StaleAfter: max(b.staleAfter, 0),
+	}
+}
+
+// Probe reports whether the lock directory with the given path exists, and
+// whether it is held. A stale lock exists but is not held.
+func (b *MkdirBackend) Probe(path string) (exists, held bool, err error) {
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return false, false, nil
+	case err != nil:
+		return false, false, err
+	case !info.IsDir():
+		return true, false, nil
+	}
+	_, stale := b.stale(path)
+	return true, !stale, nil
+}
+
+// ReadLock returns the contents of the holder file within the lock
+// directory with the given path.
+func (b *MkdirBackend) ReadLock(path string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(path, mkdirHolderName))
+}
+
+// stale returns true if the lock directory at path was left behind by a
+// holder that is no longer running. It also returns the contents of the
+// holder file that the decision was based on.
+//
+// A directory without a readable holder file may belong to a holder that
+// has not written it yet, so it is only stale once the directory itself is
+// older than the staleness threshold.
+func (b *MkdirBackend) stale(path string) ([]byte, bool) {
+	holder := filepath.Join(path, mkdirHolderName)
+	data, err := os.ReadFile(holder)
+	if err != nil {
+		holder = path
+	}
+
+	info, err := os.Stat(holder)
+	if err != nil {
+		return data, false
+	}
+	return data, staleHolder(data, info.ModTime(), b.staleAfter)
+}
+
+// writeHolder records the holder of the lock directory at path. The holder
+// file is written under a temporary name and renamed into place, so that it
+// is never seen partially written.
+func writeHolder(path string, data []byte) error {
+	temp := filepath.Join(path, mkdirHolderName+".tmp")
+	if err := os.WriteFile(temp, data, 0644); err != nil {
+		return classifyError(err)
+	}
+	return os.Rename(temp, filepath.Join(path, mkdirHolderName))
+}
@@ -0,0 +1,138 @@
+package lockfile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestMkdirBackend(t *testing.T) {
+	backend := lockfile.WithBackend(lockfile.NewMkdirBackend(0))
+	path := filepath.Join(t.TempDir(), "mkdir.lock")
+
+	file, err := lockfile.Create(path, backend)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if g := file.Guarantees(); !g.Exclusive || g.KernelLock || g.StaleAfter != 0 {
+		t.Fatalf("unexpected guarantees: %+v", g)
+	}
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		t.Fatalf("the lock directory was not created: %v", err)
+	}
+	if _, err := lockfile.Create(path, backend); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected ErrExist while the lock is held, got: %v", err)
+	}
+	if _, err := lockfile.CreateShared(path, backend); !errors.Is(err, lockfile.ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption for a shared lock, got: %v", err)
+	}
+
+	info, err := lockfile.Inspect(path, backend)
+	if err != nil || !info.Held {
+		t.Fatalf("expected Inspect to report the lock as held, got %+v, %v", info, err)
+	}
+	if info.Metadata == nil || info.Metadata.Holder.PID != os.Getpid() {
+		t.Fatalf("Inspect did not report the holder: %+v", info.Metadata)
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the lock directory was not removed: %v", err)
+	}
+}
+
+func TestMkdirBackendStale(t *testing.T) {
+	dir := t.TempDir()
+	hostname, _ := os.Hostname()
+
+	// A holder on this host that is no longer running is broken.
+	dead := filepath.Join(dir, "dead.lock")
+	md := lockfile.NewMetadata(0)
+	md.Holder = lockfile.Holder{PID: 1<<22 - 1, Hostname: hostname}
+	writeMkdirLock(t, dead, md)
+
+	file, err := lockfile.Create(dead, lockfile.WithBackend(lockfile.NewMkdirBackend(0)))
+	if err != nil {
+		t.Fatalf("Create did not break the lock of a dead holder: %v", err)
+	}
+	file.Close()
+
+	// A holder on another host is only broken once it is older than the
+	// threshold.
+	remote := filepath.Join(dir, "remote.lock")
+	md.Holder = lockfile.Holder{PID: 1, Hostname: hostname + ".elsewhere"}
+	writeMkdirLock(t, remote, md)
+
+	if g := lockfile.GuaranteesOf(lockfile.WithBackend(lockfile.NewMkdirBackend(time.Hour))); g.KernelLock || g.StaleAfter != time.Hour {
+		t.Fatalf("unexpected guarantees with a threshold: %+v", g)
+	}
+	if _, err := lockfile.Create(remote, lockfile.WithBackend(lockfile.NewMkdirBackend(time.Hour))); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected ErrExist for a recent remote holder, got: %v", err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(remote, "holder"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockfile.Create(remote, lockfile.WithBackend(lockfile.NewMkdirBackend(0))); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected ErrExist without a threshold, got: %v", err)
+	}
+	file, err = lockfile.Create(remote, lockfile.WithBackend(lockfile.NewMkdirBackend(time.Hour)))
+	if err != nil {
+		t.Fatalf("Create did not break the lock of an old remote holder: %v", err)
+	}
+	file.Close()
+
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Fatalf("the directory was not left empty: %v, %v", entries, err)
+	}
+}
+
+func TestMkdirBackendBroken(t *testing.T) {
+	backend := lockfile.WithBackend(lockfile.NewMkdirBackend(0))
+	path := filepath.Join(t.TempDir(), "mkdir.lock")
+
+	file, err := lockfile.Create(path, backend)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// Someone else breaks the lock and acquires it.
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatal(err)
+	}
+	other, err := lockfile.Create(path, backend)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer other.Close()
+
+	if err := file.Close(); !errors.Is(err, lockfile.ErrMoved) {
+		t.Fatalf("expected ErrMoved from the broken lock, got: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("releasing the broken lock removed the new holder's directory: %v", err)
+	}
+}
+
+// writeMkdirLock creates a lock directory at path that records md as its
+// holder, as the mkdir backend does.
+func writeMkdirLock(t *testing.T, path string, md lockfile.Metadata) {
+	t.Helper()
+
+	data, err := lockfile.EncodeMetadata(md, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "holder"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,150 @@
+package lockfile
+
+import (
+	"os"
+	"time"
+)
+
+// WithLinkMonitor returns an option that checks a held lock file at the
+// given interval, to detect whether it has been unlinked, linked under
+// another name, or replaced by another file while it is held, such as by
+// an administrator who removed it. Without it, such problems are only
+// discovered when the lock file is released.
+//
+// A lock file that is found to be compromised moves to [StateLost], and
+// the problem is reported to the Warning hook and to each channel
+// returned by [File.Monitor], as an error that wraps [ErrCompromised].
+//
+// Lock files are only checked on Unix systems. On Windows, a held lock file
+// cannot be deleted or renamed.
+func WithLinkMonitor(interval time.Duration) Option {
+	return func(c *config) {
+		c.linkMonitor = interval
+	}
+}
+
+// WithRecreateOnDelete returns an option that re-creates a held lock file
+// when [WithLinkMonitor] finds that it was removed from its path by
+// someone else, so that new contenders are excluded again. The original
+// lock file remains locked until the new one has been locked.
+//
+// The incident is reported to the Warning hook as an [*os.PathError] with
+// the operation "recreate" that wraps [ErrCompromised], and the lock
+// remains held. If the lock file cannot be re-created, such as because a
+// new contender has already created one, the lock is compromised as
+// described by [WithLinkMonitor]. Lock files that were replaced or linked
+// under another name are never re-created.
+func WithRecreateOnDelete() Option {
+	return func(c *config) {
+		c.recreate = true
+	}
+}
+
+// Monitor returns a channel that receives an error that wraps
+// [ErrCompromised] if the lock file is found to be compromised while it is
+// held, as described by [WithLinkMonitor], or if it is a [Lease] that
+// expired before it could be renewed. The channel is closed once the error
+// has been sent, or once the lock has been released.
+//
+// Each call returns a new channel. Without [WithLinkMonitor], the channel
+// of a lock file that is not a lease is closed when the lock is released
+// without receiving anything.
+func (f *File) Monitor() <-chan error {
+	ch := make(chan error, 1)
+
+	f.h.mutex.Lock()
+	defer f.h.mutex.Unlock()
+
+	switch {
+	case f.h.compromise != nil:
+		ch <- f.h.compromise
+		close(ch)
+	case f.h.refs == 0:
+		close(ch)
+	default:
+		f.h.monitors = append(f.h.monitors, ch)
+	}
+	return ch
+}
+
+// startMonitor starts checking the lock file at the given interval, until
+// it is released or found to be compromised.
+func (h *lockHandle) startMonitor(interval time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.monitorStop = make(chan struct{})
+	go h.monitorLoop(interval, h.monitorStop)
+}
+
+// monitorLoop checks the lock file at the given interval until stop is
+// closed or it is found to be compromised.
+func (h *lockHandle) monitorLoop(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		h.mutex.Lock()
+		if h.file == nil {
+			h.mutex.Unlock()
+			return
+		}
+		removed, err := h.checkLinks()
+		var recreated error
+		if err != nil && removed && h.cfg.recreate && !h.soft && h.backend == nil {
+			if h.recreate() == nil {
+				recreated = &os.PathError{Op: "recreate", Path: h.path, Err: err}
+				err = nil
+			}
+		}
+		if err != nil {
+			h.compromised(&os.PathError{Op: "monitor", Path: h.path, Err: err})
+		}
+		h.mutex.Unlock()
+
+		if recreated != nil {
+			h.cfg.warn(h.path, recreated)
+		}
+		if err != nil {
+			h.cfg.warn(h.path, h.compromise)
+			return
+		}
+	}
+}
+
+// compromised records err, which wraps [ErrCompromised], as the reason the
+// lock was lost, moves the lock to [StateLost] and sends err to the
+// channels returned by [File.Monitor]. The state changes first, so that an
+// observer woken by the channels sees the lock as lost.
+//
+// The caller must hold h.mutex.
+func (h *lockHandle) compromised(err error) {
+	h.compromise = err
+	h.lifecycle.transition(StateLost)
+	for _, ch := range h.monitors {
+		ch <- err
+		close(ch)
+	}
+	h.monitors = nil
+}
+
+// stopMonitor stops checking the lock file, and closes the channels
+// returned by [File.Monitor].
+//
+// The caller must hold h.mutex.
+func (h *lockHandle) stopMonitor() {
+	if h.monitorStop != nil {
+		close(h.monitorStop)
+		h.monitorStop = nil
+	}
+	for _, ch := range h.monitors {
+		close(ch)
+	}
+	h.monitors = nil
+}
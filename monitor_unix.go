@@ -0,0 +1,68 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// checkLinks returns an error that wraps [ErrCompromised] if the held lock
+// file no longer has exactly one link, or if its path refers to some other
+// file. It reports whether the lock file was removed from its path, in
+// which case it may be re-created.
+//
+// The caller must hold h.mutex.
+func (h *lockHandle) checkLinks() (removed bool, err error) {
+	sys := h.cfg.system()
+
+	held, err := sys.fstat(h.path, int(h.file.Fd()))
+	if err != nil {
+		return false, nil // Not evidence of a problem
+	}
+	switch {
+	case held.Nlink == 0:
+		return true, fmt.Errorf("%w: the lock file was unlinked", ErrCompromised)
+	case held.Nlink > 1:
+		return false, fmt.Errorf("%w: the lock file was linked under another name", ErrCompromised)
+	}
+
+	current, err := sys.stat(h.path)
+	switch {
+	case errors.Is(err, syscall.ENOENT):
+		return true, fmt.Errorf("%w: the lock file was moved", ErrCompromised)
+	case err != nil:
+		return false, nil
+	case current.Dev != held.Dev || current.Ino != held.Ino:
+		return false, fmt.Errorf("%w: the lock file was replaced", ErrCompromised)
+	}
+	return false, nil
+}
+
+// recreate creates and locks a new lock file at the path of a lock file
+// that was removed, and holds it in place of the original. The original
+// remains locked until the new one has been locked, and is then closed.
+//
+// If a new contender has already created a lock file at the path, it
+// fails, and the lock remains compromised.
+//
+// The caller must hold h.mutex.
+func (h *lockHandle) recreate() error {
+	fresh, err := h.cfg.lock(h.path)
+	if err != nil {
+		return err
+	}
+
+	// Take the open file from the new handle, which is discarded without
+	// being released.
+	original := h.file
+	h.file, fresh.h.file = fresh.h.file, nil
+	fresh.h.refs = 0
+	h.cfg.system().closeFile(h.path, original)
+
+	if h.metadata {
+		h.writeMetadata()
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestLinkMonitor(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		tamper func(path string) error
+	}{
+		{"unlinked", os.Remove},
+		{"linked", func(path string) error { return os.Link(path, path+".link") }},
+		{"replaced", func(path string) error {
+			if err := os.Rename(path, path+".moved"); err != nil {
+				return err
+			}
+			return os.WriteFile(path, nil, 0600)
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "monitored.lock")
+			file, err := lockfile.Create(path, lockfile.WithLinkMonitor(10*time.Millisecond))
+			if err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+			defer file.Close()
+
+			monitor := file.Monitor()
+			ctx := file.Context(context.Background())
+			if err := tc.tamper(path); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case err := <-monitor:
+				if !errors.Is(err, lockfile.ErrCompromised) {
+					t.Fatalf("expected an error that wraps ErrCompromised, got: %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("the compromised lock file was not detected")
+			}
+
+			<-ctx.Done()
+			if cause := context.Cause(ctx); !errors.Is(cause, lockfile.ErrCompromised) {
+				t.Fatalf("expected the context to be cancelled by the compromise, got: %v", cause)
+			}
+			if state := file.State(); state != lockfile.StateLost {
+				t.Fatalf("unexpected state of a compromised lock: %v", state)
+			}
+			if err := <-file.Monitor(); !errors.Is(err, lockfile.ErrCompromised) {
+				t.Fatalf("a later call to Monitor did not report the compromise: %v", err)
+			}
+		})
+	}
+}
+
+func TestLinkMonitorReleased(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monitored.lock")
+	file, err := lockfile.Create(path, lockfile.WithLinkMonitor(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	monitor := file.Monitor()
+	time.Sleep(50 * time.Millisecond)
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if err, ok := <-monitor; ok {
+		t.Fatalf("Monitor reported a problem with a lock file that was released: %v", err)
+	}
+}
+
+func TestRecreateOnDelete(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recreated.lock")
+
+	warnings := make(chan error, 1)
+	file, err := lockfile.Create(path,
+		lockfile.WithLinkMonitor(10*time.Millisecond),
+		lockfile.WithRecreateOnDelete(),
+		lockfile.WithHooks(lockfile.Hooks{
+			Warning: func(path string, err error) {
+				select {
+				case warnings <- err:
+				default:
+				}
+			},
+		}))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-warnings:
+		var pathErr *os.PathError
+		if !errors.As(err, &pathErr) || pathErr.Op != "recreate" || !errors.Is(err, lockfile.ErrCompromised) {
+			t.Fatalf("unexpected warning: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the deleted lock file was not re-created")
+	}
+
+	if state := file.State(); state != lockfile.StateAcquired {
+		t.Fatalf("unexpected state of a re-created lock: %v", state)
+	}
+	if _, err := lockfile.Create(path); !lockfile.IsTemporary(err) {
+		t.Fatalf("a new contender was not excluded by the re-created lock file: %v", err)
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("the re-created lock file was not deleted on release: %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package lockfile
+
+// checkLinks returns nil, because a held lock file cannot be deleted or
+// renamed on Windows.
+func (h *lockHandle) checkLinks() (removed bool, err error) {
+	return false, nil
+}
+
+// recreate is never called on Windows, because checkLinks never reports a
+// problem.
+func (h *lockHandle) recreate() error {
+	return ErrCompromised
+}
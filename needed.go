@@ -0,0 +1,40 @@
+package lockfile
+
+// WithStillNeeded returns an option that runs check after a lock file has
+// been acquired by [WaitCtx], [WaitUntil] or [Lock.Acquire].
+//
+// A common pattern is for several processes to wait for the same lock in
+// order to perform the same task, such as refreshing a cache. By the time
+// a waiter acquires the lock, another process may already have completed
+// the task. If check returns false, the lock file is closed and
+// [ErrNoLongerNeeded] is returned, so that the caller does not duplicate
+// the work. If check returns an error, the lock file is closed and the
+// error is returned.
+//
+// The check is not run by [Create] or [Lock.TryAcquire].
+func WithStillNeeded(check func() (needed bool, err error)) Option {
+	return func(c *config) {
+		c.stillNeeded = check
+	}
+}
+
+// checkStillNeeded runs the check provided by [WithStillNeeded] for a
+// freshly acquired lock file. It returns the file if the check passes or
+// no check was provided. Otherwise it closes the file and returns an
+// error.
+func (c *config) checkStillNeeded(file *File) (*File, error) {
+	if c.stillNeeded == nil {
+		return file, nil
+	}
+
+	needed, err := c.stillNeeded()
+	if err == nil && !needed {
+		err = ErrNoLongerNeeded
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
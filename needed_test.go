@@ -0,0 +1,57 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestStillNeeded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "needed.lock")
+
+	done := false
+	check := lockfile.WithStillNeeded(func() (bool, error) {
+		return !done, nil
+	})
+
+	file, err := lockfile.WaitCtx(context.Background(), path, check)
+	if err != nil {
+		t.Fatalf("WaitCtx failed while the work was still needed: %v", err)
+	}
+	done = true
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	_, err = lockfile.WaitCtx(context.Background(), path, check)
+	if !errors.Is(err, lockfile.ErrNoLongerNeeded) {
+		t.Fatalf("expected ErrNoLongerNeeded, got: %v", err)
+	}
+
+	// The lock file must have been released.
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("lock file was not released: %v", err)
+	}
+}
+
+func TestStillNeededError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "needed.lock")
+	errBroken := errors.New("broken")
+
+	_, err := lockfile.WaitCtx(context.Background(), path, lockfile.WithStillNeeded(func() (bool, error) {
+		return false, errBroken
+	}))
+	if !errors.Is(err, errBroken) {
+		t.Fatalf("expected the check's error, got: %v", err)
+	}
+
+	file, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("lock file was not released: %v", err)
+	}
+	file.Close()
+}
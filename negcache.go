@@ -0,0 +1,108 @@
+package lockfile
+
+import (
+	"sync"
+	"time"
+)
+
+// NegativeCache remembers recent contention for lock files, so that
+// repeated calls to [Create] for the same path within a short period of
+// time don't hit the filesystem each time.
+//
+// This is useful for callers that poll the same lock file frequently, such
+// as user interfaces that display whether a lock is available. Entries
+// expire after the cache's time-to-live, and can be invalidated early by
+// calling [NegativeCache.Invalidate] when an event indicates that the lock
+// file may have been released. Entries are invalidated automatically when
+// a lock file that was acquired with the cache is released, and when a
+// waiter is woken by a change to the lock file.
+//
+// A NegativeCache is installed by [WithNegativeCache]. It is safe for
+// concurrent use and may be shared by many callers.
+type NegativeCache struct {
+	ttl     time.Duration
+	mutex   sync.Mutex
+	entries map[string]time.Time // Maps paths to expiration times
+}
+
+// negativeCacheSweepSize is the number of entries at which expired entries
+// are swept from a negative cache.
+const negativeCacheSweepSize = 1024
+
+// NewNegativeCache returns a [NegativeCache] that remembers contention for
+// the given time-to-live.
+func NewNegativeCache(ttl time.Duration) *NegativeCache {
+	return &NegativeCache{
+		ttl:     ttl,
+		entries: make(map[string]time.Time),
+	}
+}
+
+// WithNegativeCache returns an option that consults the given cache before
+// attempting to create a lock file, and records contention in it.
+//
+// While a path is cached, [Create] returns an [*os.PathError] that wraps
+// [os.ErrExist] without touching the filesystem.
+func WithNegativeCache(cache *NegativeCache) Option {
+	return func(c *config) {
+		c.negativeCache = cache
+	}
+}
+
+// Invalidate removes any cached contention for path.
+func (c *NegativeCache) Invalidate(path string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.entries, path)
+}
+
+// Clear removes all cached contention.
+func (c *NegativeCache) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	clear(c.entries)
+}
+
+// forgetContention removes any contention for the lock file at path from
+// the negative cache of the configuration, if it has one.
+func (c *config) forgetContention(path string) {
+	if c.negativeCache == nil {
+		return
+	}
+	if mapped, err := c.mapPath(path); err == nil {
+		c.negativeCache.Invalidate(mapped)
+	}
+}
+
+// contended returns true if contention for path has been recorded and has
+// not yet expired.
+func (c *NegativeCache) contended(path string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	expiration, found := c.entries[path]
+	if !found {
+		return false
+	}
+	if time.Now().After(expiration) {
+		delete(c.entries, path)
+		return false
+	}
+	return true
+}
+
+// record remembers contention for path.
+func (c *NegativeCache) record(path string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= negativeCacheSweepSize {
+		for key, expiration := range c.entries {
+			if now.After(expiration) {
+				delete(c.entries, key)
+			}
+		}
+	}
+	c.entries[path] = now.Add(c.ttl)
+}
@@ -0,0 +1,109 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestNegativeCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.lock")
+	cache := lockfile.NewNegativeCache(time.Hour)
+
+	held, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create lock file: %v", err)
+	}
+
+	if _, err := lockfile.Create(path, lockfile.WithNegativeCache(cache)); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected contention, got: %v", err)
+	}
+
+	if err := held.Close(); err != nil {
+		t.Fatalf("failed to close lock file: %v", err)
+	}
+
+	// The cached contention should still be reported.
+	if _, err := lockfile.Create(path, lockfile.WithNegativeCache(cache)); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected cached contention, got: %v", err)
+	}
+
+	// After invalidation the lock file should be acquired.
+	cache.Invalidate(path)
+	lock, err := lockfile.Create(path, lockfile.WithNegativeCache(cache))
+	if err != nil {
+		t.Fatalf("failed to create lock file after invalidation: %v", err)
+	}
+	lock.Close()
+}
+
+func TestNegativeCacheRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.lock")
+	cache := lockfile.NewNegativeCache(time.Hour)
+
+	held, err := lockfile.Create(path, lockfile.WithNegativeCache(cache))
+	if err != nil {
+		t.Fatalf("failed to create lock file: %v", err)
+	}
+	if _, err := lockfile.Create(path, lockfile.WithNegativeCache(cache)); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected contention, got: %v", err)
+	}
+
+	// Releasing a lock file that was acquired with the cache invalidates it.
+	if err := held.Close(); err != nil {
+		t.Fatalf("failed to close lock file: %v", err)
+	}
+	lock, err := lockfile.Create(path, lockfile.WithNegativeCache(cache))
+	if err != nil {
+		t.Fatalf("failed to create lock file after its release: %v", err)
+	}
+	lock.Close()
+}
+
+func TestNegativeCacheClassifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.lock")
+	cache := lockfile.NewNegativeCache(time.Hour)
+	busy := errors.New("busy")
+	classifier := lockfile.WithClassifier(lockfile.NewClassifier(0, busy))
+	hooks := lockfile.WithHooks(lockfile.Hooks{
+		BeforeOp: func(op lockfile.Op, path string) error {
+			return busy
+		},
+	})
+
+	// An error that the classifier treats as temporary is cached, and is
+	// reported as contention both times.
+	_, err := lockfile.Create(path, lockfile.WithNegativeCache(cache), classifier, hooks)
+	if ce := (*lockfile.ContentionError)(nil); !errors.As(err, &ce) || !errors.Is(err, busy) {
+		t.Fatalf("expected a ContentionError that wraps the classified error, got: %v", err)
+	}
+	_, err = lockfile.Create(path, lockfile.WithNegativeCache(cache), classifier)
+	if ce := (*lockfile.ContentionError)(nil); !errors.As(err, &ce) {
+		t.Fatalf("expected cached contention, got: %v", err)
+	}
+}
+
+func TestNegativeCacheWait(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.lock")
+	cache := lockfile.NewNegativeCache(time.Hour)
+
+	held, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create lock file: %v", err)
+	}
+	time.AfterFunc(100*time.Millisecond, func() { held.Close() })
+
+	// The waiter is woken by the release, which invalidates the cached
+	// contention long before it expires.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	lock, err := lockfile.WaitCtx(ctx, path, lockfile.WithNegativeCache(cache))
+	if err != nil {
+		t.Fatalf("failed to wait for lock file: %v", err)
+	}
+	lock.Close()
+}
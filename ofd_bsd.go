@@ -0,0 +1,12 @@
+//go:build darwin || dragonfly || freebsd || openbsd
+
+package lockfile
+
+// ofdSupported is true if [WithOFDLocks] is supported on this platform.
+const ofdSupported = false
+
+// newOFDSystem returns next, because open file description locks are not
+// supported.
+func newOFDSystem(next system) system {
+	return next
+}
@@ -0,0 +1,36 @@
+//go:build linux
+
+package lockfile
+
+import (
+	"io"
+	"syscall"
+)
+
+// ofdSupported is true if [WithOFDLocks] is supported on this platform.
+const ofdSupported = true
+
+// ofdSystem performs operations with open file description locks in place
+// of flock, on behalf of configurations with [WithOFDLocks].
+type ofdSystem struct {
+	system
+}
+
+// newOFDSystem returns a system that uses open file description locks,
+// and performs all other operations with next.
+func newOFDSystem(next system) system {
+	return ofdSystem{system: next}
+}
+
+// flock locks or unlocks the whole file that is open as fd with an open
+// file description lock. Contention is reported as [syscall.EWOULDBLOCK],
+// just as it is by flock. Locks are always attempted without blocking.
+func (ofdSystem) flock(path string, fd int, how int) error {
+	lk := syscall.Flock_t{Type: flockType(how), Whence: io.SeekStart}
+	switch err := fcntl(fd, fOFDSetLk, &lk); err {
+	case syscall.EAGAIN, syscall.EACCES:
+		return syscall.EWOULDBLOCK
+	default:
+		return err
+	}
+}
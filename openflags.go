@@ -0,0 +1,30 @@
+package lockfile
+
+import "fmt"
+
+// WithOpenFlags returns an option that adds extra platform-specific flags
+// when the lock file is opened.
+//
+// This is an escape hatch for users with unusual filesystem requirements.
+// Only flags that are known to be safe are accepted:
+//
+//   - On Linux: O_SYNC, O_DSYNC, O_NOATIME and O_NOFOLLOW.
+//   - On macOS and the BSDs: O_SYNC and O_NOFOLLOW.
+//   - On Windows: FILE_FLAG_WRITE_THROUGH, FILE_ATTRIBUTE_HIDDEN and
+//     FILE_ATTRIBUTE_NOT_CONTENT_INDEXED.
+//
+// If any other flags are provided, lock file creation fails with an error.
+func WithOpenFlags(flags uint32) Option {
+	return func(c *config) {
+		c.openFlags |= flags
+	}
+}
+
+// validateOpenFlags returns an error if flags contains anything that is not
+// in the platform's allowlist.
+func validateOpenFlags(flags uint32) error {
+	if unsupported := flags &^ allowedOpenFlags; unsupported != 0 {
+		return fmt.Errorf("%w: unsupported open flags: %#x", ErrInvalidOption, unsupported)
+	}
+	return nil
+}
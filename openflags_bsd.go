@@ -0,0 +1,9 @@
+//go:build darwin || dragonfly || freebsd || openbsd
+
+package lockfile
+
+import "syscall"
+
+// allowedOpenFlags are the extra open flags that may be provided by
+// [WithOpenFlags].
+const allowedOpenFlags = syscall.O_SYNC | syscall.O_NOFOLLOW
@@ -0,0 +1,9 @@
+//go:build linux
+
+package lockfile
+
+import "syscall"
+
+// allowedOpenFlags are the extra open flags that may be provided by
+// [WithOpenFlags].
+const allowedOpenFlags = syscall.O_SYNC | syscall.O_DSYNC | syscall.O_NOATIME | syscall.O_NOFOLLOW
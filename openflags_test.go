@@ -0,0 +1,24 @@
+package lockfile_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestOpenFlagsRejected(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flags.lock")
+
+	// Bit 30 is not in the allowlist of any platform.
+	lock, err := lockfile.Create(path, lockfile.WithOpenFlags(0x40000000))
+	if err == nil {
+		lock.Close()
+		t.Fatalf("Create accepted unsupported open flags")
+	}
+
+	if _, err := lockfile.New(path, lockfile.WithOpenFlags(0x40000000)); !errors.Is(err, lockfile.ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption, got: %v", err)
+	}
+}
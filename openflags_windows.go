@@ -0,0 +1,9 @@
+//go:build windows
+
+package lockfile
+
+// allowedOpenFlags are the extra flags and attributes that may be provided
+// by [WithOpenFlags].
+const allowedOpenFlags = 0x80000000 | // FILE_FLAG_WRITE_THROUGH
+	0x00000002 | // FILE_ATTRIBUTE_HIDDEN
+	0x00002000 // FILE_ATTRIBUTE_NOT_CONTENT_INDEXED
@@ -0,0 +1,162 @@
+package lockfile
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Option configures the behavior of lock file operations.
+type Option func(*config)
+
+// config holds the configuration assembled from a set of options.
+type config struct {
+	hooks     Hooks
+	opTimeout time.Duration
+	pool      *WorkerPool
+
+	negativeCache *NegativeCache
+
+	openFlags uint32
+
+	soft           bool
+	softStaleAfter time.Duration
+
+	noFilesystemDetection bool
+	softFallback          bool
+	strictFilesystem      bool
+	fallbackDir           string
+
+	mapper *Mapper
+
+	minFreeBytes  uint64
+	minFreeInodes uint64
+	retryNoSpace  bool
+
+	stillNeeded func() (bool, error)
+
+	classifier *Classifier
+
+	dryRun *Plan
+	force  bool
+
+	waitTicket     bool
+	ticketPriority int
+
+	siblingPolicy SiblingPolicy
+
+	shared bool
+
+	dirQuota int
+
+	freezePolicy FreezePolicy
+
+	heatmap *Heatmap
+
+	schedule Schedule
+
+	precondition func(ctx context.Context) error
+
+	metadata      bool
+	metadataCodec Codec
+	scrub         bool
+
+	generations *atomic.Uint64 // Records the latest generation of a Lock
+
+	leaseTTL time.Duration
+
+	fencing bool
+
+	linkMonitor time.Duration
+	recreate    bool
+
+	idleThreshold time.Duration
+
+	maxAttempts    int
+	waitBudget     time.Duration
+	attemptTimeout time.Duration
+	herdThreshold  int
+
+	posix     bool
+	bothLocks bool
+	ofd       bool
+
+	traceID string
+
+	backend Backend
+
+	sys system
+	err error // The result of validation
+}
+
+// defaultConfig is used when no options are provided. It must not be
+// modified.
+var defaultConfig = &config{sys: defaultSystem}
+
+// newConfig returns the configuration described by opts.
+func newConfig(opts []Option) *config {
+	if len(opts) == 0 {
+		return defaultConfig
+	}
+
+	cfg := &config{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(cfg)
+		}
+	}
+
+	// Only route operations through hooks, timeouts and worker pools when
+	// they are needed, so that the common path does not allocate.
+	cfg.sys = defaultSystem
+	if cfg.posix {
+		cfg.sys = newPOSIXSystem(cfg.sys, cfg.bothLocks)
+	} else if cfg.ofd {
+		cfg.sys = newOFDSystem(cfg.sys)
+	}
+	if cfg.hooks.BeforeOp != nil || cfg.hooks.AfterOp != nil || cfg.opTimeout > 0 || cfg.pool != nil {
+		cfg.sys = &hookedSystem{c: cfg, next: cfg.sys}
+	}
+
+	cfg.err = cfg.validate()
+
+	return cfg
+}
+
+// validate returns an error if the configuration is invalid.
+func (c *config) validate() error {
+	if c.opTimeout < 0 {
+		return fmt.Errorf("%w: the operation timeout must not be negative", ErrInvalidOption)
+	}
+	if c.fcntlLocks() && !posixSupported {
+		return fmt.Errorf("%w: fcntl locks are not supported on this platform", ErrInvalidOption)
+	}
+	if c.ofd && !ofdSupported {
+		return fmt.Errorf("%w: open file description locks are not supported on this platform", ErrInvalidOption)
+	}
+	if c.fcntlLocks() && c.soft {
+		return fmt.Errorf("%w: fcntl locks cannot be used with soft lock files", ErrInvalidOption)
+	}
+	if c.softFallback && !c.soft && c.softStaleAfter <= 0 {
+		return fmt.Errorf("%w: the soft lock fallback requires a positive stale timeout", ErrInvalidOption)
+	}
+	if c.softFallback && c.strictFilesystem {
+		return fmt.Errorf("%w: a soft lock fallback cannot be combined with a strict filesystem", ErrInvalidOption)
+	}
+	if c.posix && c.ofd {
+		return fmt.Errorf("%w: POSIX and OFD locks cannot be combined", ErrInvalidOption)
+	}
+	if err := c.validateBackend(); err != nil {
+		return err
+	}
+	if !validTraceID(c.traceID) {
+		return fmt.Errorf("%w: the trace ID %q is invalid", ErrInvalidOption, c.traceID)
+	}
+	return validateOpenFlags(c.openFlags)
+}
+
+// system returns the system that operations should be performed with.
+func (c *config) system() system {
+	return c.sys
+}
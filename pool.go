@@ -0,0 +1,55 @@
+package lockfile
+
+import "fmt"
+
+// WorkerPool bounds the number of operating system operations on lock
+// files that can be in progress at the same time.
+//
+// Each goroutine that is blocked in the kernel consumes an operating system
+// thread. When thousands of waiters contend for lock files on a stalled
+// filesystem, this can exhaust the threads available to the process. A
+// WorkerPool that is shared by those waiters limits the number of threads
+// that can be consumed this way. Operations that cannot be started
+// immediately wait for a free slot without consuming a thread.
+//
+// A WorkerPool is installed by [WithWorkerPool]. It is safe for concurrent
+// use.
+type WorkerPool struct {
+	slots chan struct{}
+}
+
+// NewWorkerPool returns a [WorkerPool] that allows up to size operations
+// to run at the same time. It returns an error if size is less than 1.
+func NewWorkerPool(size int) (*WorkerPool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("%w: worker pool size must be at least 1", ErrInvalidOption)
+	}
+	return &WorkerPool{slots: make(chan struct{}, size)}, nil
+}
+
+// Size returns the maximum number of operations that can run at the same
+// time.
+func (p *WorkerPool) Size() int {
+	return cap(p.slots)
+}
+
+// Busy returns the number of operations that are currently running.
+func (p *WorkerPool) Busy() int {
+	return len(p.slots)
+}
+
+// release frees a slot that was acquired by sending on p.slots.
+func (p *WorkerPool) release() {
+	<-p.slots
+}
+
+// WithWorkerPool returns an option that runs operating system operations
+// on lock files within the given pool.
+//
+// When combined with [WithOpTimeout], time spent waiting for a free slot
+// counts toward the timeout.
+func WithWorkerPool(pool *WorkerPool) Option {
+	return func(c *config) {
+		c.pool = pool
+	}
+}
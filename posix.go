@@ -0,0 +1,95 @@
+package lockfile
+
+// WithPOSIXLocks returns an option that locks lock files with POSIX record
+// locks, acquired with the fcntl system call, instead of flock. On Linux,
+// the two kinds of lock do not interact, so this option is needed to
+// exclude other software that locks the same files with fcntl.
+//
+// POSIX record locks are DANGEROUS. They belong to the process rather than
+// to the file descriptor that acquired them, which has two surprising
+// consequences:
+//
+//   - Closing ANY descriptor for a locked file releases every lock that
+//     the process holds on it, even if the descriptor was opened
+//     elsewhere for an unrelated purpose, such as by a library that
+//     merely reads the file.
+//   - A process never conflicts with itself, so a second lock of the same
+//     file within a process always succeeds.
+//
+// Lock files acquired with this option are guarded against both: the
+// package keeps a registry of the files that the process has locked, which
+// excludes other holders within the process, and descriptors that it opens
+// for a locked file are kept open until the lock is released, rather than
+// being closed. Within a process, shared lock files are therefore held
+// exclusively. These safeguards only cover descriptors opened by this
+// package with this option, so every operation on such a lock file within
+// the process must be configured with it, and nothing else in the process
+// may open the lock file at all.
+//
+// POSIX write locks can only be acquired through a descriptor that is
+// open for writing, so lock files are always opened for writing, and a
+// lock file left behind read-only cannot be acquired.
+//
+// POSIX record locks are not inherited by child processes, so lock files
+// acquired with this option cannot be shared with [File.Share]. Soft lock
+// files are never used, even on filesystems that are unreliable for flock,
+// and configuring [WithSoftLock] with this option is an error.
+//
+// This option is not supported on Windows, where lock file creation fails
+// with an error that wraps [ErrInvalidOption].
+func WithPOSIXLocks() Option {
+	return func(c *config) {
+		c.posix = true
+	}
+}
+
+// WithBothLocks returns an option that locks lock files with both flock
+// and a POSIX record lock, so that they exclude other software that uses
+// either kind of lock. It carries all of the hazards and safeguards of
+// [WithPOSIXLocks], which it implies.
+//
+// The flock lock is acquired first, and the POSIX lock second. If the
+// POSIX lock cannot be acquired, the flock lock is released again before
+// the lock file is closed, and the lock file is reported as held if
+// either lock was contended. Both locks are held while the lock file is
+// deleted on release, and are released together when it is closed
+// afterward, so that neither kind of holder can acquire a lock file that
+// is about to be deleted.
+func WithBothLocks() Option {
+	return func(c *config) {
+		c.posix = true
+		c.bothLocks = true
+	}
+}
+
+// WithOFDLocks returns an option that locks lock files with open file
+// description locks, acquired with the fcntl system call, instead of
+// flock. It is only supported on Linux.
+//
+// Open file description locks cover the whole file like flock, and belong
+// to the open file like flock, so they have none of the hazards of
+// [WithPOSIXLocks]. Unlike flock, they are propagated to the server on NFS
+// mounts and other filesystems that support byte-range locks, which makes
+// them suitable where flock locks are not visible to other hosts. They
+// conflict with POSIX record locks held by other processes, but not with
+// flock.
+//
+// Write locks can only be acquired through a descriptor that is open for
+// writing, so lock files are always opened for writing. Lock files
+// acquired with this option cannot be shared with [File.Share]. Soft lock
+// files are never used, and configuring [WithSoftLock] or
+// [WithPOSIXLocks] with this option is an error.
+//
+// On other platforms, lock file creation fails with an error that wraps
+// [ErrInvalidOption].
+func WithOFDLocks() Option {
+	return func(c *config) {
+		c.ofd = true
+	}
+}
+
+// fcntlLocks returns true if lock files are locked with fcntl, whether or
+// not they are also locked with flock.
+func (c *config) fcntlLocks() bool {
+	return c.posix || c.ofd
+}
@@ -0,0 +1,197 @@
+//go:build linux
+
+package lockfile_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+// Open file description locks always conflict with POSIX record locks,
+// even within the same process, so they stand in for other software.
+const (
+	fOFDGetLk = 36
+	fOFDSetLk = 37
+)
+
+// ofdLock performs an open file description lock command on file.
+func ofdLock(t *testing.T, file *os.File, cmd int, typ int16) syscall.Flock_t {
+	t.Helper()
+	lk := syscall.Flock_t{Type: typ, Whence: io.SeekStart}
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, file.Fd(), uintptr(cmd), uintptr(unsafe.Pointer(&lk))); errno != 0 {
+		t.Fatalf("fcntl failed: %v", errno)
+	}
+	return lk
+}
+
+func TestPOSIXLocksExcludeOthers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posix.lock")
+	other, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	ofdLock(t, other, fOFDSetLk, syscall.F_WRLCK)
+
+	if _, err := lockfile.Create(path, lockfile.WithPOSIXLocks()); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected ErrExist while another lock is held, got: %v", err)
+	}
+
+	ofdLock(t, other, fOFDSetLk, syscall.F_UNLCK)
+	file, err := lockfile.Create(path, lockfile.WithPOSIXLocks())
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if lk := ofdLock(t, other, fOFDGetLk, syscall.F_WRLCK); lk.Type == syscall.F_UNLCK {
+		t.Fatal("the POSIX lock is not visible to others")
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the lock file was not removed: %v", err)
+	}
+}
+
+func TestPOSIXLocksWithinProcess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posix.lock")
+	file, err := lockfile.Create(path, lockfile.WithPOSIXLocks())
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer file.Close()
+
+	// The kernel would grant a second lock to the same process.
+	for _, create := range []func(string, ...lockfile.Option) (*lockfile.File, error){
+		lockfile.Create,
+		lockfile.CreateShared,
+	} {
+		if _, err := create(path, lockfile.WithPOSIXLocks()); !errors.Is(err, os.ErrExist) {
+			t.Fatalf("expected ErrExist for a second holder in the process, got: %v", err)
+		}
+	}
+
+	// Inspecting the lock file opens and closes a descriptor for it, which
+	// must not release the lock.
+	info, err := lockfile.Inspect(path, lockfile.WithPOSIXLocks())
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if !info.Held {
+		t.Fatal("Inspect reported that the lock file is not held")
+	}
+
+	other, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if lk := ofdLock(t, other, fOFDGetLk, syscall.F_WRLCK); lk.Type == syscall.F_UNLCK {
+		t.Fatal("the POSIX lock was released by another descriptor")
+	}
+}
+
+func TestPOSIXLocksWithSoftLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posix.lock")
+	if _, err := lockfile.Create(path, lockfile.WithPOSIXLocks(), lockfile.WithSoftLock(0)); !errors.Is(err, lockfile.ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption, got: %v", err)
+	}
+}
+
+func TestBothLocks(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		lock func(t *testing.T, file *os.File)
+	}{
+		{"flock", func(t *testing.T, file *os.File) {
+			if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"fcntl", func(t *testing.T, file *os.File) {
+			ofdLock(t, file, fOFDSetLk, syscall.F_WRLCK)
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "both.lock")
+			other, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.lock(t, other)
+
+			if _, err := lockfile.Create(path, lockfile.WithBothLocks()); !errors.Is(err, os.ErrExist) {
+				t.Fatalf("expected ErrExist while a %s lock is held, got: %v", tc.name, err)
+			}
+			other.Close()
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "both.lock")
+	file, err := lockfile.Create(path, lockfile.WithBothLocks())
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer file.Close()
+
+	other, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if err := syscall.Flock(int(other.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != syscall.EWOULDBLOCK {
+		t.Fatalf("expected the flock lock to be held, got: %v", err)
+	}
+	if lk := ofdLock(t, other, fOFDGetLk, syscall.F_WRLCK); lk.Type == syscall.F_UNLCK {
+		t.Fatal("expected the POSIX lock to be held")
+	}
+}
+
+func TestOFDLocks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ofd.lock")
+	file, err := lockfile.Create(path, lockfile.WithOFDLocks())
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// Open file description locks conflict within the process, and are
+	// not released when another descriptor is closed.
+	if _, err := lockfile.Create(path, lockfile.WithOFDLocks()); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected ErrExist for a second holder, got: %v", err)
+	}
+	other, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Close()
+
+	other, err = os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if lk := ofdLock(t, other, fOFDGetLk, syscall.F_WRLCK); lk.Type == syscall.F_UNLCK {
+		t.Fatal("the OFD lock is not held")
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if lk := ofdLock(t, other, fOFDGetLk, syscall.F_WRLCK); lk.Type != syscall.F_UNLCK {
+		t.Fatal("the OFD lock was not released")
+	}
+}
+
+func TestOFDLocksWithPOSIXLocks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ofd.lock")
+	if _, err := lockfile.Create(path, lockfile.WithOFDLocks(), lockfile.WithPOSIXLocks()); !errors.Is(err, lockfile.ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption, got: %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile
+
+import (
+	"io"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// posixSupported is true if [WithPOSIXLocks] is supported on this
+// platform.
+const posixSupported = true
+
+// posixFileID identifies a locked file by its device and inode.
+type posixFileID struct {
+	dev uint64
+	ino uint64
+}
+
+// posixHold records a file that the process holds a POSIX lock on.
+type posixHold struct {
+	fd     int            // The descriptor that acquired the lock
+	how    int            // The flock operation that acquired the lock
+	parked []func() error // Closes deferred until the lock is released
+}
+
+// posixHolds is the registry of files that the process holds POSIX locks
+// on. It is shared by every configuration, because the locks themselves
+// are shared by the whole process.
+var posixHolds struct {
+	mutex sync.Mutex
+	files map[posixFileID]*posixHold
+}
+
+// posixSystem performs operations with POSIX record locks in place of
+// flock, on behalf of configurations with [WithPOSIXLocks], or in addition
+// to flock, on behalf of configurations with [WithBothLocks].
+//
+// Its flock operation translates flock semantics into fcntl calls, and
+// excludes other holders within the process, which POSIX locks do not.
+// Its close operations keep descriptors for a locked file open until the
+// lock is released, because closing them would release it.
+type posixSystem struct {
+	system
+	both bool // Whether flock is called as well
+}
+
+// newPOSIXSystem returns a system that uses POSIX record locks, and
+// performs all other operations with next. If both is true, flock locks
+// are acquired with next before each POSIX lock.
+func newPOSIXSystem(next system, both bool) system {
+	return posixSystem{system: next, both: both}
+}
+
+// flock locks or unlocks the whole file that is open as fd with a POSIX
+// record lock. Contention is reported as [syscall.EWOULDBLOCK], just as it
+// is by flock.
+//
+// When both kinds of lock are used, the flock lock is acquired first. If
+// the POSIX lock then fails, the flock lock is restored to what it was
+// before, so that a partial failure never leaves the file locked by only
+// one of them.
+//
+// Locks are always attempted without blocking, because a blocked attempt
+// would have to hold the registry while it waits. Callers only ever ask
+// for non-blocking locks.
+func (s posixSystem) flock(path string, fd int, how int) error {
+	id, err := posixID(fd)
+	if err != nil {
+		return err
+	}
+
+	lk := syscall.Flock_t{Type: flockType(how), Whence: io.SeekStart}
+
+	posixHolds.mutex.Lock()
+	defer posixHolds.mutex.Unlock()
+
+	// The kernel never reports a conflict between locks held by the same
+	// process, so other holders within the process are excluded here.
+	hold := posixHolds.files[id]
+	if hold != nil && hold.fd != fd {
+		return syscall.EWOULDBLOCK
+	}
+
+	if s.both {
+		if err := s.system.flock(path, fd, how|syscall.LOCK_NB); err != nil {
+			return err
+		}
+	}
+
+	err = fcntl(fd, syscall.F_SETLK, &lk)
+	if err != nil && s.both {
+		restore := syscall.LOCK_UN
+		if hold != nil {
+			restore = hold.how | syscall.LOCK_NB
+		}
+		s.system.flock(path, fd, restore)
+	}
+	switch err {
+	case nil:
+	case syscall.EAGAIN, syscall.EACCES:
+		return syscall.EWOULDBLOCK
+	default:
+		return err
+	}
+
+	switch {
+	case lk.Type == syscall.F_UNLCK:
+	case hold != nil:
+		hold.how = how &^ syscall.LOCK_NB
+	default:
+		if posixHolds.files == nil {
+			posixHolds.files = make(map[posixFileID]*posixHold)
+		}
+		posixHolds.files[id] = &posixHold{fd: fd, how: how &^ syscall.LOCK_NB}
+	}
+	return nil
+}
+
+func (s posixSystem) closeFd(path string, fd int) error {
+	return s.close(fd, func() error {
+		return s.system.closeFd(path, fd)
+	})
+}
+
+func (s posixSystem) closeFile(path string, file *os.File) error {
+	return s.close(int(file.Fd()), func() error {
+		return s.system.closeFile(path, file)
+	})
+}
+
+// close closes the descriptor fd by calling closeFn, unless it refers to
+// a file that the process holds a POSIX lock on through another
+// descriptor. Closing it would release that lock, so it is parked instead,
+// and closed once the lock is released.
+//
+// Closing the descriptor that holds the lock releases it, so the parked
+// descriptors are closed along with it.
+func (s posixSystem) close(fd int, closeFn func() error) error {
+	id, err := posixID(fd)
+	if err != nil {
+		return closeFn()
+	}
+
+	posixHolds.mutex.Lock()
+	defer posixHolds.mutex.Unlock()
+
+	hold := posixHolds.files[id]
+	switch {
+	case hold == nil:
+		return closeFn()
+	case hold.fd != fd:
+		hold.parked = append(hold.parked, closeFn)
+		return nil
+	}
+
+	// The registry entry is only removed once every descriptor has been
+	// closed, so that a new holder cannot acquire a lock that one of them
+	// would release.
+	err = closeFn()
+	for _, parked := range hold.parked {
+		parked()
+	}
+	delete(posixHolds.files, id)
+	return err
+}
+
+// posixID returns the identity of the file that is open as fd.
+func posixID(fd int) (posixFileID, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Fstat(fd, &stat); err != nil {
+		return posixFileID{}, err
+	}
+	return posixFileID{dev: uint64(stat.Dev), ino: stat.Ino}, nil
+}
+
+// flockType returns the type of fcntl lock that corresponds to the flock
+// operation how.
+func flockType(how int) int16 {
+	switch how &^ syscall.LOCK_NB {
+	case syscall.LOCK_EX:
+		return syscall.F_WRLCK
+	case syscall.LOCK_SH:
+		return syscall.F_RDLCK
+	}
+	return syscall.F_UNLCK
+}
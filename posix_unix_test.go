@@ -0,0 +1,69 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+// envPOSIXChild is set when the test binary is run as a child process by
+// TestPOSIXLocksWhileWaiting, to the path of the lock file to check.
+const envPOSIXChild = "LOCKFILE_TEST_POSIX_CHILD"
+
+func TestPOSIXLocksWhileWaiting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posix.lock")
+	file, err := lockfile.Create(path, lockfile.WithPOSIXLocks())
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer file.Close()
+
+	// A waiter within the same process must not release the lock with the
+	// descriptors that it opens and closes while it waits.
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if _, err := lockfile.WaitCtx(ctx, path, lockfile.WithPOSIXLocks()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the waiter to time out, got: %v", err)
+	}
+
+	// POSIX locks never conflict within a process, so the lock can only be
+	// seen from another one.
+	cmd := exec.Command(os.Args[0], "-test.run=^TestPOSIXLocksChild$", "-test.v")
+	cmd.Env = append(os.Environ(), envPOSIXChild+"="+path)
+	out, err := cmd.CombinedOutput()
+	if err != nil || !bytes.Contains(out, []byte("--- PASS: TestPOSIXLocksChild")) {
+		t.Fatalf("the POSIX lock was released while it was held: %v\n%s", err, out)
+	}
+}
+
+func TestPOSIXLocksChild(t *testing.T) {
+	path := os.Getenv(envPOSIXChild)
+	if path == "" {
+		t.Skip("only run as a child process by TestPOSIXLocksWhileWaiting")
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	lk := syscall.Flock_t{Type: syscall.F_WRLCK, Whence: io.SeekStart}
+	if err := syscall.FcntlFlock(file.Fd(), syscall.F_GETLK, &lk); err != nil {
+		t.Fatalf("fcntl failed: %v", err)
+	}
+	if lk.Type == syscall.F_UNLCK {
+		t.Fatal("the POSIX lock is not held")
+	}
+}
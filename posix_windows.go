@@ -0,0 +1,21 @@
+//go:build windows
+
+package lockfile
+
+// posixSupported is true if [WithPOSIXLocks] is supported on this
+// platform.
+const posixSupported = false
+
+// ofdSupported is true if [WithOFDLocks] is supported on this platform.
+const ofdSupported = false
+
+// newPOSIXSystem returns next, because POSIX locks are not supported.
+func newPOSIXSystem(next system, both bool) system {
+	return next
+}
+
+// newOFDSystem returns next, because open file description locks are not
+// supported.
+func newOFDSystem(next system) system {
+	return next
+}
@@ -0,0 +1,35 @@
+package lockfile
+
+import (
+	"context"
+	"fmt"
+)
+
+// WithPrecondition returns an option that calls check immediately before
+// each attempt to acquire a lock file, and again once it has been
+// acquired.
+//
+// This gates acquisition on the health of the resource that the lock file
+// protects, such as a device or a database, so that a process does not
+// take the lock and then sit on it while the resource is unreachable. The
+// second check catches a resource that failed while the lock file was
+// being acquired, in which case the lock file is released again.
+//
+// If check returns an error, the attempt fails with an error that wraps
+// both [ErrPreconditionFailed] and the error from check, and waiting
+// stops. Callers that want to wait for the resource to recover should use
+// [WaitUntil] instead. The context passed to check is the one given to
+// [WaitCtx], or a background context for [Create].
+func WithPrecondition(check func(ctx context.Context) error) Option {
+	return func(c *config) {
+		c.precondition = check
+	}
+}
+
+// checkPrecondition runs the check provided by [WithPrecondition].
+func (c *config) checkPrecondition(ctx context.Context) error {
+	if err := c.precondition(ctx); err != nil {
+		return fmt.Errorf("%w: %w", ErrPreconditionFailed, err)
+	}
+	return nil
+}
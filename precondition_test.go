@@ -0,0 +1,67 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+var errUnreachable = errors.New("the device is unreachable")
+
+func TestPrecondition(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "device.lock")
+
+	calls := 0
+	healthy := lockfile.WithPrecondition(func(ctx context.Context) error {
+		calls++
+		return nil
+	})
+	file, err := lockfile.Create(path, healthy)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	file.Close()
+	if calls != 2 {
+		t.Fatalf("the precondition was checked %d times, want 2", calls)
+	}
+
+	unhealthy := lockfile.WithPrecondition(func(ctx context.Context) error {
+		return errUnreachable
+	})
+	_, err = lockfile.WaitCtx(context.Background(), path, unhealthy)
+	if !errors.Is(err, lockfile.ErrPreconditionFailed) || !errors.Is(err, errUnreachable) {
+		t.Fatalf("WaitCtx returned %v with a failing precondition", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("the lock file was created despite the failing precondition: %v", err)
+	}
+}
+
+func TestPreconditionAfterAcquisition(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "device.lock")
+
+	// The resource fails while the lock file is being acquired.
+	type key struct{}
+	calls := 0
+	flaky := lockfile.WithPrecondition(func(ctx context.Context) error {
+		if ctx.Value(key{}) != "waiter" {
+			t.Errorf("the precondition was not given the waiter's context")
+		}
+		if calls++; calls > 1 {
+			return errUnreachable
+		}
+		return nil
+	})
+
+	ctx := context.WithValue(context.Background(), key{}, "waiter")
+	if _, err := lockfile.WaitCtx(ctx, path, flaky); !errors.Is(err, errUnreachable) {
+		t.Fatalf("WaitCtx returned %v when the precondition failed after acquisition", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("the lock file was not released: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package lockfile
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Presets bundle options that are appropriate for common scenarios, so
+// that most callers can get safe behavior without understanding every
+// option. A preset is an [Option] like any other. Options that follow a
+// preset take precedence over the options that it bundles, so a preset
+// can be adjusted by following it with the options that should differ.
+
+// PresetLocalFast returns an option bundle for lock files on a local
+// filesystem that is known to support them, where acquisition should be
+// as cheap as possible.
+//
+// The filesystem that holds each lock file is not examined, as described
+// by [WithFilesystemDetection], and no metadata is written.
+func PresetLocalFast() Option {
+	return bundle(
+		WithFilesystemDetection(false),
+	)
+}
+
+// PresetNFSSafe returns an option bundle for lock files on NFS and other
+// network filesystems, whose operations may hang, and whose locks may be
+// compromised by other hosts.
+//
+// On Linux, lock files are locked with open file description locks, as
+// described by [WithOFDLocks], because they are propagated to the server
+// where flock locks may not be. On macOS and the BSDs, which lack them,
+// POSIX record locks are used instead, as described by [WithPOSIXLocks].
+// Metadata is written, so that holders on other hosts can be identified.
+// Each operation is limited to 10 seconds, and each attempt while waiting
+// to 30 seconds, so that a stalled mount does not freeze the caller. Held
+// lock files are checked every 5 seconds for tampering, as described by
+// [WithLinkMonitor]. Warnings are logged to the default [slog.Logger].
+func PresetNFSSafe() Option {
+	return bundle(
+		networkLocks(),
+		WithMetadata(nil),
+		WithOpTimeout(10*time.Second),
+		WithAttemptTimeout(30*time.Second),
+		WithLinkMonitor(5*time.Second),
+		logWarnings(),
+	)
+}
+
+// PresetSingleInstanceApp returns an option bundle for an application that
+// holds a lock file for as long as it runs, to ensure that only one
+// instance of it runs at a time.
+//
+// Metadata is written, so that a second instance can report which process
+// is already running. Waiting gives up after the first attempt finds the
+// lock file held, with an error that wraps [ErrNeverFree]. The lock file
+// is checked every 10 seconds and re-created if something, such as a
+// temporary file cleaner, removes it, as described by
+// [WithRecreateOnDelete].
+func PresetSingleInstanceApp() Option {
+	return bundle(
+		WithMetadata(nil),
+		WithMaxAttempts(1),
+		WithLinkMonitor(10*time.Second),
+		WithRecreateOnDelete(),
+	)
+}
+
+// PresetBatchJob returns an option bundle for batch jobs that are willing
+// to wait a long time for a lock file held by another job.
+//
+// Metadata is written, and a wait ticket is published while waiting, as
+// described by [WithWaitTicket], so that operators can see who holds the
+// lock file and who is waiting for it. Waiting continues while the
+// filesystem is full, as described by [WithRetryNoSpace]. Warnings are
+// logged to the default [slog.Logger].
+func PresetBatchJob() Option {
+	return bundle(
+		WithMetadata(nil),
+		WithWaitTicket(0),
+		WithRetryNoSpace(),
+		logWarnings(),
+	)
+}
+
+// bundle returns an option that applies each of opts in order.
+func bundle(opts ...Option) Option {
+	return func(c *config) {
+		for _, opt := range opts {
+			if opt != nil {
+				opt(c)
+			}
+		}
+	}
+}
+
+// logWarnings returns an option that logs the warnings reported for lock
+// files to the default [slog.Logger]. It preserves any other hooks.
+func logWarnings() Option {
+	return func(c *config) {
+		c.hooks.Warning = func(path string, err error) {
+			slog.Warn("lock file warning", slog.String("path", path), slog.Any("error", err))
+		}
+	}
+}
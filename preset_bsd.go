@@ -0,0 +1,10 @@
+//go:build darwin || dragonfly || freebsd || openbsd
+
+package lockfile
+
+// networkLocks returns the option that selects the locks used by
+// [PresetNFSSafe]. Open file description locks are not available, so it
+// selects POSIX record locks, which are also propagated to the server.
+func networkLocks() Option {
+	return WithPOSIXLocks()
+}
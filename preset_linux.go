@@ -0,0 +1,9 @@
+//go:build linux
+
+package lockfile
+
+// networkLocks returns the option that selects the locks used by
+// [PresetNFSSafe], which are open file description locks.
+func networkLocks() Option {
+	return WithOFDLocks()
+}
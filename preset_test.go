@@ -0,0 +1,58 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestPresets(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		preset lockfile.Option
+	}{
+		{"local-fast", lockfile.PresetLocalFast()},
+		{"nfs-safe", lockfile.PresetNFSSafe()},
+		{"single-instance-app", lockfile.PresetSingleInstanceApp()},
+		{"batch-job", lockfile.PresetBatchJob()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "preset.lock")
+			file, err := lockfile.Create(path, tc.preset)
+			if err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+			if _, err := lockfile.Create(path, tc.preset); !lockfile.IsTemporary(err) {
+				t.Fatalf("expected a temporary error while the lock file is held, got: %v", err)
+			}
+			if err := file.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestPresetSingleInstanceApp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.lock")
+	file, err := lockfile.Create(path, lockfile.PresetSingleInstanceApp())
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer file.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = lockfile.WaitCtx(ctx, path, lockfile.PresetSingleInstanceApp())
+	if !errors.Is(err, lockfile.ErrNeverFree) {
+		t.Fatalf("expected an error that wraps ErrNeverFree, got: %v", err)
+	}
+
+	var contention *lockfile.ContentionError
+	if !errors.As(err, &contention) || contention.Holder == nil {
+		t.Fatalf("expected the error to identify the running instance, got: %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package lockfile
+
+// networkLocks returns the option that selects the locks used by
+// [PresetNFSSafe]. Windows does not need a different kind of lock for
+// network shares, so it returns nil.
+func networkLocks() Option {
+	return nil
+}
@@ -0,0 +1,39 @@
+package lockfile
+
+import (
+	"errors"
+	"os"
+)
+
+// probe reports whether a lock file exists at path, and whether it is held,
+// without creating, acquiring or removing it.
+func (c *config) probe(path string) (exists, held bool, err error) {
+	if c.backend != nil {
+		return c.backend.Probe(path)
+	}
+	if c.useSoftLock(path) {
+		return c.probeSoft(path)
+	}
+
+	exists, held, err = c.probeLock(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, false, nil
+	}
+	return exists, held, err
+}
+
+// probeSoft reports whether a soft lock file exists at path, and whether
+// it is held. A soft lock file is held if it exists and is not stale.
+func (c *config) probeSoft(path string) (exists, held bool, err error) {
+	err = c.do(OpStat, path, func() error {
+		_, err := os.Stat(path)
+		return err
+	})
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return false, false, nil
+	case err != nil:
+		return false, false, err
+	}
+	return true, !c.softStale(path), nil
+}
@@ -0,0 +1,38 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile
+
+import "syscall"
+
+// probeLock reports whether a lock file exists at path, and whether it is
+// held, by briefly locking it if it exists.
+//
+// The lock file is opened without being created, and is closed without
+// being deleted, so that probing it does not disturb its holder or anyone
+// waiting for it.
+func (c *config) probeLock(path string) (exists, held bool, err error) {
+	sys := c.system()
+
+	// Write locks acquired with fcntl can only be acquired through a
+	// descriptor that is open for writing.
+	flag := syscall.O_RDONLY
+	if c.fcntlLocks() {
+		flag = syscall.O_RDWR
+	}
+
+	fd, err := sys.open(path, flag, 0)
+	if err != nil {
+		return false, false, pathError("open", path, err)
+	}
+	defer sys.closeFd(path, fd)
+
+	if err := sys.flock(path, fd, syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if err == syscall.EWOULDBLOCK {
+			return true, true, nil
+		}
+		return true, false, pathError("flock", path, err)
+	}
+
+	// Closing the file releases the lock that was just acquired.
+	return true, false, nil
+}
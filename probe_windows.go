@@ -0,0 +1,33 @@
+//go:build windows
+
+package lockfile
+
+import "syscall"
+
+// probeLock reports whether a lock file exists at path, and whether it is
+// held, by briefly opening it if it exists.
+//
+// Held lock files are open without sharing, so opening them for reading
+// fails with a sharing violation. The lock file is opened without delete-on-close, so
+// that probing it does not delete it.
+func (c *config) probeLock(path string) (exists, held bool, err error) {
+	const (
+		ERROR_ACCESS_DENIED     = syscall.Errno(5)
+		ERROR_SHARING_VIOLATION = syscall.Errno(32)
+		FILE_SHARE_READ         = 0x1
+	)
+
+	sys := c.system()
+
+	handle, err := sys.open(path, syscall.GENERIC_READ, FILE_SHARE_READ, syscall.OPEN_EXISTING, 0)
+	switch err {
+	case nil:
+		sys.closeHandle(path, handle)
+		return true, false, nil
+	case ERROR_SHARING_VIOLATION, ERROR_ACCESS_DENIED:
+		// Access is denied while a lock file is pending deletion, which
+		// is treated as being held, just as it is by IsTemporary.
+		return true, true, nil
+	}
+	return false, false, pathError("open", path, err)
+}
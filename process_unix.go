@@ -0,0 +1,93 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// processAlive returns true if a process with the given ID exists on this
+// host. A process that exists but cannot be signaled by the caller is
+// alive.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// clockTicks is the number of clock ticks per second used by the process
+// start times in /proc. It is 100 on every architecture that Linux
+// supports today, and is fixed by the kernel ABI.
+const clockTicks = 100
+
+// processStarted returns the time at which the process with the given ID
+// started. It returns the zero time if it cannot be determined, such as
+// on systems without /proc.
+//
+// The start time is derived from the boot time of the host, which the
+// kernel reports to the nearest second, so it is only precise to within a
+// second or so.
+func processStarted(pid int) time.Time {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return time.Time{}
+	}
+
+	// The name of the process is enclosed in parentheses and may contain
+	// spaces, so the fields are counted from the last closing parenthesis.
+	// The start time is the 22nd field, and the state is the 3rd.
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return time.Time{}
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 20 {
+		return time.Time{}
+	}
+	ticks, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	boot := bootTime()
+	if boot.IsZero() {
+		return time.Time{}
+	}
+	return boot.Add(time.Duration(ticks) * time.Second / clockTicks)
+}
+
+// bootTime returns the time at which the host booted, or the zero time if
+// it cannot be determined.
+var bootTime = sync.OnceValue(func() time.Time {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}
+	}
+	for line := range strings.Lines(string(data)) {
+		value, found := strings.CutPrefix(line, "btime ")
+		if !found {
+			continue
+		}
+		seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			break
+		}
+		return time.Unix(seconds, 0)
+	}
+	return time.Time{}
+})
+
+// bootID returns a value that identifies the current boot of the host, or
+// an empty string if it cannot be determined.
+var bootID = sync.OnceValue(func() string {
+	data, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+})
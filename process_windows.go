@@ -0,0 +1,58 @@
+//go:build windows
+
+package lockfile
+
+import (
+	"syscall"
+	"time"
+)
+
+// processAlive returns true if a process with the given ID exists on this
+// host. A process that exists but cannot be opened by the caller is alive.
+func processAlive(pid int) bool {
+	const (
+		PROCESS_QUERY_LIMITED_INFORMATION = 0x1000
+		STILL_ACTIVE                      = 259
+
+		ERROR_ACCESS_DENIED = syscall.Errno(5)
+	)
+
+	handle, err := syscall.OpenProcess(PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return err == ERROR_ACCESS_DENIED
+	}
+	defer syscall.CloseHandle(handle)
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(handle, &code); err != nil {
+		return true
+	}
+	return code == STILL_ACTIVE
+}
+
+// processStarted returns the time at which the process with the given ID
+// started. It returns the zero time if it cannot be determined, such as
+// when the process cannot be opened by the caller.
+func processStarted(pid int) time.Time {
+	const PROCESS_QUERY_LIMITED_INFORMATION = 0x1000
+
+	handle, err := syscall.OpenProcess(PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return time.Time{}
+	}
+	defer syscall.CloseHandle(handle)
+
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(handle, &creation, &exit, &kernel, &user); err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, creation.Nanoseconds())
+}
+
+// bootID returns a value that identifies the current boot of the host, or
+// an empty string if it cannot be determined. Windows does not provide
+// one, so process start times alone guard against the reuse of process
+// IDs.
+func bootID() string {
+	return ""
+}
@@ -0,0 +1,66 @@
+package lockfile
+
+import "context"
+
+// Provider acquires lock files. [NewProvider] returns the provider that is
+// implemented by this package. Alternative implementations can prove that
+// they honor the same contract with the conformance suite in the
+// lockfiletest package.
+type Provider interface {
+	// Create makes a single attempt to acquire the lock file at path. If
+	// it is held by someone else, it returns an error for which
+	// [IsTemporary] returns true.
+	Create(path string) (Handle, error)
+
+	// Wait waits for the lock file at path to be acquired, until it
+	// succeeds, a non-temporary error is encountered or ctx is cancelled.
+	Wait(ctx context.Context, path string) (Handle, error)
+
+	// Guarantees describes the lock files that are acquired by the
+	// provider.
+	Guarantees() Guarantees
+}
+
+// Handle is a lock that is held through a [Provider]. [*File] implements
+// it.
+type Handle interface {
+	// Path returns the path of the lock file.
+	Path() string
+
+	// Close releases the lock. It returns an error that wraps
+	// [os.ErrClosed] if the lock has already been released.
+	Close() error
+}
+
+// NewProvider returns a [Provider] that acquires lock files with the given
+// options, as [Create] and [WaitCtx] do. The handles it returns are
+// [*File] values.
+func NewProvider(opts ...Option) Provider {
+	return provider{cfg: newConfig(opts)}
+}
+
+// provider implements [Provider] with a configuration.
+type provider struct {
+	cfg *config
+}
+
+func (p provider) Create(path string) (Handle, error) {
+	return handle(p.cfg.create(path))
+}
+
+func (p provider) Wait(ctx context.Context, path string) (Handle, error) {
+	return handle(p.cfg.wait(ctx, path))
+}
+
+func (p provider) Guarantees() Guarantees {
+	return p.cfg.guarantees(p.cfg.soft, p.cfg.shared)
+}
+
+// handle converts the result of an acquisition to a [Handle], taking care
+// not to return a non-nil interface that holds a nil file.
+func handle(file *File, err error) (Handle, error) {
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,163 @@
+package lockfile
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// QueueKind identifies the kind of queue that a waiter is in.
+type QueueKind int
+
+const (
+	// QueueTicket is a waiter that published a ticket with
+	// [WithWaitTicket].
+	QueueTicket QueueKind = iota
+
+	// QueueRotation is a participant that is waiting for its turn in a
+	// [Rotation].
+	QueueRotation
+)
+
+// String returns a description of the queue kind.
+func (k QueueKind) String() string {
+	switch k {
+	case QueueTicket:
+		return "ticket"
+	case QueueRotation:
+		return "rotation"
+	}
+	return "unknown"
+}
+
+// QueuedWaiter describes a process that is waiting for a lock file, as
+// reported by [QueueStatus].
+type QueuedWaiter struct {
+	// ID identifies the waiter among those of the lock file, so that it
+	// can be evicted with [CancelWaiter].
+	ID string
+
+	Kind     QueueKind
+	Holder   Holder
+	Priority int
+
+	// Since is the time at which the waiter started waiting, and Waited is
+	// how long it has been waiting for.
+	Since  time.Time
+	Waited time.Duration
+
+	// Deadline is the time at which the waiter gives up, if it has one.
+	Deadline time.Time
+
+	// Exited is true if the waiter is on this host and is no longer
+	// running, which means that its ticket was left behind.
+	Exited bool
+
+	// TraceID is the trace or correlation ID of the waiter, if it has one.
+	TraceID string
+}
+
+// QueueStatus returns the processes that are waiting for the lock file with
+// the given path, in the order in which they are expected to acquire it.
+//
+// Participants in a [Rotation] come first, in arrival order, followed by
+// waiters that published tickets with [WithWaitTicket], ordered from most
+// to least urgent as by [Waiters]. Waiters that do neither cannot be seen.
+// A missing queue is not an error, and results in an empty list.
+func QueueStatus(path string) ([]QueuedWaiter, error) {
+	now := time.Now()
+
+	rotation, err := readQueue(rotationDir(path))
+	if err != nil {
+		return nil, err
+	}
+	tickets, err := Waiters(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var waiters []QueuedWaiter
+	add := func(kind QueueKind, ticket WaitTicket) {
+		waiters = append(waiters, QueuedWaiter{
+			ID:       ticket.ID,
+			Kind:     kind,
+			Holder:   ticket.Holder,
+			Priority: ticket.Priority,
+			Since:    ticket.Since,
+			Waited:   now.Sub(ticket.Since),
+			Deadline: ticket.Deadline,
+			Exited:   exited(ticket.Holder),
+			TraceID:  ticket.TraceID,
+		})
+	}
+	for _, ticket := range rotation {
+		add(QueueRotation, ticket)
+	}
+	for _, ticket := range tickets {
+		add(QueueTicket, ticket)
+	}
+
+	return waiters, nil
+}
+
+// CancelWaiter evicts the waiter with the given ID from the queue for the
+// lock file with the given path, by removing its ticket. IDs are reported
+// by [QueueStatus] and [Waiters].
+//
+// The waiter notices that its ticket is gone the next time that it tries
+// to acquire the lock file, and stops waiting with an error that wraps
+// [ErrEvictedFromQueue]. This allows an operator to unstick a pipeline
+// without killing the process that is waiting.
+//
+// If no waiter has the given ID, it returns an [*os.PathError] that wraps
+// [os.ErrNotExist].
+func CancelWaiter(path, id string) error {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return &os.PathError{Op: "cancel", Path: path, Err: os.ErrNotExist}
+	}
+
+	for _, dir := range []string{rotationDir(path), ticketDir(path)} {
+		err := os.Remove(filepath.Join(dir, id+".json"))
+		if err == nil || !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return &os.PathError{Op: "cancel", Path: path, Err: os.ErrNotExist}
+}
+
+// readQueue returns the tickets in dir, ordered by name. Tickets that cannot
+// be read are skipped.
+func readQueue(dir string) ([]WaitTicket, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var tickets []WaitTicket
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		ticket := WaitTicket{ID: strings.TrimSuffix(entry.Name(), ".json")}
+		if json.Unmarshal(data, &ticket) == nil {
+			tickets = append(tickets, ticket)
+		}
+	}
+	return tickets, nil
+}
+
+// exited returns true if holder is on this host and is no longer running.
+func exited(holder Holder) bool {
+	hostname, _ := os.Hostname()
+	return holder.Hostname == hostname && holder.PID != 0 && !processAlive(holder.PID)
+}
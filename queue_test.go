@@ -0,0 +1,122 @@
+package lockfile_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+// writeTicket writes ticket to the file with the given name in dir.
+func writeTicket(t *testing.T, dir, name string, ticket lockfile.WaitTicket) {
+	t.Helper()
+	data, err := json.Marshal(ticket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueueStatus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.lock")
+
+	if waiters, err := lockfile.QueueStatus(path); err != nil || len(waiters) != 0 {
+		t.Fatalf("expected no waiters without a queue, got: %v, %v", waiters, err)
+	}
+
+	since := time.Now().Add(-time.Minute)
+	self := lockfile.CurrentHolder()
+	gone := self
+	gone.PID = exitedPID(t)
+
+	writeTicket(t, path+".waiters", "low.json", lockfile.WaitTicket{Holder: self, Priority: 1, Since: since})
+	writeTicket(t, path+".waiters", "high.json", lockfile.WaitTicket{Holder: gone, Priority: 9, Since: since})
+	writeTicket(t, path+".rotation", "2.json", lockfile.WaitTicket{Holder: self, Since: since.Add(time.Second)})
+	writeTicket(t, path+".rotation", "1.json", lockfile.WaitTicket{Holder: self, Since: since})
+
+	waiters, err := lockfile.QueueStatus(path)
+	if err != nil {
+		t.Fatalf("QueueStatus failed: %v", err)
+	}
+	if len(waiters) != 4 {
+		t.Fatalf("found %d waiters, expected 4", len(waiters))
+	}
+
+	expected := []struct {
+		kind     lockfile.QueueKind
+		priority int
+		since    time.Time
+		exited   bool
+	}{
+		{lockfile.QueueRotation, 0, since, false},
+		{lockfile.QueueRotation, 0, since.Add(time.Second), false},
+		{lockfile.QueueTicket, 9, since, true},
+		{lockfile.QueueTicket, 1, since, false},
+	}
+	for i, want := range expected {
+		got := waiters[i]
+		if got.Kind != want.kind || got.Priority != want.priority || !got.Since.Equal(want.since) || got.Exited != want.exited {
+			t.Errorf("waiter %d: unexpected status: %+v", i, got)
+		}
+		if got.ID == "" {
+			t.Errorf("waiter %d: no ID was reported", i)
+		}
+		if got.Waited < time.Since(got.Since)-time.Second {
+			t.Errorf("waiter %d: unexpected wait of %v", i, got.Waited)
+		}
+	}
+}
+
+func TestCancelWaiter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.lock")
+
+	held, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer held.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		file, err := lockfile.WaitCtx(ctx, path, lockfile.WithWaitTicket(1))
+		if err == nil {
+			file.Close()
+		}
+		result <- err
+	}()
+
+	// Wait for the waiter to join the queue.
+	var waiters []lockfile.QueuedWaiter
+	for len(waiters) == 0 && ctx.Err() == nil {
+		if waiters, err = lockfile.QueueStatus(path); err != nil {
+			t.Fatalf("QueueStatus failed: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(waiters) != 1 || waiters[0].ID == "" {
+		t.Fatalf("unexpected queue: %+v", waiters)
+	}
+
+	if err := lockfile.CancelWaiter(path, "missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected an error that wraps os.ErrNotExist for a missing waiter, got: %v", err)
+	}
+	if err := lockfile.CancelWaiter(path, waiters[0].ID); err != nil {
+		t.Fatalf("CancelWaiter failed: %v", err)
+	}
+	if err := <-result; !errors.Is(err, lockfile.ErrEvictedFromQueue) {
+		t.Fatalf("expected the waiter to be evicted, got: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package lockfile
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// WithDirQuota returns an option that limits the number of lock files that
+// a [Manager] holds at once in any one directory. Attempts to acquire a
+// lock file beyond the limit fail immediately with a [*QuotaError].
+//
+// This surfaces bugs that derive lock file names from unbounded keys
+// quickly, before they exhaust the inodes of the volume. The quota is only
+// enforced by a Manager. Lock files that are being waited for count
+// against it, and a limit of zero or less means no limit.
+func WithDirQuota(limit int) Option {
+	return func(c *config) {
+		c.dirQuota = limit
+	}
+}
+
+// QuotaError is returned by a [Manager] when acquiring a lock file would
+// exceed the limit configured by [WithDirQuota].
+type QuotaError struct {
+	Dir   string
+	Count int // The number of lock files held in Dir
+	Limit int
+}
+
+// Error returns a description of the exceeded quota.
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("lockfile: too many lock files in \"%s\": %d of %d are held", e.Dir, e.Count, e.Limit)
+}
+
+// Is returns true if target is [ErrTooManyLocks].
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrTooManyLocks
+}
+
+// As sets target to [ReasonExhausted] if it is a [*ReasonCode].
+func (e *QuotaError) As(target any) bool {
+	return setReason(target, ReasonExhausted)
+}
+
+// reserve reserves a place for the lock file at path within the quota of
+// its directory. It returns the directory, which must be passed to trackIn
+// once the lock file has been acquired or has failed to be. If no quota is
+// configured, it returns an empty directory.
+func (m *Manager) reserve(path string) (string, error) {
+	if m.cfg.dirQuota <= 0 {
+		return "", nil
+	}
+
+	dir := filepath.Dir(path)
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if count := m.dirs[dir]; count >= m.cfg.dirQuota {
+		return "", &QuotaError{Dir: dir, Count: count, Limit: m.cfg.dirQuota}
+	}
+	m.dirs[dir]++
+
+	return dir, nil
+}
+
+// unreserveLocked gives up a place reserved by reserve. The caller must
+// hold m.mutex.
+func (m *Manager) unreserveLocked(dir string) {
+	if dir == "" {
+		return
+	}
+	if m.dirs[dir]--; m.dirs[dir] <= 0 {
+		delete(m.dirs, dir)
+	}
+}
@@ -0,0 +1,64 @@
+package lockfile_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestDirQuota(t *testing.T) {
+	dir := t.TempDir()
+	other := t.TempDir()
+	m := lockfile.NewManager(lockfile.WithDirQuota(2))
+	defer m.CloseAll()
+
+	var files []*lockfile.File
+	for i := range 2 {
+		file, err := m.Create(filepath.Join(dir, strconv.Itoa(i)+".lock"))
+		if err != nil {
+			t.Fatalf("Create %d failed: %v", i, err)
+		}
+		files = append(files, file)
+	}
+
+	_, err := m.Create(filepath.Join(dir, "2.lock"))
+	var quotaErr *lockfile.QuotaError
+	if !errors.As(err, &quotaErr) || !errors.Is(err, lockfile.ErrTooManyLocks) {
+		t.Fatalf("Create returned %v beyond the quota", err)
+	}
+	if quotaErr.Count != 2 || quotaErr.Limit != 2 {
+		t.Fatalf("unexpected quota error: %+v", *quotaErr)
+	}
+
+	// Other directories have their own quota.
+	if _, err := m.Create(filepath.Join(other, "0.lock")); err != nil {
+		t.Fatalf("Create failed in another directory: %v", err)
+	}
+
+	// Releasing a lock file makes room for another.
+	if err := files[0].Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Create(filepath.Join(dir, "2.lock")); err != nil {
+		t.Fatalf("Create failed after a lock file was released: %v", err)
+	}
+
+	// A failed acquisition does not count against the quota.
+	if err := files[1].Close(); err != nil {
+		t.Fatal(err)
+	}
+	held, err := lockfile.Create(filepath.Join(dir, "held.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Close()
+	if _, err := m.Create(held.Path()); !lockfile.IsTemporary(err) {
+		t.Fatalf("Create returned %v for a held lock file", err)
+	}
+	if _, err := m.Create(filepath.Join(dir, "1.lock")); err != nil {
+		t.Fatalf("Create failed below the quota: %v", err)
+	}
+}
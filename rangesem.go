@@ -0,0 +1,187 @@
+package lockfile
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// RangeSemaphore bounds the number of holders that may work concurrently,
+// like a [Semaphore], but keeps all of its slots in a single coordination
+// file. Each slot is a byte-range lock on one byte of the file, so the
+// directory stays clean and the semaphore scales to hundreds of slots.
+//
+// The byte-range locks are open file description locks on Linux, LockFileEx
+// locks on Windows, and POSIX record locks on macOS and the BSDs. The
+// operating system releases them if the process exits. POSIX record locks
+// belong to the process rather than the open file, so on macOS and the BSDs
+// a process must not open more than one RangeSemaphore for the same
+// coordination file: they would grant the same slots, and closing one would
+// release the permits of the others. Unlike lock files,
+// they are not visible to processes that only look at the directory, and
+// the coordination file is not deleted when the semaphore is closed.
+//
+// Waiters poll for a free slot, so permits are not granted in arrival
+// order. Use a [Semaphore] where fairness matters.
+//
+// A RangeSemaphore is safe for concurrent use. Each permit that it grants
+// counts against the limit, whether it is held by this process or another.
+type RangeSemaphore struct {
+	path  string
+	slots int
+
+	mutex sync.Mutex
+	file  *os.File
+	held  []bool // The slots held through file
+}
+
+// RangePermit is a slot of a [RangeSemaphore] that is held by the current
+// process. It is released by calling its Close method.
+type RangePermit struct {
+	sem  *RangeSemaphore
+	slot int
+	once sync.Once
+}
+
+// OpenRangeSemaphore opens the semaphore with the given number of slots
+// whose coordination file has the given path, creating the file if
+// necessary. Every process that shares the semaphore must agree on the
+// number of slots.
+//
+// It returns an error that wraps [ErrInvalidOption] if slots is not
+// positive.
+func OpenRangeSemaphore(path string, slots int) (*RangeSemaphore, error) {
+	if path == "" {
+		return nil, ErrEmptyPath
+	}
+	if slots <= 0 {
+		return nil, fmt.Errorf("%w: a semaphore must have at least one slot", ErrInvalidOption)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RangeSemaphore{path: path, slots: slots, file: file, held: make([]bool, slots)}, nil
+}
+
+// Path returns the path of the coordination file.
+func (s *RangeSemaphore) Path() string {
+	return s.path
+}
+
+// Slots returns the number of slots of the semaphore.
+func (s *RangeSemaphore) Slots() int {
+	return s.slots
+}
+
+// TryAcquire makes a single attempt to acquire a permit.
+//
+// If no permit is available, it returns an [*os.PathError] that wraps
+// [os.ErrExist]. It returns an [*os.PathError] that wraps [os.ErrClosed]
+// if the semaphore has been closed.
+func (s *RangeSemaphore) TryAcquire() (*RangePermit, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return nil, withReason(&os.PathError{Op: "acquire", Path: s.path, Err: os.ErrClosed})
+	}
+
+	for slot := range s.slots {
+		// Byte-range locks do not conflict with others held through the
+		// same open file, so slots held by this semaphore are skipped.
+		if s.held[slot] {
+			continue
+		}
+		ok, err := tryLockRange(s.file, int64(slot), true)
+		if err != nil {
+			return nil, pathError("acquire", s.path, err)
+		}
+		if ok {
+			s.held[slot] = true
+			return &RangePermit{sem: s, slot: slot}, nil
+		}
+	}
+
+	return nil, withReason(&os.PathError{Op: "acquire", Path: s.path, Err: os.ErrExist})
+}
+
+// Acquire waits for a permit, polling with a random backoff until it
+// acquires one, encounters an error or ctx is cancelled.
+func (s *RangeSemaphore) Acquire(ctx context.Context) (*RangePermit, error) {
+	var timer *time.Timer
+	for attempt := 0; ; attempt++ {
+		permit, err := s.TryAcquire()
+		if err == nil || !IsTemporary(err) {
+			return permit, err
+		}
+
+		if timer == nil {
+			timer = time.NewTimer(randomBackoff(attempt))
+			defer timer.Stop()
+		} else {
+			timer.Reset(randomBackoff(attempt))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, withReason(ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// Close closes the coordination file, which releases every permit that
+// is still held through it.
+//
+// It returns an [*os.PathError] that wraps [os.ErrClosed] if the function
+// has already been called.
+func (s *RangeSemaphore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return withReason(&os.PathError{Op: "close", Path: s.path, Err: os.ErrClosed})
+	}
+
+	err := s.file.Close()
+	s.file = nil
+	clear(s.held)
+	return err
+}
+
+// Slot returns the index of the slot that the permit holds, from zero to
+// one less than the number of slots of its semaphore.
+func (p *RangePermit) Slot() int {
+	return p.slot
+}
+
+// Close releases the permit.
+//
+// It returns an [*os.PathError] that wraps [os.ErrClosed] if the permit
+// has already been released.
+func (p *RangePermit) Close() error {
+	err := error(&os.PathError{Op: "release", Path: p.sem.path, Err: os.ErrClosed})
+	p.once.Do(func() {
+		err = p.sem.release(p.slot)
+	})
+	return err
+}
+
+// release unlocks the given slot.
+func (s *RangeSemaphore) release(slot int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Closing the semaphore has already released the slot.
+	if s.file == nil {
+		return nil
+	}
+
+	s.held[slot] = false
+	return pathError("release", s.path, unlockRange(s.file, int64(slot)))
+}
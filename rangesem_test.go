@@ -0,0 +1,92 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestRangeSemaphore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "slots")
+	a, err := lockfile.OpenRangeSemaphore(path, 3)
+	if err != nil {
+		t.Fatalf("OpenRangeSemaphore failed: %v", err)
+	}
+	defer a.Close()
+
+	// POSIX record locks belong to the process, so on platforms other than
+	// Linux and Windows a process may only open the semaphore once.
+	perFile := runtime.GOOS == "linux" || runtime.GOOS == "windows"
+	b := a
+	if perFile {
+		b, err = lockfile.OpenRangeSemaphore(path, 3)
+		if err != nil {
+			t.Fatalf("OpenRangeSemaphore failed: %v", err)
+		}
+		defer b.Close()
+	}
+
+	seen := make(map[int]bool)
+	var permits []*lockfile.RangePermit
+	for _, sem := range []*lockfile.RangeSemaphore{a, a, b} {
+		permit, err := sem.TryAcquire()
+		if err != nil {
+			t.Fatalf("TryAcquire failed: %v", err)
+		}
+		if seen[permit.Slot()] {
+			t.Fatalf("slot %d was granted twice", permit.Slot())
+		}
+		seen[permit.Slot()] = true
+		permits = append(permits, permit)
+	}
+
+	for _, sem := range []*lockfile.RangeSemaphore{a, b} {
+		if _, err := sem.TryAcquire(); !errors.Is(err, os.ErrExist) {
+			t.Fatalf("expected ErrExist when every slot is held, got: %v", err)
+		}
+	}
+
+	// A permit released by one holder can be acquired by another.
+	if err := permits[0].Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := permits[0].Close(); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("expected ErrClosed when releasing twice, got: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	permit, err := b.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if permit.Slot() != permits[0].Slot() {
+		t.Fatalf("expected slot %d, got %d", permits[0].Slot(), permit.Slot())
+	}
+
+	if !perFile {
+		return
+	}
+
+	// Closing a semaphore releases the permits that it still holds.
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := b.TryAcquire(); err != nil {
+		t.Fatalf("TryAcquire failed after the other semaphore was closed: %v", err)
+	}
+	if err := permits[1].Close(); err != nil {
+		t.Fatalf("releasing a permit of a closed semaphore failed: %v", err)
+	}
+}
+
+func TestRangeSemaphoreInvalid(t *testing.T) {
+	if _, err := lockfile.OpenRangeSemaphore(filepath.Join(t.TempDir(), "slots"), 0); !errors.Is(err, lockfile.ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption, got: %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package lockfile
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+)
+
+// WithFallbackDir returns an option that relocates lock files to dir when
+// their intended location is on a read-only filesystem.
+//
+// This is common in containers, where application data may live on a
+// read-only layer while a writable runtime directory, such as /run or a
+// tmpfs mount, is available. The relocated lock file is named after the
+// original path, so that every process that is configured with the same
+// fallback directory contends for the same relocated lock file. The path of
+// the relocated lock file is available from [File.Path].
+//
+// When a lock file is relocated, a warning wrapping
+// [ErrReadOnlyFilesystem] is reported to the Warning hook.
+func WithFallbackDir(dir string) Option {
+	return func(c *config) {
+		c.fallbackDir = dir
+	}
+}
+
+// fallbackPath returns the path within dir that the lock file at path is
+// relocated to.
+//
+// The name includes a hash of the original path, so that lock files with
+// the same name in different directories do not collide.
+func fallbackPath(dir, path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(path))
+
+	return filepath.Join(dir, fmt.Sprintf("%08x-%s", h.Sum32(), filepath.Base(path)))
+}
@@ -0,0 +1,61 @@
+package lockfile_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+// readOnlyHook simulates a read-only filesystem for lock files in dir by
+// failing open operations with the platform's read-only error.
+func readOnlyHook(dir string, readOnlyErr error) lockfile.Option {
+	return lockfile.WithHooks(lockfile.Hooks{
+		BeforeOp: func(op lockfile.Op, path string) error {
+			if op == lockfile.OpOpen && strings.HasPrefix(path, dir) {
+				return readOnlyErr
+			}
+			return nil
+		},
+	})
+}
+
+func TestReadOnlyFilesystem(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "readonly.lock")
+
+	_, err := lockfile.Create(path, readOnlyHook(dir, readOnlyErr))
+	if !errors.Is(err, lockfile.ErrReadOnlyFilesystem) {
+		t.Fatalf("expected ErrReadOnlyFilesystem, got: %v", err)
+	}
+	if !errors.Is(err, readOnlyErr) {
+		t.Fatalf("the original error was not preserved: %v", err)
+	}
+}
+
+func TestReadOnlyFallbackDir(t *testing.T) {
+	dir := t.TempDir()
+	fallback := t.TempDir()
+	path := filepath.Join(dir, "readonly.lock")
+
+	opts := []lockfile.Option{
+		readOnlyHook(dir, readOnlyErr),
+		lockfile.WithFallbackDir(fallback),
+	}
+
+	lock, err := lockfile.Create(path, opts...)
+	if err != nil {
+		t.Fatalf("failed to create relocated lock file: %v", err)
+	}
+	defer lock.Close()
+
+	if filepath.Dir(lock.Path()) != fallback {
+		t.Fatalf("lock file was not relocated: %s", lock.Path())
+	}
+
+	if _, err := lockfile.Create(path, opts...); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected contention for relocated lock file, got: %v", err)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isReadOnly returns true if err indicates that a file could not be
+// created because its filesystem is read-only.
+func isReadOnly(err error) bool {
+	return errors.Is(err, syscall.EROFS)
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package lockfile
+
+import (
+	"errors"
+	"syscall"
+)
+
+// errorWriteProtect is returned when a file cannot be created because
+// the media is write-protected.
+const errorWriteProtect syscall.Errno = 19
+
+// isReadOnly returns true if err indicates that a file could not be
+// created because its filesystem is read-only.
+func isReadOnly(err error) bool {
+	return errors.Is(err, errorWriteProtect)
+}
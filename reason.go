@@ -0,0 +1,233 @@
+package lockfile
+
+import (
+	"context"
+	"errors"
+	"os"
+)
+
+// ReasonCode is a stable, machine-readable classification of an error
+// returned by this package. It lets callers such as telemetry pipelines
+// aggregate failures by their cause without matching error text.
+//
+// Errors returned by this package carry a ReasonCode that can be retrieved
+// with [errors.As]:
+//
+//	var code lockfile.ReasonCode
+//	if errors.As(err, &code) {
+//		metrics.Count("lock_failures", code.String())
+//	}
+//
+// [Reason] does the same, and also classifies errors that did not come from
+// this package.
+//
+// The values of the codes and the names returned by String never change.
+// New codes may be added in the future.
+type ReasonCode int
+
+// Reason codes that classify the errors returned by this package.
+const (
+	// ReasonUnknown is the code of errors that have no other
+	// classification.
+	ReasonUnknown ReasonCode = iota
+
+	// ReasonContended is the code of errors caused by a lock file that is
+	// held by someone else.
+	ReasonContended
+
+	// ReasonStale is the code of errors caused by a lock file that was
+	// removed while it was held, usually because someone else judged it to
+	// be stale, or that appears to have been abandoned by its holder.
+	ReasonStale
+
+	// ReasonUnsafeFilesystem is the code of errors caused by a filesystem
+	// that cannot be relied upon to provide mutual exclusion.
+	ReasonUnsafeFilesystem
+
+	// ReasonPermissionDenied is the code of errors caused by the lack of
+	// permission to create or write a lock file, including filesystems that
+	// are read-only.
+	ReasonPermissionDenied
+
+	// ReasonTampered is the code of errors caused by a lock file whose
+	// contents or identity were changed by someone else.
+	ReasonTampered
+
+	// ReasonTimeout is the code of errors caused by a deadline, a wait
+	// budget or an operation that did not complete in time.
+	ReasonTimeout
+
+	// ReasonCancelled is the code of errors caused by the cancellation of a
+	// context, or of a waiter by an operator.
+	ReasonCancelled
+
+	// ReasonExhausted is the code of errors caused by exhausted resources
+	// or budgets, such as a full filesystem or a directory quota.
+	ReasonExhausted
+
+	// ReasonInvalid is the code of errors caused by invalid arguments,
+	// options or metadata, or by a lock file that is already held by the
+	// caller.
+	ReasonInvalid
+
+	// ReasonNotHeld is the code of errors caused by releasing or using a
+	// lock that is not held.
+	ReasonNotHeld
+
+	// ReasonRefused is the code of errors caused by a policy that refused
+	// the acquisition of a lock file, such as a schedule, a rate limit or a
+	// precondition.
+	ReasonRefused
+
+	// ReasonUnsupported is the code of errors caused by a feature that is
+	// not available for the lock file or on the current system.
+	ReasonUnsupported
+)
+
+// reasonNames are the names of the reason codes, indexed by code.
+var reasonNames = [...]string{
+	ReasonUnknown:          "unknown",
+	ReasonContended:        "contended",
+	ReasonStale:            "stale",
+	ReasonUnsafeFilesystem: "unsafe_filesystem",
+	ReasonPermissionDenied: "permission_denied",
+	ReasonTampered:         "tampered",
+	ReasonTimeout:          "timeout",
+	ReasonCancelled:        "cancelled",
+	ReasonExhausted:        "exhausted",
+	ReasonInvalid:          "invalid",
+	ReasonNotHeld:          "not_held",
+	ReasonRefused:          "refused",
+	ReasonUnsupported:      "unsupported",
+}
+
+// String returns the name of the code, such as "contended", which is
+// suitable for use as a metric label.
+func (r ReasonCode) String() string {
+	if r < 0 || int(r) >= len(reasonNames) {
+		return reasonNames[ReasonUnknown]
+	}
+	return reasonNames[r]
+}
+
+// Error returns the name of the code. It is implemented so that a
+// ReasonCode can be retrieved from an error with [errors.As].
+func (r ReasonCode) Error() string {
+	return "lockfile: " + r.String()
+}
+
+// Reason returns the reason code of err. Errors returned by this package
+// report the code that they carry. Other errors are classified by the
+// standard errors they match, such as [context.Canceled] or
+// [os.ErrPermission].
+//
+// It returns [ReasonUnknown] if err is nil or cannot be classified.
+func Reason(err error) ReasonCode {
+	var code ReasonCode
+	if errors.As(err, &code) {
+		return code
+	}
+
+	switch {
+	case err == nil:
+		return ReasonUnknown
+	case errors.Is(err, context.Canceled):
+		return ReasonCancelled
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return ReasonTimeout
+	case IsTemporary(err):
+		return ReasonContended
+	case errors.Is(err, os.ErrPermission):
+		return ReasonPermissionDenied
+	case errors.Is(err, os.ErrClosed):
+		return ReasonNotHeld
+	}
+	return ReasonUnknown
+}
+
+// reasonError is an error with a reason code. The sentinel errors of this
+// package are reasonErrors.
+type reasonError struct {
+	text string
+	code ReasonCode
+}
+
+// newError returns an error with the given text and reason code.
+func newError(code ReasonCode, text string) error {
+	return &reasonError{text: text, code: code}
+}
+
+func (e *reasonError) Error() string {
+	return e.text
+}
+
+// As sets target to the reason code of the error if it is a
+// [*ReasonCode].
+func (e *reasonError) As(target any) bool {
+	return setReason(target, e.code)
+}
+
+// reasonWrapper adds a reason code to an error that does not carry one,
+// without changing its text.
+type reasonWrapper struct {
+	err  error
+	code ReasonCode
+}
+
+func (e *reasonWrapper) Error() string {
+	return e.err.Error()
+}
+
+func (e *reasonWrapper) Unwrap() error {
+	return e.err
+}
+
+// As sets target to the reason code of the error if it is a
+// [*ReasonCode].
+func (e *reasonWrapper) As(target any) bool {
+	return setReason(target, e.code)
+}
+
+// withReason adds the reason code determined by [Reason] to err, if err
+// does not already carry one and can be classified. If err is an
+// [*os.PathError], the code is added to the error it wraps, so that the
+// caller still receives an [*os.PathError].
+//
+// The returned error continues to match everything that err matched.
+func withReason(err error) error {
+	if err == nil || hasReason(err) {
+		return err
+	}
+
+	code := Reason(err)
+	if code == ReasonUnknown {
+		return err
+	}
+
+	if pathErr, ok := err.(*os.PathError); ok {
+		return &os.PathError{
+			Op:   pathErr.Op,
+			Path: pathErr.Path,
+			Err:  &reasonWrapper{err: pathErr.Err, code: code},
+		}
+	}
+
+	return &reasonWrapper{err: err, code: code}
+}
+
+// hasReason returns true if err carries a reason code.
+func hasReason(err error) bool {
+	var code ReasonCode
+	return errors.As(err, &code)
+}
+
+// setReason sets target to code if it is a [*ReasonCode], and reports
+// whether it did. It implements the As methods of the error types of this
+// package.
+func setReason(target any, code ReasonCode) bool {
+	r, ok := target.(*ReasonCode)
+	if ok {
+		*r = code
+	}
+	return ok
+}
@@ -0,0 +1,124 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestReasonCode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reason.lock")
+
+	held, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer held.Close()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		err  func() error
+		want lockfile.ReasonCode
+	}{
+		{"Contended", func() error {
+			_, err := lockfile.Create(path)
+			return err
+		}, lockfile.ReasonContended},
+		{"Cancelled", func() error {
+			_, err := lockfile.WaitCtx(cancelled, path)
+			return err
+		}, lockfile.ReasonCancelled},
+		{"Invalid", func() error {
+			_, err := lockfile.Create(path, lockfile.WithTraceID("not valid"))
+			return err
+		}, lockfile.ReasonInvalid},
+		{"Hang", func() error {
+			return &lockfile.HangError{Op: lockfile.OpOpen, Path: path}
+		}, lockfile.ReasonTimeout},
+		{"Wrapped", func() error {
+			return &os.PathError{Op: "steal", Path: path, Err: lockfile.ErrNotStale}
+		}, lockfile.ReasonContended},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.err()
+			var code lockfile.ReasonCode
+			if !errors.As(err, &code) {
+				t.Fatalf("the error carries no reason code: %v", err)
+			}
+			if code != test.want {
+				t.Fatalf("the error has reason %s, expected %s: %v", code, test.want, err)
+			}
+			if got := lockfile.Reason(err); got != test.want {
+				t.Fatalf("Reason returned %s, expected %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReasonCodeMatching(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reason.lock")
+
+	held, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer held.Close()
+
+	// Adding a reason code must not hide the errors that are wrapped.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := lockfile.WaitCtx(ctx, path); !errors.Is(err, context.Canceled) {
+		t.Fatalf("WaitCtx returned %v, expected an error that wraps %v", err, context.Canceled)
+	}
+	if _, err := lockfile.Create(path); !lockfile.IsTemporary(err) {
+		t.Fatalf("Create returned %v, expected a temporary error", err)
+	}
+}
+
+func TestReasonOther(t *testing.T) {
+	tests := []struct {
+		err  error
+		want lockfile.ReasonCode
+	}{
+		{nil, lockfile.ReasonUnknown},
+		{errors.New("other"), lockfile.ReasonUnknown},
+		{context.DeadlineExceeded, lockfile.ReasonTimeout},
+		{&os.PathError{Op: "open", Path: "x", Err: os.ErrExist}, lockfile.ReasonContended},
+		{os.ErrClosed, lockfile.ReasonNotHeld},
+	}
+
+	for _, test := range tests {
+		if got := lockfile.Reason(test.err); got != test.want {
+			t.Errorf("Reason(%v) returned %s, expected %s", test.err, got, test.want)
+		}
+	}
+}
+
+func TestReasonCodeString(t *testing.T) {
+	tests := []struct {
+		code lockfile.ReasonCode
+		want string
+	}{
+		{lockfile.ReasonUnknown, "unknown"},
+		{lockfile.ReasonContended, "contended"},
+		{lockfile.ReasonUnsafeFilesystem, "unsafe_filesystem"},
+		{lockfile.ReasonPermissionDenied, "permission_denied"},
+		{lockfile.ReasonCode(-1), "unknown"},
+		{lockfile.ReasonCode(1000), "unknown"},
+	}
+
+	for _, test := range tests {
+		if got := test.code.String(); got != test.want {
+			t.Errorf("ReasonCode(%d).String() returned %q, expected %q", int(test.code), got, test.want)
+		}
+	}
+}
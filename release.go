@@ -0,0 +1,71 @@
+package lockfile
+
+// Stage identifies a step in the release of a lock, as reported to the
+// callbacks registered with [File.OnRelease].
+type Stage int
+
+const (
+	// StageBeforeRelease is reported when the lock is about to be
+	// released. The lock is still held, and the lock file is still in
+	// place.
+	StageBeforeRelease Stage = iota
+
+	// StageUnlinked is reported once the lock file has been removed from
+	// its path. New contenders may create a new lock file from this point
+	// on. On Linux the lock on the removed file is still held, so nothing
+	// else can have acquired it. On Windows the lock file is removed when
+	// the lock is released, so this stage is reported just before
+	// StageReleased.
+	//
+	// It is not reported if the lock file was not removed, such as when
+	// other holders of a shared lock remain, or when the lock file was
+	// moved while it was held.
+	StageUnlinked
+
+	// StageReleased is reported once the lock has been released, whether
+	// or not the release succeeded.
+	StageReleased
+)
+
+// String returns a description of the stage.
+func (s Stage) String() string {
+	switch s {
+	case StageBeforeRelease:
+		return "before release"
+	case StageUnlinked:
+		return "unlinked"
+	case StageReleased:
+		return "released"
+	}
+	return "unknown"
+}
+
+// OnRelease registers fn to be called at each stage of the release of the
+// lock held by f, so that applications can act at a precise moment in its
+// lifetime, such as flushing caches before the lock is released, or
+// notifying peers as soon as the lock file is gone.
+//
+// Callbacks are shared by every reference to the lock created by
+// [File.Dup], and are called when the lock itself is released, in the
+// order they were registered. They are called while the lock is being
+// released, so they must not call methods of the [File] that affect it,
+// such as Close or Dup. Callbacks registered after the lock has been
+// released are never called.
+func (f *File) OnRelease(fn func(stage Stage)) {
+	f.h.mutex.Lock()
+	defer f.h.mutex.Unlock()
+
+	if f.h.refs == 0 {
+		return
+	}
+	f.h.onRelease = append(f.h.onRelease, fn)
+}
+
+// notify calls the callbacks registered with [File.OnRelease] for stage.
+//
+// The caller must hold h.mutex.
+func (h *lockHandle) notify(stage Stage) {
+	for _, fn := range h.onRelease {
+		fn(stage)
+	}
+}
@@ -0,0 +1,84 @@
+package lockfile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestOnRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "release.lock")
+
+	file, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dup, err := file.Dup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stages []lockfile.Stage
+	file.OnRelease(func(stage lockfile.Stage) {
+		stages = append(stages, stage)
+
+		_, statErr := os.Stat(path)
+		switch stage {
+		case lockfile.StageBeforeRelease:
+			if statErr != nil {
+				t.Errorf("the lock file was removed before it was released: %v", statErr)
+			}
+		case lockfile.StageUnlinked, lockfile.StageReleased:
+			if !errors.Is(statErr, os.ErrNotExist) {
+				t.Errorf("the lock file is still in place at stage %s: %v", stage, statErr)
+			}
+		}
+	})
+
+	// Callbacks are only called once the last reference is closed.
+	if err := dup.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(stages) != 0 {
+		t.Fatalf("callbacks were called before the lock was released: %v", stages)
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := []lockfile.Stage{lockfile.StageBeforeRelease, lockfile.StageUnlinked, lockfile.StageReleased}
+	if !slices.Equal(stages, want) {
+		t.Fatalf("stages %v, want %v", stages, want)
+	}
+
+	// Callbacks registered after the lock was released are never called.
+	file.OnRelease(func(stage lockfile.Stage) {
+		t.Errorf("unexpected callback after release: %s", stage)
+	})
+}
+
+func TestOnReleaseSoft(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "release.lock")
+
+	file, err := lockfile.Create(path, lockfile.WithSoftLock(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stages []lockfile.Stage
+	file.OnRelease(func(stage lockfile.Stage) {
+		stages = append(stages, stage)
+	})
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []lockfile.Stage{lockfile.StageBeforeRelease, lockfile.StageUnlinked, lockfile.StageReleased}
+	if !slices.Equal(stages, want) {
+		t.Fatalf("stages %v, want %v", stages, want)
+	}
+}
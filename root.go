@@ -0,0 +1,266 @@
+package lockfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// lockSuffix is the suffix of the lock files in the root directory of a
+// [Manager]. Other files in the directory, such as the companion files of
+// lock files, are ignored.
+const lockSuffix = ".lock"
+
+// NewDirManager returns a [Manager] for a directory of named lock files,
+// which acquires them with the given options. The directory is created if
+// it does not exist.
+//
+// The paths given to [Manager.Create] and [Manager.Wait] of the returned
+// Manager are names rather than paths. Each name is escaped, so that any
+// string may be used as a name, and stored as a lock file with a ".lock"
+// suffix in root. Names are listed by [Manager.List]. Names that differ
+// only in case refer to the same lock file on filesystems that are not
+// case-sensitive. A name whose escaped form is too long to be used as a
+// file name is rejected with an error that wraps [ErrNameTooLong].
+func NewDirManager(root string, opts ...Option) (*Manager, error) {
+	if root == "" {
+		return nil, ErrEmptyPath
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, &os.PathError{Op: "abs", Path: root, Err: err}
+	}
+	if err := os.MkdirAll(abs, 0755); err != nil {
+		return nil, err
+	}
+
+	m := NewManager(opts...)
+	if m.cfg.err != nil {
+		return nil, m.cfg.err
+	}
+	m.root = abs
+	return m, nil
+}
+
+// Root returns the directory of named lock files of the manager, or an
+// empty string if it acquires lock files by path.
+func (m *Manager) Root() string {
+	return m.root
+}
+
+// Acquire makes a single attempt to acquire the lock file with the given
+// name. It is equivalent to [Manager.Create].
+func (m *Manager) Acquire(name string) (*File, error) {
+	return m.Create(name)
+}
+
+// List returns the names of the lock files in the root directory of the
+// manager, in sorted order. The lock files are not necessarily held.
+//
+// It returns an error that wraps [ErrInvalidOption] if the manager does
+// not have a root directory.
+func (m *Manager) List() ([]string, error) {
+	if m.root == "" {
+		return nil, fmt.Errorf("%w: the manager does not have a root directory", ErrInvalidOption)
+	}
+
+	entries, err := os.ReadDir(m.root)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		base, found := strings.CutSuffix(entry.Name(), lockSuffix)
+		if !found || entry.IsDir() {
+			continue
+		}
+		if name, ok := unescapeName(base); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Clean removes the lock files in the root directory of the manager that
+// are not held, such as those left behind by holders that crashed, and
+// returns their names. Each lock file is removed by acquiring and
+// releasing it, so a lock file that is held is never disturbed.
+//
+// It returns an error that wraps [ErrInvalidOption] if the manager does
+// not have a root directory. Lock files that cannot be removed for other
+// reasons than being held are reported in the returned error, but do not
+// stop the rest from being removed.
+//
+// In dry-run mode, as set by [WithDryRun], the lock files that are not
+// held are recorded in the plan instead of being removed, and their names
+// are returned.
+func (m *Manager) Clean() ([]string, error) {
+	names, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		removed []string
+		errs    []error
+	)
+	for _, name := range names {
+		path := filepath.Join(m.root, escapeName(name)+lockSuffix)
+		if m.cfg.dryRun != nil {
+			exists, held, err := m.cfg.probe(path)
+			switch {
+			case err != nil:
+				errs = append(errs, err)
+			case exists && !held:
+				m.cfg.dryRun.record(Action{
+					Op:     "remove",
+					Path:   path,
+					Reason: "the lock file is not held",
+				})
+				removed = append(removed, name)
+			}
+			continue
+		}
+
+		file, err := m.cfg.create(path)
+		if err != nil {
+			if !m.cfg.isTemporary(err) && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if err := file.Close(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed = append(removed, name)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// resolve returns the path of the lock file with the given name, if the
+// manager has a root directory. Otherwise name is a path, and is returned
+// unchanged.
+func (m *Manager) resolve(name string) (string, error) {
+	if m.root == "" {
+		return name, nil
+	}
+	if name == "" {
+		return "", ErrEmptyPath
+	}
+	base := escapeName(name) + lockSuffix
+	if len(base) > maxBaseName {
+		return "", &os.PathError{Op: "resolve", Path: name, Err: ErrNameTooLong}
+	}
+	return filepath.Join(m.root, base), nil
+}
+
+// nameOf returns the name of the lock file at path, if it is a lock file in
+// the root directory of the manager.
+func (m *Manager) nameOf(path string) (string, bool) {
+	if m.root == "" || filepath.Dir(path) != m.root {
+		return "", false
+	}
+	base, found := strings.CutSuffix(filepath.Base(path), lockSuffix)
+	if !found {
+		return "", false
+	}
+	return unescapeName(base)
+}
+
+// maxBaseName is the length in bytes of the longest base name of a lock
+// file in the root directory of a manager. Most filesystems do not support
+// longer file names.
+const maxBaseName = 255
+
+// escapeName escapes name so that it can be used as the base name of a
+// file, with a ".lock" suffix, on any platform. Letters, digits, '-' and
+// '_' are kept, as are dots other than a leading one. Every other byte is
+// escaped as '%' followed by two hexadecimal digits, as is the first byte
+// of a name that Windows reserves for a device, such as CON or LPT1.
+//
+// Letters keep their case, so names that differ only in case are escaped
+// to base names that collide on filesystems that are not case-sensitive.
+// The escaped name may be up to three times as long as name, and the
+// caller is responsible for checking it against maxBaseName.
+func escapeName(name string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case i == 0 && reservedName(name):
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '_':
+			b.WriteByte(c)
+			continue
+		case c == '.' && i > 0:
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0xF])
+	}
+	return b.String()
+}
+
+// reservedName returns true if name refers to a device on Windows, such as
+// CON or LPT1, regardless of its case and of any extension that follows it.
+func reservedName(name string) bool {
+	stem, _, _ := strings.Cut(name, ".")
+	switch strings.ToUpper(stem) {
+	case "CON", "PRN", "AUX", "NUL":
+		return true
+	}
+	if len(stem) != 4 || stem[3] < '1' || stem[3] > '9' {
+		return false
+	}
+	switch strings.ToUpper(stem[:3]) {
+	case "COM", "LPT":
+		return true
+	}
+	return false
+}
+
+// unescapeName reverses escapeName. It returns false if escaped is not a
+// valid escaped name.
+func unescapeName(escaped string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		c := escaped[i]
+		if c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		if i+2 >= len(escaped) {
+			return "", false
+		}
+		hi, ok1 := unhex(escaped[i+1])
+		lo, ok2 := unhex(escaped[i+2])
+		if !ok1 || !ok2 {
+			return "", false
+		}
+		b.WriteByte(hi<<4 | lo)
+		i += 2
+	}
+	return b.String(), b.Len() > 0
+}
+
+// unhex returns the value of the hexadecimal digit c.
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	}
+	return 0, false
+}
@@ -0,0 +1,290 @@
+package lockfile
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Rotation shares a lock file among a group of processes that take turns
+// holding it for a fixed time slice each, such as agents that each get
+// exclusive use of a device for at most 30 seconds at a time.
+//
+// Each turn is held as a [Lease] that lasts one time slice and is never
+// renewed. Participants on other hosts, whose locks may not be visible to
+// this one, and participants that use soft lock files, as described by
+// [WithSoftLock], treat the lock file as free once the lease has expired,
+// so a participant that overruns its turn or hangs cannot keep it from
+// them. Within a host, the lock itself is held until the participant
+// returns, so participants there rely on it returning promptly once its
+// turn ends.
+//
+// Participants queue for their turn in arrival order. A participant that
+// finishes its turn and wants another joins the back of the queue, so
+// every participant that is waiting gets a turn before anyone gets a
+// second one. The queue is a directory of tickets next to the lock file,
+// named after it with a ".rotation" suffix.
+//
+// A Rotation is safe for concurrent use. Each call to [Rotation.Take] is a
+// separate participant.
+type Rotation struct {
+	path  string
+	slice time.Duration
+	cfg   *config
+}
+
+// NewRotation returns a [Rotation] for the lock file with the given path,
+// which gives each participant turns of the given length. The options are
+// applied to each acquisition of the lock file.
+//
+// It returns an error that wraps [ErrInvalidOption] if slice is not
+// positive, or if the options are invalid.
+func NewRotation(path string, slice time.Duration, opts ...Option) (*Rotation, error) {
+	if path == "" {
+		return nil, ErrEmptyPath
+	}
+	if slice <= 0 {
+		return nil, fmt.Errorf("%w: the time slice must be positive", ErrInvalidOption)
+	}
+
+	cfg := newConfig(withLease(opts, slice))
+	if cfg.err != nil {
+		return nil, cfg.err
+	}
+
+	return &Rotation{path: path, slice: slice, cfg: cfg}, nil
+}
+
+// Path returns the path of the lock file.
+func (r *Rotation) Path() string {
+	return r.path
+}
+
+// Slice returns the length of each turn.
+func (r *Rotation) Slice() time.Duration {
+	return r.slice
+}
+
+// Take waits for the next turn of the caller, and calls fn while holding
+// the lock file. The context passed to fn is cancelled when the time slice
+// ends, at which point the lease on the turn expires, and fn must return
+// promptly once it is. The lock file is released when fn returns, even if
+// it overruns its time slice.
+//
+// The provided context governs both waiting and the turn itself. If the
+// lock file cannot be released, the error from [File.Close] is returned,
+// unless fn returned an error of its own. If the lease cannot be recorded
+// in the lock file, it returns an error that wraps [ErrMetadataUnwritable]
+// without calling fn.
+func (r *Rotation) Take(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	ticket, err := r.enqueue()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		os.Remove(ticket)
+		os.Remove(r.queueDir()) // Fails harmlessly if others are queued
+	}()
+
+	file, err := r.waitTurn(ctx, ticket)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	// Leave the queue, so that the next participant moves to the front.
+	os.Remove(ticket)
+
+	// The turn ends when its lease expires, which is when other
+	// participants may break it.
+	expires := file.h.expires
+	if expires.IsZero() {
+		return &os.PathError{Op: "lease", Path: r.path, Err: ErrMetadataUnwritable}
+	}
+	turn, cancel := context.WithDeadline(ctx, expires)
+	defer cancel()
+
+	return fn(turn)
+}
+
+// queueDir returns the directory that holds the tickets of the rotation.
+func (r *Rotation) queueDir() string {
+	return rotationDir(r.path)
+}
+
+// rotationDir returns the directory that holds the tickets of a rotation of
+// the lock file at path.
+func rotationDir(path string) string {
+	return path + ".rotation"
+}
+
+// enqueue adds a ticket for a new participant to the back of the queue,
+// and returns its path.
+func (r *Rotation) enqueue() (string, error) {
+	return enqueueTicket(r.queueDir())
+}
+
+// Tickets in an arrival-ordered queue are written atomically, so a ticket
+// that cannot be read was left behind by a waiter that failed to write it,
+// or is being written by an older version of this package. It holds its
+// place in the queue for queueTicketGrace before it is removed.
+//
+// Waiters touch their tickets each time they check the queue, and a ticket
+// that has not been touched for queueTicketStale is removed, so that the
+// tickets of waiters on other hosts that have exited do not block the
+// queue forever.
+const (
+	queueTicketGrace = time.Second
+	queueTicketStale = 30 * time.Second
+)
+
+// enqueueTicket adds a ticket for the current process to the back of the
+// arrival-ordered queue in dir, and returns its path.
+//
+// Ticket names begin with the time at which they were created, so that
+// they sort in arrival order.
+func enqueueTicket(dir string) (string, error) {
+	data, err := json.Marshal(WaitTicket{
+		Holder: CurrentHolder(),
+		Since:  time.Now(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var id [4]byte
+	rand.Read(id[:])
+	name := filepath.Join(dir, fmt.Sprintf("%020d-%s.json", time.Now().UnixNano(), hex.EncodeToString(id[:])))
+
+	// The directory may be removed by another participant between its
+	// creation and the creation of the ticket, so try again once if that
+	// happens.
+	for attempt := 0; ; attempt++ {
+		if err = os.MkdirAll(dir, 0755); err == nil {
+			err = writeFileAtomic(name, data)
+		}
+		if err == nil || attempt > 0 || !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// waitTurn waits until ticket is at the front of the queue and the lock
+// file has been acquired.
+func (r *Rotation) waitTurn(ctx context.Context, ticket string) (*File, error) {
+	var timer *time.Timer
+	for attempt := 0; ; attempt++ {
+		if err := evicted(r.path, ticket); err != nil {
+			return nil, err
+		}
+		touchTicket(ticket)
+
+		front, err := r.front()
+		if err != nil {
+			return nil, err
+		}
+
+		if front == ticket {
+			file, err := r.cfg.create(r.path)
+			if err == nil {
+				return file, nil
+			}
+			if !r.cfg.isTemporary(err) {
+				return nil, err
+			}
+		}
+
+		// The queue moves once per time slice at most, so there is no
+		// need to back off as far as a plain waiter would.
+		delay := randomBackoff(min(attempt, 9))
+		if timer == nil {
+			timer = time.NewTimer(delay)
+			defer timer.Stop()
+		} else {
+			timer.Reset(delay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, withReason(ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// front returns the path of the ticket at the front of the queue.
+func (r *Rotation) front() (string, error) {
+	return queueFront(r.queueDir())
+}
+
+// touchTicket marks the ticket with the given path as belonging to a
+// waiter that is still waiting. Failures are ignored, because a ticket that
+// cannot be touched is only removed once it is stale, and its waiter is
+// then evicted.
+func touchTicket(name string) {
+	now := time.Now()
+	os.Chtimes(name, now, now)
+}
+
+// queueFront returns the path of the ticket at the front of the
+// arrival-ordered queue in dir, or an empty string if it is empty.
+// Tickets that were left behind by processes on this host that have
+// exited, tickets that are stale and tickets that have been unreadable for
+// longer than the grace period are removed.
+func queueFront(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	hostname, _ := os.Hostname()
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		name := filepath.Join(dir, entry.Name())
+
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed since the directory was read
+		}
+		age := time.Since(info.ModTime())
+
+		var ticket WaitTicket
+		data, err := os.ReadFile(name)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			continue
+		case err != nil || json.Unmarshal(data, &ticket) != nil:
+			if age > queueTicketGrace {
+				os.Remove(name)
+				continue
+			}
+		case age > queueTicketStale:
+			os.Remove(name)
+			continue
+		case ticket.Holder.Hostname == hostname && !processAlive(ticket.Holder.PID):
+			os.Remove(name)
+			continue
+		}
+
+		return name, nil
+	}
+
+	return "", nil
+}
@@ -0,0 +1,133 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestRotationTakesTurns(t *testing.T) {
+	r, err := lockfile.NewRotation(filepath.Join(t.TempDir(), "device.lock"), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	const turns = 3
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+		order []int
+	)
+	for id := range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range turns {
+				err := r.Take(ctx, func(ctx context.Context) error {
+					mutex.Lock()
+					order = append(order, id)
+					mutex.Unlock()
+
+					// Hold the turn long enough for the other participant
+					// to join the queue.
+					time.Sleep(time.Millisecond * 50)
+					return nil
+				})
+				if err != nil {
+					t.Errorf("Take failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(order) != turns*2 {
+		t.Fatalf("unexpected number of turns: %v", order)
+	}
+	for i := 1; i < len(order); i++ {
+		if order[i] == order[i-1] {
+			t.Fatalf("participant %d took two turns in a row: %v", order[i], order)
+		}
+	}
+}
+
+func TestRotationSliceEnds(t *testing.T) {
+	r, err := lockfile.NewRotation(filepath.Join(t.TempDir(), "device.lock"), time.Millisecond*50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	err = r.Take(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Take returned %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second*2 {
+		t.Fatalf("the turn was not ended by its time slice: %v", elapsed)
+	}
+}
+
+func TestRotationOverrunIsBroken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "device.lock")
+	soft := lockfile.WithSoftLock(time.Hour)
+	overrunner, err := lockfile.NewRotation(path, time.Millisecond*100, soft)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := lockfile.NewRotation(path, time.Millisecond*100, soft)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// The first participant ignores the end of its turn, and only returns
+	// once the next participant has had its own.
+	started := make(chan struct{})
+	taken := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- overrunner.Take(ctx, func(context.Context) error {
+			close(started)
+			select {
+			case <-taken:
+			case <-ctx.Done():
+			}
+			return nil
+		})
+	}()
+	<-started
+
+	err = next.Take(ctx, func(context.Context) error {
+		close(taken)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("the next participant did not get its turn: %v", err)
+	}
+
+	// The overrunner's lock file was broken while it held it, which it
+	// finds when it releases it.
+	if err := <-done; err == nil {
+		t.Fatal("expected the overrunner to find its lock file broken")
+	}
+}
+
+func TestNewRotationInvalid(t *testing.T) {
+	if _, err := lockfile.NewRotation("device.lock", 0); !errors.Is(err, lockfile.ErrInvalidOption) {
+		t.Fatalf("NewRotation returned %v for a zero time slice", err)
+	}
+}
@@ -0,0 +1,56 @@
+package lockfile
+
+import (
+	"context"
+	"os/exec"
+)
+
+// Run acquires the lock file with the given path, starts cmd, and holds the
+// lock until cmd exits. It waits for the lock like [WaitCtx], and the
+// options are applied in the same way.
+//
+// The provided context only governs acquisition of the lock. To stop the
+// command when a context is cancelled, create it with
+// [exec.CommandContext].
+//
+// If the command runs and exits with a non-zero status, the returned error
+// is an [*exec.ExitError], which reports the exit code of the command. If
+// the command succeeds but the lock file cannot be released, the error
+// from [File.Close] is returned.
+func Run(ctx context.Context, path string, cmd *exec.Cmd, opts ...Option) (err error) {
+	file, err := WaitCtx(ctx, path, opts...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	return cmd.Run()
+}
+
+// WithLock acquires the lock file with the given path, calls fn while
+// holding it, and releases it when fn returns. It waits for the lock like
+// [WaitCtx], and the options are applied in the same way.
+//
+// The lock file is released even if fn panics, in which case the panic
+// continues once it has been. The provided context governs acquisition of
+// the lock, and is passed to fn.
+//
+// If fn returns an error, it is returned. Otherwise, if the lock file
+// cannot be released, the error from [File.Close] is returned.
+func WithLock(ctx context.Context, path string, fn func(ctx context.Context) error, opts ...Option) (err error) {
+	file, err := WaitCtx(ctx, path, opts...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	return fn(ctx)
+}
@@ -0,0 +1,92 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+// envRunChild is set when the test binary is run as a child process by
+// TestRun.
+const envRunChild = "LOCKFILE_TEST_RUN_CHILD"
+
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.lock")
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRunChild$")
+	cmd.Env = append(os.Environ(), envRunChild+"="+path)
+
+	err := lockfile.Run(context.Background(), path, cmd)
+
+	// The child exits with status 3 if it observed the lock being held.
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Fatalf("expected exit code 3 from the child, got: %v", err)
+	}
+
+	// The lock must have been released when the child exited.
+	file, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("the lock was not released after the child exited: %v", err)
+	}
+	file.Close()
+}
+
+func TestRunChild(t *testing.T) {
+	path := os.Getenv(envRunChild)
+	if path == "" {
+		t.Skip("only run as a child process by TestRun")
+	}
+
+	if _, err := lockfile.Create(path); lockfile.IsTemporary(err) {
+		os.Exit(3)
+	}
+	os.Exit(1)
+}
+
+func TestWithLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "with.lock")
+
+	errFailed := errors.New("failed")
+	err := lockfile.WithLock(context.Background(), path, func(ctx context.Context) error {
+		if _, err := lockfile.Create(path); !lockfile.IsTemporary(err) {
+			t.Errorf("the lock file was not held while fn ran: %v", err)
+		}
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("expected the error from fn, got: %v", err)
+	}
+
+	file, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("the lock was not released after fn returned: %v", err)
+	}
+	file.Close()
+}
+
+func TestWithLockPanic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "with.lock")
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the panic in fn was not propagated")
+			}
+		}()
+		lockfile.WithLock(context.Background(), path, func(ctx context.Context) error {
+			panic("boom")
+		})
+	}()
+
+	file, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("the lock was not released after fn panicked: %v", err)
+	}
+	file.Close()
+}
@@ -0,0 +1,261 @@
+package lockfile
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+// An RWLock is a pair of byte-range locks in a small coordination file.
+// The data byte is locked shared by readers and exclusively by a writer.
+// The gate byte is locked exclusively, and briefly, by anyone who wants
+// exclusive access: a writer while it tries to lock the data byte, and a
+// reader while it upgrades. Holding the gate during an upgrade keeps
+// writers out of any window in which the reader's lock has to be given up
+// and taken again, and allows only one reader to upgrade at a time.
+
+const (
+	rwLockData = 0
+	rwLockGate = 1
+)
+
+// rwState is the kind of lock held by an [RWLock].
+type rwState int
+
+const (
+	rwUnlocked rwState = iota
+	rwRead
+	rwWrite
+)
+
+// RWLock is a reader/writer lock that is shared by cooperating processes.
+//
+// Any number of processes can hold an RWLock for reading at once, while a
+// process that holds it for writing excludes all others. A reader can
+// atomically upgrade to a writer with [RWLock.TryUpgrade], and a writer can
+// atomically downgrade to a reader with [RWLock.Downgrade], without a
+// window in which another writer could acquire the lock.
+//
+// An RWLock is identified by the path of its coordination file, which is
+// not deleted when the lock is closed. The locks are held by the open file,
+// so the operating system releases them if the process exits.
+// They are only available on Linux and Windows. On other platforms,
+// [OpenRWLock] returns [ErrRangeLocksUnsupported].
+//
+// Each RWLock is a single holder: it is either unlocked, or locked for
+// reading or writing. Its methods are safe for concurrent use, but
+// goroutines that need to hold the lock independently should each open
+// their own RWLock.
+type RWLock struct {
+	path  string
+	mutex sync.Mutex
+	file  *os.File
+	state rwState
+}
+
+// OpenRWLock opens the reader/writer lock whose coordination file has the
+// given path, creating the file if necessary.
+func OpenRWLock(path string) (*RWLock, error) {
+	if path == "" {
+		return nil, ErrEmptyPath
+	}
+	if !rangeLocksPerFile {
+		return nil, ErrRangeLocksUnsupported
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RWLock{path: path, file: file}, nil
+}
+
+// Path returns the path of the coordination file.
+func (l *RWLock) Path() string {
+	return l.path
+}
+
+// RLock acquires the lock for reading, waiting until no writer holds it or
+// ctx is cancelled.
+func (l *RWLock) RLock(ctx context.Context) error {
+	return l.acquire(ctx, "rlock", rwRead, func() (bool, error) {
+		return tryLockRange(l.file, rwLockData, false)
+	})
+}
+
+// Lock acquires the lock for writing, waiting until no other reader or
+// writer holds it or ctx is cancelled.
+func (l *RWLock) Lock(ctx context.Context) error {
+	return l.acquire(ctx, "lock", rwWrite, func() (bool, error) {
+		ok, err := tryLockRange(l.file, rwLockGate, true)
+		if !ok || err != nil {
+			return false, err
+		}
+		defer unlockRange(l.file, rwLockGate)
+
+		return tryLockRange(l.file, rwLockData, true)
+	})
+}
+
+// RUnlock releases the lock after it was acquired for reading.
+//
+// It returns an [*os.PathError] that wraps [ErrNotHeld] if the lock is not
+// held for reading.
+func (l *RWLock) RUnlock() error {
+	return l.release("runlock", rwRead)
+}
+
+// Unlock releases the lock after it was acquired for writing.
+//
+// It returns an [*os.PathError] that wraps [ErrNotHeld] if the lock is not
+// held for writing.
+func (l *RWLock) Unlock() error {
+	return l.release("unlock", rwWrite)
+}
+
+// TryUpgrade attempts to convert a lock held for reading into a lock held
+// for writing, without releasing it. It returns true if the lock is now
+// held for writing. It returns false if other readers hold the lock, or if
+// another process is acquiring it for writing, in which case the lock
+// remains held for reading.
+//
+// It returns an [*os.PathError] that wraps [ErrNotHeld] if the lock is not
+// held for reading.
+func (l *RWLock) TryUpgrade() (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if err := l.check("upgrade", rwRead); err != nil {
+		return false, err
+	}
+
+	ok, err := tryLockRange(l.file, rwLockGate, true)
+	if !ok || err != nil {
+		return false, pathError("upgrade", l.path, err)
+	}
+	defer unlockRange(l.file, rwLockGate)
+
+	ok, err = upgradeRange(l.file, rwLockData)
+	if err != nil {
+		// The read lock could not be restored.
+		l.state = rwUnlocked
+		return false, pathError("upgrade", l.path, err)
+	}
+	if ok {
+		l.state = rwWrite
+	}
+	return ok, nil
+}
+
+// Downgrade converts a lock held for writing into a lock held for reading,
+// without releasing it. Other readers may acquire the lock once it returns,
+// but writers remain excluded until it is released.
+//
+// It returns an [*os.PathError] that wraps [ErrNotHeld] if the lock is not
+// held for writing.
+func (l *RWLock) Downgrade() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if err := l.check("downgrade", rwWrite); err != nil {
+		return err
+	}
+
+	if err := downgradeRange(l.file, rwLockData); err != nil {
+		return pathError("downgrade", l.path, err)
+	}
+	l.state = rwRead
+	return nil
+}
+
+// Close closes the coordination file, which releases the lock if it is
+// held.
+//
+// It returns an [*os.PathError] that wraps [os.ErrClosed] if the function
+// has already been called.
+func (l *RWLock) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.file == nil {
+		return &os.PathError{Op: "close", Path: l.path, Err: os.ErrClosed}
+	}
+
+	err := l.file.Close()
+	l.file = nil
+	l.state = rwUnlocked
+	return err
+}
+
+// acquire waits for try to acquire the lock, polling with a random backoff
+// until it succeeds or ctx is cancelled.
+func (l *RWLock) acquire(ctx context.Context, op string, state rwState, try func() (bool, error)) error {
+	var timer *time.Timer
+	for attempt := 0; ; attempt++ {
+		ok, err := l.tryAcquire(op, state, try)
+		if ok || err != nil {
+			return err
+		}
+
+		if timer == nil {
+			timer = time.NewTimer(randomBackoff(attempt))
+			defer timer.Stop()
+		} else {
+			timer.Reset(randomBackoff(attempt))
+		}
+
+		select {
+		case <-ctx.Done():
+			return withReason(ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// tryAcquire makes a single attempt to acquire the lock.
+func (l *RWLock) tryAcquire(op string, state rwState, try func() (bool, error)) (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.file == nil {
+		return false, &os.PathError{Op: op, Path: l.path, Err: os.ErrClosed}
+	}
+	if l.state != rwUnlocked {
+		return false, &os.PathError{Op: op, Path: l.path, Err: ErrAlreadyHeld}
+	}
+
+	ok, err := try()
+	if err != nil {
+		return false, pathError(op, l.path, err)
+	}
+	if ok {
+		l.state = state
+	}
+	return ok, nil
+}
+
+// release releases the lock if it is held in the given state.
+func (l *RWLock) release(op string, state rwState) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if err := l.check(op, state); err != nil {
+		return err
+	}
+
+	l.state = rwUnlocked
+	return pathError(op, l.path, unlockRange(l.file, rwLockData))
+}
+
+// check returns an error if the lock is not held in the given state.
+func (l *RWLock) check(op string, state rwState) error {
+	if l.file == nil {
+		return &os.PathError{Op: op, Path: l.path, Err: os.ErrClosed}
+	}
+	if l.state != state {
+		return &os.PathError{Op: op, Path: l.path, Err: ErrNotHeld}
+	}
+	return nil
+}
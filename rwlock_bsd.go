@@ -0,0 +1,20 @@
+//go:build darwin || dragonfly || freebsd || openbsd
+
+package lockfile
+
+import "syscall"
+
+// The byte-range locks of a RangeSemaphore are POSIX record locks, which
+// belong to the process rather than the open file. A process cannot
+// contend with itself for them, and closing any of its descriptors for the
+// coordination file releases all of them, so each process must use a
+// single RangeSemaphore per coordination file. An RWLock relies on its
+// locks excluding other goroutines of the same process, so it is not
+// supported.
+
+// rangeLocksPerFile is true if byte-range locks belong to the open file
+// rather than the process on this platform.
+const rangeLocksPerFile = false
+
+// rangeLockCmd is the fcntl command that sets a byte-range lock.
+const rangeLockCmd = syscall.F_SETLK
@@ -0,0 +1,16 @@
+//go:build linux
+
+package lockfile
+
+// The byte-range locks of an RWLock or a RangeSemaphore are open file
+// description locks, which belong to the open file rather than the
+// process. Converting such a lock between shared and exclusive is atomic:
+// if an exclusive lock cannot be granted, the shared lock is kept. See the
+// fcntl(2) man page.
+
+// rangeLocksPerFile is true if byte-range locks belong to the open file
+// rather than the process on this platform.
+const rangeLocksPerFile = true
+
+// rangeLockCmd is the fcntl command that sets a byte-range lock.
+const rangeLockCmd = fOFDSetLk
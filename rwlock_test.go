@@ -0,0 +1,120 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func openRWLock(t *testing.T, path string) *lockfile.RWLock {
+	t.Helper()
+	l, err := lockfile.OpenRWLock(path)
+	if errors.Is(err, lockfile.ErrRangeLocksUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("OpenRWLock failed: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+// tryLock makes a single attempt to acquire a lock with fn.
+func tryLock(fn func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	return fn(ctx)
+}
+
+func TestRWLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rw.lock")
+	a := openRWLock(t, path)
+	b := openRWLock(t, path)
+	c := openRWLock(t, path)
+
+	// Readers share the lock.
+	if err := tryLock(a.RLock); err != nil {
+		t.Fatalf("first RLock failed: %v", err)
+	}
+	if err := tryLock(b.RLock); err != nil {
+		t.Fatalf("second RLock failed: %v", err)
+	}
+
+	// Readers exclude a writer.
+	if err := tryLock(c.Lock); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Lock returned %v while readers held the lock", err)
+	}
+
+	// A reader cannot upgrade while another reader holds the lock.
+	if ok, err := a.TryUpgrade(); ok || err != nil {
+		t.Fatalf("TryUpgrade succeeded with another reader: %v, %v", ok, err)
+	}
+	if err := b.RUnlock(); err != nil {
+		t.Fatalf("RUnlock failed: %v", err)
+	}
+
+	// The reader kept its lock, and can now upgrade.
+	if ok, err := a.TryUpgrade(); !ok || err != nil {
+		t.Fatalf("TryUpgrade failed as the only reader: %v, %v", ok, err)
+	}
+	if err := tryLock(b.RLock); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RLock returned %v while a writer held the lock", err)
+	}
+
+	// Downgrading admits readers but not writers.
+	if err := a.Downgrade(); err != nil {
+		t.Fatalf("Downgrade failed: %v", err)
+	}
+	if err := tryLock(b.RLock); err != nil {
+		t.Fatalf("RLock failed after a downgrade: %v", err)
+	}
+	if err := tryLock(c.Lock); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Lock returned %v after a downgrade", err)
+	}
+
+	if err := a.Unlock(); !errors.Is(err, lockfile.ErrNotHeld) {
+		t.Fatalf("Unlock returned %v for a read lock", err)
+	}
+	if err := a.RUnlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RUnlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tryLock(c.Lock); err != nil {
+		t.Fatalf("Lock failed once the readers were gone: %v", err)
+	}
+	if err := c.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRWLockClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rw.lock")
+	a := openRWLock(t, path)
+	b := openRWLock(t, path)
+
+	if err := tryLock(a.Lock); err != nil {
+		t.Fatal(err)
+	}
+	if err := tryLock(a.RLock); !errors.Is(err, lockfile.ErrAlreadyHeld) {
+		t.Fatalf("RLock returned %v while the lock was held", err)
+	}
+
+	// Closing the lock releases it.
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := tryLock(b.Lock); err != nil {
+		t.Fatalf("Lock failed after the holder was closed: %v", err)
+	}
+	if err := a.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("second Close returned %v", err)
+	}
+}
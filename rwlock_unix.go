@@ -0,0 +1,56 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// tryLockRange attempts to lock the byte at offset without waiting. It
+// returns false if the byte is locked by someone else.
+func tryLockRange(f *os.File, offset int64, exclusive bool) (bool, error) {
+	typ := int16(syscall.F_RDLCK)
+	if exclusive {
+		typ = syscall.F_WRLCK
+	}
+
+	err := setRange(f, offset, typ)
+	switch err {
+	case nil:
+		return true, nil
+	case syscall.EAGAIN, syscall.EACCES:
+		return false, nil
+	}
+	return false, err
+}
+
+// unlockRange unlocks the byte at offset.
+func unlockRange(f *os.File, offset int64) error {
+	return setRange(f, offset, syscall.F_UNLCK)
+}
+
+// upgradeRange atomically converts a shared lock on the byte at offset
+// into an exclusive one. It returns false if other holders remain, in which
+// case the shared lock is kept.
+func upgradeRange(f *os.File, offset int64) (bool, error) {
+	return tryLockRange(f, offset, true)
+}
+
+// downgradeRange atomically converts an exclusive lock on the byte at
+// offset into a shared one.
+func downgradeRange(f *os.File, offset int64) error {
+	return setRange(f, offset, syscall.F_RDLCK)
+}
+
+// setRange sets the lock on the byte at offset without waiting.
+func setRange(f *os.File, offset int64, typ int16) error {
+	lk := syscall.Flock_t{
+		Type:   typ,
+		Whence: io.SeekStart,
+		Start:  offset,
+		Len:    1,
+	}
+	return fcntl(int(f.Fd()), rangeLockCmd, &lk)
+}
@@ -0,0 +1,75 @@
+//go:build windows
+
+package lockfile
+
+import (
+	"os"
+	"syscall"
+)
+
+// rangeLocksPerFile is true if byte-range locks belong to the open file
+// rather than the process on this platform.
+const rangeLocksPerFile = true
+
+// Windows does not convert byte-range locks. A handle that holds an
+// exclusive lock may also lock the same range shared, and the exclusive
+// lock is released first when the range is unlocked, which allows an
+// atomic downgrade. An upgrade has to release the shared lock before it
+// tries to lock the range exclusively, which is made safe by the gate.
+
+// tryLockRange attempts to lock the byte at offset without waiting. It
+// returns false if the byte is locked by someone else.
+func tryLockRange(f *os.File, offset int64, exclusive bool) (bool, error) {
+	const ERROR_LOCK_VIOLATION = syscall.Errno(33)
+
+	flags := uint32(lockfileFailImmediately)
+	if exclusive {
+		flags |= lockfileExclusiveLock
+	}
+
+	err := lockFileEx(syscall.Handle(f.Fd()), flags, offset)
+	switch err {
+	case nil:
+		return true, nil
+	case ERROR_LOCK_VIOLATION:
+		return false, nil
+	}
+	return false, err
+}
+
+// unlockRange unlocks the byte at offset.
+func unlockRange(f *os.File, offset int64) error {
+	return unlockFileEx(syscall.Handle(f.Fd()), offset)
+}
+
+// upgradeRange converts a shared lock on the byte at offset into an
+// exclusive one. It returns false if other holders remain, in which case
+// the shared lock is restored. The caller must hold the gate, so that no
+// writer can take the range while it is unlocked.
+func upgradeRange(f *os.File, offset int64) (bool, error) {
+	if err := unlockRange(f, offset); err != nil {
+		return false, err
+	}
+
+	ok, err := tryLockRange(f, offset, true)
+	if ok || err != nil {
+		return ok, err
+	}
+
+	// Only readers can hold the range while we hold the gate, so the
+	// shared lock can always be restored.
+	ok, err = tryLockRange(f, offset, false)
+	if err == nil && !ok {
+		err = ErrNotHeld
+	}
+	return false, err
+}
+
+// downgradeRange atomically converts an exclusive lock on the byte at
+// offset into a shared one.
+func downgradeRange(f *os.File, offset int64) error {
+	if _, err := tryLockRange(f, offset, false); err != nil {
+		return err
+	}
+	return unlockRange(f, offset)
+}
@@ -0,0 +1,120 @@
+package lockfile
+
+import (
+	"os"
+	"time"
+)
+
+// Schedule describes the windows of time during which a lock file may be
+// acquired, such as a nightly maintenance window.
+type Schedule interface {
+	// Window returns the window that contains t, or the first window that
+	// starts after t if t is outside of every window. The window includes
+	// its start and excludes its end.
+	Window(t time.Time) (start, end time.Time)
+}
+
+// Daily returns a [Schedule] with one window each day, starting and ending
+// at the given offsets from midnight in the local time zone. If end is not
+// after start, the window wraps past midnight, so Daily(22*time.Hour,
+// 2*time.Hour) allows acquisition from 22:00 until 02:00 the next day.
+func Daily(start, end time.Duration) Schedule {
+	return daily{start: start, end: end}
+}
+
+// daily implements [Daily].
+type daily struct {
+	start, end time.Duration
+}
+
+func (d daily) Window(t time.Time) (start, end time.Time) {
+	length := d.end - d.start
+	if length <= 0 {
+		length += 24 * time.Hour
+	}
+
+	// A window that contains t started yesterday at the earliest.
+	y, m, day := t.Date()
+	for i := -1; ; i++ {
+		start = time.Date(y, m, day+i, 0, 0, 0, 0, t.Location()).Add(d.start)
+		end = start.Add(length)
+		if end.After(t) {
+			return start, end
+		}
+	}
+}
+
+// WithSchedule returns an option that only allows the lock file to be
+// acquired during the windows of the given schedule.
+//
+// Outside of a window, [Create] returns an error that wraps
+// [ErrOutsideWindow], while [WaitCtx] sleeps until the next window opens
+// before it contends for the lock file. A holder that still holds the lock
+// file when the window closes is notified through the channel returned by
+// [File.ReleaseRequested], but the lock is not taken away from it.
+func WithSchedule(schedule Schedule) Option {
+	return func(c *config) {
+		c.schedule = schedule
+	}
+}
+
+// checkSchedule returns an error if the lock file at path may not be
+// acquired at the current time. Otherwise it returns the end of the
+// current window.
+func (c *config) checkSchedule(path string) (end time.Time, err error) {
+	now := time.Now()
+	start, end := c.schedule.Window(now)
+	if now.Before(start) {
+		return end, &os.PathError{Op: "open", Path: path, Err: ErrOutsideWindow}
+	}
+	return end, nil
+}
+
+// untilWindow returns the time until the next window of the schedule
+// opens.
+func (c *config) untilWindow() time.Duration {
+	now := time.Now()
+	start, _ := c.schedule.Window(now)
+	return max(start.Sub(now), time.Millisecond)
+}
+
+// ReleaseRequested returns a channel that is closed when the holder of the
+// lock is asked to release it, such as when the window of the schedule
+// configured by [WithSchedule] closes. Holders that can stop early should
+// select on the channel and close the lock when it is closed.
+//
+// The channel is shared by f and its duplicates.
+func (f *File) ReleaseRequested() <-chan struct{} {
+	f.h.mutex.Lock()
+	defer f.h.mutex.Unlock()
+
+	if f.h.requested == nil {
+		f.h.requested = make(chan struct{})
+	}
+	return f.h.requested
+}
+
+// requestReleaseAt arranges for release of the lock to be requested at the
+// given time.
+func (h *lockHandle) requestReleaseAt(t time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.requestTimer = time.AfterFunc(time.Until(t), h.requestRelease)
+}
+
+// requestRelease requests release of the lock, if it has not already been
+// requested.
+func (h *lockHandle) requestRelease() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.requested == nil {
+		h.requested = make(chan struct{})
+	}
+	select {
+	case <-h.requested:
+	default:
+		close(h.requested)
+	}
+}
@@ -0,0 +1,82 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+// fixedWindow is a schedule with a single window.
+type fixedWindow struct {
+	start, end time.Time
+}
+
+func (w fixedWindow) Window(t time.Time) (start, end time.Time) {
+	return w.start, w.end
+}
+
+func TestScheduleWait(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "maintenance.lock")
+	opens := time.Now().Add(time.Millisecond * 100)
+	window := lockfile.WithSchedule(fixedWindow{start: opens, end: opens.Add(time.Millisecond * 200)})
+
+	if _, err := lockfile.Create(path, window); !errors.Is(err, lockfile.ErrOutsideWindow) {
+		t.Fatalf("Create returned %v before the window opened", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	file, err := lockfile.WaitCtx(ctx, path, window)
+	if err != nil {
+		t.Fatalf("WaitCtx failed: %v", err)
+	}
+	defer file.Close()
+
+	if time.Now().Before(opens) {
+		t.Fatalf("the lock file was acquired before the window opened")
+	}
+
+	select {
+	case <-file.ReleaseRequested():
+		t.Fatalf("release was requested while the window was open")
+	default:
+	}
+
+	select {
+	case <-file.ReleaseRequested():
+	case <-ctx.Done():
+		t.Fatalf("release was not requested when the window closed")
+	}
+}
+
+func TestDaily(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2024, time.March, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name       string
+		schedule   lockfile.Schedule
+		t          time.Time
+		start, end time.Time
+	}{
+		{"inside", lockfile.Daily(2*time.Hour, 4*time.Hour), at(10, 3), at(10, 2), at(10, 4)},
+		{"before", lockfile.Daily(2*time.Hour, 4*time.Hour), at(10, 1), at(10, 2), at(10, 4)},
+		{"after", lockfile.Daily(2*time.Hour, 4*time.Hour), at(10, 5), at(11, 2), at(11, 4)},
+		{"wrapped", lockfile.Daily(22*time.Hour, 2*time.Hour), at(10, 1), at(9, 22), at(10, 2)},
+		{"wrapped-evening", lockfile.Daily(22*time.Hour, 2*time.Hour), at(10, 23), at(10, 22), at(11, 2)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start, end := test.schedule.Window(test.t)
+			if !start.Equal(test.start) || !end.Equal(test.end) {
+				t.Fatalf("Window(%v) = %v, %v; want %v, %v", test.t, start, end, test.start, test.end)
+			}
+		})
+	}
+}
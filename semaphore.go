@@ -0,0 +1,182 @@
+package lockfile
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Semaphore bounds the number of processes that may work concurrently,
+// such as limiting the number of backups that run at once on a host. It
+// manages a fixed number of slots, each of which is a lock file in a
+// directory, and a process holds a permit while it holds one of them.
+//
+// Waiters queue for permits in arrival order, so a permit that is
+// released goes to the process that has waited longest. The queue is a
+// directory of tickets named "queue" within the directory of the slots.
+//
+// A Semaphore is safe for concurrent use. Each permit counts against the
+// limit, whether it is held by this process or another. A [RangeSemaphore]
+// offers the same limit without creating a lock file for each slot.
+type Semaphore struct {
+	dir   string
+	slots int
+	cfg   *config
+}
+
+// Permit is a slot of a [Semaphore] that is held by the current process.
+// It is released by calling its Close method.
+type Permit struct {
+	*File
+
+	slot int
+}
+
+// Slot returns the index of the slot that the permit holds, from zero to
+// one less than the number of slots of its semaphore.
+func (p *Permit) Slot() int {
+	return p.slot
+}
+
+// NewSemaphore returns a [Semaphore] with the given number of slots, whose
+// lock files are kept in dir. The directory is created if it does not
+// exist. The options are applied to each acquisition of a slot.
+//
+// Every process that shares the semaphore must agree on the number of
+// slots.
+//
+// It returns an error that wraps [ErrInvalidOption] if slots is not
+// positive, or if the options are invalid.
+func NewSemaphore(dir string, slots int, opts ...Option) (*Semaphore, error) {
+	if dir == "" {
+		return nil, ErrEmptyPath
+	}
+	if slots <= 0 {
+		return nil, fmt.Errorf("%w: a semaphore must have at least one slot", ErrInvalidOption)
+	}
+
+	cfg := newConfig(opts)
+	if cfg.err != nil {
+		return nil, cfg.err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &Semaphore{dir: dir, slots: slots, cfg: cfg}, nil
+}
+
+// Dir returns the directory that holds the slots of the semaphore.
+func (s *Semaphore) Dir() string {
+	return s.dir
+}
+
+// Slots returns the number of slots of the semaphore.
+func (s *Semaphore) Slots() int {
+	return s.slots
+}
+
+// TryAcquire makes a single attempt to acquire a permit. It does not jump
+// the queue: if any process is waiting for a permit, it fails even if a
+// slot is free.
+//
+// If no permit is available, it returns an [*os.PathError] for the
+// directory of the semaphore that wraps [os.ErrExist].
+func (s *Semaphore) TryAcquire() (*Permit, error) {
+	front, err := queueFront(s.queueDir())
+	if err != nil {
+		return nil, err
+	}
+	if front != "" {
+		return nil, withReason(&os.PathError{Op: "acquire", Path: s.dir, Err: os.ErrExist})
+	}
+	return s.tryAcquire()
+}
+
+// Acquire waits for a permit, like [WaitCtx] waits for a lock file, until
+// it acquires one, encounters an error that is not temporary or ctx is
+// cancelled.
+//
+// Waiting processes are queued in arrival order, and only the process at
+// the front of the queue attempts to acquire a permit.
+func (s *Semaphore) Acquire(ctx context.Context) (*Permit, error) {
+	permit, err := s.TryAcquire()
+	if err == nil || !s.cfg.isTemporary(err) {
+		return permit, err
+	}
+
+	ticket, err := enqueueTicket(s.queueDir())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		os.Remove(ticket)
+		os.Remove(s.queueDir()) // Fails harmlessly if others are queued
+	}()
+
+	var timer *time.Timer
+	for attempt := 0; ; attempt++ {
+		if err := evicted(s.dir, ticket); err != nil {
+			return nil, err
+		}
+		touchTicket(ticket)
+
+		front, err := queueFront(s.queueDir())
+		if err != nil {
+			return nil, err
+		}
+
+		if front == ticket {
+			permit, err := s.tryAcquire()
+			if err == nil {
+				return permit, nil
+			}
+			if !s.cfg.isTemporary(err) {
+				return nil, err
+			}
+		}
+
+		delay := randomBackoff(attempt)
+		if timer == nil {
+			timer = time.NewTimer(delay)
+			defer timer.Stop()
+		} else {
+			timer.Reset(delay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, withReason(ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// tryAcquire attempts to acquire each slot in turn, and returns a permit
+// for the first one that it acquires.
+func (s *Semaphore) tryAcquire() (*Permit, error) {
+	for slot := range s.slots {
+		file, err := s.cfg.create(s.slotPath(slot))
+		if err == nil {
+			return &Permit{File: file, slot: slot}, nil
+		}
+		if !s.cfg.isTemporary(err) {
+			return nil, err
+		}
+	}
+	return nil, withReason(&os.PathError{Op: "acquire", Path: s.dir, Err: os.ErrExist})
+}
+
+// slotPath returns the path of the lock file for the given slot.
+func (s *Semaphore) slotPath(slot int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("slot-%d%s", slot, lockSuffix))
+}
+
+// queueDir returns the directory that holds the tickets of the processes
+// that are waiting for a permit.
+func (s *Semaphore) queueDir() string {
+	return filepath.Join(s.dir, "queue")
+}
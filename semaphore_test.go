@@ -0,0 +1,164 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestSemaphore(t *testing.T) {
+	sem, err := lockfile.NewSemaphore(filepath.Join(t.TempDir(), "backups"), 2)
+	if err != nil {
+		t.Fatalf("NewSemaphore failed: %v", err)
+	}
+
+	first, err := sem.TryAcquire()
+	if err != nil {
+		t.Fatalf("TryAcquire failed: %v", err)
+	}
+	second, err := sem.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	defer second.Close()
+	if first.Slot() == second.Slot() {
+		t.Fatalf("both permits hold slot %d", first.Slot())
+	}
+
+	if _, err := sem.TryAcquire(); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected ErrExist when every slot is held, got: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := sem.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected Acquire to time out, got: %v", err)
+	}
+
+	// A waiter receives the permit once it is released.
+	result := make(chan error, 1)
+	go func() {
+		permit, err := sem.Acquire(context.Background())
+		if err == nil {
+			if permit.Slot() != first.Slot() {
+				err = errors.New("the waiter acquired the wrong slot")
+			}
+			permit.Close()
+		}
+		result <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("the waiter failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the waiter did not acquire the released permit")
+	}
+}
+
+func TestSemaphoreQueue(t *testing.T) {
+	sem, err := lockfile.NewSemaphore(filepath.Join(t.TempDir(), "backups"), 1)
+	if err != nil {
+		t.Fatalf("NewSemaphore failed: %v", err)
+	}
+
+	permit, err := sem.TryAcquire()
+	if err != nil {
+		t.Fatalf("TryAcquire failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	defer func() {
+		cancel()
+		<-done
+	}()
+	go func() {
+		defer close(done)
+		if permit, err := sem.Acquire(ctx); err == nil {
+			permit.Close()
+		}
+	}()
+
+	// Wait for the waiter to join the queue. Its ticket is written to a
+	// temporary file first, and only joins once it has been renamed.
+	queue := filepath.Join(sem.Dir(), "queue")
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		if tickets, _ := filepath.Glob(filepath.Join(queue, "*.json")); len(tickets) > 0 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("the waiter did not join the queue")
+		}
+	}
+
+	// The slot is free, but the waiter is ahead of us.
+	permit.Close()
+	if extra, err := sem.TryAcquire(); err == nil {
+		extra.Close()
+		t.Fatal("TryAcquire jumped the queue")
+	}
+}
+
+func TestSemaphoreAbandonedTickets(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "backups")
+	sem, err := lockfile.NewSemaphore(dir, 1)
+	if err != nil {
+		t.Fatalf("NewSemaphore failed: %v", err)
+	}
+
+	// An empty ticket, left behind by a waiter that was killed before it
+	// wrote it, and a stale ticket of a waiter on another host are at the
+	// front of the queue.
+	queue := filepath.Join(dir, "queue")
+	if err := os.MkdirAll(queue, 0755); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(queue, "00000000000000000001-00000000.json")
+	if err := os.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	remote := filepath.Join(queue, "00000000000000000002-00000000.json")
+	data := []byte(`{"holder":{"pid":1,"hostname":"elsewhere.invalid"},"since":"2000-01-01T00:00:00Z"}`)
+	if err := os.WriteFile(remote, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(remote, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	permit, err := sem.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire failed behind abandoned tickets: %v", err)
+	}
+	permit.Close()
+
+	for _, name := range []string{empty, remote} {
+		if _, err := os.Stat(name); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("the abandoned ticket %s was not removed: %v", filepath.Base(name), err)
+		}
+	}
+}
+
+func TestSemaphoreInvalid(t *testing.T) {
+	if _, err := lockfile.NewSemaphore(t.TempDir(), 0); !errors.Is(err, lockfile.ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption, got: %v", err)
+	}
+	if _, err := lockfile.NewSemaphore("", 1); !errors.Is(err, lockfile.ErrEmptyPath) {
+		t.Fatalf("expected ErrEmptyPath, got: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package lockfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// NextSequence increments the sequence number of the lock file and returns
+// the new value. It must only be called while the lock is held.
+//
+// The sequence number is stored in a companion file, named after the lock
+// file with a ".seq" suffix, which persists after the lock is released.
+// Because it is only modified under the protection of the lock, calling
+// NextSequence once in each critical section gives the critical sections
+// a cheap total order, which can be used to build logs or replication on
+// top of the lock. The first sequence number is 1.
+//
+// Shared locks do not exclude each other, so the sequence number of a
+// shared lock file cannot be incremented safely. NextSequence returns an
+// error that wraps [ErrInvalidOption] for them.
+//
+// The companion file is replaced atomically, so a crash never leaves a
+// partially written sequence number behind.
+func (f *File) NextSequence() (uint64, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return 0, &os.PathError{Op: "sequence", Path: f.h.path, Err: os.ErrClosed}
+	}
+	if f.h.shared {
+		return 0, &os.PathError{Op: "sequence", Path: f.h.path, Err: fmt.Errorf("%w: sequence numbers require an exclusive lock", ErrInvalidOption)}
+	}
+
+	return nextSequence(sequencePath(f.h.path))
+}
+
+// NextSequence waits for the lock file with the given path like [WaitCtx],
+// increments its sequence number as described by [File.NextSequence], and
+// releases the lock. It returns the new sequence number.
+func NextSequence(ctx context.Context, path string, opts ...Option) (seq uint64, err error) {
+	file, err := WaitCtx(ctx, path, opts...)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	return file.NextSequence()
+}
+
+// sequencePath returns the path of the file that holds the sequence number
+// of the lock file at path.
+func sequencePath(path string) string {
+	return path + ".seq"
+}
+
+// nextSequence increments the sequence number stored at path.
+func nextSequence(path string) (uint64, error) {
+	var seq uint64
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return 0, err
+	default:
+		seq, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return 0, &os.PathError{Op: "sequence", Path: path, Err: fmt.Errorf("invalid sequence number: %w", err)}
+		}
+	}
+
+	seq++
+	if err := writeFileAtomic(path, []byte(strconv.FormatUint(seq, 10)+"\n")); err != nil {
+		return 0, err
+	}
+
+	return seq, nil
+}
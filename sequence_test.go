@@ -0,0 +1,68 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestNextSequence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sequence.lock")
+
+	const workers = 4
+	const iterations = 10
+
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+		seen  = make(map[uint64]bool)
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range iterations {
+				seq, err := lockfile.NextSequence(context.Background(), path)
+				if err != nil {
+					t.Errorf("NextSequence failed: %v", err)
+					return
+				}
+				mutex.Lock()
+				if seen[seq] {
+					t.Errorf("sequence number %d was issued twice", seq)
+				}
+				seen[seq] = true
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for seq := uint64(1); seq <= workers*iterations; seq++ {
+		if !seen[seq] {
+			t.Fatalf("sequence number %d was never issued", seq)
+		}
+	}
+}
+
+func TestNextSequenceShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sequence.lock")
+
+	file, err := lockfile.CreateShared(path)
+	if err != nil {
+		t.Fatalf("CreateShared failed: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.NextSequence(); !errors.Is(err, lockfile.ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption for a shared lock, got: %v", err)
+	}
+	if _, err := os.Stat(path + ".seq"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the sequence file was written for a shared lock: %v", err)
+	}
+}
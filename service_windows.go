@@ -0,0 +1,103 @@
+//go:build windows
+
+package lockfile
+
+import (
+	"sync"
+	"syscall"
+)
+
+// Windows services that are stopped abruptly rely on the operating system
+// to close their handles, which deletes their lock files. The helpers in
+// this file release the lock files held by a Manager promptly when the
+// service or console is told to stop, so that other processes can acquire
+// them without waiting for the process to be torn down.
+
+// Service control codes, as defined by the ControlService function.
+const (
+	serviceControlStop        = 0x00000001
+	serviceControlShutdown    = 0x00000005
+	serviceControlPreshutdown = 0x0000000F
+)
+
+// Console control events, as defined by the HandlerRoutine callback.
+const (
+	ctrlCloseEvent    = 2
+	ctrlShutdownEvent = 6
+)
+
+var procSetConsoleCtrlHandler = modkernel32.NewProc("SetConsoleCtrlHandler")
+
+// ServiceControl releases every lock file held by the manager if cmd is a
+// service control code that stops the service: SERVICE_CONTROL_STOP,
+// SERVICE_CONTROL_SHUTDOWN or SERVICE_CONTROL_PRESHUTDOWN. It returns true
+// if the lock files were released.
+//
+// It is intended to be called from the loop that handles change requests
+// in a service's Execute method, following the pattern of the
+// golang.org/x/sys/windows/svc package:
+//
+//	case c := <-r:
+//		if manager.ServiceControl(uint32(c.Cmd)) {
+//			return false, 0
+//		}
+func (m *Manager) ServiceControl(cmd uint32) bool {
+	switch cmd {
+	case serviceControlStop, serviceControlShutdown, serviceControlPreshutdown:
+		m.CloseAll()
+		return true
+	}
+	return false
+}
+
+// consoleManagers are the managers that release their lock files when the
+// console is closed or the system shuts down.
+var consoleManagers struct {
+	once     sync.Once
+	err      error
+	mutex    sync.Mutex
+	managers map[*Manager]struct{}
+}
+
+// HandleConsoleControl arranges for every lock file held by the manager to
+// be released when the console window is closed or the system shuts down.
+// Other console events, such as CTRL+C, are left to the application.
+//
+// The handler does not prevent the process from exiting. It returns a
+// function that stops handling console events for the manager.
+func (m *Manager) HandleConsoleControl() (stop func(), err error) {
+	consoleManagers.once.Do(func() {
+		consoleManagers.managers = make(map[*Manager]struct{})
+		handler := syscall.NewCallback(consoleControl)
+		if r1, _, e1 := procSetConsoleCtrlHandler.Call(handler, 1); r1 == 0 {
+			consoleManagers.err = e1
+		}
+	})
+	if consoleManagers.err != nil {
+		return nil, consoleManagers.err
+	}
+
+	consoleManagers.mutex.Lock()
+	consoleManagers.managers[m] = struct{}{}
+	consoleManagers.mutex.Unlock()
+
+	return func() {
+		consoleManagers.mutex.Lock()
+		delete(consoleManagers.managers, m)
+		consoleManagers.mutex.Unlock()
+	}, nil
+}
+
+// consoleControl is the console control handler. It returns false, so
+// that the next handler is called and the event is handled as usual.
+func consoleControl(event uint32) uintptr {
+	switch event {
+	case ctrlCloseEvent, ctrlShutdownEvent:
+		consoleManagers.mutex.Lock()
+		defer consoleManagers.mutex.Unlock()
+		for m := range consoleManagers.managers {
+			m.CloseAll()
+		}
+	}
+	return 0
+}
@@ -0,0 +1,35 @@
+//go:build windows
+
+package lockfile_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestServiceControl(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "service.lock")
+	manager := lockfile.NewManager()
+
+	if _, err := manager.Create(path); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	const serviceControlInterrogate = 0x4
+	if manager.ServiceControl(serviceControlInterrogate) {
+		t.Fatalf("locks were released for an interrogate request")
+	}
+	if manager.Len() != 1 {
+		t.Fatalf("the lock was released for an interrogate request")
+	}
+
+	const serviceControlStop = 0x1
+	if !manager.ServiceControl(serviceControlStop) {
+		t.Fatalf("locks were not released for a stop request")
+	}
+	if manager.Len() != 0 {
+		t.Fatalf("the lock is still held after a stop request")
+	}
+}
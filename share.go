@@ -0,0 +1,125 @@
+package lockfile
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// EnvInherit is the environment variable that records the lock files that
+// have been shared with a child process by [File.Share]. It holds a JSON
+// object that maps the absolute path of each lock file to the descriptor
+// or handle for it in the child.
+const EnvInherit = "LOCKFILE_INHERIT"
+
+// Share arranges for the lock held by f to be shared with the child
+// process that will be started by cmd. It must be called before the
+// command is started.
+//
+// The child inherits a descriptor for the lock file, so that the parent
+// and child hold one logical lock collectively. The child can verify that
+// it shares the lock, and obtain a [File] for it, by calling [Inherited].
+// Share modifies the environment of cmd to record the inherited lock, and
+// must be called for each lock that is to be shared.
+//
+// The lock file is deleted once the parent and every child that shares it
+// have closed it, by whichever of them closes it last.
+//
+// It returns an [*os.PathError] that wraps [os.ErrClosed] if f has already
+// been closed, or [ErrInvalidOption] if it was acquired with
+// [WithPOSIXLocks] or [WithOFDLocks], whose locks cannot be verified by
+// the child, or through a [Backend] that has no lock file.
+func (f *File) Share(cmd *exec.Cmd) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return &os.PathError{Op: "share", Path: f.h.path, Err: os.ErrClosed}
+	}
+	if f.h.cfg.fcntlLocks() || f.h.file == nil {
+		return &os.PathError{Op: "share", Path: f.h.path, Err: ErrInvalidOption}
+	}
+
+	path, err := filepath.Abs(f.h.path)
+	if err != nil {
+		return &os.PathError{Op: "share", Path: f.h.path, Err: err}
+	}
+
+	inherited := inheritedFiles(cmd.Env)
+	inherited[path] = shareFile(cmd, f.h.file)
+
+	f.h.mutex.Lock()
+	f.h.sharedWithChild = true
+	f.h.mutex.Unlock()
+
+	value, err := json.Marshal(inherited)
+	if err != nil {
+		return &os.PathError{Op: "share", Path: f.h.path, Err: err}
+	}
+
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = setEnv(cmd.Env, EnvInherit, string(value))
+
+	return nil
+}
+
+// Inherited returns a [File] for the lock file with the given path that
+// was shared with the current process by its parent with [File.Share].
+//
+// It verifies that the inherited descriptor refers to the lock file at
+// path, and that it holds the lock. It returns an [*os.PathError] that
+// wraps [ErrNotShared] if the lock was not shared with the current
+// process, or if the verification fails.
+//
+// Closing the returned File closes the inherited descriptor. The lock file
+// is deleted if the parent and any other children that share it have
+// already closed it.
+func Inherited(path string) (*File, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "inherit", Path: path, Err: err}
+	}
+
+	fd, ok := inheritedFiles(os.Environ())[abs]
+	if !ok {
+		return nil, &os.PathError{Op: "inherit", Path: path, Err: ErrNotShared}
+	}
+
+	file, err := inheritFile(path, fd)
+	if err != nil {
+		return nil, err
+	}
+
+	f := newFile(path, defaultConfig, file, false)
+	f.h.inherited = true
+	return f, nil
+}
+
+// inheritedFiles returns the inherited lock files that are recorded in
+// the given environment.
+func inheritedFiles(env []string) map[string]uintptr {
+	files := make(map[string]uintptr)
+	for _, entry := range env {
+		if value, ok := strings.CutPrefix(entry, EnvInherit+"="); ok {
+			json.Unmarshal([]byte(value), &files)
+		}
+	}
+	return files
+}
+
+// setEnv returns env with the variable key set to value, replacing any
+// existing value.
+func setEnv(env []string, key, value string) []string {
+	prefix := key + "="
+	out := make([]string, 0, len(env)+1)
+	for _, entry := range env {
+		if !strings.HasPrefix(entry, prefix) {
+			out = append(out, entry)
+		}
+	}
+	return append(out, prefix+value)
+}
@@ -0,0 +1,148 @@
+package lockfile_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+// envShareChild is set when the test binary is run as a child process by
+// TestShare.
+const envShareChild = "LOCKFILE_TEST_SHARE_CHILD"
+
+func TestShare(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.lock")
+
+	file, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer file.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestShareChild$", "-test.v")
+	if err := file.Share(cmd); err != nil {
+		t.Fatalf("Share failed: %v", err)
+	}
+	cmd.Env = append(cmd.Env, envShareChild+"="+path)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil || !bytes.Contains(out, []byte("--- PASS: TestShareChild")) {
+		t.Fatalf("child failed to inherit the lock: %v\n%s", err, out)
+	}
+
+	// The lock must still be held by the parent.
+	if _, err := lockfile.Create(path); !lockfile.IsTemporary(err) {
+		t.Fatalf("the lock was released when the child exited: %v", err)
+	}
+}
+
+func TestShareChild(t *testing.T) {
+	path := os.Getenv(envShareChild)
+	if path == "" {
+		t.Skip("only run as a child process by TestShare")
+	}
+
+	file, err := lockfile.Inherited(path)
+	if err != nil {
+		t.Fatalf("Inherited failed: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+// envShareHold is set when the test binary is run as a child process by
+// TestShareOutlivesParent.
+const envShareHold = "LOCKFILE_TEST_SHARE_HOLD"
+
+func TestShareOutlivesParent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.lock")
+
+	file, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer file.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestShareHold$")
+	if err := file.Share(cmd); err != nil {
+		t.Fatalf("Share failed: %v", err)
+	}
+	cmd.Env = append(cmd.Env, envShareHold+"="+path)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start the child: %v", err)
+	}
+	defer cmd.Wait()
+	defer stdin.Close()
+
+	ready, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil || ready != "ready\n" {
+		t.Fatalf("the child failed to inherit the lock: %q, %v", ready, err)
+	}
+
+	// The lock is still held by the child after the parent releases it.
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := lockfile.Create(path); !lockfile.IsTemporary(err) {
+		t.Fatalf("the lock was released while the child still held it: %v", err)
+	}
+
+	// Once the child releases it, the lock file is free.
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("the child failed: %v", err)
+	}
+	other, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("the lock was not released by the child: %v", err)
+	}
+	other.Close()
+}
+
+func TestShareHold(t *testing.T) {
+	path := os.Getenv(envShareHold)
+	if path == "" {
+		t.Skip("only run as a child process by TestShareOutlivesParent")
+	}
+
+	file, err := lockfile.Inherited(path)
+	if err != nil {
+		t.Fatalf("Inherited failed: %v", err)
+	}
+	fmt.Println("ready")
+	io.Copy(io.Discard, os.Stdin)
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if runtime.GOOS != "windows" {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("the last holder did not delete the lock file: %v", err)
+		}
+	}
+}
+
+func TestInheritedNotShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "unshared.lock")
+	if _, err := lockfile.Inherited(path); !errors.Is(err, lockfile.ErrNotShared) {
+		t.Fatalf("expected ErrNotShared, got: %v", err)
+	}
+}
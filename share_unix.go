@@ -0,0 +1,84 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// shareFile arranges for file to be inherited by the child process started
+// by cmd, and returns its descriptor in the child.
+func shareFile(cmd *exec.Cmd, file *os.File) uintptr {
+	// Descriptors 0, 1 and 2 are used for standard input, output and error.
+	cmd.ExtraFiles = append(cmd.ExtraFiles, file)
+	return uintptr(2 + len(cmd.ExtraFiles))
+}
+
+// inheritFile verifies that the inherited descriptor fd refers to the lock
+// file at path and holds its lock, and returns a file for it.
+func inheritFile(path string, fd uintptr) (*os.File, error) {
+	var stat1, stat2 syscall.Stat_t
+	if err := syscall.Fstat(int(fd), &stat1); err != nil {
+		return nil, &os.PathError{Op: "inherit", Path: path, Err: ErrNotShared}
+	}
+	if err := syscall.Stat(path, &stat2); err != nil {
+		return nil, pathError("stat", path, err)
+	}
+	if stat1.Dev != stat2.Dev || stat1.Ino != stat2.Ino {
+		return nil, &os.PathError{Op: "inherit", Path: path, Err: ErrNotShared}
+	}
+
+	// The inherited descriptor shares its open file description with the
+	// parent, so it can only lock the file without blocking if the lock is
+	// already held through that description.
+	if err := syscall.Flock(int(fd), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		return nil, &os.PathError{Op: "inherit", Path: path, Err: ErrNotShared}
+	}
+
+	syscall.CloseOnExec(int(fd))
+	return os.NewFile(fd, path), nil
+}
+
+// releaseInherited closes a lock file whose lock is shared with another
+// process through an inherited descriptor, and deletes it if nobody holds
+// the lock any longer.
+//
+// Every descriptor that is shared in this way refers to the same open
+// file description, which holds the lock until all of them have been
+// closed, so the lock cannot tell us whether the other process still
+// holds it. Once our descriptor has been closed, the lock file is locked
+// again through a new open file description instead, which only succeeds
+// if nobody else holds the lock. In that case the lock file is deleted
+// like any other.
+//
+// The caller must hold h.mutex.
+func (h *lockHandle) releaseInherited() error {
+	sys := h.cfg.system()
+	err := pathError("close", h.path, sys.closeFile(h.path, h.file))
+	h.file = nil
+	if err != nil {
+		return err
+	}
+
+	fd, err := sys.open(h.path, syscall.O_RDONLY, 0)
+	switch {
+	case errors.Is(err, syscall.ENOENT):
+		return nil
+	case err != nil:
+		return pathError("open", h.path, err)
+	}
+	if err := sys.flock(h.path, fd, syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		sys.closeFd(h.path, fd)
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil
+		}
+		return pathError("flock", h.path, err)
+	}
+
+	h.file = os.NewFile(uintptr(fd), h.path)
+	h.shared, h.sharedWithChild = false, false
+	return h.release()
+}
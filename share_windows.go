@@ -0,0 +1,63 @@
+//go:build windows
+
+package lockfile
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// shareFile arranges for file to be inherited by the child process started
+// by cmd, and returns its handle in the child.
+func shareFile(cmd *exec.Cmd, file *os.File) uintptr {
+	// Inherited handles have the same value in the child.
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	handle := syscall.Handle(file.Fd())
+	cmd.SysProcAttr.AdditionalInheritedHandles = append(cmd.SysProcAttr.AdditionalInheritedHandles, handle)
+	return uintptr(handle)
+}
+
+// inheritFile verifies that the inherited handle refers to the lock file at
+// path, and returns a file for it.
+//
+// The lock file cannot be opened again to compare it with the handle,
+// because it was created without sharing. Its final path is compared
+// instead.
+func inheritFile(path string, fd uintptr) (*os.File, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "inherit", Path: path, Err: err}
+	}
+
+	handle := syscall.Handle(fd)
+	final, err := getFinalPathName(handle)
+	if err != nil {
+		return nil, &os.PathError{Op: "inherit", Path: path, Err: ErrNotShared}
+	}
+
+	// Remove the extended path prefix that is added to final paths.
+	final = strings.TrimPrefix(final, `\\?\`)
+	if !strings.EqualFold(final, abs) {
+		return nil, &os.PathError{Op: "inherit", Path: path, Err: ErrNotShared}
+	}
+
+	// Don't pass the handle on to grandchildren unless it is shared again.
+	syscall.SetHandleInformation(handle, syscall.HANDLE_FLAG_INHERIT, 0)
+
+	return os.NewFile(fd, path), nil
+}
+
+// releaseInherited closes an inherited lock file. It is deleted by the
+// operating system once every process that shares it has closed it.
+//
+// The caller must hold h.mutex.
+func (h *lockHandle) releaseInherited() error {
+	err := pathError("close", h.path, h.cfg.system().closeFile(h.path, h.file))
+	h.file = nil
+	return err
+}
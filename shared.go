@@ -0,0 +1,51 @@
+package lockfile
+
+import "context"
+
+// CreateShared attempts to create or open a lock file with the given path,
+// and to acquire a shared lock on it.
+//
+// Any number of processes can hold a shared lock on the same lock file at
+// once, but a shared lock excludes an exclusive lock acquired by [Create]
+// or [WaitCtx], and vice versa. This allows several readers of the data
+// protected by the lock file to proceed together while still excluding a
+// writer. The semantics of exclusive locks are unchanged.
+//
+// On Linux, the lock is acquired with the flock system call in shared
+// mode. On Windows, readers open the lock file with a share mode that
+// admits other readers but not a writer. In both cases, the lock file is
+// deleted when its last holder releases it.
+//
+// On Windows, once one reader has released its lock while other readers
+// still hold theirs, the lock file is pending deletion, and new readers
+// contend until the remaining readers have released it too.
+//
+// If the lock file is held exclusively, it returns an [*os.PathError]
+// that wraps [os.ErrExist]. Shared locks cannot be soft, so it returns an
+// error that wraps [ErrSharedUnsupported] if a soft lock file would be
+// used for path.
+//
+// Options may be provided to customize its behavior.
+func CreateShared(path string, opts ...Option) (*File, error) {
+	return newConfig(withShared(opts)).create(path)
+}
+
+// WaitSharedCtx waits for a shared lock on a lock file with the given path
+// like [WaitCtx]. The lock is acquired as described by [CreateShared].
+func WaitSharedCtx(ctx context.Context, path string, opts ...Option) (*File, error) {
+	return newConfig(withShared(opts)).wait(ctx, path)
+}
+
+// Shared returns true if f holds a shared lock, as acquired by
+// [CreateShared].
+func (f *File) Shared() bool {
+	return f.h.shared
+}
+
+// withShared returns opts with an additional option that requests a
+// shared lock. It does not modify opts.
+func withShared(opts []Option) []Option {
+	return append(opts[:len(opts):len(opts)], func(c *config) {
+		c.shared = true
+	})
+}
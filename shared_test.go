@@ -0,0 +1,103 @@
+package lockfile_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestCreateShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.lock")
+
+	reader1, err := lockfile.CreateShared(path)
+	if err != nil {
+		t.Fatalf("first CreateShared failed: %v", err)
+	}
+	if !reader1.Shared() {
+		t.Fatalf("Shared returned false for a shared lock")
+	}
+	if g := reader1.Guarantees(); g.Exclusive || !g.KernelLock {
+		t.Fatalf("unexpected guarantees of a shared lock: %+v", g)
+	}
+
+	reader2, err := lockfile.CreateShared(path)
+	if err != nil {
+		t.Fatalf("second CreateShared failed: %v", err)
+	}
+
+	// Readers exclude a writer.
+	if _, err := lockfile.Create(path); !lockfile.IsTemporary(err) {
+		t.Fatalf("Create returned %v while readers held the lock", err)
+	}
+
+	if err := reader1.Close(); err != nil {
+		t.Fatalf("closing the first reader failed: %v", err)
+	}
+	if _, err := lockfile.Create(path); !lockfile.IsTemporary(err) {
+		t.Fatalf("Create returned %v while a reader held the lock", err)
+	}
+
+	// The last reader deletes the lock file.
+	if err := reader2.Close(); err != nil {
+		t.Fatalf("closing the second reader failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("the lock file was not deleted by the last reader: %v", err)
+	}
+
+	// A writer excludes readers.
+	writer, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed after the readers released the lock: %v", err)
+	}
+	if writer.Shared() {
+		t.Fatalf("Shared returned true for an exclusive lock")
+	}
+	if _, err := lockfile.CreateShared(path); !lockfile.IsTemporary(err) {
+		t.Fatalf("CreateShared returned %v while a writer held the lock", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitSharedCtx(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.lock")
+
+	writer, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(time.Millisecond*50, func() { writer.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	const readers = 4
+	var wg sync.WaitGroup
+	files := make([]*lockfile.File, readers)
+	for i := range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			file, err := lockfile.WaitSharedCtx(ctx, path)
+			if err != nil {
+				t.Errorf("WaitSharedCtx failed: %v", err)
+				return
+			}
+			files[i] = file
+		}()
+	}
+	wg.Wait()
+
+	for _, file := range files {
+		if file != nil {
+			file.Close()
+		}
+	}
+}
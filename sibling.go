@@ -0,0 +1,117 @@
+package lockfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SiblingPolicy determines what happens when a lock file is acquired while
+// a lock file that follows a different naming convention for the same
+// resource is in use. See [WithSiblingCheck].
+type SiblingPolicy int
+
+const (
+	// SiblingIgnore does not check for sibling lock files. It is the
+	// default.
+	SiblingIgnore SiblingPolicy = iota
+
+	// SiblingWarn reports sibling lock files that are in use to the
+	// Warning hook, and acquires the lock file anyway.
+	SiblingWarn
+
+	// SiblingReject releases the lock file and returns an error if a
+	// sibling lock file is in use.
+	SiblingReject
+)
+
+// WithSiblingCheck returns an option that checks for lock files that
+// follow other naming conventions for the same resource each time a lock
+// file is acquired. This catches split-brain configurations, where
+// different tools protect the same data with differently named lock files
+// and therefore do not exclude each other.
+//
+// For a lock file named "foo.lock", the siblings are ".foo.lock",
+// "foo.lck", ".foo.lck", "foo.pid" and "LCK..foo" in the same directory. A
+// sibling is in use if it exists and records the process ID of a live
+// process other than the current one. A sibling that exists but does not
+// record a process ID is also assumed to be in use, because many tools
+// lock files without writing anything to them.
+//
+// Conflicts are reported as errors that wrap [ErrSiblingLock], either to
+// the Warning hook or to the caller, depending on policy.
+func WithSiblingCheck(policy SiblingPolicy) Option {
+	return func(c *config) {
+		c.siblingPolicy = policy
+	}
+}
+
+// checkSiblings applies the sibling policy to the lock file at path, which
+// has just been acquired as file. It closes file and returns an error if
+// the policy rejects it.
+func (c *config) checkSiblings(path string, file *File) error {
+	sibling, ok := siblingInUse(path)
+	if !ok {
+		return nil
+	}
+
+	err := &os.PathError{Op: "check", Path: path, Err: fmt.Errorf("%w: %s", ErrSiblingLock, sibling)}
+	if c.siblingPolicy == SiblingWarn {
+		c.warn(path, err)
+		return nil
+	}
+
+	file.Close()
+	return err
+}
+
+// siblingInUse returns the path of the first sibling of the lock file at
+// path that is in use.
+func siblingInUse(path string) (string, bool) {
+	for _, sibling := range siblingPaths(path) {
+		if _, err := os.Lstat(sibling); err != nil {
+			continue
+		}
+
+		info, err := Inspect(sibling)
+		if err != nil || info.Metadata == nil || info.Metadata.Holder.PID == 0 {
+			// The sibling exists, but we cannot tell who holds it.
+			return sibling, true
+		}
+
+		pid := info.Metadata.Holder.PID
+		if pid != os.Getpid() && processAlive(pid) {
+			return sibling, true
+		}
+	}
+	return "", false
+}
+
+// siblingPaths returns the paths that other lock file conventions would
+// use for the same resource as the lock file at path.
+func siblingPaths(path string) []string {
+	dir, base := filepath.Split(path)
+
+	name := strings.TrimPrefix(base, ".")
+	for _, ext := range []string{".lock", ".lck", ".pid"} {
+		name = strings.TrimSuffix(name, ext)
+	}
+
+	candidates := []string{
+		name + ".lock",
+		"." + name + ".lock",
+		name + ".lck",
+		"." + name + ".lck",
+		name + ".pid",
+		"LCK.." + name,
+	}
+
+	paths := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate != base {
+			paths = append(paths, filepath.Join(dir, candidate))
+		}
+	}
+	return paths
+}
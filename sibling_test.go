@@ -0,0 +1,69 @@
+package lockfile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestSiblingCheck(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.lock")
+	pidFile := filepath.Join(dir, "data.pid")
+
+	reject := lockfile.WithSiblingCheck(lockfile.SiblingReject)
+
+	// A PID file left behind by a process that has exited is harmless.
+	if err := os.WriteFile(pidFile, []byte("1073741822\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := lockfile.Create(path, reject)
+	if err != nil {
+		t.Fatalf("Create failed with a stale sibling: %v", err)
+	}
+	file.Close()
+
+	// A PID file held by a live process indicates a split brain. The
+	// parent of the test process is known to be alive.
+	if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getppid())), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockfile.Create(path, reject); !errors.Is(err, lockfile.ErrSiblingLock) {
+		t.Fatalf("Create returned %v with a live sibling", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("the rejected lock file was not released: %v", err)
+	}
+
+	// The warning policy reports the conflict and acquires the lock.
+	var warned error
+	warn := lockfile.WithHooks(lockfile.Hooks{
+		Warning: func(path string, err error) { warned = err },
+	})
+	file, err = lockfile.Create(path, lockfile.WithSiblingCheck(lockfile.SiblingWarn), warn)
+	if err != nil {
+		t.Fatalf("Create failed with the warning policy: %v", err)
+	}
+	file.Close()
+	if !errors.Is(warned, lockfile.ErrSiblingLock) {
+		t.Fatalf("unexpected warning: %v", warned)
+	}
+}
+
+func TestSiblingCheckHiddenLock(t *testing.T) {
+	dir := t.TempDir()
+
+	// Another tool uses a hidden, empty lock file for the same resource.
+	if err := os.WriteFile(filepath.Join(dir, ".data.lock"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := lockfile.Create(filepath.Join(dir, "data.lock"), lockfile.WithSiblingCheck(lockfile.SiblingReject))
+	if !errors.Is(err, lockfile.ErrSiblingLock) {
+		t.Fatalf("Create returned %v with a hidden sibling", err)
+	}
+}
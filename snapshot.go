@@ -0,0 +1,342 @@
+package lockfile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Snapshot describes the lock files held by a [Manager] at a point in
+// time. It is written to disk by [Manager.Snapshot], so that a process
+// that crashes can reconcile the locks it held when it starts again with
+// [Manager.Reconcile].
+type Snapshot struct {
+	Taken  time.Time      `json:"taken"`
+	Holder Holder         `json:"holder"`
+	Locks  []SnapshotLock `json:"locks"`
+}
+
+// SnapshotLock describes a lock file recorded in a [Snapshot].
+type SnapshotLock struct {
+	// Path is the absolute path of the lock file, or its name if it is held
+	// by a manager with a root directory, as created by [NewDirManager].
+	Path string `json:"path"`
+
+	Generation uint64    `json:"generation,omitempty"`
+	Acquired   time.Time `json:"acquired"`
+	Soft       bool      `json:"soft,omitempty"`
+
+	// Token is the fencing token that was assigned to the lock file by
+	// [WithFencing], if any.
+	Token uint64 `json:"token,omitempty"`
+}
+
+// Snapshot writes a description of the lock files held by the manager to
+// the file at path.
+//
+// The file is replaced atomically, so that a crash while it is being
+// written leaves either the previous snapshot or the new one intact.
+// Callers typically take a snapshot each time the set of held lock files
+// changes.
+func (m *Manager) Snapshot(path string) error {
+	snap := Snapshot{
+		Taken:  time.Now(),
+		Holder: CurrentHolder(),
+		Locks:  m.snapshotLocks(),
+	}
+
+	data, err := json.MarshalIndent(snap, "", "\t")
+	if err != nil {
+		return &os.PathError{Op: "snapshot", Path: path, Err: err}
+	}
+
+	return writeFileAtomic(path, data)
+}
+
+// snapshotLocks returns a description of each lock file held by the
+// manager.
+func (m *Manager) snapshotLocks() []SnapshotLock {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	locks := make([]SnapshotLock, 0, len(m.handles))
+	for h := range m.handles {
+		path := h.path
+		if name, ok := m.nameOf(path); ok {
+			path = name
+		} else if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		locks = append(locks, SnapshotLock{
+			Path:       path,
+			Generation: h.generation,
+			Acquired:   h.stats.Acquired,
+			Soft:       h.soft,
+			Token:      h.token,
+		})
+	}
+	return locks
+}
+
+// ReadSnapshot reads a snapshot that was written by [Manager.Snapshot].
+func ReadSnapshot(path string) (Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return Snapshot{}, &os.PathError{Op: "snapshot", Path: path, Err: err}
+	}
+
+	return snap, nil
+}
+
+// ReconcilePolicy determines what [Manager.Reconcile] does with the lock
+// files recorded in a snapshot.
+type ReconcilePolicy int
+
+const (
+	// ReconcileReacquire waits for each lock file to be acquired again by
+	// the manager, so that the process resumes holding the locks it held
+	// before it crashed. If the manager assigns fencing tokens with
+	// [WithFencing], each lock file is assigned a token greater than the
+	// one recorded in the snapshot, even if its companion file was lost.
+	ReconcileReacquire ReconcilePolicy = iota
+
+	// ReconcileBreak acquires and immediately releases each lock file that
+	// is free, which removes any lock file that was left behind by the
+	// crash. Lock files that are held by someone else are left alone.
+	ReconcileBreak
+)
+
+// ReconcileState is the outcome of reconciling one lock file.
+type ReconcileState int
+
+const (
+	// ReconcileHeld means that the lock file is held by someone else.
+	ReconcileHeld ReconcileState = iota
+
+	// ReconcileReacquired means that the lock file was acquired again by
+	// the manager.
+	ReconcileReacquired
+
+	// ReconcileBroken means that the lock file was acquired and released,
+	// removing anything that was left behind.
+	ReconcileBroken
+
+	// ReconcileFailed means that the lock file could not be reconciled.
+	ReconcileFailed
+)
+
+// String returns a description of the state.
+func (s ReconcileState) String() string {
+	switch s {
+	case ReconcileHeld:
+		return "held"
+	case ReconcileReacquired:
+		return "reacquired"
+	case ReconcileBroken:
+		return "broken"
+	case ReconcileFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// Reconciled is the outcome of reconciling a lock file recorded in a
+// snapshot.
+type Reconciled struct {
+	Lock  SnapshotLock
+	State ReconcileState
+
+	// File is the reacquired lock file, if the state is
+	// ReconcileReacquired. It is held by the manager.
+	File *File
+
+	// Err is the error that prevented reconciliation, if the state is
+	// ReconcileFailed.
+	Err error
+}
+
+// Reconcile reads the snapshot at path, and reconciles each lock file it
+// records according to policy. It is intended to be called when a process
+// starts, before it acquires any other locks, to recover from a crash.
+//
+// If the manager was created with [WithDryRun], lock files are not broken.
+// The results describe what would have happened, and lock files that would
+// have been removed are recorded in the plan.
+//
+// Soft lock files are not released when their holder crashes, so each one
+// recorded in the snapshot is first broken if its holder is no longer
+// running or it has outlived its stale timeout, as it would be by [Break].
+//
+// It returns an error if the snapshot cannot be read, or if ctx is
+// cancelled. A missing snapshot is not an error, and results in nothing
+// being reconciled. Failures to reconcile individual lock files are
+// reported in the results.
+func (m *Manager) Reconcile(ctx context.Context, path string, policy ReconcilePolicy) ([]Reconciled, error) {
+	snap, err := ReadSnapshot(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Reconciled, 0, len(snap.Locks))
+	for _, lock := range snap.Locks {
+		if err := ctx.Err(); err != nil {
+			return results, withReason(err)
+		}
+		results = append(results, m.reconcile(ctx, lock, policy))
+	}
+
+	return results, nil
+}
+
+// reconcile reconciles a single lock file recorded in a snapshot.
+func (m *Manager) reconcile(ctx context.Context, lock SnapshotLock, policy ReconcilePolicy) Reconciled {
+	result := Reconciled{Lock: lock}
+
+	if m.cfg.dryRun != nil {
+		return m.planReconcile(lock, policy)
+	}
+
+	path, err := m.resolve(lock.Path)
+	if err != nil {
+		result.State, result.Err = ReconcileFailed, err
+		return result
+	}
+	if lock.Soft {
+		if err := m.breakAbandoned(path); err != nil {
+			result.State, result.Err = ReconcileFailed, err
+			return result
+		}
+	}
+
+	if policy == ReconcileReacquire {
+		file, err := m.Wait(ctx, lock.Path)
+		if err != nil {
+			result.State, result.Err = ReconcileFailed, err
+			return result
+		}
+		if err := m.cfg.restoreFence(file, lock.Token); err != nil {
+			file.Close()
+			result.State, result.Err = ReconcileFailed, err
+			return result
+		}
+		result.State, result.File = ReconcileReacquired, file
+		return result
+	}
+
+	file, err := m.cfg.create(path)
+	switch {
+	case m.cfg.isTemporary(err):
+		result.State = ReconcileHeld
+		return result
+	case err != nil:
+		result.State, result.Err = ReconcileFailed, err
+		return result
+	}
+
+	// The lock file is free. Releasing it removes anything that was left
+	// behind.
+	result.State = ReconcileBroken
+	if err := file.Close(); err != nil {
+		result.State, result.Err = ReconcileFailed, err
+	}
+	return result
+}
+
+// planReconcile determines what reconciling a single lock file recorded in
+// a snapshot would do, and records it in the dry-run plan.
+func (m *Manager) planReconcile(lock SnapshotLock, policy ReconcilePolicy) Reconciled {
+	result := Reconciled{Lock: lock}
+
+	path, err := m.resolve(lock.Path)
+	if err != nil {
+		result.State, result.Err = ReconcileFailed, err
+		return result
+	}
+
+	var exists, held bool
+	if lock.Soft {
+		var (
+			verdict StaleVerdict
+			info    Inspection
+		)
+		verdict, info, err = m.cfg.staleCheck(path)
+		exists, held = info.Exists, info.Exists && !m.cfg.breakable(info, verdict)
+	} else {
+		exists, held, err = m.cfg.probe(path)
+	}
+	switch {
+	case err != nil:
+		result.State, result.Err = ReconcileFailed, err
+	case held:
+		result.State = ReconcileHeld
+	case policy == ReconcileReacquire:
+		result.State = ReconcileReacquired
+	default:
+		result.State = ReconcileBroken
+		if exists {
+			m.cfg.dryRun.record(Action{
+				Op:     "remove",
+				Path:   path,
+				Reason: "the lock file was left behind by a previous holder",
+			})
+		}
+	}
+
+	return result
+}
+
+// breakAbandoned breaks the soft lock file at path, as [Break] would, if it
+// was left behind by a holder that is no longer running or has outlived
+// its stale timeout. A lock file that is still held is left alone.
+func (m *Manager) breakAbandoned(path string) error {
+	verdict, info, err := m.cfg.staleCheck(path)
+	if err != nil || !info.Exists || !m.cfg.breakable(info, verdict) {
+		return err
+	}
+	if err := m.cfg.breakAside(info.Path); err != nil && !errors.Is(err, ErrNotStale) {
+		return err
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory as
+// path, flushes it to disk, and renames it over path. The directory is
+// flushed as well, so that the rename survives a crash.
+func writeFileAtomic(path string, data []byte) (err error) {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			temp.Close()
+			os.Remove(temp.Name())
+		}
+	}()
+
+	if _, err = temp.Write(data); err != nil {
+		return err
+	}
+	if err = temp.Sync(); err != nil {
+		return err
+	}
+	if err = temp.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Rename(temp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
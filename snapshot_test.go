@@ -0,0 +1,223 @@
+package lockfile_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestSnapshotReconcile(t *testing.T) {
+	dir := t.TempDir()
+	snapshot := filepath.Join(dir, "locks.json")
+	a, b := filepath.Join(dir, "a.lock"), filepath.Join(dir, "b.lock")
+
+	before := lockfile.NewManager()
+	if _, err := before.Create(a); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := before.Create(b); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := before.Snapshot(snapshot); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	snap, err := lockfile.ReadSnapshot(snapshot)
+	if err != nil {
+		t.Fatalf("ReadSnapshot failed: %v", err)
+	}
+	if len(snap.Locks) != 2 {
+		t.Fatalf("snapshot records %d locks, expected 2", len(snap.Locks))
+	}
+
+	// Simulate a crash that released lock a, while b is still held by
+	// someone else.
+	before.CloseAll()
+	other, err := lockfile.Create(b)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer other.Close()
+
+	after := lockfile.NewManager()
+	results, err := after.Reconcile(context.Background(), snapshot, lockfile.ReconcileBreak)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	states := make(map[string]lockfile.ReconcileState)
+	for _, result := range results {
+		states[result.Lock.Path] = result.State
+	}
+	if states[a] != lockfile.ReconcileBroken {
+		t.Errorf("lock a was %s, expected broken", states[a])
+	}
+	if states[b] != lockfile.ReconcileHeld {
+		t.Errorf("lock b was %s, expected held", states[b])
+	}
+}
+
+func TestReconcileReacquire(t *testing.T) {
+	dir := t.TempDir()
+	snapshot := filepath.Join(dir, "locks.json")
+	path := filepath.Join(dir, "a.lock")
+
+	before := lockfile.NewManager()
+	if _, err := before.Create(path); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := before.Snapshot(snapshot); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	before.CloseAll()
+
+	after := lockfile.NewManager()
+	results, err := after.Reconcile(context.Background(), snapshot, lockfile.ReconcileReacquire)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if len(results) != 1 || results[0].State != lockfile.ReconcileReacquired {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if n := after.Len(); n != 1 {
+		t.Fatalf("manager holds %d locks after reacquiring, expected 1", n)
+	}
+	after.CloseAll()
+}
+
+func TestReconcileDirManager(t *testing.T) {
+	root := t.TempDir()
+	snapshot := filepath.Join(t.TempDir(), "locks.json")
+	const name = "jobs/nightly"
+
+	before, err := lockfile.NewDirManager(root)
+	if err != nil {
+		t.Fatalf("NewDirManager failed: %v", err)
+	}
+	held, err := before.Create(name)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	path := held.Path()
+	if err := before.Snapshot(snapshot); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	before.CloseAll()
+
+	after, err := lockfile.NewDirManager(root)
+	if err != nil {
+		t.Fatalf("NewDirManager failed: %v", err)
+	}
+	results, err := after.Reconcile(context.Background(), snapshot, lockfile.ReconcileReacquire)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	defer after.CloseAll()
+	if len(results) != 1 || results[0].State != lockfile.ReconcileReacquired || results[0].Lock.Path != name {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if got := results[0].File.Path(); got != path {
+		t.Fatalf("the lock was reacquired at %s, expected %s", got, path)
+	}
+	if names, err := after.List(); err != nil || len(names) != 1 || names[0] != name {
+		t.Fatalf("unexpected names after reconciling: %v, %v", names, err)
+	}
+}
+
+func TestReconcileMissingSnapshot(t *testing.T) {
+	results, err := lockfile.NewManager().Reconcile(context.Background(), filepath.Join(t.TempDir(), "missing.json"), lockfile.ReconcileBreak)
+	if err != nil || len(results) != 0 {
+		t.Fatalf("unexpected result for a missing snapshot: %v, %v", results, err)
+	}
+}
+
+func TestReconcileSoft(t *testing.T) {
+	dir := t.TempDir()
+	snapshot := filepath.Join(dir, "locks.json")
+	dead, alive := filepath.Join(dir, "dead.lock"), filepath.Join(dir, "alive.lock")
+
+	// Simulate soft lock files that were left behind by a crash, and one
+	// that has since been acquired by a process that is still running.
+	md := lockfile.NewMetadata(1)
+	if data, err := lockfile.EncodeMetadata(md, nil); err != nil || os.WriteFile(alive, data, 0600) != nil {
+		t.Fatal("failed to write the live soft lock file")
+	}
+	md.Holder.PID = 1<<22 - 1
+	if data, err := lockfile.EncodeMetadata(md, nil); err != nil || os.WriteFile(dead, data, 0600) != nil {
+		t.Fatal("failed to write the abandoned soft lock file")
+	}
+	data, err := json.Marshal(lockfile.Snapshot{Locks: []lockfile.SnapshotLock{
+		{Path: dead, Soft: true},
+		{Path: alive, Soft: true},
+	}})
+	if err != nil || os.WriteFile(snapshot, data, 0600) != nil {
+		t.Fatalf("failed to write the snapshot: %v", err)
+	}
+
+	manager := lockfile.NewManager(lockfile.WithSoftLock(0))
+	results, err := manager.Reconcile(context.Background(), snapshot, lockfile.ReconcileBreak)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	states := make(map[string]lockfile.ReconcileState)
+	for _, result := range results {
+		states[result.Lock.Path] = result.State
+	}
+	if states[dead] != lockfile.ReconcileBroken {
+		t.Errorf("the abandoned soft lock file was %s, expected broken", states[dead])
+	}
+	if states[alive] != lockfile.ReconcileHeld {
+		t.Errorf("the live soft lock file was %s, expected held", states[alive])
+	}
+	if _, err := os.Stat(dead); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the abandoned soft lock file was not removed: %v", err)
+	}
+	if _, err := os.Stat(alive); err != nil {
+		t.Errorf("the live soft lock file was removed: %v", err)
+	}
+}
+
+func TestReconcileFencing(t *testing.T) {
+	dir := t.TempDir()
+	snapshot := filepath.Join(dir, "locks.json")
+	path := filepath.Join(dir, "fenced.lock")
+
+	before := lockfile.NewManager(lockfile.WithFencing())
+	for range 3 {
+		file, err := before.Create(path)
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if err := before.Snapshot(snapshot); err != nil {
+			t.Fatalf("Snapshot failed: %v", err)
+		}
+		file.Close()
+	}
+	snap, err := lockfile.ReadSnapshot(snapshot)
+	if err != nil || len(snap.Locks) != 1 || snap.Locks[0].Token != 3 {
+		t.Fatalf("the snapshot did not record the fencing token: %+v, %v", snap.Locks, err)
+	}
+
+	// The companion file that holds the most recent token is lost.
+	if err := os.Remove(path + ".fence"); err != nil {
+		t.Fatal(err)
+	}
+
+	after := lockfile.NewManager(lockfile.WithFencing())
+	defer after.CloseAll()
+	results, err := after.Reconcile(context.Background(), snapshot, lockfile.ReconcileReacquire)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if len(results) != 1 || results[0].State != lockfile.ReconcileReacquired {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if token := results[0].File.Token(); token <= 3 {
+		t.Fatalf("the reacquired lock file was assigned token %d, expected more than 3", token)
+	}
+}
@@ -0,0 +1,111 @@
+package lockfile
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// DataLockPath returns the path of the lock file that protects the data
+// file at dataPath, by convention. It is the path of the data file with a
+// ".lock" suffix.
+func DataLockPath(dataPath string) string {
+	return dataPath + ".lock"
+}
+
+// SnapshotFile copies the data file at dataPath to destPath while holding
+// the lock that protects it, as described by [DataLockPath]. This gives
+// readers a consistent point-in-time copy of the file, and only excludes
+// writers for as long as the copy takes.
+//
+// The lock is waited for like [WaitSharedCtx], and the options are applied
+// in the same way. SnapshotFile acquires a shared lock, so several
+// snapshots can be taken at once, but writers that hold the lock
+// exclusively are excluded.
+//
+// The copy is written to a temporary file in the same directory as
+// destPath, and renamed into place once it is complete, so destPath never
+// holds a partial copy. It has the same permissions as the data file.
+//
+// On file systems that support copy-on-write clones, such as Btrfs, XFS
+// and ReFS, the copy shares its blocks with the data file, so large files
+// are snapshotted without being copied in full.
+func SnapshotFile(ctx context.Context, dataPath, destPath string, opts ...Option) (err error) {
+	file, err := WaitSharedCtx(ctx, DataLockPath(dataPath), opts...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	return copyFileAtomic(dataPath, destPath)
+}
+
+// copyFileAtomic copies the file at src to a temporary file in the same
+// directory as dst, flushes it to disk, and renames it over dst.
+func copyFileAtomic(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			temp.Close()
+			os.Remove(temp.Name())
+		}
+	}()
+
+	if err = copyContents(temp, in); err != nil {
+		return err
+	}
+	if err = temp.Chmod(fi.Mode().Perm()); err != nil {
+		return err
+	}
+	if err = temp.Sync(); err != nil {
+		return err
+	}
+	if err = temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), dst)
+}
+
+// copyContents copies the contents of src to dst, which must both be
+// positioned at the start of the file.
+//
+// Where the file system supports it, dst is made a copy-on-write clone of
+// src, which shares its blocks and takes the same short time regardless of
+// the size of the file. Otherwise the contents are copied, which the
+// kernel may still be able to do without passing them through user space.
+func copyContents(dst, src *os.File) error {
+	if cloneFile(dst, src) == nil {
+		return nil
+	}
+
+	// A failed clone may have left dst extended, so start again.
+	if err := dst.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := dst.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	_, err := dst.ReadFrom(src)
+	return err
+}
@@ -0,0 +1,73 @@
+package lockfile_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestSnapshotFile(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data.txt")
+	dest := filepath.Join(dir, "copy.txt")
+
+	if err := os.WriteFile(data, []byte("point in time"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lockfile.SnapshotFile(context.Background(), data, dest); err != nil {
+		t.Fatalf("SnapshotFile failed: %v", err)
+	}
+
+	copied, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(copied) != "point in time" {
+		t.Fatalf("unexpected contents: %q", copied)
+	}
+
+	// The lock must have been released.
+	file, err := lockfile.Create(lockfile.DataLockPath(data))
+	if err != nil {
+		t.Fatalf("the lock was not released: %v", err)
+	}
+	file.Close()
+}
+
+func TestSnapshotFileLarge(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data.bin")
+	dest := filepath.Join(dir, "copy.bin")
+
+	// The size is not a multiple of any cluster size, so that cloning the
+	// final partial cluster is exercised where it is supported.
+	contents := make([]byte, 3<<20+123)
+	for i := range contents {
+		contents[i] = byte(i * 7)
+	}
+	if err := os.WriteFile(data, contents, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace an existing, longer copy.
+	if err := os.WriteFile(dest, make([]byte, 4<<20), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lockfile.SnapshotFile(context.Background(), data, dest); err != nil {
+		t.Fatalf("SnapshotFile failed: %v", err)
+	}
+
+	copied, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(copied, contents) {
+		t.Fatalf("the copy differs from the data file (%d bytes, want %d)", len(copied), len(contents))
+	}
+}
@@ -0,0 +1,268 @@
+package lockfile
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// WithSoftLock returns an option that manages lock files as simple marker
+// files, without acquiring an operating system lock.
+//
+// In this mode a lock file is created with exclusive creation semantics
+// (O_EXCL on Linux and CREATE_NEW on Windows), and removed when it is
+// closed. This is intended for filesystems that don't support file locks
+// at all, such as FAT32 and some FUSE filesystems.
+//
+// Soft locks provide weaker guarantees than regular lock files, as
+// described by [Guarantees]. In particular, the operating system does not
+// release a soft lock when its holder crashes. If staleAfter is greater
+// than zero, a marker file that has not been modified for longer than
+// staleAfter is considered abandoned and is removed by the next caller that
+// encounters it. Holders that keep a soft lock for longer than staleAfter
+// must call [File.Refresh] more often than that, or it may be broken while
+// they still hold it. If staleAfter is zero or less, abandoned marker files
+// must be removed manually.
+func WithSoftLock(staleAfter time.Duration) Option {
+	return func(c *config) {
+		c.soft = true
+		c.softStaleAfter = staleAfter
+	}
+}
+
+// lockSoft attempts to create a soft lock file at path.
+func (c *config) lockSoft(path string) (*File, error) {
+	for attempt := 0; ; attempt++ {
+		flag, perm := os.O_RDONLY, os.FileMode(0400)
+		if c.writesMetadata() {
+			flag, perm = os.O_RDWR, 0600
+		}
+
+		var file *os.File
+		err := c.doUndo(OpOpen, path, func() (err error) {
+			file, err = os.OpenFile(path, flag|os.O_CREATE|os.O_EXCL, perm)
+			return err
+		}, func() {
+			file.Close()
+		})
+		if err == nil {
+			f := newFile(path, c, file, true)
+			if c.writesMetadata() {
+				f.h.writeMetadata()
+			}
+			return f, nil
+		}
+
+		// If the marker file exists, check whether it has been abandoned.
+		// Only try to break it once, so that we don't fight over it with
+		// another caller that is doing the same thing.
+		if !errors.Is(err, os.ErrExist) || attempt > 0 {
+			return nil, err
+		}
+		broken, breakErr := c.breakSoft(path)
+		if breakErr != nil {
+			return nil, breakErr
+		}
+		if !broken {
+			return nil, err
+		}
+	}
+}
+
+// breakSoft removes the soft lock file at path if it is stale. It returns
+// true if the marker file was removed, by this call or by someone else.
+//
+// The marker file is only removed if it is still the file that was found to
+// be stale, and it has not been modified since, once it has been moved out
+// of the way as described by breakTomb. A marker that was broken and
+// created again by another caller in the meantime is never removed.
+func (c *config) breakSoft(path string) (bool, error) {
+	var judged os.FileInfo
+	err := c.do(OpStat, path, func() (err error) {
+		judged, err = os.Stat(path)
+		return err
+	})
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return true, nil
+	case err != nil:
+		return false, err
+	case !c.softStale(path):
+		return false, nil
+	}
+
+	var broken bool
+	err = c.do(OpUnlink, path, func() (err error) {
+		broken, err = breakTomb(path, func(tomb string) bool {
+			info, err := os.Stat(tomb)
+			return err == nil && os.SameFile(info, judged) && info.ModTime().Equal(judged.ModTime())
+		})
+		return err
+	})
+	c.warnMoved(path, err)
+	return broken, err
+}
+
+// softStale returns true if the soft lock file at path has not been
+// modified for longer than the configured stale timeout, or if it is held
+// as a lease that has expired.
+func (c *config) softStale(path string) bool {
+	if expired, ok := c.leaseExpired(path); ok {
+		return expired
+	}
+
+	staleAfter := c.softStaleTimeout()
+	if staleAfter <= 0 {
+		return false
+	}
+
+	var fi os.FileInfo
+	err := c.do(OpStat, path, func() (err error) {
+		fi, err = os.Stat(path)
+		return err
+	})
+	if err != nil {
+		return false
+	}
+
+	return time.Since(fi.ModTime()) > staleAfter
+}
+
+// Refresh updates the modification time of a soft lock file, so that it is
+// not considered stale while it is held, as described by [WithSoftLock].
+// It has no effect on other lock files.
+//
+// It returns an [*os.PathError] that wraps [ErrMoved] if the marker file
+// has been broken by someone else, or one that wraps [os.ErrClosed] if f
+// has been closed.
+func (f *File) Refresh() error {
+	if err := f.begin("refresh"); err != nil {
+		return err
+	}
+	defer f.end()
+
+	f.h.mutex.Lock()
+	defer f.h.mutex.Unlock()
+
+	return f.h.refresh()
+}
+
+// refresh updates the modification time of a soft lock file.
+//
+// The caller must hold h.mutex.
+func (h *lockHandle) refresh() error {
+	if !h.soft || h.file == nil {
+		return nil
+	}
+
+	var held, current os.FileInfo
+	err := h.cfg.do(OpStat, h.path, func() (err error) {
+		if held, err = h.file.Stat(); err != nil {
+			return err
+		}
+		current, err = os.Stat(h.path)
+		return err
+	})
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return &os.PathError{Op: "refresh", Path: h.path, Err: ErrMoved}
+	case err != nil:
+		return err
+	case !os.SameFile(held, current):
+		return &os.PathError{Op: "refresh", Path: h.path, Err: ErrMoved}
+	}
+
+	return h.cfg.do(OpChtimes, h.path, func() error {
+		now := time.Now()
+		return os.Chtimes(h.path, now, now)
+	})
+}
+
+// startRefresher starts refreshing the soft lock file at a third of
+// staleAfter, until it is released. It is used for the soft lock files that
+// are created by the fallback described by [WithFilesystemDetection].
+func (h *lockHandle) startRefresher(staleAfter time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.refreshStop = make(chan struct{})
+	go h.refreshLoop(max(staleAfter/3, time.Millisecond), h.refreshStop)
+}
+
+// refreshLoop refreshes the soft lock file at the given interval until stop
+// is closed. Failures are reported to the Warning hook. It stops once the
+// marker file has been broken, since refreshing it cannot restore it.
+func (h *lockHandle) refreshLoop(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		h.mutex.Lock()
+		if h.file == nil {
+			h.mutex.Unlock()
+			return
+		}
+		err := h.refresh()
+		h.mutex.Unlock()
+
+		if err != nil {
+			h.cfg.warn(h.path, err)
+			if errors.Is(err, ErrMoved) {
+				return
+			}
+		}
+	}
+}
+
+// stopRefresher stops refreshing the soft lock file.
+//
+// The caller must hold h.mutex.
+func (h *lockHandle) stopRefresher() {
+	if h.refreshStop != nil {
+		close(h.refreshStop)
+		h.refreshStop = nil
+	}
+}
+
+// releaseSoft closes and removes a soft lock file. The caller must hold
+// h.mutex.
+//
+// The marker file is only removed if it is still the file that was
+// created, so that a marker that was broken and replaced by another caller
+// is left alone.
+func (h *lockHandle) releaseSoft() error {
+	var fi1, fi2 os.FileInfo
+	statErr := h.cfg.do(OpStat, h.path, func() (err error) {
+		if fi1, err = h.file.Stat(); err != nil {
+			return err
+		}
+		fi2, err = os.Stat(h.path)
+		return err
+	})
+
+	// The file must be closed before it can be removed on Windows.
+	closeErr := h.cfg.do(OpClose, h.path, h.file.Close)
+	h.file = nil
+
+	switch {
+	case statErr != nil:
+		return errors.Join(statErr, closeErr)
+	case !os.SameFile(fi1, fi2):
+		return errors.Join(&os.PathError{Op: "unlink", Path: h.path, Err: ErrMoved}, closeErr)
+	}
+
+	removeErr := h.cfg.do(OpUnlink, h.path, func() error {
+		return os.Remove(h.path)
+	})
+	if removeErr == nil {
+		h.notify(StageUnlinked)
+	}
+
+	return errors.Join(closeErr, removeErr)
+}
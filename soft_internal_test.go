@@ -0,0 +1,49 @@
+package lockfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSoftLockRefresher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "refresher.lock")
+	file, err := Create(path, WithSoftLock(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fallback refreshes its soft lock files in the background, so
+	// that they are not broken while they are held.
+	file.h.startRefresher(30 * time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.ModTime().After(old.Add(time.Minute)) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the soft lock file was not refreshed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	file.h.mutex.Lock()
+	defer file.h.mutex.Unlock()
+	if file.h.refreshStop != nil {
+		t.Fatal("the refresher was not stopped on release")
+	}
+}
@@ -0,0 +1,140 @@
+package lockfile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestSoftLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "soft.lock")
+	soft := lockfile.WithSoftLock(time.Hour)
+
+	lock, err := lockfile.Create(path, soft)
+	if err != nil {
+		t.Fatalf("failed to create soft lock: %v", err)
+	}
+	if g := lock.Guarantees(); g.KernelLock || g.StaleAfter != time.Hour {
+		t.Fatalf("soft lock reported unexpected guarantees: %+v", g)
+	}
+
+	if _, err := lockfile.Create(path, soft); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected contention for a held soft lock, got: %v", err)
+	}
+
+	if err := lock.Close(); err != nil {
+		t.Fatalf("failed to close soft lock: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("soft lock file was not removed: %v", err)
+	}
+}
+
+func TestSoftLockStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stale.lock")
+
+	// Simulate a marker file that was abandoned by a crashed holder.
+	if err := os.WriteFile(path, nil, 0400); err != nil {
+		t.Fatalf("failed to create marker file: %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("failed to age marker file: %v", err)
+	}
+
+	lock, err := lockfile.Create(path, lockfile.WithSoftLock(time.Minute))
+	if err != nil {
+		t.Fatalf("stale soft lock was not broken: %v", err)
+	}
+	lock.Close()
+}
+
+func TestSoftLockRefresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "refreshed.lock")
+	soft := lockfile.WithSoftLock(time.Minute)
+
+	lock, err := lockfile.Create(path, soft)
+	if err != nil {
+		t.Fatalf("failed to create soft lock: %v", err)
+	}
+	defer lock.Close()
+
+	// A marker that has outlived its stale timeout is kept by refreshing it.
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("failed to age marker file: %v", err)
+	}
+	if err := lock.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if _, err := lockfile.Create(path, soft); !lockfile.IsTemporary(err) {
+		t.Fatalf("expected contention for a refreshed soft lock, got: %v", err)
+	}
+
+	// A marker that was broken and replaced is not refreshed.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	other, err := lockfile.Create(path, soft)
+	if err != nil {
+		t.Fatalf("failed to create soft lock: %v", err)
+	}
+	defer other.Close()
+	if err := lock.Refresh(); !errors.Is(err, lockfile.ErrMoved) {
+		t.Fatalf("expected ErrMoved for a broken soft lock, got: %v", err)
+	}
+}
+
+func TestSoftLockRefreshHooks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hooked.lock")
+	refused := errors.New("refused")
+	hooks := lockfile.Hooks{
+		BeforeOp: func(op lockfile.Op, path string) error {
+			if op == lockfile.OpChtimes {
+				return refused
+			}
+			return nil
+		},
+	}
+
+	lock, err := lockfile.Create(path, lockfile.WithSoftLock(time.Minute), lockfile.WithHooks(hooks))
+	if err != nil {
+		t.Fatalf("failed to create soft lock: %v", err)
+	}
+	defer lock.Close()
+
+	// Refreshing the marker is an operation like any other.
+	if err := lock.Refresh(); !errors.Is(err, refused) {
+		t.Fatalf("expected the BeforeOp hook to refuse the refresh, got: %v", err)
+	}
+}
+
+func TestSoftLockFallbackTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fallback.lock")
+	if _, err := lockfile.Create(path, lockfile.WithSoftLockFallback(0)); !errors.Is(err, lockfile.ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption for a fallback without a stale timeout, got: %v", err)
+	}
+
+	file, err := lockfile.Create(path, lockfile.WithSoftLockFallback(time.Hour))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	file.Close()
+}
+
+func TestStrictFilesystemFallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "strict.lock")
+	if _, err := lockfile.Create(path, lockfile.WithStrictFilesystem(), lockfile.WithSoftLockFallback(time.Hour)); !errors.Is(err, lockfile.ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption for a strict filesystem with a fallback, got: %v", err)
+	}
+
+	file, err := lockfile.Create(path, lockfile.WithStrictFilesystem())
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	file.Close()
+}
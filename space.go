@@ -0,0 +1,100 @@
+package lockfile
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// WithMinFree returns an option that verifies that the filesystem
+// containing a lock file has at least minBytes of free space and minInodes
+// free inodes before the lock file is created.
+//
+// If the filesystem falls below either threshold, lock file creation fails
+// with a [*SpaceError] that wraps [ErrInsufficientSpace]. This surfaces
+// exhausted filesystems close to the real problem, instead of as confusing
+// failures further along. A threshold of zero is not checked. Free inodes
+// are not checked on platforms that don't report them, such as Windows.
+func WithMinFree(minBytes, minInodes uint64) Option {
+	return func(c *config) {
+		c.minFreeBytes = minBytes
+		c.minFreeInodes = minInodes
+	}
+}
+
+// SpaceError reports that the filesystem containing a lock file does not
+// have enough free space or inodes.
+type SpaceError struct {
+	Path       string // The path of the lock file
+	FreeBytes  uint64
+	MinBytes   uint64
+	FreeInodes uint64
+	MinInodes  uint64
+}
+
+// Error returns a description of the shortfall.
+func (e *SpaceError) Error() string {
+	return fmt.Sprintf("%s: %v: %d bytes and %d inodes free, %d bytes and %d inodes required",
+		e.Path, ErrInsufficientSpace, e.FreeBytes, e.FreeInodes, e.MinBytes, e.MinInodes)
+}
+
+// Is returns true if target is [ErrInsufficientSpace].
+func (e *SpaceError) Is(target error) bool {
+	return target == ErrInsufficientSpace
+}
+
+// As sets target to [ReasonExhausted] if it is a [*ReasonCode].
+func (e *SpaceError) As(target any) bool {
+	return setReason(target, ReasonExhausted)
+}
+
+// checkSpace returns an error if the filesystem containing the lock file at
+// path has less free space or fewer free inodes than required.
+func (c *config) checkSpace(path string) error {
+	if c.minFreeBytes == 0 && c.minFreeInodes == 0 {
+		return nil
+	}
+
+	dir := filepath.Dir(path)
+
+	var (
+		bytes, inodes uint64
+		inodesKnown   bool
+	)
+	err := c.do(OpStat, dir, func() (err error) {
+		bytes, inodes, inodesKnown, err = freeSpace(dir)
+		return err
+	})
+	if err != nil {
+		return pathError("statfs", dir, err)
+	}
+
+	short := bytes < c.minFreeBytes
+	if inodesKnown && inodes < c.minFreeInodes {
+		short = true
+	}
+	if !short {
+		return nil
+	}
+
+	return &SpaceError{
+		Path:       path,
+		FreeBytes:  bytes,
+		MinBytes:   c.minFreeBytes,
+		FreeInodes: inodes,
+		MinInodes:  c.minFreeInodes,
+	}
+}
+
+// WithRetryNoSpace returns an option that causes [WaitCtx] to keep waiting
+// when a lock file cannot be created because its filesystem is full or the
+// user's disk quota has been exhausted, as indicated by [ErrNoSpace] and
+// [ErrQuotaExceeded].
+//
+// This is useful in shared scratch directories, where space is often freed
+// by other users. These conditions are retried with a longer backoff than
+// regular contention.
+func WithRetryNoSpace() Option {
+	return func(c *config) {
+		c.retryNoSpace = true
+	}
+}
@@ -0,0 +1,15 @@
+//go:build darwin || dragonfly || freebsd || linux
+
+package lockfile
+
+import "syscall"
+
+// freeSpace returns the number of bytes and inodes that are available to
+// unprivileged users on the filesystem containing dir.
+func freeSpace(dir string) (bytes, inodes uint64, inodesKnown bool, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, 0, false, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), uint64(stat.Ffree), true, nil
+}
@@ -0,0 +1,81 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestMinFree(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "space.lock")
+
+	lock, err := lockfile.Create(path, lockfile.WithMinFree(1, 0))
+	if err != nil {
+		t.Fatalf("failed to create lock file with a small free space requirement: %v", err)
+	}
+	lock.Close()
+
+	_, err = lockfile.Create(path, lockfile.WithMinFree(math.MaxUint64, 0))
+	if !errors.Is(err, lockfile.ErrInsufficientSpace) {
+		t.Fatalf("expected ErrInsufficientSpace, got: %v", err)
+	}
+	var spaceErr *lockfile.SpaceError
+	if !errors.As(err, &spaceErr) || spaceErr.Path != path {
+		t.Fatalf("expected a SpaceError for %s, got: %v", path, err)
+	}
+}
+
+func TestNoSpaceClassified(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "full.lock")
+
+	_, err := lockfile.Create(path, failOpen(noSpaceErr))
+	if !errors.Is(err, lockfile.ErrNoSpace) {
+		t.Fatalf("expected ErrNoSpace, got: %v", err)
+	}
+	if !errors.Is(err, noSpaceErr) {
+		t.Fatalf("the original error was not preserved: %v", err)
+	}
+}
+
+func TestRetryNoSpace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "full.lock")
+
+	// Fail the first open with a full filesystem error, then succeed.
+	var opens atomic.Int32
+	hooks := lockfile.WithHooks(lockfile.Hooks{
+		BeforeOp: func(op lockfile.Op, path string) error {
+			if op == lockfile.OpOpen && opens.Add(1) == 1 {
+				return noSpaceErr
+			}
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	lock, err := lockfile.WaitCtx(ctx, path, hooks, lockfile.WithRetryNoSpace())
+	if err != nil {
+		t.Fatalf("WaitCtx did not retry a full filesystem: %v", err)
+	}
+	lock.Close()
+}
+
+// failOpen returns an option that fails every open operation with err.
+func failOpen(err error) lockfile.Option {
+	return lockfile.WithHooks(lockfile.Hooks{
+		BeforeOp: func(op lockfile.Op, path string) error {
+			if op == lockfile.OpOpen {
+				return err
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,19 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isNoSpace returns true if err indicates that the filesystem is full.
+func isNoSpace(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
+
+// isQuotaExceeded returns true if err indicates that the user's disk quota
+// has been exhausted.
+func isQuotaExceeded(err error) bool {
+	return errors.Is(err, syscall.EDQUOT)
+}
@@ -0,0 +1,44 @@
+//go:build windows
+
+package lockfile
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+// System error codes that indicate a lack of space.
+const (
+	errorHandleDiskFull    syscall.Errno = 39
+	errorDiskFull          syscall.Errno = 112
+	errorDiskQuotaExceeded syscall.Errno = 1295
+)
+
+// freeSpace returns the number of bytes that are available to the calling
+// user on the volume containing dir. Windows does not report inodes.
+func freeSpace(dir string) (bytes, inodes uint64, inodesKnown bool, err error) {
+	dp, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	var available uint64
+	r1, _, e1 := procGetDiskFreeSpaceExW.Call(uintptr(unsafe.Pointer(dp)), uintptr(unsafe.Pointer(&available)), 0, 0)
+	if r1 == 0 {
+		return 0, 0, false, e1
+	}
+
+	return available, 0, false, nil
+}
+
+// isNoSpace returns true if err indicates that the volume is full.
+func isNoSpace(err error) bool {
+	return errors.Is(err, errorDiskFull) || errors.Is(err, errorHandleDiskFull)
+}
+
+// isQuotaExceeded returns true if err indicates that the user's disk quota
+// has been exhausted.
+func isQuotaExceeded(err error) bool {
+	return errors.Is(err, errorDiskQuotaExceeded)
+}
@@ -0,0 +1,180 @@
+package lockfile
+
+import (
+	"os"
+	"time"
+)
+
+// Liveness describes whether the holder of a lock file is still running,
+// as determined by [StaleCheck].
+type Liveness int
+
+const (
+	// LivenessUnknown means that it cannot be determined whether the
+	// holder is running, because the lock file does not record a holder on
+	// this host.
+	LivenessUnknown Liveness = iota
+
+	// LivenessAlive means that the holder is running, or that the lock
+	// file is held.
+	LivenessAlive
+
+	// LivenessDead means that the holder is no longer running.
+	LivenessDead
+
+	// LivenessReused means that a process with the ID of the holder is
+	// running, but it started after the holder did, so the holder is no
+	// longer running.
+	LivenessReused
+
+	// LivenessRebooted means that the host has rebooted since the lock file
+	// was written, so the holder is no longer running.
+	LivenessRebooted
+
+	// LivenessExpired means that the holder's [Lease] on the lock file has
+	// expired without being renewed.
+	LivenessExpired
+)
+
+// String returns a description of the liveness.
+func (l Liveness) String() string {
+	switch l {
+	case LivenessUnknown:
+		return "unknown"
+	case LivenessAlive:
+		return "alive"
+	case LivenessDead:
+		return "dead"
+	case LivenessReused:
+		return "reused"
+	case LivenessRebooted:
+		return "rebooted"
+	case LivenessExpired:
+		return "expired"
+	}
+	return "unknown"
+}
+
+// StaleVerdict is the outcome of checking whether a lock file is stale, as
+// reported by [StaleCheck].
+type StaleVerdict struct {
+	Path     string
+	Liveness Liveness
+
+	// Holder is the holder recorded in the lock file, if any.
+	Holder *Holder
+
+	// Reason explains how the liveness was determined.
+	Reason string
+}
+
+// Stale returns true if the holder of the lock file is known to be no
+// longer running.
+func (v StaleVerdict) Stale() bool {
+	switch v.Liveness {
+	case LivenessDead, LivenessReused, LivenessRebooted, LivenessExpired:
+		return true
+	}
+	return false
+}
+
+// startedTolerance is the largest difference between start times of a
+// process that are considered to be the same. Start times are derived from
+// the boot time of the host on some systems, which is only reported to the
+// nearest second.
+const startedTolerance = 2 * time.Second
+
+// StaleCheck determines whether the holder recorded in the lock file at
+// path is still running. It does not acquire or remove the lock file.
+//
+// A lock file that is held is never stale. Otherwise the process ID of
+// the holder recorded in its metadata is checked, along with the start
+// time of the process and the boot of the host, so that a process that
+// reuses the ID of the holder is not mistaken for it. A holder on another
+// host cannot be checked, and results in [LivenessUnknown], unless it
+// holds a [Lease], which is stale once it expires.
+//
+// A missing lock file is not an error, and results in [LivenessUnknown].
+// Options may be provided to customize its behavior, as for [Inspect].
+func StaleCheck(path string, opts ...Option) (StaleVerdict, error) {
+	verdict, _, err := newConfig(opts).staleCheck(path)
+	return verdict, err
+}
+
+// staleCheck determines whether the holder recorded in the lock file at
+// path is still running, as described by [StaleCheck]. It also returns the
+// inspection that the verdict is based on.
+func (c *config) staleCheck(path string) (StaleVerdict, Inspection, error) {
+	info, err := c.inspect(path)
+	if err != nil {
+		return StaleVerdict{Path: path}, info, err
+	}
+	return c.verdict(info), info, nil
+}
+
+// verdict determines whether the holder of the lock file described by info
+// is still running, as described by [StaleCheck].
+func (c *config) verdict(info Inspection) StaleVerdict {
+	verdict := StaleVerdict{Path: info.Path}
+	if info.Metadata != nil {
+		verdict.Holder = &info.Metadata.Holder
+	}
+
+	switch {
+	case !info.Exists:
+		verdict.Reason = "the lock file does not exist"
+	case info.Held && !c.useSoftLock(info.Path):
+		verdict.Liveness = LivenessAlive
+		verdict.Reason = "the lock file is held"
+	case verdict.Holder == nil || verdict.Holder.PID == 0:
+		verdict.Reason = "the lock file does not record its holder"
+	default:
+		verdict.Liveness, verdict.Reason = checkMetadata(*info.Metadata)
+	}
+
+	return verdict
+}
+
+// checkMetadata determines whether the holder described by md is still
+// running, or still holds a lease on the lock file.
+func checkMetadata(md Metadata) (Liveness, string) {
+	if !md.Expires.IsZero() {
+		if time.Now().After(md.Expires) {
+			return LivenessExpired, "the lease of the holder has expired"
+		}
+		if remoteHolder(md.Holder) {
+			return LivenessAlive, "the lease of the holder has not expired"
+		}
+	}
+	return checkHolder(md.Holder)
+}
+
+// checkHolder determines whether holder is still running.
+func checkHolder(holder Holder) (Liveness, string) {
+	if remoteHolder(holder) {
+		return LivenessUnknown, "the holder is on another host"
+	}
+
+	if boot := bootID(); holder.Boot != "" && boot != "" && holder.Boot != boot {
+		return LivenessRebooted, "the host has rebooted since the lock file was written"
+	}
+
+	if !processAlive(holder.PID) {
+		return LivenessDead, "the holder is not running"
+	}
+
+	if !holder.Started.IsZero() {
+		started := processStarted(holder.PID)
+		if !started.IsZero() && started.Sub(holder.Started).Abs() > startedTolerance {
+			return LivenessReused, "the process ID of the holder has been reused"
+		}
+	}
+
+	return LivenessAlive, "the holder is running"
+}
+
+// remoteHolder returns true if holder is known to be on another host.
+func remoteHolder(holder Holder) bool {
+	hostname, _ := os.Hostname()
+	return holder.Hostname != "" && holder.Hostname != hostname
+}
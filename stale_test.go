@@ -0,0 +1,99 @@
+package lockfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestStaleCheck(t *testing.T) {
+	dir := t.TempDir()
+
+	exited := exitedPID(t)
+
+	current := lockfile.CurrentHolder()
+	reused := current
+	reused.Started = current.Started.Add(-time.Hour)
+	rebooted := current
+	rebooted.Boot = "another-boot"
+	remote := current
+	remote.Hostname = "another-host.invalid"
+	dead := current
+	dead.PID, dead.Started = exited, time.Time{}
+
+	tests := []struct {
+		name     string
+		holder   lockfile.Holder
+		liveness lockfile.Liveness
+		skip     bool
+	}{
+		{"alive", current, lockfile.LivenessAlive, false},
+		{"dead", dead, lockfile.LivenessDead, false},
+		{"reused", reused, lockfile.LivenessReused, current.Started.IsZero()},
+		{"rebooted", rebooted, lockfile.LivenessRebooted, current.Boot == ""},
+		{"remote", remote, lockfile.LivenessUnknown, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.skip {
+				t.Skip("not supported on this system")
+			}
+
+			md := lockfile.NewMetadata(0)
+			md.Holder = test.holder
+			data, err := lockfile.EncodeMetadata(md, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, test.name+".lock")
+			if err := os.WriteFile(path, data, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			verdict, err := lockfile.StaleCheck(path)
+			if err != nil {
+				t.Fatalf("StaleCheck failed: %v", err)
+			}
+			if verdict.Liveness != test.liveness {
+				t.Fatalf("liveness %s, want %s (%s)", verdict.Liveness, test.liveness, verdict.Reason)
+			}
+			if verdict.Holder == nil || verdict.Holder.PID != test.holder.PID {
+				t.Fatalf("unexpected holder: %+v", verdict.Holder)
+			}
+			want := test.liveness != lockfile.LivenessAlive && test.liveness != lockfile.LivenessUnknown
+			if verdict.Stale() != want {
+				t.Fatalf("Stale() returned %t, want %t", verdict.Stale(), want)
+			}
+		})
+	}
+}
+
+func TestStaleCheckHeld(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "held.lock")
+
+	verdict, err := lockfile.StaleCheck(path)
+	if err != nil {
+		t.Fatalf("StaleCheck failed for a missing lock file: %v", err)
+	}
+	if verdict.Liveness != lockfile.LivenessUnknown || verdict.Stale() {
+		t.Fatalf("unexpected verdict for a missing lock file: %+v", verdict)
+	}
+
+	file, err := lockfile.Create(path, lockfile.WithMetadata(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	verdict, err = lockfile.StaleCheck(path)
+	if err != nil {
+		t.Fatalf("StaleCheck failed for a held lock file: %v", err)
+	}
+	if verdict.Liveness != lockfile.LivenessAlive || verdict.Stale() {
+		t.Fatalf("unexpected verdict for a held lock file: %+v", verdict)
+	}
+}
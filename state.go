@@ -0,0 +1,111 @@
+package lockfile
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// State is the state of a lock held by a [File] and its duplicates.
+type State int
+
+const (
+	// StateAcquired means that the lock is held.
+	StateAcquired State = iota
+
+	// StateReleasing means that the last reference to the lock has been
+	// closed, and the lock file is being deleted.
+	StateReleasing
+
+	// StateReleased means that the lock has been released.
+	StateReleased
+
+	// StateLost means that the lock is no longer held, because its lock
+	// file was moved or deleted by someone else while it was held.
+	StateLost
+)
+
+// String returns a description of the state.
+func (s State) String() string {
+	switch s {
+	case StateAcquired:
+		return "acquired"
+	case StateReleasing:
+		return "releasing"
+	case StateReleased:
+		return "released"
+	case StateLost:
+		return "lost"
+	}
+	return "unknown"
+}
+
+// lifecycle records the state of a lock, and notifies observers when it
+// changes. It has its own mutex, so that the state can be observed while a
+// lock is being released.
+type lifecycle struct {
+	mutex   sync.Mutex
+	state   State
+	changed chan struct{} // Created on demand, closed on the next transition
+}
+
+// State returns the current state of the lock held by f.
+//
+// The state is shared by f and its duplicates. It reflects the lock rather
+// than this reference to it, so a closed File reports StateAcquired for as
+// long as some duplicate of it remains open.
+func (f *File) State() State {
+	f.h.lifecycle.mutex.Lock()
+	defer f.h.lifecycle.mutex.Unlock()
+
+	return f.h.lifecycle.state
+}
+
+// StateChanged returns a channel that is closed once the state of the lock
+// held by f is no longer from. If the state already differs from from, the
+// returned channel is closed.
+//
+// Observers typically call [File.State], wait on the channel returned for
+// that state, and repeat until the lock reaches [StateReleased] or
+// [StateLost], which are final.
+func (f *File) StateChanged(from State) <-chan struct{} {
+	l := &f.h.lifecycle
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.state != from {
+		return closedChan
+	}
+	if l.changed == nil {
+		l.changed = make(chan struct{})
+	}
+	return l.changed
+}
+
+// transition moves the lock to the given state and notifies observers. It
+// does nothing if the lock has already reached a final state.
+func (l *lifecycle) transition(state State) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.state == state || l.state == StateReleased || l.state == StateLost {
+		return
+	}
+
+	l.state = state
+	if l.changed != nil {
+		close(l.changed)
+		l.changed = nil
+	}
+}
+
+// finish moves the lock to its final state, which depends on the error
+// that was returned when it was released. A lock file that was found to be
+// missing or replaced during release had already been lost.
+func (l *lifecycle) finish(err error) {
+	if errors.Is(err, ErrMoved) || errors.Is(err, os.ErrNotExist) {
+		l.transition(StateLost)
+	} else {
+		l.transition(StateReleased)
+	}
+}
@@ -0,0 +1,74 @@
+package lockfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestFileState(t *testing.T) {
+	file, err := lockfile.Create(filepath.Join(t.TempDir(), "state.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dup, err := file.Dup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state := file.State(); state != lockfile.StateAcquired {
+		t.Fatalf("unexpected initial state: %v", state)
+	}
+	changed := file.StateChanged(lockfile.StateAcquired)
+
+	// Closing one of two references leaves the lock held.
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+		t.Fatalf("the state changed while a reference remained open: %v", file.State())
+	default:
+	}
+
+	if err := dup.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatalf("the state did not change when the lock was released")
+	}
+
+	if state := file.State(); state != lockfile.StateReleased {
+		t.Fatalf("unexpected final state: %v", state)
+	}
+
+	select {
+	case <-file.StateChanged(lockfile.StateAcquired):
+	default:
+		t.Fatalf("StateChanged returned an open channel for a past state")
+	}
+}
+
+func TestFileStateLost(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.lock")
+	file, err := lockfile.Create(path, lockfile.WithSoftLock(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting the lock file out from under its holder loses the lock.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	if state := file.State(); state != lockfile.StateLost {
+		t.Fatalf("unexpected state after the lock file was deleted: %v", state)
+	}
+}
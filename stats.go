@@ -0,0 +1,33 @@
+package lockfile
+
+import "time"
+
+// Stats describes how a lock file was acquired.
+type Stats struct {
+	// Acquired is the time at which the lock file was created.
+	Acquired time.Time
+
+	// Attempts is the number of attempts that were made to acquire the
+	// lock file, including the successful one. It is 1 if the lock file
+	// was acquired immediately.
+	Attempts int
+
+	// Wait is the amount of time that was spent waiting for the lock file
+	// before it was acquired. It is zero if the lock file was acquired
+	// immediately.
+	Wait time.Duration
+}
+
+// Waited returns true if acquisition of the lock file required waiting
+// for it to be released by another holder.
+//
+// Callers can use this to adjust their behavior, such as by skipping work
+// that another holder is likely to have just completed while they waited.
+func (s Stats) Waited() bool {
+	return s.Attempts > 1
+}
+
+// Stats returns statistics about the acquisition of the lock file.
+func (f *File) Stats() Stats {
+	return f.h.stats
+}
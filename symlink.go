@@ -0,0 +1,170 @@
+package lockfile
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SymlinkBackend is a [Backend] that uses the creation of a symbolic link
+// as the lock. A lock is held while a symbolic link exists at its path.
+//
+// Creating a symbolic link is atomic, even on many network filesystems on
+// which flock and byte-range locks cannot be trusted. The target of the
+// link does not exist: it encodes the holder of the lock in the
+// [FormatSymlink] format, so that the holder can be read with a single
+// readlink, and is written atomically along with the lock itself.
+//
+// Because the holder travels with the link, a link left behind by a
+// process that crashed is recognized from the link alone. A link whose
+// holder has exited, as determined by [StaleCheck], or whose holder is on
+// another host and which is older than the staleness threshold of the
+// backend, is removed by the next caller of TryAcquire. A holder whose link
+// was removed learns of it from the error wrapping [ErrMoved] that it
+// receives on release.
+//
+// Only exclusive locks are supported, and there is no file to hold open,
+// so features that read or write the lock file are not available. On
+// Windows, creating symbolic links requires a privilege that processes do
+// not have by default.
+type SymlinkBackend struct {
+	staleAfter time.Duration
+}
+
+// NewSymlinkBackend returns a [SymlinkBackend] that breaks a link whose
+// holder is on another host once the link is older than staleAfter. Such
+// links are never broken if staleAfter is zero or less.
+//
+// The modification time of a symbolic link is set when it is created, so
+// staleAfter must exceed the longest time a lock is held, plus any clock
+// skew between the hosts.
+func NewSymlinkBackend(staleAfter time.Duration) *SymlinkBackend {
+	return &SymlinkBackend{staleAfter: staleAfter}
+}
+
+// TryAcquire makes a single attempt to create the symbolic link with the
+// given path, breaking it first if it is stale. It returns an
+// [*os.PathError] that wraps [os.ErrExist] if the lock is held by someone
+// else.
+func (b *SymlinkBackend) TryAcquire(path string, shared bool) (BackendLock, error) {
+	if shared {
+		return BackendLock{}, &os.PathError{Op: "acquire", Path: path, Err: fmt.Errorf("%w: the symlink backend does not support shared locks", ErrInvalidOption)}
+	}
+
+	target := symlinkTarget(CurrentHolder())
+
+	return tryAcquireOrBreak(path, func() (BackendLock, error) {
+		if err := os.Symlink(target, path); err != nil {
+			return BackendLock{}, err
+		}
+		return BackendLock{Value: target}, nil
+	}, b.stale, b.ReadLock)
+}
+
+// Release removes the symbolic link with the given path. It returns an
+// [*os.PathError] that wraps [ErrMoved] if the lock was broken by someone
+// else while it was held, in which case the link is left alone.
+func (b *SymlinkBackend) Release(path string, lock BackendLock) error {
+	target, err := os.Readlink(path)
+	owned, _ := lock.Value.(string)
+	if err != nil || target != owned {
+		return &os.PathError{Op: "release", Path: path, Err: ErrMoved}
+	}
+	return os.Remove(path)
+}
+
+// Guarantees returns the guarantees provided by the symbolic links of the
+// backend, which the operating system does not release. A link whose
+// holder cannot be checked is broken once it is older than the staleness
+// threshold of the backend.
+func (b *SymlinkBackend) Guarantees(shared bool) Guarantees {
+	return Guarantees{
+		Exclusive:  !shared,
+		StaleAfter: max(b.staleAfter, 0),
+	}
+}
+
+// Probe reports whether the symbolic link with the given path exists, and
+// whether it is held. A stale lock exists but is not held.
+func (b *SymlinkBackend) Probe(path string) (exists, held bool, err error) {
+	_, err = os.Lstat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return false, false, nil
+	case err != nil:
+		return false, false, err
+	}
+	_, stale := b.stale(path)
+	return true, !stale, nil
+}
+
+// ReadLock returns the target of the symbolic link with the given path.
+func (b *SymlinkBackend) ReadLock(path string) ([]byte, error) {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(target), nil
+}
+
+// stale returns true if the symbolic link at path was left behind by a
+// holder that is no longer running. It also returns the target of the link
+// that the decision was based on. Anything other than a symbolic link is
+// never stale, because it was not created by the backend.
+func (b *SymlinkBackend) stale(path string) ([]byte, bool) {
+	data, err := b.ReadLock(path)
+	if err != nil {
+		return nil, false
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return data, false
+	}
+	return data, staleHolder(data, info.ModTime(), b.staleAfter)
+}
+
+// symlinkTarget returns the target of a symbolic link that records holder
+// in the [FormatSymlink] format. Each call returns a different target.
+func symlinkTarget(holder Holder) string {
+	var nonce [8]byte
+	rand.Read(nonce[:])
+
+	var started int64
+	if !holder.Started.IsZero() {
+		started = holder.Started.UnixNano()
+	}
+
+	return fmt.Sprintf("%d@%s:%d:%s", holder.PID, holder.Hostname, started, hex.EncodeToString(nonce[:]))
+}
+
+// parseSymlinkTarget parses a holder in the [FormatSymlink] format.
+func parseSymlinkTarget(s string) (Holder, bool) {
+	id, rest, found := strings.Cut(s, "@")
+	if !found {
+		return Holder{}, false
+	}
+	pid, ok := parsePID(id)
+	if !ok {
+		return Holder{}, false
+	}
+
+	fields := strings.Split(rest, ":")
+	if len(fields) != 3 || fields[0] == "" {
+		return Holder{}, false
+	}
+	started, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return Holder{}, false
+	}
+
+	holder := Holder{PID: pid, Hostname: fields[0]}
+	if started != 0 {
+		holder.Started = time.Unix(0, started)
+	}
+	return holder, true
+}
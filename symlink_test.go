@@ -0,0 +1,82 @@
+package lockfile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestSymlinkBackend(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links requires a privilege on Windows")
+	}
+
+	backend := lockfile.WithBackend(lockfile.NewSymlinkBackend(0))
+	path := filepath.Join(t.TempDir(), "symlink.lock")
+
+	file, err := lockfile.Create(path, backend)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if g := file.Guarantees(); !g.Exclusive || g.KernelLock || g.StaleAfter != 0 {
+		t.Fatalf("unexpected guarantees: %+v", g)
+	}
+	if _, err := lockfile.Create(path, backend); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected ErrExist while the lock is held, got: %v", err)
+	}
+
+	info, err := lockfile.Inspect(path, backend)
+	if err != nil || !info.Held {
+		t.Fatalf("expected Inspect to report the lock as held, got %+v, %v", info, err)
+	}
+	if info.Format != lockfile.FormatSymlink || info.Metadata.Holder.PID != os.Getpid() {
+		t.Fatalf("Inspect did not report the holder: %q, %+v", info.Format, info.Metadata)
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Lstat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the symbolic link was not removed: %v", err)
+	}
+}
+
+func TestSymlinkBackendStale(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links requires a privilege on Windows")
+	}
+
+	backend := lockfile.WithBackend(lockfile.NewSymlinkBackend(0))
+	path := filepath.Join(t.TempDir(), "symlink.lock")
+	hostname, _ := os.Hostname()
+
+	// A holder on this host that is no longer running is broken.
+	if err := os.Symlink("4194303@"+hostname+":0:00", path); err != nil {
+		t.Fatal(err)
+	}
+	file, err := lockfile.Create(path, backend)
+	if err != nil {
+		t.Fatalf("Create did not break the lock of a dead holder: %v", err)
+	}
+
+	// Someone else breaks the lock and acquires it.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	other, err := lockfile.Create(path, backend)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer other.Close()
+
+	if err := file.Close(); !errors.Is(err, lockfile.ErrMoved) {
+		t.Fatalf("expected ErrMoved from the broken lock, got: %v", err)
+	}
+	if _, err := os.Lstat(path); err != nil {
+		t.Fatalf("releasing the broken lock removed the new holder's link: %v", err)
+	}
+}
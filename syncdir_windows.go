@@ -0,0 +1,12 @@
+//go:build windows
+
+package lockfile
+
+// syncDir flushes the directory with the given path to disk, so that the
+// creation, removal or renaming of the files within it is durable.
+//
+// Directories cannot be opened for writing on Windows, so they cannot be
+// flushed, and NTFS journals changes to them instead. This does nothing.
+func syncDir(path string) error {
+	return nil
+}
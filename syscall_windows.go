@@ -4,6 +4,31 @@ package lockfile
 
 import (
 	"syscall"
+	"unsafe"
+)
+
+var (
+	modkernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	procGetVolumePathNameW    = modkernel32.NewProc("GetVolumePathNameW")
+	procGetVolumeInformationW = modkernel32.NewProc("GetVolumeInformationW")
+	procGetDiskFreeSpaceExW   = modkernel32.NewProc("GetDiskFreeSpaceExW")
+	procGetDiskFreeSpaceW     = modkernel32.NewProc("GetDiskFreeSpaceW")
+
+	procGetFinalPathNameByHandleW = modkernel32.NewProc("GetFinalPathNameByHandleW")
+
+	procSetFileInformationByHandle = modkernel32.NewProc("SetFileInformationByHandle")
+
+	procLockFileEx   = modkernel32.NewProc("LockFileEx")
+	procUnlockFileEx = modkernel32.NewProc("UnlockFileEx")
+
+	procGetOverlappedResult = modkernel32.NewProc("GetOverlappedResult")
+)
+
+// Flags for lockFileEx.
+const (
+	lockfileFailImmediately = 0x00000001
+	lockfileExclusiveLock   = 0x00000002
 )
 
 // createFile opens or creates a file by its name. The file will be opened
@@ -24,3 +49,111 @@ func createFile(fileName string, access, shareMode, createMode, flagsAndAttribut
 
 	return syscall.CreateFile(fnp, access, shareMode, nil, createMode, flagsAndAttributes, 0)
 }
+
+// getVolumePathName returns the mount point of the volume that contains
+// the given path.
+func getVolumePathName(fileName string) (string, error) {
+	fnp, err := syscall.UTF16PtrFromString(fileName)
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]uint16, syscall.MAX_PATH+1)
+	r1, _, e1 := procGetVolumePathNameW.Call(uintptr(unsafe.Pointer(fnp)), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	if r1 == 0 {
+		return "", e1
+	}
+
+	return syscall.UTF16ToString(buf), nil
+}
+
+// getClusterSize returns the size in bytes of the clusters of the volume
+// that contains the given path.
+func getClusterSize(fileName string) (int64, error) {
+	root, err := getVolumePathName(fileName)
+	if err != nil {
+		return 0, err
+	}
+
+	rp, err := syscall.UTF16PtrFromString(root)
+	if err != nil {
+		return 0, err
+	}
+
+	var sectorsPerCluster, bytesPerSector uint32
+	r1, _, e1 := procGetDiskFreeSpaceW.Call(uintptr(unsafe.Pointer(rp)), uintptr(unsafe.Pointer(&sectorsPerCluster)), uintptr(unsafe.Pointer(&bytesPerSector)), 0, 0)
+	if r1 == 0 {
+		return 0, e1
+	}
+
+	return int64(sectorsPerCluster) * int64(bytesPerSector), nil
+}
+
+// getFileSystemName returns the name of the file system used by the
+// volume mounted at the given root path, such as "NTFS" or "FAT32".
+func getFileSystemName(rootPath string) (string, error) {
+	rpp, err := syscall.UTF16PtrFromString(rootPath)
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]uint16, syscall.MAX_PATH+1)
+	r1, _, e1 := procGetVolumeInformationW.Call(uintptr(unsafe.Pointer(rpp)), 0, 0, 0, 0, 0, uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	if r1 == 0 {
+		return "", e1
+	}
+
+	return syscall.UTF16ToString(buf), nil
+}
+
+// getFinalPathName returns the path of the file that is open as handle.
+func getFinalPathName(handle syscall.Handle) (string, error) {
+	buf := make([]uint16, syscall.MAX_PATH+1)
+	for {
+		r1, _, e1 := procGetFinalPathNameByHandleW.Call(uintptr(handle), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), 0)
+		if r1 == 0 {
+			return "", e1
+		}
+		if int(r1) < len(buf) {
+			return syscall.UTF16ToString(buf[:r1]), nil
+		}
+		buf = make([]uint16, r1)
+	}
+}
+
+// lockFileEx locks the byte at offset of the file with the given handle.
+func lockFileEx(handle syscall.Handle, flags uint32, offset int64) error {
+	ol := syscall.Overlapped{Offset: uint32(offset), OffsetHigh: uint32(offset >> 32)}
+	r1, _, e1 := procLockFileEx.Call(uintptr(handle), uintptr(flags), 0, 1, 0, uintptr(unsafe.Pointer(&ol)))
+	if r1 == 0 {
+		return e1
+	}
+	return nil
+}
+
+// unlockFileEx unlocks the byte at offset of the file with the given
+// handle.
+func unlockFileEx(handle syscall.Handle, offset int64) error {
+	ol := syscall.Overlapped{Offset: uint32(offset), OffsetHigh: uint32(offset >> 32)}
+	r1, _, e1 := procUnlockFileEx.Call(uintptr(handle), 0, 1, 0, uintptr(unsafe.Pointer(&ol)))
+	if r1 == 0 {
+		return e1
+	}
+	return nil
+}
+
+// getOverlappedResult returns the number of bytes transferred by the
+// overlapped request ov on handle. If wait is true, it waits for the
+// request to complete.
+func getOverlappedResult(handle syscall.Handle, ov *syscall.Overlapped, wait bool) (uint32, error) {
+	var n uint32
+	var w uintptr
+	if wait {
+		w = 1
+	}
+	r1, _, e1 := procGetOverlappedResult.Call(uintptr(handle), uintptr(unsafe.Pointer(ov)), uintptr(unsafe.Pointer(&n)), w)
+	if r1 == 0 {
+		return 0, e1
+	}
+	return n, nil
+}
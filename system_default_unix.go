@@ -0,0 +1,7 @@
+//go:build (darwin || dragonfly || freebsd || linux || openbsd) && !(linux && lockfile_iouring)
+
+package lockfile
+
+// defaultSystem is the system used by configurations that do not route
+// operations through hooks, timeouts or worker pools.
+var defaultSystem system = directSystem{}
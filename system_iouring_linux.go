@@ -0,0 +1,511 @@
+//go:build linux && lockfile_iouring
+
+package lockfile
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// This file provides an experimental system that performs blocking
+// filesystem operations through io_uring. It is enabled by building with
+// the lockfile_iouring tag.
+//
+// Operations are submitted to a single ring that is shared by the whole
+// process. One goroutine waits for their completion, so a stalled
+// filesystem occupies kernel workers instead of one operating system
+// thread per blocked operation. The flock operation is not supported by
+// io_uring, and is always performed directly. If io_uring is unavailable,
+// or does not support the required operations, every operation is
+// performed directly.
+//
+// See the io_uring(7) man page for details.
+
+// defaultSystem is the system used by configurations that do not route
+// operations through hooks, timeouts or worker pools.
+var defaultSystem system = uringSystem{}
+
+const (
+	sysIOUringSetup    = 425
+	sysIOUringEnter    = 426
+	sysIOUringRegister = 427
+
+	uringEntries = 256
+
+	uringOffSQRing = 0
+	uringOffCQRing = 0x8000000
+	uringOffSQEs   = 0x10000000
+
+	uringEnterGetEvents = 1
+	uringRegisterProbe  = 8
+	uringOpSupported    = 1
+
+	uringOpOpenAt   = 18
+	uringOpClose    = 19
+	uringOpStatx    = 21
+	uringOpUnlinkAt = 36
+
+	atFDCWD      = -100
+	atEmptyPath  = 0x1000
+	statxBasic   = 0x7ff
+	uringPathMax = 4096
+)
+
+// uringParams is struct io_uring_params.
+type uringParams struct {
+	sqEntries    uint32
+	cqEntries    uint32
+	flags        uint32
+	sqThreadCPU  uint32
+	sqThreadIdle uint32
+	features     uint32
+	wqFd         uint32
+	resv         [3]uint32
+	sqOff        [10]uint32 // struct io_sqring_offsets
+	cqOff        [10]uint32 // struct io_cqring_offsets
+}
+
+// uringSQE is struct io_uring_sqe.
+type uringSQE struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	opFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFdIn  int32
+	addr3       uint64
+	pad         uint64
+}
+
+// uringCQE is struct io_uring_cqe.
+type uringCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// uringStatx is struct statx.
+type uringStatx struct {
+	mask           uint32
+	blksize        uint32
+	attributes     uint64
+	nlink          uint32
+	uid            uint32
+	gid            uint32
+	mode           uint16
+	_              uint16
+	ino            uint64
+	size           uint64
+	blocks         uint64
+	attributesMask uint64
+	atime          uringTimestamp
+	btime          uringTimestamp
+	ctime          uringTimestamp
+	mtime          uringTimestamp
+	rdevMajor      uint32
+	rdevMinor      uint32
+	devMajor       uint32
+	devMinor       uint32
+	_              [14]uint64
+}
+
+// uringTimestamp is struct statx_timestamp.
+type uringTimestamp struct {
+	sec  int64
+	nsec uint32
+	_    int32
+}
+
+// uringRequest is an operation that has been submitted to the ring. The
+// kernel reads from and writes to its buffers until the operation
+// completes, so requests are kept reachable by the ring until then.
+type uringRequest struct {
+	path  [uringPathMax]byte
+	statx uringStatx
+	res   int32
+	done  chan struct{}
+}
+
+var uringRequests = sync.Pool{
+	New: func() any {
+		return &uringRequest{done: make(chan struct{}, 1)}
+	},
+}
+
+// uringRing is an io_uring instance and its shared memory.
+type uringRing struct {
+	fd    int
+	slots chan struct{} // Bounds the number of operations in flight
+
+	sqHead  *atomic.Uint32
+	sqTail  *atomic.Uint32
+	sqMask  uint32
+	sqArray []uint32
+	sqes    []uringSQE
+
+	cqHead *atomic.Uint32
+	cqTail *atomic.Uint32
+	cqMask uint32
+	cqes   []uringCQE
+
+	mutex    sync.Mutex // Guards submission and inflight
+	inflight []*uringRequest
+	free     []uint64 // Unused indices in inflight
+}
+
+// uring returns the process-wide ring, or nil if io_uring is unavailable.
+var uring = sync.OnceValue(func() *uringRing {
+	ring, err := newUringRing()
+	if err != nil {
+		return nil
+	}
+	return ring
+})
+
+// newUringRing sets up a ring, and starts the goroutine that collects its
+// completions.
+func newUringRing() (*uringRing, error) {
+	var params uringParams
+	fd, _, errno := syscall.Syscall(sysIOUringSetup, uringEntries, uintptr(unsafe.Pointer(&params)), 0)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	ring, err := mapUringRing(int(fd), &params)
+	if err != nil {
+		syscall.Close(int(fd))
+		return nil, err
+	}
+
+	go ring.reap()
+
+	return ring, nil
+}
+
+// mapUringRing maps the shared memory of the ring that is open as fd, and
+// verifies that it supports the required operations.
+func mapUringRing(fd int, params *uringParams) (*uringRing, error) {
+	if !uringSupports(fd, uringOpOpenAt, uringOpClose, uringOpStatx, uringOpUnlinkAt) {
+		return nil, syscall.ENOTSUP
+	}
+
+	sqOff, cqOff := params.sqOff, params.cqOff
+	sqSize := int(sqOff[6] + params.sqEntries*4) // array + entries
+	cqSize := int(cqOff[5] + params.cqEntries*uint32(unsafe.Sizeof(uringCQE{})))
+	sqesSize := int(params.sqEntries * uint32(unsafe.Sizeof(uringSQE{})))
+
+	prot, flags := syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE
+	sq, err := syscall.Mmap(fd, uringOffSQRing, sqSize, prot, flags)
+	if err != nil {
+		return nil, err
+	}
+	cq, err := syscall.Mmap(fd, uringOffCQRing, cqSize, prot, flags)
+	if err != nil {
+		syscall.Munmap(sq)
+		return nil, err
+	}
+	sqes, err := syscall.Mmap(fd, uringOffSQEs, sqesSize, prot, flags)
+	if err != nil {
+		syscall.Munmap(sq)
+		syscall.Munmap(cq)
+		return nil, err
+	}
+
+	u32 := func(mem []byte, off uint32) *atomic.Uint32 {
+		return (*atomic.Uint32)(unsafe.Pointer(&mem[off]))
+	}
+
+	return &uringRing{
+		fd:       fd,
+		slots:    make(chan struct{}, params.sqEntries),
+		sqHead:   u32(sq, sqOff[0]),
+		sqTail:   u32(sq, sqOff[1]),
+		sqMask:   u32(sq, sqOff[2]).Load(),
+		sqArray:  unsafe.Slice((*uint32)(unsafe.Pointer(&sq[sqOff[6]])), params.sqEntries),
+		sqes:     unsafe.Slice((*uringSQE)(unsafe.Pointer(&sqes[0])), params.sqEntries),
+		cqHead:   u32(cq, cqOff[0]),
+		cqTail:   u32(cq, cqOff[1]),
+		cqMask:   u32(cq, cqOff[2]).Load(),
+		cqes:     unsafe.Slice((*uringCQE)(unsafe.Pointer(&cq[cqOff[5]])), params.cqEntries),
+		inflight: make([]*uringRequest, params.sqEntries),
+		free:     uringIndices(params.sqEntries),
+	}, nil
+}
+
+// uringSupports returns true if the ring that is open as fd supports all
+// of the given operations.
+func uringSupports(fd int, ops ...uint8) bool {
+	var probe struct {
+		lastOp uint8
+		opsLen uint8
+		_      uint16
+		_      [3]uint32
+		ops    [256]struct {
+			op    uint8
+			_     uint8
+			flags uint16
+			_     uint32
+		}
+	}
+	_, _, errno := syscall.Syscall6(sysIOUringRegister, uintptr(fd), uringRegisterProbe, uintptr(unsafe.Pointer(&probe)), 256, 0, 0)
+	if errno != 0 {
+		return false
+	}
+	for _, op := range ops {
+		if op > probe.lastOp || probe.ops[op].flags&uringOpSupported == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// uringIndices returns the indices from 0 to n-1.
+func uringIndices(n uint32) []uint64 {
+	indices := make([]uint64, n)
+	for i := range indices {
+		indices[i] = uint64(i)
+	}
+	return indices
+}
+
+// submit submits an operation described by sqe on behalf of req, and waits
+// for it to complete. It returns the result of the operation.
+func (r *uringRing) submit(req *uringRequest, sqe uringSQE) int32 {
+	r.slots <- struct{}{}
+	defer func() { <-r.slots }()
+
+	r.mutex.Lock()
+	id := r.free[len(r.free)-1]
+	r.free = r.free[:len(r.free)-1]
+	r.inflight[id] = req
+
+	tail := r.sqTail.Load()
+	index := tail & r.sqMask
+	sqe.userData = id
+	r.sqes[index] = sqe
+	r.sqArray[index] = index
+	r.sqTail.Store(tail + 1)
+
+	head := r.sqHead.Load()
+	for {
+		_, _, errno := syscall.Syscall6(sysIOUringEnter, uintptr(r.fd), uintptr(tail+1-head), 0, 0, 0, 0)
+		if errno == 0 {
+			break
+		}
+		if errno != syscall.EINTR {
+			r.withdraw(head, tail+1, errno)
+			break
+		}
+	}
+	r.mutex.Unlock()
+
+	<-req.done
+	return req.res
+}
+
+// withdraw removes the entries from head up to tail, which the kernel
+// refused to consume, from the submission queue, and completes their
+// operations with errno. The caller must hold r.mutex.
+func (r *uringRing) withdraw(head, tail uint32, errno syscall.Errno) {
+	for i := head; i != tail; i++ {
+		id := r.sqes[r.sqArray[i&r.sqMask]].userData
+		req := r.inflight[id]
+		r.inflight[id] = nil
+		r.free = append(r.free, id)
+
+		req.res = -int32(errno)
+		req.done <- struct{}{}
+	}
+	r.sqTail.Store(head)
+}
+
+// reap waits for operations to complete, and hands their results back to
+// the goroutines that submitted them. It runs for the life of the process.
+func (r *uringRing) reap() {
+	for {
+		syscall.Syscall6(sysIOUringEnter, uintptr(r.fd), 0, 1, uringEnterGetEvents, 0, 0)
+
+		head, tail := r.cqHead.Load(), r.cqTail.Load()
+		for ; head != tail; head++ {
+			cqe := r.cqes[head&r.cqMask]
+
+			r.mutex.Lock()
+			req := r.inflight[cqe.userData]
+			r.inflight[cqe.userData] = nil
+			r.free = append(r.free, cqe.userData)
+			r.mutex.Unlock()
+
+			req.res = cqe.res
+			req.done <- struct{}{}
+		}
+		r.cqHead.Store(head)
+	}
+}
+
+// uringResult converts the result of an operation to an error.
+func uringResult(res int32) error {
+	if res < 0 {
+		return syscall.Errno(-res)
+	}
+	return nil
+}
+
+// uringSystem performs operations through io_uring if it is available,
+// and directly otherwise.
+type uringSystem struct{}
+
+// request returns a request with its path buffer set to path, or false if
+// the operation should be performed directly because io_uring is
+// unavailable or the path is too long.
+func (uringSystem) request(path string) (*uringRing, *uringRequest, bool) {
+	ring := uring()
+	if ring == nil || len(path) >= uringPathMax {
+		return nil, nil, false
+	}
+	req := uringRequests.Get().(*uringRequest)
+	n := copy(req.path[:], path)
+	req.path[n] = 0
+	return ring, req, true
+}
+
+func (s uringSystem) open(path string, flag int, perm uint32) (int, error) {
+	ring, req, ok := s.request(path)
+	if !ok {
+		return directSystem{}.open(path, flag, perm)
+	}
+	defer uringRequests.Put(req)
+
+	for {
+		res := ring.submit(req, uringSQE{
+			opcode:  uringOpOpenAt,
+			fd:      atFDCWD,
+			addr:    uint64(uintptr(unsafe.Pointer(&req.path[0]))),
+			len:     perm,
+			opFlags: uint32(flag | syscall.O_CLOEXEC),
+		})
+		if err := uringResult(res); err != syscall.EINTR {
+			if err != nil {
+				return -1, err
+			}
+			return int(res), nil
+		}
+	}
+}
+
+func (uringSystem) flock(path string, fd int, how int) error {
+	return directSystem{}.flock(path, fd, how)
+}
+
+func (s uringSystem) fstat(path string, fd int) (syscall.Stat_t, error) {
+	return s.statx(path, fd, "", atEmptyPath)
+}
+
+func (s uringSystem) stat(path string) (syscall.Stat_t, error) {
+	return s.statx(path, atFDCWD, path, 0)
+}
+
+// statx retrieves the status of the file identified by dirfd and name,
+// which is related to the lock file with the given path.
+func (s uringSystem) statx(path string, dirfd int, name string, flags uint32) (syscall.Stat_t, error) {
+	ring, req, ok := s.request(name)
+	if !ok {
+		if name == "" {
+			return directSystem{}.fstat(path, dirfd)
+		}
+		return directSystem{}.stat(path)
+	}
+	defer uringRequests.Put(req)
+
+	res := ring.submit(req, uringSQE{
+		opcode:  uringOpStatx,
+		fd:      int32(dirfd),
+		off:     uint64(uintptr(unsafe.Pointer(&req.statx))),
+		addr:    uint64(uintptr(unsafe.Pointer(&req.path[0]))),
+		len:     statxBasic,
+		opFlags: flags,
+	})
+	if err := uringResult(res); err != nil {
+		return syscall.Stat_t{}, err
+	}
+
+	return req.statx.stat(), nil
+}
+
+// stat converts x to the equivalent [syscall.Stat_t]. The types of its
+// fields vary between architectures.
+func (x *uringStatx) stat() (st syscall.Stat_t) {
+	setInt(&st.Dev, mkdev(x.devMajor, x.devMinor))
+	setInt(&st.Ino, x.ino)
+	setInt(&st.Nlink, uint64(x.nlink))
+	setInt(&st.Mode, uint64(x.mode))
+	setInt(&st.Uid, uint64(x.uid))
+	setInt(&st.Gid, uint64(x.gid))
+	setInt(&st.Rdev, mkdev(x.rdevMajor, x.rdevMinor))
+	setInt(&st.Size, x.size)
+	setInt(&st.Blksize, uint64(x.blksize))
+	setInt(&st.Blocks, x.blocks)
+	st.Atim = syscall.NsecToTimespec(x.atime.nsecs())
+	st.Mtim = syscall.NsecToTimespec(x.mtime.nsecs())
+	st.Ctim = syscall.NsecToTimespec(x.ctime.nsecs())
+	return st
+}
+
+// nsecs returns the timestamp as nanoseconds since the Unix epoch.
+func (t uringTimestamp) nsecs() int64 {
+	return t.sec*1e9 + int64(t.nsec)
+}
+
+// setInt stores v in an integer of any type.
+func setInt[T ~int32 | ~int64 | ~uint32 | ~uint64](dst *T, v uint64) {
+	*dst = T(v)
+}
+
+// mkdev returns the device number with the given major and minor numbers,
+// encoded the same way as the st_dev field of struct stat.
+func mkdev(major, minor uint32) uint64 {
+	dev := uint64(major&0x00000fff) << 8
+	dev |= uint64(major&0xfffff000) << 32
+	dev |= uint64(minor&0x000000ff) << 0
+	dev |= uint64(minor&0xffffff00) << 12
+	return dev
+}
+
+func (s uringSystem) unlink(path string) error {
+	ring, req, ok := s.request(path)
+	if !ok {
+		return directSystem{}.unlink(path)
+	}
+	defer uringRequests.Put(req)
+
+	return uringResult(ring.submit(req, uringSQE{
+		opcode: uringOpUnlinkAt,
+		fd:     atFDCWD,
+		addr:   uint64(uintptr(unsafe.Pointer(&req.path[0]))),
+	}))
+}
+
+func (s uringSystem) closeFd(path string, fd int) error {
+	ring, req, ok := s.request("")
+	if !ok {
+		return directSystem{}.closeFd(path, fd)
+	}
+	defer uringRequests.Put(req)
+
+	return uringResult(ring.submit(req, uringSQE{
+		opcode: uringOpClose,
+		fd:     int32(fd),
+	}))
+}
+
+func (uringSystem) closeFile(path string, file *os.File) error {
+	return directSystem{}.closeFile(path, file)
+}
@@ -0,0 +1,178 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile
+
+import (
+	"os"
+	"syscall"
+)
+
+// system performs the operating system operations that are needed to
+// manage lock files.
+//
+// Each operation receives the path of the lock file it relates to, so that
+// it can be reported to hooks.
+type system interface {
+	open(path string, flag int, perm uint32) (fd int, err error)
+	flock(path string, fd int, how int) error
+	fstat(path string, fd int) (syscall.Stat_t, error)
+	stat(path string) (syscall.Stat_t, error)
+	unlink(path string) error
+	closeFd(path string, fd int) error
+	closeFile(path string, file *os.File) error
+}
+
+// directSystem performs operations by calling the operating system
+// directly. It does not allocate.
+type directSystem struct{}
+
+func (directSystem) open(path string, flag int, perm uint32) (int, error) {
+	for {
+		fd, err := syscall.Open(path, flag|syscall.O_CLOEXEC, perm)
+		if err != syscall.EINTR {
+			return fd, err
+		}
+	}
+}
+
+func (directSystem) flock(path string, fd int, how int) error {
+	for {
+		err := syscall.Flock(fd, how)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+func (directSystem) fstat(path string, fd int) (stat syscall.Stat_t, err error) {
+	err = syscall.Fstat(fd, &stat)
+	return
+}
+
+func (directSystem) stat(path string) (stat syscall.Stat_t, err error) {
+	err = syscall.Stat(path, &stat)
+	return
+}
+
+func (directSystem) unlink(path string) error {
+	return syscall.Unlink(path)
+}
+
+func (directSystem) closeFd(path string, fd int) error {
+	return syscall.Close(fd)
+}
+
+func (directSystem) closeFile(path string, file *os.File) error {
+	return file.Close()
+}
+
+// coreSyscalls adapts the system of a configuration to the system calls
+// that are made by the core package. It only holds a pointer, so that it
+// can be converted to an interface without allocating.
+type coreSyscalls struct {
+	c *config
+}
+
+func (s coreSyscalls) Open(path string, flag int, perm uint32) (int, error) {
+	return s.c.system().open(path, flag, perm)
+}
+
+func (s coreSyscalls) Flock(path string, fd int, how int) error {
+	return s.c.system().flock(path, fd, how)
+}
+
+func (s coreSyscalls) Fstat(path string, fd int) (syscall.Stat_t, error) {
+	return s.c.system().fstat(path, fd)
+}
+
+func (s coreSyscalls) Stat(path string) (syscall.Stat_t, error) {
+	return s.c.system().stat(path)
+}
+
+func (s coreSyscalls) Unlink(path string) error {
+	return s.c.system().unlink(path)
+}
+
+func (s coreSyscalls) Close(path string, fd int) error {
+	return s.c.system().closeFd(path, fd)
+}
+
+// hookedSystem performs operations through the hooks, timeouts and worker
+// pool of a configuration.
+type hookedSystem struct {
+	c    *config
+	next system
+}
+
+// The operations below hand their results back through buffered channels,
+// rather than by assigning them to the results of the method, because an
+// operation that is abandoned after a timeout completes after the method
+// has returned.
+
+func (s *hookedSystem) open(path string, flag int, perm uint32) (int, error) {
+	opened := make(chan int, 1)
+	err := s.c.doUndo(OpOpen, path, func() error {
+		fd, err := s.next.open(path, flag, perm)
+		if err == nil {
+			opened <- fd
+		}
+		return err
+	}, func() {
+		// The caller has given up, so the descriptor would leak.
+		s.next.closeFd(path, <-opened)
+	})
+	if err != nil {
+		return -1, err
+	}
+	return <-opened, nil
+}
+
+func (s *hookedSystem) flock(path string, fd int, how int) error {
+	return s.c.do(OpFlock, path, func() error {
+		return s.next.flock(path, fd, how)
+	})
+}
+
+func (s *hookedSystem) fstat(path string, fd int) (syscall.Stat_t, error) {
+	result := make(chan syscall.Stat_t, 1)
+	err := s.c.do(OpStat, path, func() error {
+		stat, err := s.next.fstat(path, fd)
+		result <- stat
+		return err
+	})
+	if err != nil {
+		return syscall.Stat_t{}, err
+	}
+	return <-result, nil
+}
+
+func (s *hookedSystem) stat(path string) (syscall.Stat_t, error) {
+	result := make(chan syscall.Stat_t, 1)
+	err := s.c.do(OpStat, path, func() error {
+		stat, err := s.next.stat(path)
+		result <- stat
+		return err
+	})
+	if err != nil {
+		return syscall.Stat_t{}, err
+	}
+	return <-result, nil
+}
+
+func (s *hookedSystem) unlink(path string) error {
+	return s.c.do(OpUnlink, path, func() error {
+		return s.next.unlink(path)
+	})
+}
+
+func (s *hookedSystem) closeFd(path string, fd int) error {
+	return s.c.do(OpClose, path, func() error {
+		return s.next.closeFd(path, fd)
+	})
+}
+
+func (s *hookedSystem) closeFile(path string, file *os.File) error {
+	return s.c.do(OpClose, path, func() error {
+		return s.next.closeFile(path, file)
+	})
+}
@@ -0,0 +1,50 @@
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+
+package lockfile
+
+import (
+	"errors"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// slowOpenSystem is a system whose open waits until release is closed.
+type slowOpenSystem struct {
+	directSystem
+	release chan struct{}
+	opened  chan int
+}
+
+func (s slowOpenSystem) open(path string, flag int, perm uint32) (int, error) {
+	<-s.release
+	fd, err := s.directSystem.open(path, flag, perm)
+	s.opened <- fd
+	return fd, err
+}
+
+func TestHookedOpenClosesAbandonedDescriptor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "abandoned.lock")
+	next := slowOpenSystem{release: make(chan struct{}), opened: make(chan int, 1)}
+	sys := &hookedSystem{c: newConfig([]Option{WithOpTimeout(10 * time.Millisecond)}), next: next}
+
+	if _, err := sys.open(path, syscall.O_RDWR|syscall.O_CREAT, 0600); !errors.Is(err, ErrFilesystemHang) {
+		t.Fatalf("expected the open to be abandoned, got: %v", err)
+	}
+	close(next.release)
+	fd := <-next.opened
+
+	// The descriptor that was opened after the caller gave up is closed.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var stat syscall.Stat_t
+		if err := syscall.Fstat(fd, &stat); errors.Is(err, syscall.EBADF) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the abandoned descriptor was not closed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,79 @@
+//go:build windows
+
+package lockfile
+
+import (
+	"os"
+	"syscall"
+)
+
+// system performs the operating system operations that are needed to
+// manage lock files.
+//
+// Each operation receives the path of the lock file it relates to, so that
+// it can be reported to hooks.
+type system interface {
+	open(path string, access, shareMode, createMode, flagsAndAttributes uint32) (syscall.Handle, error)
+	closeHandle(path string, handle syscall.Handle) error
+	closeFile(path string, file *os.File) error
+}
+
+// defaultSystem is the system used by configurations that do not route
+// operations through hooks, timeouts or worker pools.
+var defaultSystem system = directSystem{}
+
+// directSystem performs operations by calling the operating system
+// directly.
+type directSystem struct{}
+
+func (directSystem) open(path string, access, shareMode, createMode, flagsAndAttributes uint32) (syscall.Handle, error) {
+	return createFile(path, access, shareMode, createMode, flagsAndAttributes)
+}
+
+func (directSystem) closeHandle(path string, handle syscall.Handle) error {
+	return syscall.CloseHandle(handle)
+}
+
+func (directSystem) closeFile(path string, file *os.File) error {
+	return file.Close()
+}
+
+// hookedSystem performs operations through the hooks, timeouts and worker
+// pool of a configuration.
+type hookedSystem struct {
+	c    *config
+	next system
+}
+
+// open hands the handle back through a buffered channel, rather than by
+// assigning it to the results of the method, because an open that is
+// abandoned after a timeout completes after the method has returned.
+func (s *hookedSystem) open(path string, access, shareMode, createMode, flagsAndAttributes uint32) (syscall.Handle, error) {
+	opened := make(chan syscall.Handle, 1)
+	err := s.c.doUndo(OpOpen, path, func() error {
+		handle, err := s.next.open(path, access, shareMode, createMode, flagsAndAttributes)
+		if err == nil {
+			opened <- handle
+		}
+		return err
+	}, func() {
+		// The caller has given up, so the handle would leak.
+		s.next.closeHandle(path, <-opened)
+	})
+	if err != nil {
+		return syscall.InvalidHandle, err
+	}
+	return <-opened, nil
+}
+
+func (s *hookedSystem) closeHandle(path string, handle syscall.Handle) error {
+	return s.c.do(OpClose, path, func() error {
+		return s.next.closeHandle(path, handle)
+	})
+}
+
+func (s *hookedSystem) closeFile(path string, file *os.File) error {
+	return s.c.do(OpClose, path, func() error {
+		return s.next.closeFile(path, file)
+	})
+}
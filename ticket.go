@@ -0,0 +1,156 @@
+package lockfile
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// WaitTicket describes a process that is waiting for a lock file. Waiters
+// that are configured with [WithWaitTicket] publish tickets while they
+// wait, so that the holder of the lock can see how urgent they are with
+// [File.Waiters], and decide whether to release the lock early.
+type WaitTicket struct {
+	// ID identifies the ticket among those of the lock file, so that its
+	// waiter can be evicted with [CancelWaiter]. It is derived from the
+	// name of the ticket, and is not stored in it.
+	ID string `json:"-"`
+
+	Holder   Holder    `json:"holder"`
+	Priority int       `json:"priority,omitempty"`
+	Deadline time.Time `json:"deadline,omitzero"`
+	Since    time.Time `json:"since"`
+
+	// TraceID is the trace or correlation ID of the waiter, if it was
+	// configured with [WithTraceID].
+	TraceID string `json:"trace,omitempty"`
+}
+
+// WithWaitTicket returns an option that publishes a wait ticket with the
+// given priority while waiting for a lock file. Higher priorities are more
+// urgent. The deadline of the ticket is taken from the context that the
+// waiter was given.
+//
+// Tickets are stored as files in a directory next to the lock file, named
+// after the lock file with a ".waiters" suffix. A ticket is only published
+// if the lock file could not be acquired immediately, and it is removed
+// when waiting ends.
+func WithWaitTicket(priority int) Option {
+	return func(c *config) {
+		c.waitTicket = true
+		c.ticketPriority = priority
+	}
+}
+
+// Waiters returns the tickets of the processes that are waiting for the
+// lock file, ordered from most to least urgent. It is equivalent to calling
+// [Waiters] with the path of the lock file.
+func (f *File) Waiters() ([]WaitTicket, error) {
+	return Waiters(f.h.path)
+}
+
+// Waiters returns the tickets of the processes that are waiting for the
+// lock file with the given path, ordered from most to least urgent.
+//
+// Tickets are ordered by descending priority, and then by ascending
+// deadline. Tickets without a deadline are less urgent than those with
+// one. Tickets that cannot be read are skipped.
+func Waiters(path string) ([]WaitTicket, error) {
+	tickets, err := readQueue(ticketDir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(tickets, func(i, j int) bool {
+		a, b := tickets[i], tickets[j]
+		switch {
+		case a.Priority != b.Priority:
+			return a.Priority > b.Priority
+		case a.Deadline.IsZero() != b.Deadline.IsZero():
+			return !a.Deadline.IsZero()
+		}
+		return a.Deadline.Before(b.Deadline)
+	})
+
+	return tickets, nil
+}
+
+// ticketDir returns the directory that holds the wait tickets for the lock
+// file at path.
+func ticketDir(path string) string {
+	return path + ".waiters"
+}
+
+// publishTicket publishes a wait ticket for the lock file at path, and
+// returns its name and a function that removes it. Failures are reported
+// to the Warning hook, because they do not prevent the lock from being
+// acquired, in which case the name is empty.
+func (c *config) publishTicket(ctx context.Context, path string) (name string, remove func()) {
+	if mapped, err := c.mapPath(path); err == nil {
+		path = mapped
+	}
+
+	ticket := WaitTicket{
+		Holder:   CurrentHolder(),
+		Priority: c.ticketPriority,
+		Since:    time.Now(),
+		TraceID:  c.traceID,
+	}
+	ticket.Deadline, _ = ctx.Deadline()
+
+	data, err := json.Marshal(ticket)
+	if err != nil {
+		c.warn(path, err)
+		return "", func() {}
+	}
+
+	// The trace ID is part of the name, so that it can be seen by tools
+	// that only see file names.
+	var id [8]byte
+	rand.Read(id[:])
+	base := hex.EncodeToString(id[:])
+	if c.traceID != "" {
+		base += "-" + c.traceID
+	}
+	dir := ticketDir(path)
+	name = filepath.Join(dir, base+".json")
+
+	// The directory may be removed by another waiter between its creation
+	// and the creation of the ticket, so try again once if that happens.
+	for attempt := 0; ; attempt++ {
+		if err = os.MkdirAll(dir, 0755); err == nil {
+			err = os.WriteFile(name, data, 0644)
+		}
+		if err == nil || attempt > 0 || !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+	}
+	if err != nil {
+		c.warn(path, err)
+		return "", func() {}
+	}
+
+	return name, func() {
+		os.Remove(name)
+		os.Remove(dir) // Fails harmlessly if other waiters remain
+	}
+}
+
+// evicted returns an error that wraps [ErrEvictedFromQueue] if the ticket
+// with the given name has been removed by [CancelWaiter]. A ticket with an
+// empty name was never published, and is never evicted.
+func evicted(path, name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, err := os.Stat(name); errors.Is(err, os.ErrNotExist) {
+		return &os.PathError{Op: "wait", Path: path, Err: ErrEvictedFromQueue}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package lockfile_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestWaitTicket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ticket.lock")
+
+	held, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	acquired := make(chan error, 1)
+	go func() {
+		file, err := lockfile.WaitCtx(ctx, path, lockfile.WithWaitTicket(5))
+		if err == nil {
+			file.Close()
+		}
+		acquired <- err
+	}()
+
+	// Wait for the ticket to be published.
+	var tickets []lockfile.WaitTicket
+	for tickets == nil && ctx.Err() == nil {
+		if tickets, err = held.Waiters(); err != nil {
+			t.Fatalf("Waiters failed: %v", err)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if len(tickets) != 1 {
+		t.Fatalf("found %d tickets, expected 1", len(tickets))
+	}
+	if tickets[0].Priority != 5 || tickets[0].Deadline.IsZero() {
+		t.Fatalf("unexpected ticket: %+v", tickets[0])
+	}
+
+	held.Close()
+	if err := <-acquired; err != nil {
+		t.Fatalf("WaitCtx failed: %v", err)
+	}
+
+	// The ticket must have been removed.
+	if tickets, err := lockfile.Waiters(path); err != nil || len(tickets) != 0 {
+		t.Fatalf("tickets remain after waiting ended: %v, %v", tickets, err)
+	}
+}
@@ -0,0 +1,39 @@
+package lockfile
+
+// maxTraceIDLength is the longest trace ID that is accepted by
+// [WithTraceID], which keeps the names of wait tickets short.
+const maxTraceIDLength = 64
+
+// WithTraceID returns an option that tags lock file acquisitions with the
+// given trace or correlation ID, so that a stuck lock can be tied to the
+// request or job that holds it, or is waiting for it.
+//
+// The ID is recorded in the metadata of the lock file when it is written,
+// as configured by [WithMetadata], and in the wait ticket that is
+// published while waiting, as configured by [WithWaitTicket]. It is also
+// appended to the file name of the wait ticket, so that it is visible to
+// tools such as lsof and Process Explorer that only see file names.
+//
+// The ID may only contain ASCII letters, digits, hyphens and underscores,
+// and must be at most 64 characters long. Otherwise lock file creation
+// fails with an error that wraps [ErrInvalidOption].
+func WithTraceID(id string) Option {
+	return func(c *config) {
+		c.traceID = id
+	}
+}
+
+// validTraceID returns true if id is acceptable to [WithTraceID].
+func validTraceID(id string) bool {
+	if len(id) > maxTraceIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package lockfile_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestTraceID(t *testing.T) {
+	const trace = "req-42_a"
+	path := filepath.Join(t.TempDir(), "traced.lock")
+
+	held, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	acquired := make(chan error, 1)
+	go func() {
+		file, err := lockfile.WaitCtx(ctx, path, lockfile.WithWaitTicket(0), lockfile.WithMetadata(nil), lockfile.WithTraceID(trace))
+		if err == nil {
+			var info lockfile.Inspection
+			if info, err = lockfile.Inspect(path); err == nil && (info.Metadata == nil || info.Metadata.TraceID != trace) {
+				err = errors.New("the metadata does not record the trace ID")
+			}
+			file.Close()
+		}
+		acquired <- err
+	}()
+
+	// Wait for the ticket to be published.
+	var waiters []lockfile.QueuedWaiter
+	for waiters == nil && ctx.Err() == nil {
+		if waiters, err = lockfile.QueueStatus(path); err != nil {
+			t.Fatalf("QueueStatus failed: %v", err)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if len(waiters) != 1 {
+		t.Fatalf("found %d waiters, expected 1", len(waiters))
+	}
+	if waiters[0].TraceID != trace || !strings.HasSuffix(waiters[0].ID, "-"+trace) {
+		t.Fatalf("the ticket does not record the trace ID: %+v", waiters[0])
+	}
+
+	held.Close()
+	if err := <-acquired; err != nil {
+		t.Fatalf("WaitCtx failed: %v", err)
+	}
+}
+
+func TestTraceIDInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traced.lock")
+	for _, id := range []string{"a/b", "a b", strings.Repeat("x", 65)} {
+		if _, err := lockfile.Create(path, lockfile.WithTraceID(id)); !errors.Is(err, lockfile.ErrInvalidOption) {
+			t.Fatalf("expected ErrInvalidOption for %q, got: %v", id, err)
+		}
+	}
+}
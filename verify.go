@@ -0,0 +1,155 @@
+package lockfile
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// EnvVerifyChild is the environment variable that instructs a process to
+// act as the helper for [VerifyExclusionWithChild], when it calls
+// [RunVerifyHelper]. It holds the path of the lock file that the helper
+// attempts to acquire.
+const EnvVerifyChild = "LOCKFILE_VERIFY_CHILD"
+
+// VerifyHelperArg is the command line argument that the helper for
+// [VerifyExclusionWithChild] is started with, in addition to
+// [EnvVerifyChild]. An executable that does not call [RunVerifyHelper]
+// is expected to reject it as an unknown flag, rather than to start
+// normally.
+const VerifyHelperArg = "-lockfile-verify-helper"
+
+// verifyPrefix marks the line on which the helper reports its outcome, so
+// that it can be told apart from anything else the executable writes to
+// standard output.
+const verifyPrefix = "lockfile-verify: "
+
+// Outcomes reported by the helper process.
+const (
+	verifyAcquired  = "acquired"
+	verifyContended = "contended"
+)
+
+// RunVerifyHelper acts as the helper process of [VerifyExclusionWithChild]
+// and exits, if the current process was started as one. It returns
+// immediately otherwise.
+//
+// A process was started as a helper if its first argument is
+// [VerifyHelperArg] and [EnvVerifyChild] is set.
+//
+// Executables that call VerifyExclusionWithChild must call RunVerifyHelper
+// at the start of their main function, or of TestMain in tests, before
+// they do anything else, including parsing their flags. The helper acquires
+// the lock file with opts, which must be the options that are passed to
+// VerifyExclusionWithChild.
+func RunVerifyHelper(opts ...Option) {
+	if len(os.Args) < 2 || os.Args[1] != VerifyHelperArg {
+		return
+	}
+	if path := os.Getenv(EnvVerifyChild); path != "" {
+		os.Exit(verifyChild(path, opts))
+	}
+}
+
+// VerifyExclusionWithChild empirically confirms that two processes cannot
+// both hold the same lock file in dir. It is intended to be run once at
+// startup by services that are deployed on unfamiliar filesystems, such as
+// network shares, where file locking may silently not work.
+//
+// It re-executes the current executable as a helper process that attempts
+// to acquire a lock file in dir. The executable must call [RunVerifyHelper]
+// with the same options as opts, which is how the helper acquires it. The
+// helper first acquires the lock file while it is free, which confirms
+// that the executable answers as a helper and that the helper can create
+// lock files at all, so that neither is mistaken for successful exclusion.
+// The check then holds the lock file, created with opts, and runs the
+// helper again, which must find it held.
+//
+// It returns an error that wraps [ErrExclusionFailed] if the helper
+// acquired the lock while it was held, or was unable to acquire it while
+// it was free.
+func VerifyExclusionWithChild(ctx context.Context, dir string, opts ...Option) error {
+	var suffix [8]byte
+	rand.Read(suffix[:])
+	path := filepath.Join(dir, ".lockfile-verify-"+hex.EncodeToString(suffix[:])+".lock")
+
+	outcome, err := runVerifyChild(ctx, path)
+	if err != nil {
+		return err
+	}
+	if outcome != verifyAcquired {
+		return fmt.Errorf("%w: a second process could not acquire the lock while it was free: %s", ErrExclusionFailed, outcome)
+	}
+
+	file, err := Create(path, opts...)
+	if err != nil {
+		return err
+	}
+
+	outcome, err = runVerifyChild(ctx, path)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	switch outcome {
+	case verifyContended:
+	case verifyAcquired:
+		return fmt.Errorf("%w: a second process acquired the lock while it was held", ErrExclusionFailed)
+	default:
+		return fmt.Errorf("%w: a second process failed to check the lock while it was held: %s", ErrExclusionFailed, outcome)
+	}
+
+	return nil
+}
+
+// runVerifyChild runs the current executable as a helper that attempts to
+// acquire the lock file at path, and returns the outcome it reports.
+func runVerifyChild(ctx context.Context, path string) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	cmd := exec.CommandContext(ctx, exe, VerifyHelperArg)
+	cmd.Env = append(os.Environ(), EnvVerifyChild+"="+path)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("lockfile: the verification helper failed; the executable must call RunVerifyHelper: %w", err)
+	}
+
+	// The outcome is reported on the last line with the prefix.
+	outcome := ""
+	for _, line := range strings.Split(string(out), "\n") {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), verifyPrefix); ok {
+			outcome = rest
+		}
+	}
+	if outcome == "" {
+		return "", fmt.Errorf("lockfile: the verification helper did not report an outcome; it must call RunVerifyHelper")
+	}
+	return outcome, nil
+}
+
+// verifyChild attempts to acquire the lock file at path with opts, reports
+// the outcome on standard output, and returns the exit code of the helper.
+func verifyChild(path string, opts []Option) int {
+	file, err := Create(path, opts...)
+	switch {
+	case err == nil:
+		file.Close()
+		fmt.Println(verifyPrefix + verifyAcquired)
+	case IsTemporary(err):
+		fmt.Println(verifyPrefix + verifyContended)
+	default:
+		fmt.Println(verifyPrefix + err.Error())
+	}
+	return 0
+}
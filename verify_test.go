@@ -0,0 +1,25 @@
+package lockfile_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestMain(m *testing.M) {
+	// VerifyExclusionWithChild runs the test binary as its helper.
+	lockfile.RunVerifyHelper()
+	os.Exit(m.Run())
+}
+
+func TestVerifyExclusionWithChild(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	if err := lockfile.VerifyExclusionWithChild(ctx, t.TempDir()); err != nil {
+		t.Fatalf("VerifyExclusionWithChild failed: %v", err)
+	}
+}
@@ -2,54 +2,360 @@ package lockfile
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math/rand/v2"
+	"os"
+	"sync"
 	"time"
 )
 
 // WaitCtx repeatedly calls [Create] with the given path until a lock file is
 // successfully created, a non-temporary error is encountered or the provided
 // context is cancelled.
-func WaitCtx(ctx context.Context, path string) (*File, error) {
-	// Try to create the lock file.
-	file, err := Create(path)
-	if err == nil {
-		return file, nil
+//
+// Options may be provided to customize its behavior. They are passed to
+// each call to [Create].
+//
+// The [File.Stats] of the returned file report whether acquisition required
+// waiting, and for how long.
+func WaitCtx(ctx context.Context, path string, opts ...Option) (*File, error) {
+	return newConfig(opts).wait(ctx, path)
+}
+
+// WaitUntil waits for a lock file with the given path like [WaitCtx], but
+// only attempts to create the lock file once predicate returns true.
+//
+// The predicate is evaluated before each attempt. This combines waiting
+// for the lock with waiting for an external readiness condition, such as
+// the existence of another file, without repeatedly acquiring and
+// releasing the lock just to check the condition. If predicate returns an
+// error, waiting stops and the error is returned.
+//
+// The predicate is not evaluated again after the lock file has been
+// created, so callers that need the condition to hold while the lock is
+// held should check it again.
+func WaitUntil(ctx context.Context, path string, predicate func() (bool, error), opts ...Option) (*File, error) {
+	return newConfig(opts).waitUntil(ctx, path, predicate)
+}
+
+// WithMaxAttempts returns an option that stops waiting for a lock file once
+// n attempts to acquire it have found it held by someone else. Waiting
+// stops with an [*os.PathError] that wraps [ErrNeverFree].
+func WithMaxAttempts(n int) Option {
+	return func(c *config) {
+		c.maxAttempts = n
 	}
+}
 
-	// If the error indicates a non-temporary failure, give up.
-	if !IsTemporary(err) {
-		return nil, err
+// WithWaitBudget returns an option that stops waiting for a lock file once
+// it has been waited for for the given duration, independently of the
+// context provided by the caller.
+//
+// If every attempt to acquire the lock file found it held by someone else,
+// waiting stops with an [*os.PathError] that wraps [ErrNeverFree], which
+// distinguishes a busy lock file from a caller that gave up. Otherwise it
+// stops with an [*os.PathError] that wraps [os.ErrDeadlineExceeded].
+func WithWaitBudget(budget time.Duration) Option {
+	return func(c *config) {
+		c.waitBudget = budget
 	}
+}
 
+// WithAttemptTimeout returns an option that limits the amount of time each
+// attempt to acquire a lock file is allowed to take while waiting for it.
+//
+// On flaky network filesystems a single attempt can stall for longer than
+// the caller is prepared to wait, leaving no time for retries. An attempt
+// that does not complete in time is abandoned, reported to the Warning
+// hook as a [*HangError] for [OpAttempt], and tried again after a backoff.
+// If an abandoned attempt eventually acquires the lock file, it is
+// released.
+//
+// The timeout only bounds individual attempts. The wait as a whole is
+// bounded by the context provided by the caller, and by [WithWaitBudget].
+// [WithOpTimeout] bounds the individual operations of each attempt
+// instead. A timeout of zero or less disables the limit, which is the
+// default.
+func WithAttemptTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.attemptTimeout = timeout
+	}
+}
+
+// wait repeatedly attempts to create a lock file with the given path until
+// it succeeds, a non-temporary error is encountered or ctx is cancelled.
+func (c *config) wait(ctx context.Context, path string) (*File, error) {
+	return c.waitUntil(ctx, path, nil)
+}
+
+// waitUntil repeatedly attempts to create a lock file with the given path
+// until it succeeds, a non-temporary error is encountered or ctx is
+// cancelled.
+//
+// If ready is non-nil, it is evaluated before each attempt, and an attempt
+// is only made once it returns true. If it returns an error, waiting stops
+// and the error is returned.
+//
+// Errors that do not already carry a [ReasonCode] are given one.
+func (c *config) waitUntil(ctx context.Context, path string, ready func() (bool, error)) (*File, error) {
+	file, err := c.waitLoop(ctx, path, ready)
+	return file, withReason(err)
+}
+
+// waitLoop repeatedly attempts to create a lock file on behalf of
+// waitUntil.
+func (c *config) waitLoop(ctx context.Context, path string, ready func() (bool, error)) (*File, error) {
 	// Repeatedly try to create the lock file until one of three things
 	// happens:
 	// 1. The lock file is successfully created.
 	// 2: A non-temporary error is returned.
 	// 3: The provided context is cancelled.
-	attempt := 0
-	timer := time.NewTimer(randomBackoff(attempt))
-	for {
-		// Wait for the timer to fire, or the context to be cancelled.
+	var (
+		timer    *time.Timer
+		budget   <-chan time.Time // Fires when the wait budget is spent
+		watch    *watcher
+		ticket   string // The name of our wait ticket, if published
+		progress waitProgress
+	)
+	start := time.Now()
+	if c.waitBudget > 0 {
+		t := time.NewTimer(c.waitBudget)
+		defer t.Stop()
+		budget = t.C
+	}
+	member := c.joinHerd(path)
+	defer member.leave()
+	for attempt := 0; ; attempt++ {
+		if err := evicted(path, ticket); err != nil {
+			return nil, err
+		}
+
+		// While the herd is serialized, only the waiter that holds its turn
+		// makes attempts.
+		if turn := member.park(); turn != nil {
+			select {
+			case <-turn:
+				member.promote()
+			case <-ctx.Done():
+				member.unpark()
+				return nil, ctx.Err()
+			case <-budget:
+				member.unpark()
+				if attempt > 0 && progress.contended == attempt {
+					return nil, progress.neverFree(path)
+				}
+				return nil, &os.PathError{Op: "wait", Path: path, Err: os.ErrDeadlineExceeded}
+			}
+		}
+
+		member.attempted()
+		file, delay, err := c.attempt(ctx, path, attempt, &progress, ready)
+		if file != nil {
+			if attempt > 0 {
+				file.h.stats.Attempts = attempt + 1
+				file.h.stats.Wait = file.h.stats.Acquired.Sub(start)
+			}
+			if file, err = handBack(ctx, file); err != nil {
+				return nil, err
+			}
+			return c.checkStillNeeded(file)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if c.maxAttempts > 0 && progress.contended >= c.maxAttempts {
+			return nil, progress.neverFree(path)
+		}
+
+		// Let the holder know that we are waiting. An operator may evict us
+		// from the queue by removing our ticket.
+		if attempt == 0 && c.waitTicket {
+			var remove func()
+			ticket, remove = c.publishTicket(ctx, path)
+			defer remove()
+		}
+
+		// Watch for the release of the lock file where possible, so that
+		// we can try again as soon as it happens. The events caused by our
+		// own attempts are discarded.
+		if attempt == 0 {
+			watch = c.watch(path)
+			defer watch.close()
+		} else {
+			watch.drain()
+		}
+
+		// Wait for the delay to pass, or the context to be cancelled.
+		if timer == nil {
+			timer = time.NewTimer(delay)
+			defer timer.Stop()
+		} else {
+			timer.Reset(delay)
+		}
+
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		case <-timer.C:
+		case <-watch.woken():
+			c.forgetContention(path)
+		case <-budget:
+			if progress.contended == attempt+1 {
+				return nil, progress.neverFree(path)
+			}
+			return nil, &os.PathError{Op: "wait", Path: path, Err: os.ErrDeadlineExceeded}
 		}
+	}
+}
+
+// waitProgress records the outcomes of the attempts made while waiting for
+// a lock file.
+type waitProgress struct {
+	streak     int              // Consecutive temporary errors other than contention
+	contended  int              // Attempts that found the lock file held
+	contention *ContentionError // The most recent contention
+}
 
-		// Try to create the lock file.
-		file, err = Create(path)
-		if err == nil {
-			return file, nil
+// neverFree returns an error that wraps [ErrNeverFree] and the most recent
+// contention, for the lock file at path.
+func (p *waitProgress) neverFree(path string) error {
+	err := ErrNeverFree
+	if p.contention != nil {
+		err = fmt.Errorf("%w: %w", ErrNeverFree, p.contention)
+	}
+	return &os.PathError{Op: "wait", Path: path, Err: err}
+}
+
+// attempt makes a single attempt to create a lock file with the given path.
+//
+// If successful, it returns the lock file. Otherwise it returns the delay
+// before the next attempt should be made, or an error if waiting should
+// stop. The outcome of the attempt is recorded in progress.
+func (c *config) attempt(ctx context.Context, path string, attempt int, progress *waitProgress, ready func() (bool, error)) (*File, time.Duration, error) {
+	if ready != nil {
+		ok, err := ready()
+		if err != nil {
+			return nil, 0, err
 		}
-		if !IsTemporary(err) {
-			return nil, err
+		if !ok {
+			return nil, randomBackoff(attempt), nil
+		}
+	}
+
+	file, err := c.createAttempt(ctx, path)
+	if err == nil {
+		return file, 0, nil
+	}
+	if hang, ok := err.(*HangError); ok && hang.Op == OpAttempt {
+		c.warn(path, err)
+		return nil, randomBackoff(attempt), nil
+	}
+	if c.isTemporary(err) {
+		progress.contended++
+	}
+	if errors.As(err, &progress.contention) {
+		progress.contention.Attempt = attempt + 1
+	}
+
+	delay, err := c.backoff(err, attempt, &progress.streak)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return nil, delay, nil
+}
+
+// createAttempt makes a single attempt to create a lock file with the
+// given path, abandoning it if it does not complete within the attempt
+// timeout. An abandoned attempt that succeeds later releases the lock file.
+func (c *config) createAttempt(ctx context.Context, path string) (*File, error) {
+	if c.attemptTimeout <= 0 {
+		return c.createCtx(ctx, path)
+	}
+
+	timer := time.NewTimer(c.attemptTimeout)
+	defer timer.Stop()
+
+	var (
+		mutex     sync.Mutex
+		abandoned bool
+		done      = make(chan error, 1)
+		file      *File
+	)
+
+	go func() {
+		f, err := c.createCtx(ctx, path)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if abandoned {
+			if err == nil {
+				f.Close()
+			}
+			return
 		}
+		file = f
+		done <- err
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+		return file, err
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timer.C:
+		err = &HangError{Op: OpAttempt, Path: path, Timeout: c.attemptTimeout}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// The attempt may have completed while we were acquiring the mutex.
+	select {
+	case err := <-done:
+		return file, err
+	default:
+	}
 
-		// Calculate a new random delay and reset the timer.
-		attempt++
-		delay := randomBackoff(attempt)
-		timer.Reset(delay)
+	abandoned = true
+	return nil, err
+}
+
+// handBack returns file if ctx is still active. If ctx has been cancelled,
+// the freshly acquired lock is closed and the context's error is returned
+// instead.
+//
+// This resolves the race between a successful call to [Create] and the
+// cancellation of ctx in favor of the cancellation, so that a cancelled
+// waiter never ends up holding a lock that its caller has abandoned.
+func handBack(ctx context.Context, file *File) (*File, error) {
+	if err := ctx.Err(); err != nil {
+		file.Close()
+		return nil, err
 	}
+	return file, nil
+}
+
+// backoff returns the delay before the next attempt to create a lock file,
+// given the error returned by the previous attempt. It returns an error if
+// the error is not worth retrying, or if the budget for consecutive
+// temporary errors has been exhausted.
+func (c *config) backoff(err error, attempt int, streak *int) (time.Duration, error) {
+	switch {
+	case c.isTemporary(err):
+		if c.exhausted(err, streak) {
+			return 0, fmt.Errorf("%w: %w", ErrRetryBudgetExhausted, err)
+		}
+		return randomBackoff(attempt), nil
+	case c.retryNoSpace && (errors.Is(err, ErrNoSpace) || errors.Is(err, ErrQuotaExceeded)):
+		return spaceBackoff(), nil
+	case c.schedule != nil && errors.Is(err, ErrOutsideWindow):
+		return c.untilWindow(), nil
+	}
+	return 0, err
 }
 
 // randomBackoff returns a random backoff time betwen 0 and 1 second.
@@ -60,3 +366,10 @@ func randomBackoff(attempt int) time.Duration {
 	milliseconds := rand.IntN((1 + attempt) * 10)
 	return time.Millisecond * time.Duration(milliseconds)
 }
+
+// spaceBackoff returns a random backoff time between 1 and 5 seconds. It is
+// used while waiting for space to be freed on a full filesystem, which
+// typically takes much longer than waiting for a lock to be released.
+func spaceBackoff() time.Duration {
+	return time.Second + time.Millisecond*time.Duration(rand.IntN(4000))
+}
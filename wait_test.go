@@ -2,8 +2,11 @@ package lockfile_test
 
 import (
 	"context"
+	"errors"
 	"math/rand/v2"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -65,3 +68,144 @@ func TestWaitParallel(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestWaitUntil(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "until.lock")
+
+	var checks atomic.Int32
+	ready := func() (bool, error) {
+		return checks.Add(1) >= 3, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	lock, err := lockfile.WaitUntil(ctx, path, ready)
+	if err != nil {
+		t.Fatalf("WaitUntil failed: %v", err)
+	}
+	defer lock.Close()
+
+	if n := checks.Load(); n != 3 {
+		t.Fatalf("predicate was evaluated %d times, expected 3", n)
+	}
+}
+
+func TestWaitUntilPredicateError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "until.lock")
+	errBroken := errors.New("broken")
+
+	_, err := lockfile.WaitUntil(context.Background(), path, func() (bool, error) {
+		return false, errBroken
+	})
+	if !errors.Is(err, errBroken) {
+		t.Fatalf("expected the predicate's error, got: %v", err)
+	}
+}
+
+func TestWaitStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.lock")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	first, err := lockfile.WaitCtx(ctx, path)
+	if err != nil {
+		t.Fatalf("WaitCtx failed: %v", err)
+	}
+	if stats := first.Stats(); stats.Waited() || stats.Attempts != 1 || stats.Wait != 0 {
+		t.Fatalf("unexpected stats for immediate acquisition: %+v", stats)
+	}
+
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		first.Close()
+	}()
+
+	second, err := lockfile.WaitCtx(ctx, path)
+	if err != nil {
+		t.Fatalf("WaitCtx failed: %v", err)
+	}
+	defer second.Close()
+
+	stats := second.Stats()
+	if !stats.Waited() || stats.Attempts < 2 || stats.Wait <= 0 {
+		t.Fatalf("unexpected stats for delayed acquisition: %+v", stats)
+	}
+}
+
+func TestWaitMaxAttempts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "attempts.lock")
+	held, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer held.Close()
+
+	_, err = lockfile.WaitCtx(context.Background(), path, lockfile.WithMaxAttempts(3))
+	if !errors.Is(err, lockfile.ErrNeverFree) {
+		t.Fatalf("expected an error that wraps ErrNeverFree, got: %v", err)
+	}
+}
+
+func TestWaitBudget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.lock")
+	held, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer held.Close()
+
+	start := time.Now()
+	_, err = lockfile.WaitCtx(context.Background(), path, lockfile.WithWaitBudget(100*time.Millisecond))
+	if !errors.Is(err, lockfile.ErrNeverFree) {
+		t.Fatalf("expected an error that wraps ErrNeverFree, got: %v", err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("the error was mistaken for the caller's deadline: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("waited for %v, longer than the budget", elapsed)
+	}
+}
+
+func TestWaitAttemptTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "attempt.lock")
+
+	// The first attempt stalls until the test ends.
+	stall := make(chan struct{})
+	defer close(stall)
+	var opens atomic.Int32
+	var hangs atomic.Int32
+	hooks := lockfile.Hooks{
+		BeforeOp: func(op lockfile.Op, path string) error {
+			if op == lockfile.OpOpen && opens.Add(1) == 1 {
+				<-stall
+				return errors.New("stalled")
+			}
+			return nil
+		},
+		Warning: func(path string, err error) {
+			var hang *lockfile.HangError
+			if errors.As(err, &hang) && hang.Op == lockfile.OpAttempt {
+				hangs.Add(1)
+			}
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	file, err := lockfile.WaitCtx(ctx, path, lockfile.WithHooks(hooks), lockfile.WithAttemptTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("WaitCtx failed: %v", err)
+	}
+	defer file.Close()
+
+	if hangs.Load() == 0 {
+		t.Fatal("the stalled attempt was not reported")
+	}
+	if stats := file.Stats(); stats.Attempts < 2 {
+		t.Fatalf("expected at least 2 attempts, got %d", stats.Attempts)
+	}
+}
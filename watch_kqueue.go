@@ -0,0 +1,182 @@
+//go:build darwin || dragonfly || freebsd || openbsd
+
+package lockfile
+
+import (
+	"sync"
+	"syscall"
+	"time"
+)
+
+// watcher watches a lock file with kqueue, so that a waiter can try again
+// as soon as the lock file is released instead of waiting for its backoff
+// to pass.
+//
+// A holder that releases a lock file deletes it, which is reported by an
+// EVFILT_VNODE filter on the file as NOTE_DELETE. The filter is attached
+// to the file itself rather than its path, so it is attached again to the
+// file that is at the path once it has fired, and on every attempt until
+// there is one to attach to.
+//
+// A holder that crashes leaves its lock file behind, and the release of
+// its flock is not reported, so the waiter relies on its backoff instead.
+//
+// No watcher is created when lock files are locked with fcntl. Closing any
+// descriptor for a file releases the POSIX locks that the process holds on
+// it, so closing the watched descriptor would release the lock of another
+// holder within the process.
+//
+// A nil watcher is valid, and never wakes.
+type watcher struct {
+	path string
+	kq   int
+	wake chan struct{} // Holds a token when the lock file has been released
+	done chan struct{} // Closed when the watcher is closed
+
+	mutex sync.Mutex
+	fd    int  // The watched lock file, or -1
+	fired bool // True if the filter fired since it was last attached
+}
+
+// watchNotes is the set of vnode events that mean that the lock file is
+// no longer at its path.
+const watchNotes = syscall.NOTE_DELETE | syscall.NOTE_RENAME | syscall.NOTE_REVOKE
+
+// watchPoll is the longest time that the watcher waits for events before
+// it checks whether it has been closed.
+const watchPoll = 250 * time.Millisecond
+
+// watch returns a watcher for the lock file with the given path. If kqueue
+// is not available, or lock files are locked with fcntl, it returns nil,
+// and the waiter falls back to polling.
+func (c *config) watch(path string) *watcher {
+	if c.fcntlLocks() {
+		return nil
+	}
+
+	path, err := c.mapPath(path)
+	if err != nil {
+		return nil
+	}
+
+	kq, err := syscall.Kqueue()
+	if err != nil {
+		return nil
+	}
+	syscall.CloseOnExec(kq)
+
+	w := &watcher{
+		path: path,
+		kq:   kq,
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+		fd:   -1,
+	}
+	w.mutex.Lock()
+	w.attach()
+	w.mutex.Unlock()
+
+	go w.run()
+	return w
+}
+
+// woken returns a channel that receives a value when the lock file may
+// have been released.
+func (w *watcher) woken() <-chan struct{} {
+	if w == nil {
+		return nil
+	}
+	return w.wake
+}
+
+// drain attaches the filter to the lock file that is now at the path, if
+// the previous one fired or there was none. A waiter's own attempts do not
+// cause the filter to fire, so there is nothing to discard.
+func (w *watcher) drain() {
+	if w == nil {
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.fd < 0 || w.fired {
+		w.attach()
+	}
+}
+
+// close stops watching the lock file. The watcher releases its resources
+// once it notices.
+func (w *watcher) close() {
+	if w != nil {
+		close(w.done)
+	}
+}
+
+// attach attaches the filter to the lock file at the path, replacing the
+// previous one. If there is no lock file, nothing is watched.
+//
+// The caller must hold w.mutex.
+func (w *watcher) attach() {
+	if w.fd >= 0 {
+		syscall.Close(w.fd) // Removes its filter
+		w.fd = -1
+	}
+	w.fired = false
+
+	fd, err := syscall.Open(w.path, syscall.O_RDONLY|syscall.O_CLOEXEC|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return
+	}
+
+	var change syscall.Kevent_t
+	syscall.SetKevent(&change, fd, syscall.EVFILT_VNODE, syscall.EV_ADD|syscall.EV_CLEAR)
+	change.Fflags = watchNotes
+	if _, err := syscall.Kevent(w.kq, []syscall.Kevent_t{change}, nil, nil); err != nil {
+		syscall.Close(fd)
+		return
+	}
+	w.fd = fd
+}
+
+// run waits for the filter to fire, until the watcher is closed.
+func (w *watcher) run() {
+	defer func() {
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+		if w.fd >= 0 {
+			syscall.Close(w.fd)
+			w.fd = -1
+		}
+		syscall.Close(w.kq)
+	}()
+
+	timeout := syscall.NsecToTimespec(int64(watchPoll))
+	var events [1]syscall.Kevent_t
+	for {
+		n, err := syscall.Kevent(w.kq, nil, events[:], &timeout)
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if n == 0 || events[0].Fflags&watchNotes == 0 {
+			continue
+		}
+
+		w.mutex.Lock()
+		w.fired = true
+		w.mutex.Unlock()
+
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,53 @@
+//go:build darwin || dragonfly || freebsd || openbsd
+
+package lockfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcherWakesOnRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watched.lock")
+
+	// There is no lock file to attach to yet, so the filter is attached
+	// once one has been created.
+	w := newConfig(nil).watch(path)
+	if w == nil {
+		t.Skip("kqueue is not available")
+	}
+	defer w.close()
+
+	for i := range 2 {
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		w.drain()
+
+		// Other files in the directory are ignored.
+		if err := os.WriteFile(path+".other", nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Remove(path + ".other"); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-w.woken():
+			t.Fatalf("attempt %d: the watcher woke for the deletion of another file", i)
+		case <-time.After(2 * watchPoll):
+		}
+
+		// The filter is attached again to each new lock file once the
+		// previous one has been deleted.
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-w.woken():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("attempt %d: the watcher did not wake when the lock file was deleted", i)
+		}
+	}
+}
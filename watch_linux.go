@@ -0,0 +1,188 @@
+//go:build linux
+
+package lockfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// watcher watches the directory of a lock file with inotify, so that a
+// waiter can try again as soon as the lock file is released instead of
+// waiting for its backoff to pass.
+//
+// A holder that releases a lock file deletes it, which is reported as
+// IN_DELETE. A holder that crashes leaves its lock file behind, and the
+// kernel releases its flock when the file is closed on its behalf, which
+// is reported as IN_CLOSE_WRITE if it was writable.
+//
+// A waiter's own attempts also close the lock file, which would wake it
+// again immediately, so the events caused by each attempt are discarded
+// by calling drain once it returns. Events are queued by the kernel before
+// the system calls that cause them return, so none can be missed. The
+// attempts of other waiters cannot be told apart from a crash, so waiters
+// would wake each other in turn; close events only wake a waiter once per
+// closeWakeInterval to bound this.
+//
+// A nil watcher is valid, and never wakes.
+type watcher struct {
+	name string // The base name of the lock file
+	fd   int
+	file *os.File
+	raw  syscall.RawConn
+	wake chan struct{} // Holds a token when a relevant event has been seen
+
+	mutex     sync.Mutex
+	events    uint32    // The relevant events seen since the last drain
+	lastClose time.Time // The last time that a close event woke the waiter
+}
+
+// watchMask is the set of inotify events that may mean that a lock file
+// has been released.
+const watchMask = syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_CLOSE_WRITE
+
+// releaseMask is the subset of watchMask that a waiter never causes
+// itself.
+const releaseMask = syscall.IN_DELETE | syscall.IN_MOVED_FROM
+
+// closeWakeInterval is the shortest time between wakes caused by close
+// events.
+const closeWakeInterval = 100 * time.Millisecond
+
+// watch returns a watcher for the lock file with the given path. If the
+// directory of the lock file cannot be watched, it returns nil, and the
+// waiter falls back to polling.
+func (c *config) watch(path string) *watcher {
+	path, err := c.mapPath(path)
+	if err != nil {
+		return nil
+	}
+
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil
+	}
+	if _, err := syscall.InotifyAddWatch(fd, filepath.Dir(path), watchMask); err != nil {
+		syscall.Close(fd)
+		return nil
+	}
+
+	file := os.NewFile(uintptr(fd), "inotify")
+	raw, err := file.SyscallConn()
+	if err != nil {
+		file.Close()
+		return nil
+	}
+
+	w := &watcher{
+		name: filepath.Base(path),
+		fd:   fd,
+		file: file,
+		raw:  raw,
+		wake: make(chan struct{}, 1),
+	}
+	go w.run()
+	return w
+}
+
+// woken returns a channel that receives a value when the lock file may
+// have been released.
+func (w *watcher) woken() <-chan struct{} {
+	if w == nil {
+		return nil
+	}
+	return w.wake
+}
+
+// drain discards the events that were caused by the waiter's own attempt
+// to acquire the lock file. Events that it could not have caused are kept.
+func (w *watcher) drain() {
+	if w == nil {
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// The file is only closed by the waiter, so its descriptor can be
+	// read directly. Reading through raw would wait for run to finish.
+	w.read(w.fd)
+	if w.events&releaseMask == 0 {
+		select {
+		case <-w.wake:
+		default:
+		}
+	}
+	w.events = 0
+}
+
+// close stops watching the lock file.
+func (w *watcher) close() {
+	if w != nil {
+		w.file.Close()
+	}
+}
+
+// run reads events as they arrive, until the watcher is closed.
+func (w *watcher) run() {
+	w.raw.Read(func(fd uintptr) bool {
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+
+		return w.read(int(fd))
+	})
+}
+
+// read reads the events that are queued on fd, and wakes the waiter if any
+// of them are relevant. It returns true if fd can no longer be read.
+//
+// The caller must hold w.mutex.
+func (w *watcher) read(fd int) (done bool) {
+	var buf [4096]byte
+	for {
+		n, err := syscall.Read(fd, buf[:])
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || n <= 0 {
+			return err != syscall.EAGAIN
+		}
+
+		var events uint32
+		for data := buf[:n]; len(data) >= syscall.SizeofInotifyEvent; {
+			mask := binary.NativeEndian.Uint32(data[4:8])
+			size := syscall.SizeofInotifyEvent + int(binary.NativeEndian.Uint32(data[12:16]))
+			if size > len(data) {
+				break
+			}
+			name := bytes.TrimRight(data[syscall.SizeofInotifyEvent:size], "\x00")
+			switch {
+			case mask&syscall.IN_Q_OVERFLOW != 0:
+				events |= releaseMask // Assume the worst
+			case string(name) == w.name:
+				events |= mask & watchMask
+			}
+			data = data[size:]
+		}
+
+		if events == 0 {
+			continue
+		}
+		w.events |= events
+		if events&releaseMask == 0 {
+			if time.Since(w.lastClose) < closeWakeInterval {
+				continue
+			}
+			w.lastClose = time.Now()
+		}
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,56 @@
+//go:build linux
+
+package lockfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcherWakesOnRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watched.lock")
+
+	w := newConfig(nil).watch(path)
+	if w == nil {
+		t.Skip("inotify is not available")
+	}
+	defer w.close()
+
+	// Closing the lock file after writing it is what our own attempts do,
+	// so draining must discard it.
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	w.drain()
+	select {
+	case <-w.woken():
+		t.Fatal("the watcher woke for an event that was drained")
+	default:
+	}
+
+	// Other files in the directory are ignored.
+	if err := os.WriteFile(path+".other", nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path + ".other"); err != nil {
+		t.Fatal(err)
+	}
+	w.drain()
+	select {
+	case <-w.woken():
+		t.Fatal("the watcher woke for the deletion of another file")
+	default:
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	w.drain() // A release is never discarded
+	select {
+	case <-w.woken():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the watcher did not wake when the lock file was deleted")
+	}
+}
@@ -0,0 +1,155 @@
+//go:build windows
+
+package lockfile
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// watcher watches the directory of a lock file with ReadDirectoryChangesW,
+// so that a waiter can try again as soon as the lock file is released
+// instead of waiting for its backoff to pass.
+//
+// A lock file is deleted when its holder closes it, even if the holder
+// crashes, which is reported as FILE_ACTION_REMOVED. A waiter's own
+// attempts never create the lock file unless they succeed, so they do not
+// cause events that need to be discarded.
+//
+// Some network shares do not support change notifications, in which case
+// no watcher is created, or it stops waking the waiter once the first
+// request fails. The waiter falls back to polling in either case.
+//
+// A nil watcher is valid, and never wakes.
+type watcher struct {
+	name string // The base name of the lock file
+	dir  syscall.Handle
+	wake chan struct{} // Holds a token when the lock file has been released
+	done chan struct{} // Closed when the watcher is closed
+}
+
+// fileListDirectory is the access right that is required to watch a
+// directory for changes.
+const fileListDirectory = 0x0001
+
+// watchPoll is the longest time in milliseconds that the watcher waits for
+// changes before it checks whether it has been closed.
+const watchPoll = 250
+
+// watch returns a watcher for the lock file with the given path. If the
+// directory of the lock file cannot be watched, it returns nil.
+func (c *config) watch(path string) *watcher {
+	path, err := c.mapPath(path)
+	if err != nil {
+		return nil
+	}
+
+	dir, err := createFile(filepath.Dir(path), fileListDirectory,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS|syscall.FILE_FLAG_OVERLAPPED)
+	if err != nil {
+		return nil
+	}
+
+	w := &watcher{
+		name: filepath.Base(path),
+		dir:  dir,
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// woken returns a channel that receives a value when the lock file may
+// have been released.
+func (w *watcher) woken() <-chan struct{} {
+	if w == nil {
+		return nil
+	}
+	return w.wake
+}
+
+// drain does nothing, because a waiter's own attempts do not cause events.
+func (w *watcher) drain() {}
+
+// close stops watching the lock file. The watcher releases its resources
+// once it notices.
+func (w *watcher) close() {
+	if w != nil {
+		close(w.done)
+	}
+}
+
+// run requests change notifications for the directory and reads them as
+// they arrive, until the watcher is closed or a request fails.
+//
+// Requests are overlapped without an event, so the directory handle is
+// signaled when one completes, which allows the watcher to check whether
+// it has been closed while it waits.
+func (w *watcher) run() {
+	defer syscall.CloseHandle(w.dir)
+
+	var (
+		buf [4096]byte
+		ov  syscall.Overlapped
+	)
+	for {
+		ov = syscall.Overlapped{}
+		err := syscall.ReadDirectoryChanges(w.dir, &buf[0], uint32(len(buf)), false, syscall.FILE_NOTIFY_CHANGE_FILE_NAME, nil, &ov, 0)
+		if err != nil && err != syscall.ERROR_IO_PENDING {
+			return
+		}
+
+		for pending := true; pending; {
+			event, err := syscall.WaitForSingleObject(w.dir, watchPoll)
+			if err != nil {
+				return
+			}
+			select {
+			case <-w.done:
+				// The buffer must outlive the request.
+				syscall.CancelIoEx(w.dir, &ov)
+				getOverlappedResult(w.dir, &ov, true)
+				return
+			default:
+			}
+			pending = event == syscall.WAIT_TIMEOUT
+		}
+
+		n, err := getOverlappedResult(w.dir, &ov, false)
+		if err != nil {
+			return
+		}
+		if n == 0 || w.released(buf[:n]) {
+			// No changes were recorded if there were too many to fit in
+			// the buffer, so assume the worst.
+			select {
+			case w.wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// released returns true if the notifications in buf record the removal of
+// the lock file.
+func (w *watcher) released(buf []byte) bool {
+	for offset := 0; offset+int(unsafe.Sizeof(syscall.FileNotifyInformation{})) <= len(buf); {
+		info := (*syscall.FileNotifyInformation)(unsafe.Pointer(&buf[offset]))
+		name := syscall.UTF16ToString(unsafe.Slice(&info.FileName, info.FileNameLength/2))
+		switch info.Action {
+		case syscall.FILE_ACTION_REMOVED, syscall.FILE_ACTION_RENAMED_OLD_NAME:
+			if strings.EqualFold(name, w.name) {
+				return true
+			}
+		}
+		if info.NextEntryOffset == 0 {
+			break
+		}
+		offset += int(info.NextEntryOffset)
+	}
+	return false
+}
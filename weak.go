@@ -0,0 +1,75 @@
+package lockfile
+
+import "context"
+
+// WeakRef is a reference to a held lock that does not keep the lock alive.
+//
+// Weak references are useful for cache layers that want to piggyback on a
+// lock if it happens to be held, without preventing its holders from
+// releasing it. When the last [File] referencing the lock is closed, the
+// channel returned by [WeakRef.Done] is closed, and the weak reference can
+// no longer be upgraded. Its holder may then lazily reacquire the lock with
+// [WeakRef.Reacquire].
+//
+// A WeakRef is safe for concurrent use.
+type WeakRef struct {
+	h *lockHandle
+}
+
+// Weak returns a weak reference to the lock held by f.
+//
+// The weak reference remains valid after f is closed, but it can only be
+// upgraded while some [File] still references the lock.
+func (f *File) Weak() *WeakRef {
+	f.h.mutex.Lock()
+	defer f.h.mutex.Unlock()
+
+	if f.h.released == nil {
+		f.h.released = make(chan struct{})
+		if f.h.refs == 0 {
+			close(f.h.released)
+		}
+	}
+
+	return &WeakRef{h: f.h}
+}
+
+// Path returns the path of the lock file.
+func (w *WeakRef) Path() string {
+	return w.h.path
+}
+
+// Done returns a channel that is closed when the last strong reference to
+// the lock has been closed and the lock has been released.
+func (w *WeakRef) Done() <-chan struct{} {
+	return w.h.released
+}
+
+// Get returns a new strong reference to the lock if it is still held. The
+// caller is responsible for closing the returned [File].
+//
+// It returns false if the lock has already been released.
+func (w *WeakRef) Get() (*File, bool) {
+	w.h.mutex.Lock()
+	defer w.h.mutex.Unlock()
+
+	if w.h.refs == 0 {
+		return nil, false
+	}
+
+	w.h.refs++
+	return &File{h: w.h}, true
+}
+
+// Reacquire returns a new strong reference to the lock if it is still
+// held. Otherwise it waits for the lock file to be acquired again, with the
+// same options that were used to create the original lock, until it
+// succeeds, a non-temporary error is encountered or ctx is cancelled.
+//
+// The caller is responsible for closing the returned [File].
+func (w *WeakRef) Reacquire(ctx context.Context) (*File, error) {
+	if file, ok := w.Get(); ok {
+		return file, nil
+	}
+	return w.h.cfg.wait(ctx, w.h.path)
+}
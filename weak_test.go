@@ -0,0 +1,49 @@
+package lockfile_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/lockfile"
+)
+
+func TestWeakRef(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weak.lock")
+
+	lock, err := lockfile.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create lock file: %v", err)
+	}
+
+	weak := lock.Weak()
+
+	strong, ok := weak.Get()
+	if !ok {
+		t.Fatalf("weak reference could not be upgraded while the lock was held")
+	}
+
+	lock.Close()
+	select {
+	case <-weak.Done():
+		t.Fatalf("weak reference reported release while a strong reference remained")
+	default:
+	}
+
+	strong.Close()
+	select {
+	case <-weak.Done():
+	default:
+		t.Fatalf("weak reference did not report release")
+	}
+
+	if _, ok := weak.Get(); ok {
+		t.Fatalf("weak reference was upgraded after the lock was released")
+	}
+
+	again, err := weak.Reacquire(context.Background())
+	if err != nil {
+		t.Fatalf("failed to reacquire lock: %v", err)
+	}
+	again.Close()
+}